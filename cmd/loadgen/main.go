@@ -0,0 +1,106 @@
+// Команда loadgen генерирует заказы и публикует их для нагрузочного тестирования.
+//
+//	go run ./cmd/loadgen -count 10000 -rate 500 -api http://localhost:8080
+//
+// Параметры подключения к NATS берутся из той же конфигурации, что и у сервиса.
+// С -dry-run заказы выводятся в stdout в формате NDJSON и могут быть опубликованы
+// командой publisher.
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "os/signal"
+    "syscall"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/broker"
+    "wb-order-hub/internal/config"
+    "wb-order-hub/internal/generator"
+    "wb-order-hub/internal/loadtest"
+    "wb-order-hub/internal/publisher"
+)
+
+func main() {
+    if err := run(os.Args[1:]); err != nil {
+        log.Fatal(err)
+    }
+}
+
+func run(args []string) error {
+    fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+    clientID := fs.String("client-id", "order-hub-loadgen", "ID клиента NATS Streaming")
+    seed := fs.Uint64("seed", 1, "seed генератора: одинаковый seed даёт одинаковые заказы")
+    count := fs.Int("count", 1000, "сколько сообщений опубликовать")
+    rateLimit := fs.Float64("rate", 0, "сообщений в секунду (0 - без ограничения)")
+    concurrency := fs.Int("concurrency", 0, "сколько заказов одновременно ждут появления в API (0 - без ограничения)")
+    invalid := fs.Float64("invalid", 0, "доля некорректных сообщений, от 0 до 1")
+    duplicate := fs.Float64("duplicate", 0, "доля повторно опубликованных заказов, от 0 до 1")
+    apiURL := fs.String("api", "http://localhost:8080", "адрес сервиса для измерения сквозной задержки (пусто - не измерять)")
+    pollInterval := fs.Duration("poll-interval", 0, "период опроса GET /order/{id} (по умолчанию 20ms)")
+    timeout := fs.Duration("timeout", 0, "сколько ждать появления заказа в API (по умолчанию 30s)")
+    maxInflight := fs.Int("max-inflight", stan.DefaultMaxPubAcksInflight, "максимум неподтверждённых сообщений")
+    dryRun := fs.Bool("dry-run", false, "вывести сообщения в stdout в формате NDJSON вместо публикации")
+
+    cfg, err := config.LoadFlags(fs, args)
+    if err != nil {
+        return err
+    }
+    if *invalid < 0 || *duplicate < 0 || *invalid+*duplicate > 1 {
+        return errors.New("-invalid и -duplicate должны быть неотрицательными и в сумме не больше 1")
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    var conn publisher.Conn = ndjsonConn{w: os.Stdout}
+    if *dryRun {
+        *apiURL = ""
+    } else {
+        sc, err := broker.Connect(cfg.NATS, *clientID, cfg.SecretStore(), stan.MaxPubAcksInflight(*maxInflight))
+        if err != nil {
+            return err
+        }
+        defer sc.Close()
+        conn = sc
+        log.Printf("Публикация %d сообщений в канал '%s' (%s)...", *count, cfg.NATS.Channel, cfg.NATS.URL)
+    }
+
+    report, err := loadtest.Run(ctx, generator.New(generator.Options{Seed: *seed}), publisher.New(conn, cfg.NATS.Channel), loadtest.Options{
+        Count:             *count,
+        Rate:              *rateLimit,
+        Concurrency:       *concurrency,
+        InvalidFraction:   *invalid,
+        DuplicateFraction: *duplicate,
+        Seed:              *seed,
+        APIURL:            *apiURL,
+        PollInterval:      *pollInterval,
+        Timeout:           *timeout,
+    })
+    report.Write(os.Stderr)
+    if err != nil {
+        return err
+    }
+    if report.Publish.Failed > 0 || report.Missing > 0 {
+        return fmt.Errorf("не опубликовано: %d, не появились в API: %d", report.Publish.Failed, report.Missing)
+    }
+    return nil
+}
+
+// ndjsonConn выводит сообщения по одному в строке и сразу подтверждает их.
+type ndjsonConn struct {
+    w io.Writer
+}
+
+func (c ndjsonConn) PublishAsync(_ string, data []byte, ah stan.AckHandler) (string, error) {
+    if _, err := fmt.Fprintf(c.w, "%s\n", data); err != nil {
+        return "", err
+    }
+    ah("", nil)
+    return "", nil
+}
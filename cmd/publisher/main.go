@@ -0,0 +1,170 @@
+// Команда publisher публикует заказы в канал NATS Streaming.
+//
+//	go run ./cmd/publisher [флаги] вход...
+//
+// Вход - файл, каталог (берутся файлы .json, .ndjson и .jsonl), шаблон вида 'orders/*.json'
+// или "-" для стандартного ввода. Параметры подключения берутся из той же конфигурации,
+// что и у сервиса: -nats.url, -nats.channel, NATS_USER и т.д.
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/broker"
+    "wb-order-hub/internal/codec"
+    "wb-order-hub/internal/config"
+    "wb-order-hub/internal/envelope"
+    "wb-order-hub/internal/input"
+    "wb-order-hub/internal/publisher"
+)
+
+func main() {
+    if err := run(os.Args[1:]); err != nil {
+        log.Fatal(err)
+    }
+}
+
+func run(args []string) error {
+    fs := flag.NewFlagSet("publisher", flag.ContinueOnError)
+    fs.Usage = func() {
+        fmt.Fprintf(fs.Output(), "Использование: publisher [флаги] файл|каталог|шаблон|- ...\n")
+        fs.PrintDefaults()
+    }
+    clientID := fs.String("client-id", "order-hub-publisher", "ID клиента NATS Streaming")
+    formatName := fs.String("format", string(input.FormatAuto), "формат входа: auto, json или ndjson")
+    repeat := fs.Int("repeat", 1, "сколько раз опубликовать каждое сообщение")
+    dryRun := fs.Bool("dry-run", false, "вывести сообщения в stdout вместо публикации")
+    maxInflight := fs.Int("max-inflight", stan.DefaultMaxPubAcksInflight, "максимум неподтверждённых сообщений")
+    ackTimeout := fs.Duration("ack-timeout", stan.DefaultAckWait, "время ожидания подтверждения")
+    producer := fs.String("envelope", "", "заворачивать заказы в конверт с указанным producer")
+    encodingName := fs.String("encoding", string(codec.JSON), "кодировка заказов: json, protobuf или msgpack")
+    var overrides publisher.Overrides
+    fs.Func("uid", "шаблон order_uid, например 'load-{{.Index}}' или '{{.OriginalUID}}-{{.Rand 4}}'", overrides.SetUID)
+    fs.Func("set", "переопределение поля путь=шаблон, например 'delivery.city=Moscow' (можно повторять)", overrides.Set)
+
+    cfg, err := config.ParseFlags(fs, args)
+    if err != nil {
+        return err
+    }
+    format, err := input.ParseFormat(*formatName)
+    if err != nil {
+        return err
+    }
+    encoding, err := codec.ParseFormat(*encodingName)
+    if err != nil {
+        return err
+    }
+    if fs.NArg() == 0 {
+        fs.Usage()
+        return errors.New("не задан ни один вход")
+    }
+    inputs, err := input.Expand(fs.Args())
+    if err != nil {
+        return err
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    var conn publisher.Conn = dryRunConn{w: os.Stdout}
+    if !*dryRun {
+        sc, err := broker.Connect(cfg.NATS, *clientID, cfg.SecretStore(),
+            stan.MaxPubAcksInflight(*maxInflight), stan.PubAckWait(*ackTimeout))
+        if err != nil {
+            return err
+        }
+        defer sc.Close()
+        conn = sc
+        log.Printf("Публикация в канал '%s' (%s)...", cfg.NATS.Channel, cfg.NATS.URL)
+    }
+
+    pub := publisher.New(conn, cfg.NATS.Channel)
+    index := 0
+    publish := func(msg input.Message) error {
+        for i := 0; i < *repeat; i++ {
+            if err := ctx.Err(); err != nil {
+                return err
+            }
+            index++
+            data, err := overrides.Apply(msg, index)
+            if err != nil {
+                pub.Fail(msg.Source, err)
+                continue
+            }
+            if data, err = encode(data, encoding, *producer); err != nil {
+                pub.Fail(msg.Source, err)
+                continue
+            }
+            pub.Publish(msg.Source, data)
+        }
+        return nil
+    }
+
+    var readErr error
+    for _, name := range inputs {
+        if readErr = input.ReadInput(name, os.Stdin, format, publish); readErr != nil {
+            break
+        }
+    }
+
+    // После прерывания подтверждения ещё можно дождаться, но не дольше ack-timeout.
+    waitCtx, cancel := context.WithTimeout(context.Background(), *ackTimeout+time.Second)
+    defer cancel()
+    waitErr := pub.Wait(waitCtx)
+
+    summary := pub.Summary()
+    summary.Write(os.Stderr)
+
+    switch {
+    case errors.Is(readErr, context.Canceled):
+        return errors.New("публикация прервана")
+    case readErr != nil:
+        return readErr
+    case waitErr != nil:
+        return waitErr
+    case summary.Failed > 0:
+        return fmt.Errorf("не опубликовано сообщений: %d", summary.Failed)
+    }
+    return nil
+}
+
+// encode перекодирует заказ из JSON в encoding и заворачивает в конверт, если задан producer.
+// Без конверта бинарный заказ получает префикс кодировки.
+func encode(data []byte, encoding codec.Format, producer string) ([]byte, error) {
+    if encoding != codec.JSON {
+        order, err := codec.UnmarshalOrder(codec.JSON, data)
+        if err != nil {
+            return nil, fmt.Errorf("ошибка десериализации: %v", err)
+        }
+        if data, err = codec.MarshalOrder(encoding, order); err != nil {
+            return nil, err
+        }
+    }
+    if producer != "" {
+        return envelope.Wrap(envelope.TypeOrder, producer, encoding, data)
+    }
+    return codec.Frame(encoding, data), nil
+}
+
+// dryRunConn выводит сообщения по одному в строке и сразу подтверждает их.
+type dryRunConn struct {
+    w io.Writer
+}
+
+func (c dryRunConn) PublishAsync(_ string, data []byte, ah stan.AckHandler) (string, error) {
+    if _, err := fmt.Fprintf(c.w, "%s\n", data); err != nil {
+        return "", err
+    }
+    ah("", nil)
+    return "", nil
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgxpool"
+    "wb-order-hub/internal/archive"
+    "wb-order-hub/internal/broker"
+    "wb-order-hub/internal/config"
+    "wb-order-hub/internal/database"
+    "wb-order-hub/internal/input"
+    "wb-order-hub/internal/replay"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/transfer"
+    "wb-order-hub/internal/verify"
+)
+
+// commandContext возвращает контекст, отменяемый по SIGINT/SIGTERM.
+func commandContext() (context.Context, context.CancelFunc) {
+    return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// openDatabase подключается к PostgreSQL; пароль берётся из secrets при каждом новом соединении.
+func openDatabase(ctx context.Context, cfg *config.Config, secrets *config.SecretStore) (*pgxpool.Pool, error) {
+    dbConfig := database.ConfigFrom(cfg.Database)
+    dbConfig.PasswordFunc = secrets.Func("database.password")
+    pool, err := database.NewPool(ctx, dbConfig)
+    if err != nil {
+        return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
+    }
+    return pool, nil
+}
+
+// openArchive возвращает архив исходных сообщений в базе или nil, если архив отключён.
+func openArchive(cfg *config.Config, pool *pgxpool.Pool) archive.Store {
+    if !cfg.Archive.Enabled {
+        return nil
+    }
+    return database.NewArchive(pool, archive.Compression(cfg.Archive.Compression))
+}
+
+// parseDate разбирает дату в формате 2006-01-02 (начало дня UTC) или RFC3339.
+func parseDate(flagName, value string) (time.Time, error) {
+    if value == "" {
+        return time.Time{}, nil
+    }
+    if t, err := time.Parse(time.DateOnly, value); err == nil {
+        return t, nil
+    }
+    t, err := time.Parse(time.RFC3339, value)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("-%s: ожидается дата 2006-01-02 или RFC3339, получено %q", flagName, value)
+    }
+    return t, nil
+}
+
+// configCommand обрабатывает "config print [-format yaml|json] [флаги конфигурации]".
+func configCommand(args []string) error {
+    if len(args) == 0 || args[0] != "print" {
+        return fmt.Errorf("использование: %s config print [-format yaml|json] [флаги конфигурации]", os.Args[0])
+    }
+
+    fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+    format := fs.String("format", "yaml", "формат вывода: yaml или json")
+    cfg, err := config.LoadFlags(fs, args[1:])
+    if err != nil {
+        return err
+    }
+    return cfg.Print(os.Stdout, *format)
+}
+
+// migrateCommand обрабатывает "migrate [-status]".
+func migrateCommand(args []string) error {
+    fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+    status := fs.Bool("status", false, "только показать применённые и ожидающие миграции")
+    cfg, err := config.LoadFlags(fs, args)
+    if err != nil {
+        return err
+    }
+
+    ctx, stop := commandContext()
+    defer stop()
+    pool, err := openDatabase(ctx, cfg, cfg.SecretStore())
+    if err != nil {
+        return err
+    }
+    defer pool.Close()
+
+    if *status {
+        migrations, err := database.Migrations()
+        if err != nil {
+            return err
+        }
+        applied, err := database.AppliedMigrations(ctx, pool)
+        if err != nil {
+            return err
+        }
+        for _, m := range migrations {
+            state := "ожидает"
+            if applied[m.Version] {
+                state = "применена"
+            }
+            fmt.Printf("%s_%s\t%s\n", m.Version, m.Name, state)
+        }
+        return nil
+    }
+
+    done, err := database.Migrate(ctx, pool)
+    for _, m := range done {
+        log.Printf("Применена миграция %s_%s", m.Version, m.Name)
+    }
+    if err != nil {
+        return err
+    }
+    if len(done) == 0 {
+        log.Println("Схема базы данных актуальна")
+    }
+    return nil
+}
+
+// importCommand обрабатывает "import [-format auto|json|ndjson] [-dry-run] файл|каталог|шаблон|- ...".
+func importCommand(args []string) error {
+    fs := flag.NewFlagSet("import", flag.ContinueOnError)
+    formatName := fs.String("format", string(input.FormatAuto), "формат входа: auto, json или ndjson")
+    dryRun := fs.Bool("dry-run", false, "только проверить заказы, не сохраняя")
+    cfg, err := config.ParseFlags(fs, args)
+    if err != nil {
+        return err
+    }
+    format, err := input.ParseFormat(*formatName)
+    if err != nil {
+        return err
+    }
+    if fs.NArg() == 0 {
+        return errors.New("не задан ни один вход")
+    }
+    inputs, err := input.Expand(fs.Args())
+    if err != nil {
+        return err
+    }
+
+    ctx, stop := commandContext()
+    defer stop()
+
+    var repo repository.OrderRepository = repository.NewMemory()
+    if !*dryRun {
+        pool, err := openDatabase(ctx, cfg, cfg.SecretStore())
+        if err != nil {
+            return err
+        }
+        defer pool.Close()
+        repo = database.NewRepository(pool)
+    }
+
+    stats, err := transfer.Import(ctx, repo, inputs, transfer.ImportOptions{Format: format, Stdin: os.Stdin, DryRun: *dryRun})
+    log.Printf("Загружено заказов: %d, некорректных: %d, уже изменённых событиями: %d", stats.Imported, stats.Invalid, stats.Superseded)
+    if err != nil {
+        return err
+    }
+    if stats.Invalid > 0 {
+        return fmt.Errorf("пропущено некорректных заказов: %d", stats.Invalid)
+    }
+    return nil
+}
+
+// exportCommand обрабатывает "export [-format ndjson|csv] [-from дата] [-to дата] [-o файл]".
+func exportCommand(args []string) error {
+    fs := flag.NewFlagSet("export", flag.ContinueOnError)
+    format := fs.String("format", transfer.FormatNDJSON, "формат выгрузки: ndjson или csv")
+    output := fs.String("o", "", "файл выгрузки (по умолчанию stdout)")
+    filter := filterFlags(fs)
+    cfg, err := config.LoadFlags(fs, args)
+    if err != nil {
+        return err
+    }
+    listFilter, err := filter()
+    if err != nil {
+        return err
+    }
+
+    ctx, stop := commandContext()
+    defer stop()
+    pool, err := openDatabase(ctx, cfg, cfg.SecretStore())
+    if err != nil {
+        return err
+    }
+    defer pool.Close()
+
+    var w io.Writer = os.Stdout
+    if *output != "" {
+        f, err := os.Create(*output)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        w = f
+    }
+
+    count, err := transfer.Export(ctx, database.NewRepository(pool), w, listFilter, *format)
+    if err != nil {
+        return err
+    }
+    log.Printf("Выгружено заказов: %d", count)
+    return nil
+}
+
+// filterFlags объявляет флаги отбора заказов и возвращает функцию, собирающую фильтр после разбора.
+func filterFlags(fs *flag.FlagSet) func() (repository.ListFilter, error) {
+    from := fs.String("from", "", "дата создания с (включительно), 2006-01-02 или RFC3339")
+    to := fs.String("to", "", "дата создания по (не включая), 2006-01-02 или RFC3339")
+    customer := fs.String("customer", "", "только заказы покупателя")
+    service := fs.String("delivery-service", "", "только заказы службы доставки")
+    locale := fs.String("locale", "", "только заказы с локалью")
+    return func() (repository.ListFilter, error) {
+        createdFrom, err := parseDate("from", *from)
+        if err != nil {
+            return repository.ListFilter{}, err
+        }
+        createdTo, err := parseDate("to", *to)
+        if err != nil {
+            return repository.ListFilter{}, err
+        }
+        return repository.ListFilter{
+            CustomerID:      *customer,
+            DeliveryService: *service,
+            Locale:          *locale,
+            CreatedFrom:     createdFrom,
+            CreatedTo:       createdTo,
+        }, nil
+    }
+}
+
+// verifyCommand обрабатывает "verify [-from дата] [-to дата] [-json]".
+func verifyCommand(args []string) error {
+    fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+    asJSON := fs.Bool("json", false, "вывести отчёт в JSON")
+    sample := fs.Float64("sample", 0, "доля проверяемых заказов от 0 до 1, 0 - все")
+    repair := fs.Bool("repair", false, "исправить расхождения: удалить строки без заказа, переписать заказы из исходных сообщений")
+    filter := filterFlags(fs)
+    cfg, err := config.LoadFlags(fs, args)
+    if err != nil {
+        return err
+    }
+    listFilter, err := filter()
+    if err != nil {
+        return err
+    }
+    if *sample < 0 || *sample > 1 {
+        return errors.New("-sample: ожидается число от 0 до 1")
+    }
+
+    ctx, stop := commandContext()
+    defer stop()
+    pool, err := openDatabase(ctx, cfg, cfg.SecretStore())
+    if err != nil {
+        return err
+    }
+    defer pool.Close()
+
+    opts := verify.ConsistencyOptions{
+        Filter: listFilter,
+        Sample: *sample,
+        Checks: verify.OrderChecks,
+        Repair: *repair,
+    }
+    if rawMessages := openArchive(cfg, pool); rawMessages != nil {
+        opts.Source = archive.Source{Store: rawMessages}
+    }
+    report, err := verify.Consistency(ctx, database.NewRepository(pool), opts)
+    if err != nil {
+        return err
+    }
+    if *asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(report); err != nil {
+            return err
+        }
+    } else {
+        for _, p := range report.Problems {
+            fmt.Println(p)
+        }
+    }
+    log.Printf("Проверено заказов: %d, нарушений: %d, исправлено: %d", report.Checked, len(report.Problems), report.Repaired)
+    if n := report.Unrepaired(); n > 0 {
+        return fmt.Errorf("найдено неисправленных нарушений: %d", n)
+    }
+    return nil
+}
+
+// rollupsCommand обрабатывает "rollups [-check]": пересчитывает дневные итоги аналитики
+// по базовым таблицам, печатает расхождения с сохранёнными и заменяет их пересчётом.
+func rollupsCommand(args []string) error {
+    fs := flag.NewFlagSet("rollups", flag.ContinueOnError)
+    check := fs.Bool("check", false, "только сверить дневные итоги с пересчётом, не заменяя")
+    cfg, err := config.LoadFlags(fs, args)
+    if err != nil {
+        return err
+    }
+
+    ctx, stop := commandContext()
+    defer stop()
+    pool, err := openDatabase(ctx, cfg, cfg.SecretStore())
+    if err != nil {
+        return err
+    }
+    defer pool.Close()
+
+    report, err := database.NewRepository(pool).RebuildRollups(ctx, !*check)
+    if err != nil {
+        return err
+    }
+    for _, d := range report.Diffs {
+        fmt.Println(d)
+    }
+    log.Printf("Расхождений дневных итогов: %d, пересчитаны: %t", len(report.Diffs), report.Rebuilt)
+    if *check && len(report.Diffs) > 0 {
+        return fmt.Errorf("дневные итоги расходятся с пересчётом: %d строк", len(report.Diffs))
+    }
+    return nil
+}
+
+// replayCommand обрабатывает "replay [-from-seq N | -from-time T] [-migrate]".
+func replayCommand(args []string) error {
+    fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+    fromSeq := fs.Uint64("from-seq", 0, "номер сообщения, с которого начать")
+    fromTime := fs.String("from-time", "", "время, с которого начать, 2006-01-02 или RFC3339")
+    migrate := fs.Bool("migrate", false, "применить миграции перед повтором (для новой базы)")
+    clientID := fs.String("client-id", "order-hub-replay", "ID клиента NATS Streaming")
+    conflict := fs.String("conflict", string(replay.PolicyOverwrite), "заказы, уже сохранённые в базе: overwrite или skip")
+    progressEvery := fs.Duration("progress", 5*time.Second, "как часто печатать прогресс, 0 - не печатать")
+    cfg, err := config.LoadFlags(fs, args)
+    if err != nil {
+        return err
+    }
+    policy, err := replay.ParsePolicy(*conflict)
+    if err != nil {
+        return err
+    }
+    startTime, err := parseDate("from-time", *fromTime)
+    if err != nil {
+        return err
+    }
+    if *fromSeq > 0 && !startTime.IsZero() {
+        return errors.New("-from-seq и -from-time нельзя задавать одновременно")
+    }
+
+    ctx, stop := commandContext()
+    defer stop()
+    secrets := cfg.SecretStore()
+    pool, err := openDatabase(ctx, cfg, secrets)
+    if err != nil {
+        return err
+    }
+    defer pool.Close()
+    if *migrate {
+        if _, err := database.Migrate(ctx, pool); err != nil {
+            return err
+        }
+    }
+
+    sc, err := broker.Connect(cfg.NATS, *clientID, secrets)
+    if err != nil {
+        return err
+    }
+    defer sc.Close()
+
+    opts := replay.Options{
+        Channel:          cfg.NATS.Channel,
+        StartSequence:    *fromSeq,
+        StartTime:        startTime,
+        Conflict:         policy,
+        ProgressInterval: *progressEvery,
+    }
+    if *progressEvery > 0 {
+        opts.Progress = func(p replay.Progress) { log.Printf("Повтор: %s", p) }
+    }
+    stats, err := replay.Run(ctx, sc, database.NewRepository(pool), opts)
+    log.Printf("Сохранено заказов: %d, пропущено существующих: %d, пропущено некорректных: %d, последнее сообщение канала: %d",
+        stats.Saved, stats.Skipped, stats.Invalid, stats.Last.Sequence)
+    return err
+}
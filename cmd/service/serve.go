@@ -0,0 +1,248 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "io/fs"
+    "log"
+    "net/url"
+    "os"
+    "os/signal"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgxpool"
+    "wb-order-hub/internal/app"
+    "wb-order-hub/internal/archive"
+    "wb-order-hub/internal/broker"
+    "wb-order-hub/internal/cache"
+    "wb-order-hub/internal/config"
+    "wb-order-hub/internal/database"
+    "wb-order-hub/internal/events"
+    "wb-order-hub/internal/logging"
+    "wb-order-hub/internal/rates"
+    "wb-order-hub/internal/reload"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/stanserver"
+    "wb-order-hub/web"
+)
+
+// archivePruneInterval - как часто удалять исходные сообщения старше archive.retention.
+const archivePruneInterval = time.Hour
+
+// loadConfig собирает конфигурацию сервиса и разбирает его собственные флаги.
+// Вызывается повторно при перезагрузке конфигурации с теми же аргументами.
+func loadConfig(args []string) (*config.Config, bool, error) {
+    fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+    dev := fs.Bool("dev", false, "режим разработки: встроенный NATS Streaming и хранение заказов в памяти, без Docker")
+    cfg, err := config.LoadFlags(fs, args)
+    return cfg, *dev, err
+}
+
+// serve запускает сервис: подписку на NATS Streaming и HTTP API.
+func serve(args []string) error {
+    cfg, dev, err := loadConfig(args)
+    if err != nil {
+        return err
+    }
+
+    if err := logging.Setup(cfg.Log); err != nil {
+        return err
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    secrets := cfg.SecretStore()
+    go secrets.Watch(ctx, cfg.Secrets.RefreshInterval.Std())
+
+    var repo repository.OrderRepository
+    var rawMessages archive.Store
+    // parked остаётся nil в режиме разработки: app.New держит отложенные события в памяти.
+    var parked events.ParkingLot
+    var rateStore rates.Store
+    if dev {
+        server, err := startDevBroker(cfg.NATS)
+        if err != nil {
+            return err
+        }
+        defer func() {
+            server.Shutdown()
+            log.Println("Остановка: встроенный NATS Streaming остановлен")
+        }()
+        repo = repository.NewMemory()
+        rateStore = rates.NewMemory()
+        if cfg.Archive.Enabled {
+            rawMessages = archive.NewMemory()
+        }
+        log.Printf("Режим разработки: встроенный NATS Streaming на %s (кластер %s), заказы хранятся в памяти",
+            server.URL(), server.ClusterID())
+    } else {
+        pool, err := openDatabase(ctx, cfg, secrets)
+        if err != nil {
+            return err
+        }
+        defer func() {
+            pool.Close()
+            log.Println("Остановка: соединения с базой данных закрыты")
+        }()
+        if err := checkMigrations(ctx, pool); err != nil {
+            return err
+        }
+        db := database.NewRepository(pool)
+        if interval := cfg.Database.DocumentCheckInterval.Std(); interval > 0 {
+            go db.WatchDocuments(ctx, interval)
+        }
+        repo = db
+        rawMessages = openArchive(cfg, pool)
+        parked = database.NewParkingLot(pool)
+        rateStore = database.NewExchangeRates(pool)
+    }
+    if rawMessages != nil && cfg.Archive.Retention > 0 {
+        go archive.Prune(ctx, rawMessages, cfg.Archive.Retention.Std(), archivePruneInterval)
+    }
+
+    book, err := openRates(ctx, cfg.Rates, rateStore)
+    if err != nil {
+        return err
+    }
+
+    sc, err := broker.Connect(cfg.NATS, cfg.NATS.ClientID, secrets)
+    if err != nil {
+        return err
+    }
+    // Закрывается до пула БД: к этому моменту подписка уже закрыта и обработчики завершены.
+    defer func() {
+        if err := sc.Close(); err != nil {
+            log.Printf("Остановка: ошибка при закрытии соединения с NATS Streaming: %v", err)
+            return
+        }
+        log.Println("Остановка: соединение с NATS Streaming закрыто")
+    }()
+
+    webFiles, err := webAssets(cfg.HTTP.WebDir)
+    if err != nil {
+        return err
+    }
+
+    orderCache := cache.New(cfg.Cache.Capacity)
+    reloader := reload.New(cfg, func() (*config.Config, error) {
+        cfg, _, err := loadConfig(args)
+        return cfg, err
+    })
+
+    service := app.New(app.Options{
+        Addr:            ":" + strconv.Itoa(cfg.HTTP.Port),
+        ReadTimeout:     cfg.HTTP.ReadTimeout.Std(),
+        WriteTimeout:    cfg.HTTP.WriteTimeout.Std(),
+        MaxHeaderBytes:  int(cfg.HTTP.MaxHeaderSize),
+        ShutdownTimeout: cfg.HTTP.ShutdownTimeout.Std(),
+        Channel:         cfg.NATS.Channel,
+        DurableName:     cfg.NATS.DurableName,
+        AckWait:         cfg.NATS.AckWait.Std(),
+        APIKeys: func() []string {
+            return secrets.List("security.api_keys")
+        },
+        OpenWithoutAPIKeys: dev,
+        Web:                webFiles,
+        RateLimit:          cfg.HTTP.RateLimit.RPS,
+        RateBurst:          cfg.HTTP.RateLimit.Burst,
+        Reload:             reloader.ReloadAndLog,
+        Archive:            rawMessages,
+
+        StatusChannel: cfg.NATS.StatusChannel,
+        EventsPrefix:  cfg.NATS.EventsPrefix,
+        Parked:        parked,
+
+        Rates:             book,
+        ReportingCurrency: cfg.Rates.Currency,
+    }, repo, orderCache, sc)
+
+    reloader.Handle(func(cfg *config.Config) {
+        orderCache.Resize(cfg.Cache.Capacity)
+    }, "cache.capacity")
+    reloader.Handle(func(cfg *config.Config) {
+        logging.SetLevel(cfg.Log.Level)
+    }, "log.level")
+    reloader.Handle(func(cfg *config.Config) {
+        service.SetRateLimit(cfg.HTTP.RateLimit.RPS, cfg.HTTP.RateLimit.Burst)
+    }, "http.rate_limit")
+    reloader.Handle(func(cfg *config.Config) {
+        secrets.Update(cfg)
+    }, "security.api_keys", "database.password", "nats.password", "nats.token", "nats.nkey_seed")
+
+    hup := make(chan os.Signal, 1)
+    signal.Notify(hup, syscall.SIGHUP)
+    defer signal.Stop(hup)
+    go reloader.Watch(ctx, hup)
+
+    return service.Run(ctx)
+}
+
+// openRates загружает курсы валют из хранилища и, если задан rates.file, сохраняет в него курсы
+// из файла. Ошибка в файле останавливает запуск: иначе суммы пересчитывались бы по старым курсам.
+func openRates(ctx context.Context, cfg config.RatesConfig, store rates.Store) (*rates.Book, error) {
+    book := rates.NewBook(store)
+    if cfg.File != "" {
+        list, err := rates.ParseFile(cfg.File)
+        if err != nil {
+            return nil, fmt.Errorf("rates.file: %w", err)
+        }
+        if err := book.Add(ctx, list); err != nil {
+            return nil, fmt.Errorf("rates.file: %w", err)
+        }
+        log.Printf("Загружено курсов валют из %s: %d", cfg.File, len(list))
+    } else if err := book.Refresh(ctx); err != nil {
+        log.Printf("Предупреждение: не удалось загрузить курсы валют: %v", err)
+    }
+    if interval := cfg.RefreshInterval.Std(); interval > 0 {
+        go book.Watch(ctx, interval)
+    }
+    return book, nil
+}
+
+// webAssets возвращает статику из каталога dir или, если он не задан, встроенную в бинарник.
+func webAssets(dir string) (fs.FS, error) {
+    if dir == "" {
+        return web.Files, nil
+    }
+    if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+        return nil, fmt.Errorf("http.web_dir: каталог %s недоступен", dir)
+    }
+    log.Printf("Веб-интерфейс раздаётся из каталога %s", dir)
+    return os.DirFS(dir), nil
+}
+
+// startDevBroker запускает встроенный NATS Streaming на порту из nats.url, чтобы
+// publisher и loadgen подключались к нему с настройками по умолчанию.
+func startDevBroker(cfg config.NATSConfig) (*stanserver.Server, error) {
+    port := 4222
+    if u, err := url.Parse(cfg.URL); err == nil && u.Port() != "" {
+        port, _ = strconv.Atoi(u.Port())
+    }
+    server, err := stanserver.Start(stanserver.Options{ClusterID: cfg.ClusterID, Port: port})
+    if err != nil {
+        return nil, fmt.Errorf("%w (порт %d занят? задайте другой через -nats.url)", err, port)
+    }
+    return server, nil
+}
+
+// checkMigrations не даёт запустить сервис на схеме, к которой применены не все встроенные миграции:
+// запросы сервиса рассчитаны на последнюю схему.
+func checkMigrations(ctx context.Context, pool *pgxpool.Pool) error {
+    pending, err := database.PendingMigrations(ctx, pool)
+    if err != nil {
+        return err
+    }
+    if len(pending) == 0 {
+        return nil
+    }
+    names := make([]string, len(pending))
+    for i, m := range pending {
+        names[i] = m.Version + "_" + m.Name
+    }
+    return fmt.Errorf("не применены миграции %s: выполните `%s migrate`", strings.Join(names, ", "), os.Args[0])
+}
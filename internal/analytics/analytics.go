@@ -0,0 +1,307 @@
+// Package analytics строит отчёты по заказам: выручку по периодам, топ брендов и товаров
+// и разрезы по службам доставки, банкам, провайдерам, регионам и городам. Итоги за день
+// считает хранилище, а пакет собирает из них периоды, пересчитывает суммы в валюту отчёта
+// и упорядочивает строки.
+package analytics
+
+import (
+    "cmp"
+    "context"
+    "fmt"
+    "math"
+    "slices"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/rates"
+    "wb-order-hub/internal/repository"
+)
+
+// Period - шаг ряда выручки.
+type Period string
+
+const (
+    Day   Period = "day"
+    Week  Period = "week"
+    Month Period = "month"
+)
+
+// ParsePeriod разбирает шаг ряда. Пустая строка - Day.
+func ParsePeriod(s string) (Period, error) {
+    switch p := Period(s); p {
+    case "":
+        return Day, nil
+    case Day, Week, Month:
+        return p, nil
+    }
+    return "", fmt.Errorf("неизвестный период %q, ожидается day, week или month", s)
+}
+
+// Start возвращает начало периода, в который попадает день day: сам день, понедельник
+// недели или первое число месяца.
+func (p Period) Start(day time.Time) time.Time {
+    day = repository.Day(day)
+    switch p {
+    case Week:
+        return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+    case Month:
+        return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+    }
+    return day
+}
+
+// Metric - показатель, по которому строится топ.
+type Metric string
+
+const (
+    Revenue Metric = "revenue"
+    Units   Metric = "units"
+)
+
+// ParseMetric разбирает показатель топа. Пустая строка - Revenue.
+func ParseMetric(s string) (Metric, error) {
+    switch m := Metric(s); m {
+    case "":
+        return Revenue, nil
+    case Revenue, Units:
+        return m, nil
+    }
+    return "", fmt.Errorf("неизвестный показатель %q, ожидается revenue или units", s)
+}
+
+// Options - общие параметры отчётов.
+type Options struct {
+    Filter repository.AnalyticsFilter
+    // Currency - валюта отчёта: итоги каждого дня пересчитываются в неё по курсу на начало
+    // этого дня (UTC) и складываются. Пустая - итоги по валютам оплаты без пересчёта.
+    Currency string
+}
+
+// Service строит отчёты по итогам из хранилища.
+type Service struct {
+    repo repository.AnalyticsRepository
+    // rates возвращает действующие курсы; nil - курсов нет.
+    rates func() *rates.Table
+}
+
+// New создаёт сервис отчётов. table возвращает курсы для пересчёта; nil - пересчёт только
+// из валюты в неё же.
+func New(repo repository.AnalyticsRepository, table func() *rates.Table) *Service {
+    return &Service{repo: repo, rates: table}
+}
+
+// converter пересчитывает итоги дня в валюту отчёта.
+type converter struct {
+    table    *rates.Table
+    currency string
+}
+
+func (s *Service) converter(opts Options) converter {
+    c := converter{currency: opts.Currency}
+    if s.rates != nil {
+        c.table = s.rates()
+    }
+    return c
+}
+
+// convert возвращает сумму итогов дня day в валюте отчёта. Ошибка оборачивает rates.ErrNoRate.
+func (c converter) convert(amount models.Amount, currency string, day time.Time) (models.Money, error) {
+    m := models.Money{Amount: amount, Currency: currency}
+    if c.currency == "" {
+        return m, nil
+    }
+    return c.table.Convert(m, c.currency, day)
+}
+
+// RevenuePoint - выручка и число заказов за период в одной валюте.
+type RevenuePoint struct {
+    Start    time.Time `json:"start"`
+    Currency string    `json:"currency"`
+    Orders   int64     `json:"orders"`
+    // Revenue - сумма оплат в минимальных единицах валюты, Display - она же для людей.
+    Revenue int64  `json:"revenue"`
+    Display string `json:"display"`
+}
+
+// RevenueReport - ряд выручки.
+type RevenueReport struct {
+    Period   Period         `json:"period"`
+    Currency string         `json:"currency,omitempty"`
+    Series   []RevenuePoint `json:"series"`
+}
+
+// Revenue строит ряд выручки и числа заказов с шагом period по возрастанию начала периода и валюты.
+func (s *Service) Revenue(ctx context.Context, opts Options, period Period) (RevenueReport, error) {
+    totals, err := s.repo.OrderTotals(ctx, opts.Filter, "")
+    if err != nil {
+        return RevenueReport{}, err
+    }
+    conv := s.converter(opts)
+    type group struct {
+        start    time.Time
+        currency string
+    }
+    points := make(map[group]*RevenuePoint)
+    for _, t := range totals {
+        m, err := conv.convert(t.Revenue, t.Currency, t.Day)
+        if err != nil {
+            return RevenueReport{}, err
+        }
+        g := group{period.Start(t.Day), m.Currency}
+        p, ok := points[g]
+        if !ok {
+            p = &RevenuePoint{Start: g.start, Currency: g.currency}
+            points[g] = p
+        }
+        p.Orders += t.Orders
+        p.Revenue += int64(m.Amount)
+    }
+
+    report := RevenueReport{Period: period, Currency: opts.Currency, Series: []RevenuePoint{}}
+    for _, p := range points {
+        p.Display = models.Money{Amount: models.Amount(p.Revenue), Currency: p.Currency}.String()
+        report.Series = append(report.Series, *p)
+    }
+    slices.SortFunc(report.Series, func(a, b RevenuePoint) int {
+        return cmp.Or(a.Start.Compare(b.Start), cmp.Compare(a.Currency, b.Currency))
+    })
+    return report, nil
+}
+
+// TopEntry - значение разреза товаров с их числом и выручкой в одной валюте.
+type TopEntry struct {
+    Key      string `json:"key"`
+    Currency string `json:"currency"`
+    Units    int64  `json:"units"`
+    Revenue  int64  `json:"revenue"`
+    Display  string `json:"display"`
+}
+
+// TopReport - топ брендов или артикулов.
+type TopReport struct {
+    By       repository.Dimension `json:"by"`
+    Metric   Metric               `json:"metric"`
+    Currency string               `json:"currency,omitempty"`
+    Entries  []TopEntry           `json:"entries"`
+}
+
+// Top возвращает limit первых значений разреза товаров by (бренд или nm_id) по показателю metric.
+// Без валюты отчёта выручки в разных валютах не складываются, и топ строится по каждой валюте.
+func (s *Service) Top(ctx context.Context, opts Options, by repository.Dimension, metric Metric, limit int) (TopReport, error) {
+    totals, err := s.repo.ItemTotals(ctx, opts.Filter, by)
+    if err != nil {
+        return TopReport{}, err
+    }
+    conv := s.converter(opts)
+    type group struct {
+        key, currency string
+    }
+    entries := make(map[group]*TopEntry)
+    for _, t := range totals {
+        m, err := conv.convert(t.Revenue, t.Currency, t.Day)
+        if err != nil {
+            return TopReport{}, err
+        }
+        g := group{t.Key, m.Currency}
+        e, ok := entries[g]
+        if !ok {
+            e = &TopEntry{Key: g.key, Currency: g.currency}
+            entries[g] = e
+        }
+        e.Units += t.Units
+        e.Revenue += int64(m.Amount)
+    }
+
+    value := func(e TopEntry) int64 { return e.Revenue }
+    if metric == Units {
+        value = func(e TopEntry) int64 { return e.Units }
+    }
+    all := make([]TopEntry, 0, len(entries))
+    for _, e := range entries {
+        e.Display = models.Money{Amount: models.Amount(e.Revenue), Currency: e.Currency}.String()
+        all = append(all, *e)
+    }
+    slices.SortFunc(all, func(a, b TopEntry) int {
+        return cmp.Or(cmp.Compare(a.Currency, b.Currency), cmp.Compare(value(b), value(a)), cmp.Compare(a.Key, b.Key))
+    })
+
+    report := TopReport{By: by, Metric: metric, Currency: opts.Currency, Entries: []TopEntry{}}
+    taken := make(map[string]int)
+    for _, e := range all {
+        if limit > 0 && taken[e.Currency] >= limit {
+            continue
+        }
+        taken[e.Currency]++
+        report.Entries = append(report.Entries, e)
+    }
+    return report, nil
+}
+
+// BreakdownEntry - итоги заказов с одним значением разреза в одной валюте.
+type BreakdownEntry struct {
+    Key      string `json:"key"`
+    Currency string `json:"currency"`
+    Orders   int64  `json:"orders"`
+    Revenue  int64  `json:"revenue"`
+    Display  string `json:"display"`
+    // AverageSale - средняя скидка товаров заказов в процентах, до сотых.
+    AverageSale float64 `json:"average_sale"`
+}
+
+// BreakdownReport - итоги заказов в разрезе.
+type BreakdownReport struct {
+    By       repository.Dimension `json:"by"`
+    Currency string               `json:"currency,omitempty"`
+    Entries  []BreakdownEntry     `json:"entries"`
+}
+
+// Breakdown считает заказы, выручку и среднюю скидку по значениям разреза заказов by,
+// по убыванию выручки в каждой валюте.
+func (s *Service) Breakdown(ctx context.Context, opts Options, by repository.Dimension) (BreakdownReport, error) {
+    if err := repository.CheckDimension(by, repository.OrderDimensions); err != nil {
+        return BreakdownReport{}, err
+    }
+    totals, err := s.repo.OrderTotals(ctx, opts.Filter, by)
+    if err != nil {
+        return BreakdownReport{}, err
+    }
+    conv := s.converter(opts)
+    type group struct {
+        key, currency string
+    }
+    type sums struct {
+        entry       BreakdownEntry
+        items, sale int64
+    }
+    groups := make(map[group]*sums)
+    for _, t := range totals {
+        m, err := conv.convert(t.Revenue, t.Currency, t.Day)
+        if err != nil {
+            return BreakdownReport{}, err
+        }
+        g := group{t.Key, m.Currency}
+        sum, ok := groups[g]
+        if !ok {
+            sum = &sums{entry: BreakdownEntry{Key: g.key, Currency: g.currency}}
+            groups[g] = sum
+        }
+        sum.entry.Orders += t.Orders
+        sum.entry.Revenue += int64(m.Amount)
+        sum.items += t.Items
+        sum.sale += t.Sale
+    }
+
+    report := BreakdownReport{By: by, Currency: opts.Currency, Entries: []BreakdownEntry{}}
+    for _, sum := range groups {
+        e := sum.entry
+        e.Display = models.Money{Amount: models.Amount(e.Revenue), Currency: e.Currency}.String()
+        if sum.items > 0 {
+            e.AverageSale = math.Round(float64(sum.sale)/float64(sum.items)*100) / 100
+        }
+        report.Entries = append(report.Entries, e)
+    }
+    slices.SortFunc(report.Entries, func(a, b BreakdownEntry) int {
+        return cmp.Or(cmp.Compare(a.Currency, b.Currency), cmp.Compare(b.Revenue, a.Revenue), cmp.Compare(a.Key, b.Key))
+    })
+    return report, nil
+}
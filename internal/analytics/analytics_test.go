@@ -0,0 +1,143 @@
+package analytics
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/rates"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func day(s string) time.Time {
+    t, _ := time.Parse(time.DateOnly, s)
+    return t
+}
+
+// newService возвращает отчёты над заказами: 4 и 11 марта 2024 (понедельники) в USD, 12 марта в RUB.
+func newService(t *testing.T) *Service {
+    repo := repository.NewMemory()
+    orders := []struct {
+        uid      string
+        created  string
+        currency string
+        amount   models.Amount
+        bank     string
+        brand    string
+        sale     int
+    }{
+        {"o-1", "2024-03-04", "USD", 1000, "alpha", "Nivea", 10},
+        {"o-2", "2024-03-10", "USD", 500, "sber", "Nivea", 20},
+        {"o-3", "2024-03-11", "USD", 2000, "alpha", "Vivienne Sabo", 30},
+        {"o-4", "2024-03-12", "RUB", 90000, "sber", "Nivea", 50},
+    }
+    for _, o := range orders {
+        order := repotest.SampleOrder(o.uid)
+        order.DateCreated = day(o.created).Add(12 * time.Hour)
+        order.Payment.Currency, order.Payment.Amount, order.Payment.Bank = o.currency, o.amount, o.bank
+        order.Items[0].Brand, order.Items[0].TotalPrice, order.Items[0].Sale = o.brand, o.amount, o.sale
+        if err := repo.Save(context.Background(), order); err != nil {
+            t.Fatal(err)
+        }
+    }
+    table := rates.NewTable([]rates.Rate{
+        {Currency: "USD", Quote: "RUB", Value: "90", ValidFrom: day("2024-03-01")},
+        {Currency: "USD", Quote: "RUB", Value: "100", ValidFrom: day("2024-03-11")},
+    })
+    return New(repo, func() *rates.Table { return table })
+}
+
+func TestPeriodStart(t *testing.T) {
+    sunday := time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC)
+    tests := map[Period]string{Day: "2024-03-10", Week: "2024-03-04", Month: "2024-03-01"}
+    for period, want := range tests {
+        if got := period.Start(sunday); !got.Equal(day(want)) {
+            t.Errorf("%s: ожидалось %s, получили %s", period, want, got)
+        }
+    }
+    if _, err := ParsePeriod("year"); err == nil {
+        t.Error("Неизвестный период должен давать ошибку")
+    }
+}
+
+func TestRevenue(t *testing.T) {
+    s := newService(t)
+    ctx := context.Background()
+
+    report, err := s.Revenue(ctx, Options{}, Week)
+    if err != nil {
+        t.Fatalf("Revenue: %v", err)
+    }
+    want := []RevenuePoint{
+        {Start: day("2024-03-04"), Currency: "USD", Orders: 2, Revenue: 1500, Display: "15.00 USD"},
+        {Start: day("2024-03-11"), Currency: "RUB", Orders: 1, Revenue: 90000, Display: "900.00 RUB"},
+        {Start: day("2024-03-11"), Currency: "USD", Orders: 1, Revenue: 2000, Display: "20.00 USD"},
+    }
+    if len(report.Series) != len(want) {
+        t.Fatalf("Ожидалось %d точек, получили %+v", len(want), report.Series)
+    }
+    for i := range want {
+        if report.Series[i] != want[i] {
+            t.Errorf("Точка %d: ожидалась %+v, получили %+v", i, want[i], report.Series[i])
+        }
+    }
+
+    // В рублях: 15 USD по 90 и 20 USD по 100 в день заказа.
+    report, err = s.Revenue(ctx, Options{Currency: "RUB"}, Month)
+    if err != nil || len(report.Series) != 1 {
+        t.Fatalf("Revenue в RUB: %+v, %v", report, err)
+    }
+    if p := report.Series[0]; p.Orders != 4 || p.Revenue != 135000+200000+90000 || p.Currency != "RUB" {
+        t.Errorf("Выручка в RUB: %+v", p)
+    }
+
+    if _, err := s.Revenue(ctx, Options{Currency: "EUR"}, Day); !errors.Is(err, rates.ErrNoRate) {
+        t.Errorf("Ожидалась ErrNoRate, получили %v", err)
+    }
+}
+
+func TestTop(t *testing.T) {
+    s := newService(t)
+    ctx := context.Background()
+
+    report, err := s.Top(ctx, Options{}, repository.ByBrand, Units, 1)
+    if err != nil {
+        t.Fatalf("Top: %v", err)
+    }
+    // Топ строится по каждой валюте отдельно.
+    if len(report.Entries) != 2 || report.Entries[0].Currency != "RUB" ||
+        report.Entries[1] != (TopEntry{Key: "Nivea", Currency: "USD", Units: 2, Revenue: 1500, Display: "15.00 USD"}) {
+        t.Errorf("Топ по числу товаров: %+v", report.Entries)
+    }
+
+    report, err = s.Top(ctx, Options{Currency: "RUB", Filter: repository.AnalyticsFilter{From: day("2024-03-05")}}, repository.ByBrand, Revenue, 10)
+    if err != nil {
+        t.Fatalf("Top: %v", err)
+    }
+    if len(report.Entries) != 2 || report.Entries[0].Key != "Vivienne Sabo" || report.Entries[0].Revenue != 200000 ||
+        report.Entries[1].Revenue != 45000+90000 {
+        t.Errorf("Топ по выручке в RUB: %+v", report.Entries)
+    }
+}
+
+func TestBreakdown(t *testing.T) {
+    s := newService(t)
+    report, err := s.Breakdown(context.Background(), Options{Filter: repository.AnalyticsFilter{Currency: "USD"}}, repository.ByBank)
+    if err != nil {
+        t.Fatalf("Breakdown: %v", err)
+    }
+    want := []BreakdownEntry{
+        {Key: "alpha", Currency: "USD", Orders: 2, Revenue: 3000, Display: "30.00 USD", AverageSale: 20},
+        {Key: "sber", Currency: "USD", Orders: 1, Revenue: 500, Display: "5.00 USD", AverageSale: 20},
+    }
+    if len(report.Entries) != len(want) || report.Entries[0] != want[0] || report.Entries[1] != want[1] {
+        t.Errorf("Разрез по банкам:\nполучили %+v\nожидали  %+v", report.Entries, want)
+    }
+
+    if _, err := s.Breakdown(context.Background(), Options{}, repository.ByBrand); err == nil {
+        t.Error("Разрез товаров для заказов должен давать ошибку")
+    }
+}
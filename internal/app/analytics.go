@@ -0,0 +1,146 @@
+package app
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+
+    "wb-order-hub/internal/analytics"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/rates"
+    "wb-order-hub/internal/repository"
+)
+
+const (
+    defaultTopLimit = 10
+    maxTopLimit     = 1000
+)
+
+// analyticsOptions разбирает общие параметры отчётов: from и to (2006-01-02 или RFC3339),
+// currency - валюту отчёта, и фильтры payment_currency, delivery_service, bank, provider,
+// region, city, brand.
+func (a *App) analyticsOptions(query url.Values) (analytics.Options, error) {
+    var opts analytics.Options
+    var err error
+    if opts.Filter.From, err = parseDateParam(query, "from"); err != nil {
+        return opts, err
+    }
+    if opts.Filter.To, err = parseDateParam(query, "to"); err != nil {
+        return opts, err
+    }
+    opts.Currency = query.Get("currency")
+    if opts.Currency != "" && (a.opts.Rates == nil || !models.ValidCurrency(opts.Currency)) {
+        return opts, errors.New("currency: ожидается код валюты ISO 4217, курсы должны быть загружены")
+    }
+    opts.Filter.Currency = query.Get("payment_currency")
+    opts.Filter.DeliveryService = query.Get("delivery_service")
+    opts.Filter.Bank = query.Get("bank")
+    opts.Filter.Provider = query.Get("provider")
+    opts.Filter.Region = query.Get("region")
+    opts.Filter.City = query.Get("city")
+    opts.Filter.Brand = query.Get("brand")
+    return opts, nil
+}
+
+func parseDateParam(query url.Values, name string) (time.Time, error) {
+    value := query.Get(name)
+    if value == "" {
+        return time.Time{}, nil
+    }
+    if t, err := time.Parse(time.DateOnly, value); err == nil {
+        return t, nil
+    }
+    t, err := time.Parse(time.RFC3339, value)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("%s: ожидается дата 2006-01-02 или RFC3339, получено %q", name, value)
+    }
+    return t, nil
+}
+
+// revenueHandler отдаёт выручку и число заказов по дням, неделям или месяцам: параметр period.
+func (a *App) revenueHandler(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    opts, err := a.analyticsOptions(query)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    period, err := analytics.ParsePeriod(query.Get("period"))
+    if err != nil {
+        http.Error(w, "period: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    report, err := a.analytics.Revenue(r.Context(), opts, period)
+    writeReport(w, report, err)
+}
+
+// topHandler отдаёт топ брендов (by=brand) или артикулов (by=nm_id) по выручке или числу
+// товаров (metric=revenue|units), limit строк на валюту.
+func (a *App) topHandler(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    opts, err := a.analyticsOptions(query)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    by := repository.Dimension(query.Get("by"))
+    if by == "" {
+        by = repository.ByBrand
+    }
+    if err := repository.CheckDimension(by, repository.ItemDimensions); err != nil {
+        http.Error(w, "by: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    metric, err := analytics.ParseMetric(query.Get("metric"))
+    if err != nil {
+        http.Error(w, "metric: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    limit := defaultTopLimit
+    if value := query.Get("limit"); value != "" {
+        if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxTopLimit {
+            http.Error(w, fmt.Sprintf("limit: ожидается число от 1 до %d", maxTopLimit), http.StatusBadRequest)
+            return
+        }
+    }
+    report, err := a.analytics.Top(r.Context(), opts, by, metric, limit)
+    writeReport(w, report, err)
+}
+
+// breakdownHandler отдаёт заказы, выручку и среднюю скидку в разрезе by: delivery_service,
+// bank, provider, region или city.
+func (a *App) breakdownHandler(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    opts, err := a.analyticsOptions(query)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    by := repository.Dimension(query.Get("by"))
+    if err := repository.CheckDimension(by, repository.OrderDimensions); err != nil {
+        http.Error(w, "by: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    report, err := a.analytics.Breakdown(r.Context(), opts, by)
+    writeReport(w, report, err)
+}
+
+// writeReport отдаёт отчёт или ошибку его построения: без курса для пересчёта - 422.
+func writeReport(w http.ResponseWriter, report any, err error) {
+    if errors.Is(err, rates.ErrNoRate) {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    if err != nil {
+        log.Printf("Не удалось построить отчёт: %v", err)
+        http.Error(w, "Ошибка построения отчёта", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(report)
+}
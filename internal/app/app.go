@@ -0,0 +1,292 @@
+package app
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/fs"
+    "log"
+    "net"
+    "net/http"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/analytics"
+    "wb-order-hub/internal/archive"
+    "wb-order-hub/internal/cache"
+    "wb-order-hub/internal/events"
+    "wb-order-hub/internal/metrics"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/rates"
+    "wb-order-hub/internal/reload"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/verify"
+)
+
+const (
+    defaultChannel         = "orders"
+    defaultDurableName     = "order-service-durable"
+    defaultShutdownTimeout = 10 * time.Second
+    // abandonGrace - сколько ждать обработчики после отмены их контекста по истечении срока остановки.
+    abandonGrace = time.Second
+)
+
+// Options - параметры запуска сервиса, не относящиеся к зависимостям.
+type Options struct {
+    Addr           string
+    ReadTimeout    time.Duration
+    WriteTimeout   time.Duration
+    MaxHeaderBytes int
+    // ShutdownTimeout ограничивает всю остановку: HTTP, дообработку сообщений и сброс буферов.
+    ShutdownTimeout time.Duration
+    // Channel и DurableName - канал с заказами и имя durable-подписки на него.
+    Channel     string
+    DurableName string
+    // AckWait - через сколько NATS Streaming повторно доставит неподтверждённое сообщение.
+    AckWait time.Duration
+    // APIKeys возвращает действующие ключи доступа к служебным эндпоинтам.
+    // Если функция не задана или ключей нет, эндпоинты закрыты.
+    APIKeys func() []string
+    // OpenWithoutAPIKeys открывает служебные эндпоинты, пока ключей нет. Только для режима -dev.
+    OpenWithoutAPIKeys bool
+    // Web - статика веб-интерфейса. nil отключает раздачу статики.
+    Web fs.FS
+    // RateLimit и RateBurst ограничивают частоту запросов к API с одного адреса.
+    // RateLimit <= 0 отключает ограничение; изменить лимит можно через SetRateLimit.
+    RateLimit float64
+    RateBurst int
+    // Reload перечитывает конфигурацию по запросу POST /admin/reload.
+    // Если функция не задана, эндпоинт не регистрируется.
+    Reload func() (reload.Result, error)
+    // Source - исходные сообщения, с которыми POST /admin/verify сверяет базу.
+    // По умолчанию - Archive; если нет и его, база сверяется только с кэшем.
+    Source verify.Source
+    // Archive сохраняет каждое полученное сообщение и отдаёт его через GET /order/{id}/raw.
+    // nil отключает архив.
+    Archive archive.Store
+    // StatusChannel - канал, в который публикуются смены статусов через API. Пустой - не публиковать.
+    StatusChannel string
+    // EventsPrefix - префикс каналов событий заказа <prefix>.<тип>. Пустой - события не принимаются;
+    // не принимаются они и хранилищем без версий заказов.
+    EventsPrefix string
+    // Parked хранит события, пришедшие раньше предшественника. По умолчанию - в памяти.
+    Parked events.ParkingLot
+    // Rates - курсы валют для пересчёта сумм в ответе API и загрузки через /admin/rates.
+    // nil отключает пересчёт и эндпоинты курсов.
+    Rates *rates.Book
+    // ReportingCurrency - валюта, в которую пересчитываются суммы заказа, если запрос не задаёт
+    // другую параметром currency. Пустая - пересчитывать только по запросу.
+    ReportingCurrency string
+}
+
+// App - сервис заказов: подписка на NATS Streaming, хранилище, кэш и HTTP API.
+type App struct {
+    opts  Options
+    repo  repository.OrderRepository
+    cache *cache.Cache
+    sc    stan.Conn
+    // statuses - repo, если оно ведёт историю статусов, иначе nil.
+    statuses repository.StatusRepository
+    // processor применяет события заказа, nil - события не принимаются.
+    processor *events.Processor
+    // analytics строит отчёты /analytics, nil - хранилище не считает итоги.
+    analytics *analytics.Service
+
+    limiter *rateLimiter
+
+    // handlerCtx - родительский контекст обработчиков сообщений, отменяется,
+    // если они не успели завершиться за время остановки.
+    handlerCtx    context.Context
+    cancelHandler context.CancelFunc
+
+    mu       sync.Mutex
+    draining bool
+    inflight sync.WaitGroup
+    // inflightCount дублирует счётчик inflight для отчёта о прерванных сообщениях.
+    inflightCount atomic.Int64
+    flushers      []flusher
+}
+
+type flusher struct {
+    name  string
+    flush func(context.Context) error
+}
+
+// New собирает сервис из готовых зависимостей. sc может быть nil, если нужен только HTTP-обработчик.
+func New(opts Options, repo repository.OrderRepository, orderCache *cache.Cache, sc stan.Conn) *App {
+    if opts.ShutdownTimeout <= 0 {
+        opts.ShutdownTimeout = defaultShutdownTimeout
+    }
+    if opts.Channel == "" {
+        opts.Channel = defaultChannel
+    }
+    if opts.DurableName == "" {
+        opts.DurableName = defaultDurableName
+    }
+    if opts.Source == nil && opts.Archive != nil {
+        opts.Source = archive.Source{Store: opts.Archive}
+    }
+    statuses, _ := repo.(repository.StatusRepository)
+    var processor *events.Processor
+    if versioned, ok := repo.(repository.VersionedRepository); ok && opts.EventsPrefix != "" {
+        if opts.Parked == nil {
+            opts.Parked = events.NewMemory()
+        }
+        processor = events.NewProcessor(versioned, opts.Parked)
+    }
+    var reports *analytics.Service
+    if totals, ok := repo.(repository.AnalyticsRepository); ok {
+        var table func() *rates.Table
+        if opts.Rates != nil {
+            table = opts.Rates.Table
+        }
+        reports = analytics.New(totals, table)
+    }
+    handlerCtx, cancel := context.WithCancel(context.Background())
+    return &App{
+        opts:          opts,
+        repo:          repo,
+        cache:         orderCache,
+        sc:            sc,
+        statuses:      statuses,
+        processor:     processor,
+        analytics:     reports,
+        limiter:       newRateLimiter(opts.RateLimit, opts.RateBurst),
+        handlerCtx:    handlerCtx,
+        cancelHandler: cancel,
+    }
+}
+
+// AddFlusher регистрирует буферизующий компонент, который нужно сбросить при остановке
+// после дообработки сообщений и до закрытия подписки. Вызывается до Run.
+func (a *App) AddFlusher(name string, flush func(context.Context) error) {
+    a.flushers = append(a.flushers, flusher{name: name, flush: flush})
+}
+
+// SetRateLimit меняет ограничение частоты запросов к API без перезапуска.
+func (a *App) SetRateLimit(rps float64, burst int) {
+    a.limiter.set(rps, burst)
+}
+
+// Run восстанавливает кэш, подписывается на канал заказов и обслуживает HTTP на opts.Addr
+// до отмены ctx.
+func (a *App) Run(ctx context.Context) error {
+    ln, err := net.Listen("tcp", a.opts.Addr)
+    if err != nil {
+        return fmt.Errorf("не удалось открыть порт %s: %w", a.opts.Addr, err)
+    }
+    return a.Serve(ctx, ln)
+}
+
+// Serve работает как Run, но принимает HTTP-соединения на готовом ln.
+func (a *App) Serve(ctx context.Context, ln net.Listener) error {
+    a.RestoreCache(ctx)
+
+    if a.sc == nil {
+        ln.Close()
+        return errors.New("не задано подключение к NATS Streaming")
+    }
+    subs, err := a.subscribe()
+    if err != nil {
+        ln.Close()
+        return err
+    }
+
+    srv := &http.Server{
+        Handler:        a.Handler(),
+        WriteTimeout:   a.opts.WriteTimeout,
+        ReadTimeout:    a.opts.ReadTimeout,
+        MaxHeaderBytes: a.opts.MaxHeaderBytes,
+    }
+
+    serveErr := make(chan error, 1)
+    go func() {
+        log.Printf("Запуск HTTP сервера на %s...", ln.Addr())
+        serveErr <- srv.Serve(ln)
+    }()
+
+    select {
+    case err := <-serveErr:
+        a.shutdown(srv, subs)
+        return fmt.Errorf("сервер не смог запуститься: %w", err)
+    case <-ctx.Done():
+    }
+    log.Println("Получен сигнал завершения. Начинаю корректную остановку...")
+    return a.shutdown(srv, subs)
+}
+
+// shutdown останавливает сервис по шагам: прекращает приём сообщений и HTTP-запросов,
+// дожидается обработчиков и их подтверждений, сбрасывает буферы и закрывает подписки.
+// Подписка закрывается без Unsubscribe, поэтому durable-позиция сохраняется на сервере.
+func (a *App) shutdown(srv *http.Server, subs []stan.Subscription) error {
+    ctx, cancel := context.WithTimeout(context.Background(), a.opts.ShutdownTimeout)
+    defer cancel()
+    defer metrics.ShutdownPhase.Set("done")
+
+    var errs []error
+    phase := func(name string, fn func() error) {
+        log.Printf("Остановка: %s...", name)
+        metrics.ShutdownPhase.Set(name)
+        start := time.Now()
+        err := fn()
+        metrics.ObserveShutdownPhase(name, time.Since(start))
+        if err != nil {
+            log.Printf("Остановка: %s завершилась с ошибкой: %v", name, err)
+            errs = append(errs, fmt.Errorf("%s: %w", name, err))
+            return
+        }
+        log.Printf("Остановка: %s завершена за %s", name, time.Since(start).Round(time.Millisecond))
+    }
+
+    phase("приём сообщений", func() error {
+        a.stopAccepting()
+        return nil
+    })
+    phase("HTTP сервер", func() error {
+        return srv.Shutdown(ctx)
+    })
+    phase("обработка сообщений", func() error {
+        return a.drain(ctx)
+    })
+    for _, f := range a.flushers {
+        phase("сброс "+f.name, func() error {
+            return f.flush(ctx)
+        })
+    }
+    phase("подписка", func() error {
+        var errs []error
+        for _, sub := range subs {
+            errs = append(errs, sub.Close())
+        }
+        return errors.Join(errs...)
+    })
+
+    return errors.Join(errs...)
+}
+
+// RestoreCache заполняет кэш заказами из хранилища.
+func (a *App) RestoreCache(ctx context.Context) {
+    log.Println("Восстановление кэша из базы данных...")
+    ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+    defer cancel()
+
+    restored := 0
+    err := a.repo.Stream(ctx, repository.ListFilter{}, func(order models.Order) error {
+        a.cacheOrder(order)
+        restored++
+        return nil
+    })
+    if err != nil {
+        log.Printf("Предупреждение: не удалось восстановить кэш из БД: %v", err)
+        return
+    }
+    log.Printf("Кэш восстановлен, заказов в кэше: %d.", restored)
+}
+
+func (a *App) cacheOrder(order models.Order) {
+    jsonOrder, _ := json.Marshal(order)
+    a.cache.Set(order.OrderUID, string(jsonOrder))
+}
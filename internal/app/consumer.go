@@ -0,0 +1,256 @@
+package app
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "log/slog"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/archive"
+    "wb-order-hub/internal/envelope"
+    "wb-order-hub/internal/events"
+    "wb-order-hub/internal/metrics"
+    "wb-order-hub/internal/repository"
+)
+
+// errInvalidMessage помечает сообщения, которые бессмысленно обрабатывать повторно.
+var errInvalidMessage = errors.New("некорректное сообщение")
+
+// subscribe подписывается на канал заказов и, если события принимаются, на канал каждого
+// типа событий. Durable-позиция у каждого канала своя.
+func (a *App) subscribe() ([]stan.Subscription, error) {
+    var subs []stan.Subscription
+    add := func(channel string, process func(context.Context, *stan.Msg) error) error {
+        log.Printf("Подписка на NATS канал '%s'...", channel)
+        sub, err := a.sc.Subscribe(channel, func(m *stan.Msg) { a.handleMessage(m, process) }, a.subscriptionOptions()...)
+        if err != nil {
+            return fmt.Errorf("не удалось подписаться на NATS канал %s: %w", channel, err)
+        }
+        subs = append(subs, sub)
+        return nil
+    }
+
+    err := add(a.opts.Channel, a.processOrderMessage)
+    if a.processor != nil {
+        for _, t := range events.Types() {
+            if err != nil {
+                break
+            }
+            err = add(events.Subject(a.opts.EventsPrefix, t), a.processEvent)
+        }
+    }
+    if err != nil {
+        for _, sub := range subs {
+            sub.Close()
+        }
+        return nil, err
+    }
+    return subs, nil
+}
+
+func (a *App) subscriptionOptions() []stan.SubscriptionOption {
+    opts := []stan.SubscriptionOption{
+        stan.DurableName(a.opts.DurableName),
+        stan.SetManualAckMode(),
+    }
+    if a.opts.AckWait > 0 {
+        opts = append(opts, stan.AckWait(a.opts.AckWait))
+    }
+    return opts
+}
+
+// handleMessage сохраняет сообщение в архив, передаёт его process и подтверждает.
+func (a *App) handleMessage(m *stan.Msg, process func(context.Context, *stan.Msg) error) {
+    if !a.beginMessage() {
+        // Сервис останавливается: сообщение не подтверждается и будет доставлено повторно после запуска.
+        metrics.MessagesRejected.Add(1)
+        return
+    }
+    defer a.endMessage()
+
+    metrics.MessagesReceived.Add(1)
+    // Содержимое сообщения пишется только на уровне debug: заказы содержат персональные данные.
+    slog.Debug("Получено сообщение", "subject", m.Subject, "sequence", m.Sequence, "data", string(m.Data))
+    ctx, cancel := context.WithTimeout(a.handlerCtx, 10*time.Second)
+    defer cancel()
+
+    // Сообщение подтверждается после успешного сохранения или если оно заведомо некорректно.
+    // При временной ошибке хранилища или архива подтверждения нет, и сервер доставит сообщение повторно.
+    err := a.archiveMessage(ctx, m)
+    if err == nil {
+        err = process(ctx, m)
+    }
+    if err != nil {
+        log.Print(err)
+        if !errors.Is(err, errInvalidMessage) {
+            metrics.MessagesFailed.Add(1)
+            return
+        }
+        metrics.MessagesInvalid.Add(1)
+    }
+    if err := m.Ack(); err != nil {
+        log.Printf("Не удалось подтвердить сообщение %d: %v", m.Sequence, err)
+        return
+    }
+    metrics.MessagesAcked.Add(1)
+}
+
+// beginMessage регистрирует обработчик в числе выполняющихся. Возвращает false,
+// если сервис уже останавливается.
+func (a *App) beginMessage() bool {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.draining {
+        return false
+    }
+    a.inflight.Add(1)
+    a.inflightCount.Add(1)
+    metrics.MessagesInFlight.Add(1)
+    return true
+}
+
+func (a *App) endMessage() {
+    metrics.MessagesInFlight.Add(-1)
+    a.inflightCount.Add(-1)
+    a.inflight.Done()
+}
+
+func (a *App) stopAccepting() {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.draining = true
+}
+
+// drain ждёт завершения выполняющихся обработчиков до истечения ctx.
+// После этого их контексты отменяются, а неподтверждённые сообщения доставляются повторно.
+func (a *App) drain(ctx context.Context) error {
+    done := make(chan struct{})
+    go func() {
+        a.inflight.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+    }
+    select {
+    case <-done:
+        return nil
+    default:
+    }
+
+    abandoned := a.inflightCount.Load()
+    metrics.MessagesAbandoned.Add(abandoned)
+    a.cancelHandler()
+    select {
+    case <-done:
+    case <-time.After(abandonGrace):
+    }
+    return fmt.Errorf("обработка %d сообщений прервана: %w", abandoned, ctx.Err())
+}
+
+// archiveMessage сохраняет сообщение в архив как есть, в том числе некорректное.
+func (a *App) archiveMessage(ctx context.Context, m *stan.Msg) error {
+    if a.opts.Archive == nil {
+        return nil
+    }
+    err := a.opts.Archive.Save(ctx, archive.Message{
+        Sequence:    m.Sequence,
+        Subject:     m.Subject,
+        Redelivered: m.Redelivered,
+        ReceivedAt:  time.Now(),
+        OrderUID:    archive.OrderUID(m.Data),
+        Data:        m.Data,
+    })
+    if err != nil {
+        return fmt.Errorf("не удалось сохранить сообщение %d в архив: %w", m.Sequence, err)
+    }
+    return nil
+}
+
+func (a *App) processOrderMessage(ctx context.Context, m *stan.Msg) error {
+    return a.processOrder(ctx, m.Data)
+}
+
+// processOrder разбирает сообщение с заказом, сохраняет его в хранилище и кэш
+// и применяет события заказа, пришедшие раньше него.
+func (a *App) processOrder(ctx context.Context, data []byte) error {
+    order, env, err := envelope.ParseOrder(data)
+    if errors.Is(err, envelope.ErrUnsupportedVersion) {
+        // Сообщение не подтверждается: его обработает обновлённый сервис.
+        return fmt.Errorf("заказ отложен до обновления сервиса: %w", err)
+    }
+    if err != nil {
+        return fmt.Errorf("%w: %v", errInvalidMessage, err)
+    }
+    if !env.Legacy {
+        slog.Debug("Конверт заказа", "order_uid", order.OrderUID, "message_id", env.MessageID,
+            "producer", env.Producer, "produced_at", env.ProducedAt, "schema_version", env.SchemaVersion)
+    }
+
+    err = a.repo.Save(ctx, order)
+    if errors.Is(err, repository.ErrSuperseded) {
+        // Заказ сохранён раньше, а события уже изменили его: сообщение подтверждается как повтор.
+        metrics.OrdersSuperseded.Add(1)
+        log.Printf("Заказ %s уже изменён событиями, повторный полный заказ пропущен", order.OrderUID)
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("не удалось сохранить заказ %s в БД: %w", order.OrderUID, err)
+    }
+
+    if a.processor != nil {
+        resumed, err := a.processor.Resume(ctx, order.OrderUID)
+        if err != nil {
+            // Заказ уже сохранён: отложенные события применятся со следующим событием заказа.
+            log.Printf("Не удалось применить отложенные события заказа %s: %v", order.OrderUID, err)
+        }
+        if resumed != nil {
+            order = *resumed
+        }
+    }
+
+    a.cacheOrder(order)
+    log.Printf("Заказ %s обработан и добавлен в кэш", order.OrderUID)
+    return nil
+}
+
+// processEvent применяет событие заказа. Тип события определяется по каналу.
+func (a *App) processEvent(ctx context.Context, m *stan.Msg) error {
+    event, err := events.Parse(m.Data, m.Subject)
+    if errors.Is(err, envelope.ErrUnsupportedVersion) {
+        return fmt.Errorf("событие отложено до обновления сервиса: %w", err)
+    }
+    if err != nil {
+        return fmt.Errorf("%w: %v", errInvalidMessage, err)
+    }
+
+    result, err := a.processor.Handle(ctx, event)
+    if errors.Is(err, events.ErrInvalid) {
+        return fmt.Errorf("%w: %v", errInvalidMessage, err)
+    }
+    if err != nil {
+        return fmt.Errorf("не удалось применить событие %s заказа %s: %w", event.Type, event.OrderUID, err)
+    }
+
+    switch result.Outcome {
+    case events.Applied:
+        metrics.EventsApplied.Add(1)
+    case events.Parked:
+        metrics.EventsParked.Add(1)
+    case events.Duplicate:
+        metrics.EventsDuplicate.Add(1)
+    }
+    metrics.EventsApplied.Add(int64(result.Released))
+    if result.Order != nil {
+        a.cacheOrder(*result.Order)
+    }
+    log.Printf("Событие %s версии %d заказа %s: %s", event.Type, event.Version, event.OrderUID, result.Outcome)
+    return nil
+}
@@ -0,0 +1,278 @@
+package app
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "errors"
+    "expvar"
+    "log"
+    "net/http"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+    "wb-order-hub/internal/archive"
+    "wb-order-hub/internal/codec"
+    "wb-order-hub/internal/dto"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/reload"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/verify"
+)
+
+// Handler возвращает HTTP-маршрутизатор сервиса.
+func (a *App) Handler() http.Handler {
+    router := mux.NewRouter()
+    router.Handle("/order/{id}", a.limiter.middleware(http.HandlerFunc(a.getOrderHandler))).Methods("GET")
+    if a.statuses != nil {
+        router.Handle("/order/{id}/status", a.limiter.middleware(http.HandlerFunc(a.getStatusHandler))).Methods("GET")
+        router.Handle("/order/{id}/status", a.requireAPIKey(http.HandlerFunc(a.patchStatusHandler))).Methods("PATCH")
+    }
+    if a.opts.Archive != nil {
+        router.Handle("/order/{id}/raw", a.requireAPIKey(http.HandlerFunc(a.getRawOrderHandler))).Methods("GET")
+    }
+    if a.analytics != nil {
+        router.Handle("/analytics/revenue", a.requireAPIKey(http.HandlerFunc(a.revenueHandler))).Methods("GET")
+        router.Handle("/analytics/top", a.requireAPIKey(http.HandlerFunc(a.topHandler))).Methods("GET")
+        router.Handle("/analytics/breakdown", a.requireAPIKey(http.HandlerFunc(a.breakdownHandler))).Methods("GET")
+    }
+    router.Handle("/debug/vars", a.requireAPIKey(expvar.Handler())).Methods("GET")
+    if a.opts.Reload != nil {
+        router.Handle("/admin/reload", a.requireAPIKey(http.HandlerFunc(a.reloadHandler))).Methods("POST")
+    }
+    router.Handle("/admin/verify", a.requireAPIKey(http.HandlerFunc(a.verifyHandler))).Methods("POST")
+    if a.opts.Rates != nil {
+        router.Handle("/admin/rates", a.requireAPIKey(http.HandlerFunc(a.getRatesHandler))).Methods("GET")
+        router.Handle("/admin/rates", a.requireAPIKey(http.HandlerFunc(a.postRatesHandler))).Methods("POST")
+    }
+    if a.opts.Web != nil {
+        router.PathPrefix("/").Handler(http.FileServerFS(a.opts.Web))
+    }
+    return router
+}
+
+func (a *App) getOrderHandler(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    orderID := vars["id"]
+
+    if orderID == "" || len(orderID) > models.MaxOrderUIDLength {
+        http.Error(w, "Некорректный ID заказа", http.StatusBadRequest)
+        return
+    }
+    format, ok := codec.Negotiate(r.Header.Get("Accept"))
+    if !ok {
+        http.Error(w, "Поддерживаются application/json, application/x-protobuf и application/msgpack", http.StatusNotAcceptable)
+        return
+    }
+    withExtra := false
+    if value := r.URL.Query().Get("extra"); value != "" {
+        var err error
+        if withExtra, err = strconv.ParseBool(value); err != nil {
+            http.Error(w, "extra: ожидается true или false", http.StatusBadRequest)
+            return
+        }
+    }
+    currency := r.URL.Query().Get("currency")
+    if format == codec.Protobuf && (withExtra || currency != "") {
+        http.Error(w, "extra и currency не поддерживаются в application/x-protobuf", http.StatusNotAcceptable)
+        return
+    }
+    if currency != "" && (a.opts.Rates == nil || !models.ValidCurrency(currency)) {
+        http.Error(w, "currency: ожидается код валюты ISO 4217, курсы должны быть загружены", http.StatusBadRequest)
+        return
+    }
+
+    var orderModel models.Order
+    if value, ok := a.cache.Get(orderID); ok {
+        if err := json.Unmarshal([]byte(value), &orderModel); err != nil {
+            http.Error(w, "Ошибка обработки данных заказа", http.StatusInternalServerError)
+            return
+        }
+    } else {
+        // Кэш ограничен по размеру, поэтому промах не означает, что заказа нет.
+        order, err := a.repo.GetByUID(r.Context(), orderID)
+        if errors.Is(err, repository.ErrNotFound) {
+            http.Error(w, "Заказ не найден", http.StatusNotFound)
+            return
+        }
+        if err != nil {
+            log.Printf("Не удалось получить заказ %s из БД: %v", orderID, err)
+            http.Error(w, "Ошибка получения заказа", http.StatusInternalServerError)
+            return
+        }
+        a.cacheOrder(order)
+        orderModel = order
+    }
+
+    // Protobuf-схема описывает заказ из канала, поэтому в ней отдаются исходные поля
+    // без производных статусов, подписей, неизвестных полей и пересчёта в другую валюту.
+    var response []byte
+    var err error
+    if format == codec.Protobuf {
+        response, err = codec.MarshalOrder(format, orderModel)
+    } else {
+        orderResponse := dto.ToResponse(orderModel)
+        if withExtra {
+            orderResponse.Extra = dto.ToExtra(orderModel)
+        }
+        orderResponse.Converted, err = a.convert(orderModel, currency)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+            return
+        }
+        response, err = codec.Marshal(format, orderResponse)
+    }
+    if err != nil {
+        http.Error(w, "Ошибка формирования ответа", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", format.ContentType())
+    w.Header().Set("Vary", "Accept")
+    w.WriteHeader(http.StatusOK)
+    w.Write(response)
+}
+
+// getRawOrderHandler отдаёт исходное сообщение заказа байт в байт: последнее полученное
+// или с номером из параметра sequence. Метаданные NATS передаются в заголовках.
+func (a *App) getRawOrderHandler(w http.ResponseWriter, r *http.Request) {
+    orderID := mux.Vars(r)["id"]
+    if orderID == "" || len(orderID) > models.MaxOrderUIDLength {
+        http.Error(w, "Некорректный ID заказа", http.StatusBadRequest)
+        return
+    }
+    var sequence uint64
+    if value := r.URL.Query().Get("sequence"); value != "" {
+        var err error
+        if sequence, err = strconv.ParseUint(value, 10, 64); err != nil {
+            http.Error(w, "sequence: ожидается номер сообщения", http.StatusBadRequest)
+            return
+        }
+    }
+
+    messages, err := a.opts.Archive.Messages(r.Context(), orderID)
+    if errors.Is(err, archive.ErrNotFound) {
+        http.Error(w, "Исходные сообщения заказа не найдены", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        log.Printf("Не удалось получить исходные сообщения заказа %s: %v", orderID, err)
+        http.Error(w, "Ошибка получения исходного сообщения", http.StatusInternalServerError)
+        return
+    }
+    msg := messages[0]
+    if sequence > 0 {
+        i := slices.IndexFunc(messages, func(m archive.Message) bool { return m.Sequence == sequence })
+        if i < 0 {
+            http.Error(w, "Сообщение с таким номером не найдено", http.StatusNotFound)
+            return
+        }
+        msg = messages[i]
+    }
+
+    contentType := "application/octet-stream"
+    if json.Valid(msg.Data) {
+        contentType = "application/json"
+    }
+    w.Header().Set("Content-Type", contentType)
+    w.Header().Set("X-Nats-Sequence", strconv.FormatUint(msg.Sequence, 10))
+    w.Header().Set("X-Nats-Subject", msg.Subject)
+    w.Header().Set("X-Nats-Redelivered", strconv.FormatBool(msg.Redelivered))
+    w.Header().Set("X-Received-At", msg.ReceivedAt.UTC().Format(time.RFC3339Nano))
+    w.Header().Set("X-Messages-Total", strconv.Itoa(len(messages)))
+    w.Write(msg.Data)
+}
+
+// reloadHandler перечитывает конфигурацию. Ответ содержит применённые поля или,
+// со статусом 409, изменения, для которых нужен перезапуск.
+func (a *App) reloadHandler(w http.ResponseWriter, r *http.Request) {
+    result, err := a.opts.Reload()
+
+    status := http.StatusOK
+    response := struct {
+        reload.Result
+        Error string `json:"error,omitempty"`
+    }{Result: result}
+    switch {
+    case errors.Is(err, reload.ErrRestartRequired):
+        status = http.StatusConflict
+        response.Error = err.Error()
+    case err != nil:
+        status = http.StatusUnprocessableEntity
+        response.Error = err.Error()
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
+
+// verifyHandler сверяет кэш, базу и исходные сообщения. Параметры запроса: sample - доля
+// проверяемых заказов от 0 до 1, repair=true - исправить найденные расхождения.
+func (a *App) verifyHandler(w http.ResponseWriter, r *http.Request) {
+    opts := verify.ConsistencyOptions{Checks: verify.OrderChecks, Cache: a.cache, Source: a.opts.Source}
+    query := r.URL.Query()
+    if value := query.Get("sample"); value != "" {
+        sample, err := strconv.ParseFloat(value, 64)
+        if err != nil || sample < 0 || sample > 1 {
+            http.Error(w, "sample: ожидается число от 0 до 1", http.StatusBadRequest)
+            return
+        }
+        opts.Sample = sample
+    }
+    if value := query.Get("repair"); value != "" {
+        repair, err := strconv.ParseBool(value)
+        if err != nil {
+            http.Error(w, "repair: ожидается true или false", http.StatusBadRequest)
+            return
+        }
+        opts.Repair = repair
+    }
+
+    report, err := verify.Consistency(r.Context(), a.repo, opts)
+    if err != nil {
+        log.Printf("Проверка согласованности прервана: %v", err)
+        http.Error(w, "Ошибка проверки согласованности", http.StatusInternalServerError)
+        return
+    }
+    log.Printf("Проверка согласованности: заказов %d, записей кэша %d, нарушений %d, исправлено %d",
+        report.Checked, report.CacheChecked, len(report.Problems), report.Repaired)
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(report)
+}
+
+// requireAPIKey пропускает запрос, только если он содержит один из ключей Options.APIKeys
+// в заголовке X-API-Key или Authorization: Bearer. Ключи запрашиваются на каждый запрос,
+// поэтому их обновление применяется сразу. Пока ключей нет, эндпоинты закрыты,
+// если только Options.OpenWithoutAPIKeys не открывает их для режима разработки.
+func (a *App) requireAPIKey(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var keys []string
+        if a.opts.APIKeys != nil {
+            keys = a.opts.APIKeys()
+        }
+        if len(keys) == 0 {
+            if a.opts.OpenWithoutAPIKeys {
+                next.ServeHTTP(w, r)
+                return
+            }
+            http.Error(w, "Ключи доступа не настроены", http.StatusServiceUnavailable)
+            return
+        }
+
+        provided := r.Header.Get("X-API-Key")
+        if provided == "" {
+            provided = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+        }
+        for _, key := range keys {
+            if provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+                next.ServeHTTP(w, r)
+                return
+            }
+        }
+        http.Error(w, "Требуется ключ доступа", http.StatusUnauthorized)
+    })
+}
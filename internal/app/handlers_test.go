@@ -0,0 +1,774 @@
+package app
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "time"
+
+    "github.com/vmihailenco/msgpack/v5"
+    "wb-order-hub/internal/analytics"
+    "wb-order-hub/internal/archive"
+    "wb-order-hub/internal/cache"
+    "wb-order-hub/internal/codec"
+    "wb-order-hub/internal/dto"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/rates"
+    "wb-order-hub/internal/reload"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+    "wb-order-hub/internal/verify"
+)
+
+// failingRepository имитирует недоступную базу данных.
+type failingRepository struct {
+    repository.OrderRepository
+}
+
+func (failingRepository) GetByUID(context.Context, string) (models.Order, error) {
+    return models.Order{}, errors.New("соединение разорвано")
+}
+
+func TestGetOrderHandler(t *testing.T) {
+    tests := []struct {
+        name       string
+        method     string
+        path       string
+        repo       repository.OrderRepository
+        setup      func(a *App)
+        wantStatus int
+        wantUID    string
+    }{
+        {
+            name: "Заказ из кэша",
+            path: "/order/cached",
+            repo: repository.NewMemory(),
+            setup: func(a *App) {
+                a.cacheOrder(repotest.SampleOrder("cached"))
+            },
+            wantStatus: http.StatusOK,
+            wantUID:    "cached",
+        },
+        {
+            name: "Заказ из БД при промахе кэша",
+            path: "/order/stored",
+            repo: repository.NewMemory(),
+            setup: func(a *App) {
+                a.repo.Save(context.Background(), repotest.SampleOrder("stored"))
+            },
+            wantStatus: http.StatusOK,
+            wantUID:    "stored",
+        },
+        {
+            name:       "Слишком длинный ID",
+            path:       "/order/" + strings.Repeat("x", models.MaxOrderUIDLength+1),
+            repo:       repository.NewMemory(),
+            wantStatus: http.StatusBadRequest,
+        },
+        {
+            name:       "Заказ не найден",
+            path:       "/order/missing",
+            repo:       repository.NewMemory(),
+            wantStatus: http.StatusNotFound,
+        },
+        {
+            name:       "Ошибка БД",
+            path:       "/order/any",
+            repo:       failingRepository{},
+            wantStatus: http.StatusInternalServerError,
+        },
+        {
+            name: "Повреждённая запись в кэше",
+            path: "/order/broken",
+            repo: repository.NewMemory(),
+            setup: func(a *App) {
+                a.cache.Set("broken", "{not json")
+            },
+            wantStatus: http.StatusInternalServerError,
+        },
+        {
+            name:       "Неподдерживаемый метод",
+            method:     http.MethodPost,
+            path:       "/order/any",
+            repo:       repository.NewMemory(),
+            wantStatus: http.StatusMethodNotAllowed,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            a := New(Options{}, tt.repo, cache.New(10), nil)
+            if tt.setup != nil {
+                tt.setup(a)
+            }
+
+            method := tt.method
+            if method == "" {
+                method = http.MethodGet
+            }
+            rec := httptest.NewRecorder()
+            a.Handler().ServeHTTP(rec, httptest.NewRequest(method, tt.path, nil))
+
+            if rec.Code != tt.wantStatus {
+                t.Fatalf("Ожидался статус %d, получили %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+            }
+            if tt.wantUID == "" {
+                return
+            }
+
+            if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+                t.Errorf("Ожидался Content-Type application/json, получили %q", ct)
+            }
+            var response dto.OrderResponse
+            if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+                t.Fatalf("Ответ не является JSON заказа: %v", err)
+            }
+            if response.OrderUID != tt.wantUID {
+                t.Errorf("Ожидался заказ %s, получили %s", tt.wantUID, response.OrderUID)
+            }
+        })
+    }
+}
+
+func TestGetOrderHandler_CachesOrderFromRepository(t *testing.T) {
+    repo := repository.NewMemory()
+    repo.Save(context.Background(), repotest.SampleOrder("stored"))
+    a := New(Options{}, repo, cache.New(10), nil)
+
+    rec := httptest.NewRecorder()
+    a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/stored", nil))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("Ожидался статус 200, получили %d", rec.Code)
+    }
+
+    if _, ok := a.cache.Get("stored"); !ok {
+        t.Error("Заказ, прочитанный из БД, должен попасть в кэш")
+    }
+}
+
+func TestGetOrderHandler_Display(t *testing.T) {
+    repo := repository.NewMemory()
+    repo.Save(context.Background(), repotest.SampleOrder("display"))
+    a := New(Options{}, repo, cache.New(10), nil)
+
+    rec := httptest.NewRecorder()
+    a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/display", nil))
+    var response dto.OrderResponse
+    if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+        t.Fatalf("Ответ не является JSON заказа: %v", err)
+    }
+
+    if response.DateCreated != "2021-11-26T06:22:19Z" || response.Payment.PaymentDt != 1637907727 {
+        t.Errorf("Даты для программ: %q, %d", response.DateCreated, response.Payment.PaymentDt)
+    }
+    if response.Payment.Amount != 1817 || response.Payment.Exponent != 2 {
+        t.Errorf("Сумма для программ: %d, знаков %d", response.Payment.Amount, response.Payment.Exponent)
+    }
+    if got := response.Payment.Display.Amount; got != "18.17 USD" {
+        t.Errorf("Ожидалась сумма 18.17 USD, получили %q", got)
+    }
+    if got := response.Items[0].Display.TotalPrice; got != "3.17 USD" {
+        t.Errorf("Ожидалась цена 3.17 USD, получили %q", got)
+    }
+    if got := response.Display.DateCreated; got != "26.11.2021 06:22:19 UTC" {
+        t.Errorf("Ожидалась дата 26.11.2021 06:22:19 UTC, получили %q", got)
+    }
+}
+
+func TestGetOrderHandler_Accept(t *testing.T) {
+    repo := repository.NewMemory()
+    repo.Save(context.Background(), repotest.SampleOrder("formats"))
+    a := New(Options{}, repo, cache.New(10), nil)
+
+    get := func(accept string, query ...string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/order/formats"+strings.Join(query, ""), nil)
+        req.Header.Set("Accept", accept)
+        rec := httptest.NewRecorder()
+        a.Handler().ServeHTTP(rec, req)
+        return rec
+    }
+
+    rec := get("application/x-protobuf")
+    if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-protobuf" {
+        t.Fatalf("Protobuf: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+    }
+    if order, err := codec.UnmarshalOrder(codec.Protobuf, rec.Body.Bytes()); err != nil || order.OrderUID != "formats" {
+        t.Errorf("Protobuf: %+v, %v", order, err)
+    }
+    // Схема Protobuf не передаёт неизвестные поля и пересчёт: запрошенное не игнорируется молча.
+    for _, query := range []string{"?extra=true", "?currency=EUR"} {
+        if rec := get("application/x-protobuf", query); rec.Code != http.StatusNotAcceptable {
+            t.Errorf("Protobuf%s: ожидался статус 406, получили %d", query, rec.Code)
+        }
+    }
+
+    rec = get("application/msgpack")
+    var response map[string]any
+    if err := msgpack.Unmarshal(rec.Body.Bytes(), &response); err != nil || response["order_uid"] != "formats" || response["status"] == nil {
+        t.Errorf("MessagePack: %v, %v", response, err)
+    }
+
+    if rec := get("text/html"); rec.Code != http.StatusNotAcceptable {
+        t.Errorf("Ожидался статус 406, получили %d", rec.Code)
+    }
+}
+
+func TestGetOrderHandler_Extra(t *testing.T) {
+    repo := repository.NewMemory()
+    a := New(Options{}, repo, cache.New(10), nil)
+    if err := a.processOrder(context.Background(), []byte(`{"order_uid": "extra", "loyalty": "gold", "items": [{"rid": "a", "color": "red"}]}`)); err != nil {
+        t.Fatal(err)
+    }
+
+    get := func(target string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+        rec := httptest.NewRecorder()
+        a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+        var body map[string]json.RawMessage
+        json.Unmarshal(rec.Body.Bytes(), &body)
+        return rec, body
+    }
+
+    if _, body := get("/order/extra"); body["extra"] != nil {
+        t.Errorf("extra без запроса: %s", body["extra"])
+    }
+    // Заказ берётся из кэша: неизвестные поля должны пережить и его.
+    if _, body := get("/order/extra?extra=true"); string(body["extra"]) != `{"order":{"loyalty":"gold"},"items":[{"color":"red"}]}` {
+        t.Errorf("extra: %s", body["extra"])
+    }
+    if rec, _ := get("/order/extra?extra=yes"); rec.Code != http.StatusBadRequest {
+        t.Errorf("Ожидался статус 400, получили %d", rec.Code)
+    }
+}
+
+func TestRatesHandlers(t *testing.T) {
+    repo := repository.NewMemory()
+    repo.Save(context.Background(), repotest.SampleOrder("converted"))
+    book := rates.NewBook(rates.NewMemory())
+    a := New(Options{
+        APIKeys:           func() []string { return []string{"key"} },
+        Rates:             book,
+        ReportingCurrency: "RUB",
+    }, repo, cache.New(10), nil)
+
+    do := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(method, target, strings.NewReader(body))
+        req.Header.Set("X-API-Key", "key")
+        if contentType != "" {
+            req.Header.Set("Content-Type", contentType)
+        }
+        rec := httptest.NewRecorder()
+        a.Handler().ServeHTTP(rec, req)
+        return rec
+    }
+    converted := func(target string) (*httptest.ResponseRecorder, *dto.ConvertedInfo) {
+        rec := do(http.MethodGet, target, "", "")
+        var response dto.OrderResponse
+        json.Unmarshal(rec.Body.Bytes(), &response)
+        return rec, response.Converted
+    }
+
+    // Без курса для валюты отчётности заказ отдаётся без пересчёта.
+    if rec, c := converted("/order/converted"); rec.Code != http.StatusOK || c != nil {
+        t.Errorf("Без курсов: %d, %+v", rec.Code, c)
+    }
+
+    rec := do(http.MethodPost, "/admin/rates", "text/csv; charset=utf-8",
+        "currency,quote,rate,valid_from\nUSD,RUB,92.5,2021-01-01\n")
+    if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"saved":1}` {
+        t.Fatalf("Загрузка CSV: %d %s", rec.Code, rec.Body.String())
+    }
+    rec = do(http.MethodPost, "/admin/rates", "application/json",
+        `[{"currency": "EUR", "quote": "USD", "rate": "1.25", "valid_from": "2021-01-01"}]`)
+    if rec.Code != http.StatusOK {
+        t.Fatalf("Загрузка JSON: %d %s", rec.Code, rec.Body.String())
+    }
+    if rec := do(http.MethodPost, "/admin/rates", "application/json", `[{"currency": "EUR"}]`); rec.Code != http.StatusBadRequest {
+        t.Errorf("Некорректный курс: ожидался статус 400, получили %d", rec.Code)
+    }
+
+    var list []rates.Rate
+    if rec := do(http.MethodGet, "/admin/rates", "", ""); json.Unmarshal(rec.Body.Bytes(), &list) != nil || len(list) != 2 {
+        t.Errorf("Список курсов: %s", rec.Body.String())
+    }
+
+    // 18.17 USD по курсу 92.5 - 1680.725 RUB.
+    if _, c := converted("/order/converted"); c == nil || c.Currency != "RUB" || c.Amount != 168073 || c.Rate != "92.5" ||
+        c.Display.Amount != "1680.73 RUB" {
+        t.Errorf("Пересчёт в валюту отчётности: %+v", c)
+    }
+    if _, c := converted("/order/converted?currency=EUR"); c == nil || c.Amount != 1454 || c.Rate != "0.8" {
+        t.Errorf("Пересчёт в EUR: %+v", c)
+    }
+    if rec, _ := converted("/order/converted?currency=JPY"); rec.Code != http.StatusUnprocessableEntity {
+        t.Errorf("Нет курса: ожидался статус 422, получили %d", rec.Code)
+    }
+    if rec, _ := converted("/order/converted?currency=rub"); rec.Code != http.StatusBadRequest {
+        t.Errorf("Некорректная валюта: ожидался статус 400, получили %d", rec.Code)
+    }
+}
+
+func TestAnalyticsHandlers(t *testing.T) {
+    repo := repository.NewMemory()
+    repo.Save(context.Background(), repotest.SampleOrder("analytics"))
+    a := New(Options{APIKeys: func() []string { return []string{"key"} }}, repo, cache.New(10), nil)
+
+    get := func(target string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, target, nil)
+        req.Header.Set("X-API-Key", "key")
+        rec := httptest.NewRecorder()
+        a.Handler().ServeHTTP(rec, req)
+        return rec
+    }
+
+    rec := get("/analytics/revenue?period=month&from=2021-11-01&to=2021-12-01")
+    var revenue analytics.RevenueReport
+    if err := json.Unmarshal(rec.Body.Bytes(), &revenue); err != nil || len(revenue.Series) != 1 || revenue.Series[0].Revenue != 1817 {
+        t.Errorf("Выручка: %d %s", rec.Code, rec.Body.String())
+    }
+    rec = get("/analytics/top?by=nm_id&metric=units")
+    var top analytics.TopReport
+    if err := json.Unmarshal(rec.Body.Bytes(), &top); err != nil || len(top.Entries) != 1 || top.Entries[0].Key != "2389212" {
+        t.Errorf("Топ: %d %s", rec.Code, rec.Body.String())
+    }
+    rec = get("/analytics/breakdown?by=city&delivery_service=meest")
+    var breakdown analytics.BreakdownReport
+    if err := json.Unmarshal(rec.Body.Bytes(), &breakdown); err != nil || len(breakdown.Entries) != 1 || breakdown.Entries[0].AverageSale != 30 {
+        t.Errorf("Разрез: %d %s", rec.Code, rec.Body.String())
+    }
+
+    for _, target := range []string{
+        "/analytics/revenue?period=year",
+        "/analytics/revenue?from=вчера",
+        "/analytics/revenue?currency=RUB",
+        "/analytics/top?by=bank",
+        "/analytics/top?limit=0",
+        "/analytics/breakdown",
+    } {
+        if rec := get(target); rec.Code != http.StatusBadRequest {
+            t.Errorf("%s: ожидался статус 400, получили %d", target, rec.Code)
+        }
+    }
+}
+
+func TestProcessOrder(t *testing.T) {
+    model := repotest.SampleOrder("from-nats")
+    valid, _ := json.Marshal(model)
+
+    wrapped := []byte(`{"type": "order", "schema_version": 1, "message_id": "m-1", "payload": ` + string(valid) + `}`)
+    protoData, _ := codec.MarshalOrder(codec.Protobuf, model)
+    future := []byte(`{"type": "order", "schema_version": 99, "payload": ` + string(valid) + `}`)
+
+    tests := []struct {
+        name    string
+        data    []byte
+        wantErr bool
+        // retry - ошибка временная, сообщение нужно доставить повторно.
+        retry bool
+    }{
+        {"Корректный заказ", valid, false, false},
+        {"Заказ в конверте", wrapped, false, false},
+        {"Protobuf", codec.Frame(codec.Protobuf, protoData), false, false},
+        {"Схема новее поддерживаемой", future, true, true},
+        {"Некорректный JSON", []byte("{"), true, false},
+        {"Пустой order_uid", []byte(`{"order_uid": ""}`), true, false},
+        {"Некорректная дата", []byte(`{"order_uid": "x", "date_created": "вчера"}`), true, false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            repo := repository.NewMemory()
+            a := New(Options{}, repo, cache.New(10), nil)
+
+            err := a.processOrder(context.Background(), tt.data)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Ожидалась ошибка: %v, получили %v", tt.wantErr, err)
+            }
+            if tt.wantErr {
+                if errors.Is(err, errInvalidMessage) == tt.retry {
+                    t.Errorf("Сообщение с ошибкой %v должно подтверждаться: %v", err, !tt.retry)
+                }
+                return
+            }
+            if _, err := repo.GetByUID(context.Background(), model.OrderUID); err != nil {
+                t.Errorf("Заказ должен быть сохранён: %v", err)
+            }
+            if _, ok := a.cache.Get(model.OrderUID); !ok {
+                t.Error("Заказ должен быть в кэше")
+            }
+        })
+    }
+}
+
+// Полный заказ, доставленный повторно после события, подтверждается и не откатывает событие.
+func TestProcessOrder_AfterEvents(t *testing.T) {
+    ctx := context.Background()
+    model := repotest.SampleOrder("redelivered")
+    data, _ := json.Marshal(model)
+    repo := repository.NewMemory()
+    a := New(Options{}, repo, cache.New(10), nil)
+    if err := a.processOrder(ctx, data); err != nil {
+        t.Fatalf("processOrder: %v", err)
+    }
+    _, err := repo.Update(ctx, model.OrderUID, 1, repository.Update{Apply: func(o *models.Order) error {
+        o.Payment.Amount = 2000
+        return nil
+    }})
+    if err != nil {
+        t.Fatalf("Update: %v", err)
+    }
+
+    if err := a.processOrder(ctx, data); err != nil {
+        t.Fatalf("Повторный заказ должен подтверждаться, получили %v", err)
+    }
+    if got, _ := repo.GetByUID(ctx, model.OrderUID); got.Payment.Amount != 2000 {
+        t.Errorf("Повторный заказ откатил событие: сумма %d", got.Payment.Amount)
+    }
+}
+
+func TestDebugVarsRequiresAPIKey(t *testing.T) {
+    keys := []string{"old-key"}
+    a := New(Options{APIKeys: func() []string { return keys }}, repository.NewMemory(), cache.New(10), nil)
+
+    tests := []struct {
+        name       string
+        header     string
+        value      string
+        wantStatus int
+    }{
+        {"Без ключа", "", "", http.StatusUnauthorized},
+        {"Неверный ключ", "X-API-Key", "wrong", http.StatusUnauthorized},
+        {"Ключ в X-API-Key", "X-API-Key", "old-key", http.StatusOK},
+        {"Ключ в Authorization", "Authorization", "Bearer old-key", http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+            if tt.header != "" {
+                req.Header.Set(tt.header, tt.value)
+            }
+            rec := httptest.NewRecorder()
+            a.Handler().ServeHTTP(rec, req)
+            if rec.Code != tt.wantStatus {
+                t.Errorf("Ожидался статус %d, получили %d", tt.wantStatus, rec.Code)
+            }
+        })
+    }
+
+    // Ключи запрашиваются на каждый запрос, поэтому замена применяется без перезапуска.
+    keys = []string{"new-key"}
+    req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+    req.Header.Set("X-API-Key", "old-key")
+    rec := httptest.NewRecorder()
+    a.Handler().ServeHTTP(rec, req)
+    if rec.Code != http.StatusUnauthorized {
+        t.Errorf("Старый ключ должен перестать работать после замены, статус %d", rec.Code)
+    }
+}
+
+func TestDebugVarsClosedWithoutAPIKeys(t *testing.T) {
+    for name, opts := range map[string]Options{
+        "Ключи не заданы":    {},
+        "Список ключей пуст": {APIKeys: func() []string { return nil }},
+    } {
+        t.Run(name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+            rec := httptest.NewRecorder()
+            New(opts, repository.NewMemory(), cache.New(10), nil).Handler().ServeHTTP(rec, req)
+            if rec.Code != http.StatusServiceUnavailable {
+                t.Errorf("Ожидался статус %d, получили %d", http.StatusServiceUnavailable, rec.Code)
+            }
+        })
+    }
+
+    // В режиме разработки эндпоинты без ключей открываются явно.
+    rec := httptest.NewRecorder()
+    New(Options{OpenWithoutAPIKeys: true}, repository.NewMemory(), cache.New(10), nil).Handler().
+        ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+    if rec.Code != http.StatusOK {
+        t.Errorf("С OpenWithoutAPIKeys ожидался статус 200, получили %d", rec.Code)
+    }
+}
+
+func TestRateLimit(t *testing.T) {
+    repo := repository.NewMemory()
+    repo.Save(context.Background(), repotest.SampleOrder("order-1"))
+    a := New(Options{RateLimit: 1, RateBurst: 2}, repo, cache.New(10), nil)
+    handler := a.Handler()
+
+    get := func(remoteAddr string) int {
+        req := httptest.NewRequest(http.MethodGet, "/order/order-1", nil)
+        req.RemoteAddr = remoteAddr
+        rec := httptest.NewRecorder()
+        handler.ServeHTTP(rec, req)
+        return rec.Code
+    }
+
+    for i := 0; i < 2; i++ {
+        if code := get("10.0.0.1:1000"); code != http.StatusOK {
+            t.Fatalf("Запрос %d в пределах лимита: статус %d", i+1, code)
+        }
+    }
+    if code := get("10.0.0.1:1001"); code != http.StatusTooManyRequests {
+        t.Errorf("Ожидался статус 429 сверх лимита, получили %d", code)
+    }
+    if code := get("10.0.0.2:1000"); code != http.StatusOK {
+        t.Errorf("Лимит другого адреса не должен расходоваться, статус %d", code)
+    }
+
+    a.SetRateLimit(0, 0)
+    if code := get("10.0.0.1:1000"); code != http.StatusOK {
+        t.Errorf("После отключения лимита ожидался статус 200, получили %d", code)
+    }
+}
+
+func TestReloadHandler(t *testing.T) {
+    tests := []struct {
+        name       string
+        result     reload.Result
+        err        error
+        wantStatus int
+    }{
+        {"Применено", reload.Result{Applied: []string{"cache.capacity"}}, nil, http.StatusOK},
+        {"Нужен перезапуск", reload.Result{Rejected: []string{`http.port: "8080" -> "9090"`}}, reload.ErrRestartRequired, http.StatusConflict},
+        {"Некорректная конфигурация", reload.Result{}, errors.New("cache.capacity: должно быть больше нуля"), http.StatusUnprocessableEntity},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            a := New(Options{
+                APIKeys: func() []string { return []string{"key"} },
+                Reload:  func() (reload.Result, error) { return tt.result, tt.err },
+            }, repository.NewMemory(), cache.New(10), nil)
+
+            req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+            rec := httptest.NewRecorder()
+            a.Handler().ServeHTTP(rec, req)
+            if rec.Code != http.StatusUnauthorized {
+                t.Fatalf("Перезагрузка без ключа должна быть запрещена, статус %d", rec.Code)
+            }
+
+            req = httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+            req.Header.Set("X-API-Key", "key")
+            rec = httptest.NewRecorder()
+            a.Handler().ServeHTTP(rec, req)
+            if rec.Code != tt.wantStatus {
+                t.Errorf("Ожидался статус %d, получили %d", tt.wantStatus, rec.Code)
+            }
+            var body struct {
+                Applied  []string `json:"applied"`
+                Rejected []string `json:"rejected"`
+                Error    string   `json:"error"`
+            }
+            if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+                t.Fatalf("Некорректный JSON: %v", err)
+            }
+            if len(body.Rejected) != len(tt.result.Rejected) || (tt.err != nil) != (body.Error != "") {
+                t.Errorf("Неожиданный ответ: %s", rec.Body.String())
+            }
+        })
+    }
+
+    // Без настроенных ключей перезагрузка закрыта, а не открыта всем.
+    reloaded := false
+    a := New(Options{Reload: func() (reload.Result, error) {
+        reloaded = true
+        return reload.Result{}, nil
+    }}, repository.NewMemory(), cache.New(10), nil)
+    rec := httptest.NewRecorder()
+    a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+    if rec.Code != http.StatusServiceUnavailable || reloaded {
+        t.Errorf("Без ключей ожидался статус 503 без перезагрузки, получили %d", rec.Code)
+    }
+}
+
+func TestWebAssets(t *testing.T) {
+    files := fstest.MapFS{"index.html": {Data: []byte("<html>Order Hub</html>")}}
+    a := New(Options{Web: files}, repository.NewMemory(), cache.New(10), nil)
+
+    rec := httptest.NewRecorder()
+    a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Order Hub") {
+        t.Errorf("Ожидалась страница веб-интерфейса, получили %d: %s", rec.Code, rec.Body.String())
+    }
+
+    rec = httptest.NewRecorder()
+    New(Options{}, repository.NewMemory(), cache.New(10), nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+    if rec.Code != http.StatusNotFound {
+        t.Errorf("Без статики ожидался статус 404, получили %d", rec.Code)
+    }
+}
+
+func TestVerifyHandler(t *testing.T) {
+    repo := repository.NewMemory()
+    repo.Save(context.Background(), repotest.SampleOrder("stored"))
+    orderCache := cache.New(10)
+    orderCache.Set("stored", `{"order_uid":"stored"}`)
+    a := New(Options{APIKeys: func() []string { return []string{"key"} }}, repo, orderCache, nil)
+
+    post := func(query string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/admin/verify"+query, nil)
+        req.Header.Set("X-API-Key", "key")
+        rec := httptest.NewRecorder()
+        a.Handler().ServeHTTP(rec, req)
+        return rec
+    }
+    report := func(rec *httptest.ResponseRecorder) verify.Report {
+        t.Helper()
+        if rec.Code != http.StatusOK {
+            t.Fatalf("Ожидался статус 200, получили %d: %s", rec.Code, rec.Body.String())
+        }
+        var r verify.Report
+        if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+            t.Fatalf("Некорректный JSON: %v", err)
+        }
+        return r
+    }
+
+    for _, query := range []string{"?sample=2", "?repair=maybe"} {
+        if rec := post(query); rec.Code != http.StatusBadRequest {
+            t.Errorf("%s: ожидался статус 400, получили %d", query, rec.Code)
+        }
+    }
+
+    r := report(post(""))
+    if r.Checked != 1 || r.CacheChecked != 1 || len(r.Problems) == 0 || r.Repaired != 0 {
+        t.Fatalf("Неожиданный отчёт: %+v", r)
+    }
+    r = report(post("?repair=true"))
+    if r.Unrepaired() != 0 {
+        t.Errorf("Кэш должен быть исправлен: %+v", r.Problems)
+    }
+    if r = report(post("")); len(r.Problems) != 0 {
+        t.Errorf("После исправления нарушений быть не должно: %+v", r.Problems)
+    }
+}
+
+func TestGetRawOrderHandler(t *testing.T) {
+    store := archive.NewMemory()
+    received := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+    store.Save(context.Background(), archive.Message{Sequence: 7, Subject: "orders", OrderUID: "a", ReceivedAt: received, Data: []byte("not json")})
+    store.Save(context.Background(), archive.Message{Sequence: 9, Subject: "orders", Redelivered: true, OrderUID: "a", ReceivedAt: received.Add(time.Minute), Data: []byte(`{"order_uid": "a"}`)})
+    a := New(Options{Archive: store, APIKeys: func() []string { return []string{"key"} }}, repository.NewMemory(), cache.New(10), nil)
+
+    get := func(path string, key string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, path, nil)
+        req.Header.Set("X-API-Key", key)
+        rec := httptest.NewRecorder()
+        a.Handler().ServeHTTP(rec, req)
+        return rec
+    }
+
+    tests := []struct {
+        name       string
+        path       string
+        key        string
+        wantStatus int
+        wantBody   string
+        wantSeq    string
+    }{
+        {"Без ключа", "/order/a/raw", "", http.StatusUnauthorized, "", ""},
+        {"Последнее сообщение", "/order/a/raw", "key", http.StatusOK, `{"order_uid": "a"}`, "9"},
+        {"По номеру", "/order/a/raw?sequence=7", "key", http.StatusOK, "not json", "7"},
+        {"Нет сообщения с номером", "/order/a/raw?sequence=8", "key", http.StatusNotFound, "", ""},
+        {"Некорректный номер", "/order/a/raw?sequence=x", "key", http.StatusBadRequest, "", ""},
+        {"Нет сообщений", "/order/b/raw", "key", http.StatusNotFound, "", ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := get(tt.path, tt.key)
+            if rec.Code != tt.wantStatus {
+                t.Fatalf("Ожидался статус %d, получили %d", tt.wantStatus, rec.Code)
+            }
+            if tt.wantStatus != http.StatusOK {
+                return
+            }
+            if rec.Body.String() != tt.wantBody || rec.Header().Get("X-Nats-Sequence") != tt.wantSeq {
+                t.Errorf("Неожиданный ответ %q, заголовки %v", rec.Body.String(), rec.Header())
+            }
+        })
+    }
+
+    rec := get("/order/a/raw", "key")
+    if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("X-Nats-Redelivered") != "true" ||
+        rec.Header().Get("X-Messages-Total") != "2" {
+        t.Errorf("Неожиданные заголовки: %v", rec.Header())
+    }
+    if rec = get("/order/a/raw?sequence=7", "key"); rec.Header().Get("Content-Type") != "application/octet-stream" {
+        t.Errorf("Не-JSON сообщение должно отдаваться как octet-stream: %v", rec.Header())
+    }
+}
+
+func TestStatusHandlers(t *testing.T) {
+    repo := repository.NewMemory()
+    order := repotest.SampleOrder("a")
+    order.Items = append(order.Items, order.Items[0])
+    order.Items[0].Status, order.Items[1].Status = models.ItemAccepted, models.ItemAccepted
+    order.Items[1].RID = "second"
+    repo.Save(context.Background(), order)
+    orderCache := cache.New(10)
+    a := New(Options{APIKeys: func() []string { return []string{"key"} }}, repo, orderCache, nil)
+    a.cacheOrder(order)
+
+    patch := func(path, body, key string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+        req.Header.Set("X-API-Key", key)
+        rec := httptest.NewRecorder()
+        a.Handler().ServeHTTP(rec, req)
+        return rec
+    }
+
+    tests := []struct {
+        name       string
+        path       string
+        body       string
+        key        string
+        wantStatus int
+    }{
+        {"Без ключа", "/order/a/status", `{"status": "assembled"}`, "", http.StatusUnauthorized},
+        {"Некорректное тело", "/order/a/status", `{"status": `, "key", http.StatusBadRequest},
+        {"Неизвестный статус", "/order/a/status", `{"status": "lost"}`, "key", http.StatusBadRequest},
+        {"Нет заказа", "/order/b/status", `{"status": "assembled"}`, "key", http.StatusNotFound},
+        {"Нет товара", "/order/a/status", `{"rid": "x", "status": "assembled"}`, "key", http.StatusNotFound},
+        {"Недопустимый переход", "/order/a/status", `{"status": "delivered"}`, "key", http.StatusConflict},
+        {"Один товар по коду", "/order/a/status", `{"rid": "second", "status": 201, "comment": "упакован"}`, "key", http.StatusOK},
+        {"Все товары по имени", "/order/a/status", `{"status": "assembled"}`, "key", http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if rec := patch(tt.path, tt.body, tt.key); rec.Code != tt.wantStatus {
+                t.Fatalf("Ожидался статус %d, получили %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+            }
+        })
+    }
+
+    var cached models.Order
+    value, _ := orderCache.Get("a")
+    json.Unmarshal([]byte(value), &cached)
+    if cached.Items[0].Status != models.ItemAssembled || cached.Items[1].Status != models.ItemAssembled {
+        t.Errorf("Кэш не обновлён после смены статуса: %+v", cached.Items)
+    }
+
+    rec := httptest.NewRecorder()
+    a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/a/status", nil))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("Ожидался статус 200, получили %d", rec.Code)
+    }
+    var response dto.StatusResponse
+    if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+        t.Fatal(err)
+    }
+    if response.Status != string(models.OrderAssembling) || len(response.Items) != 2 {
+        t.Errorf("Неожиданные статусы: %+v", response)
+    }
+    // Два товара при сохранении заказа и два перехода через API.
+    if len(response.History) != 4 {
+        t.Fatalf("Ожидалось 4 перехода в истории, получили %+v", response.History)
+    }
+    if last := response.History[2]; last.RID != "second" || last.Source != models.StatusSourceAPI || last.Comment != "упакован" || last.FromName != "accepted" {
+        t.Errorf("Неожиданный переход: %+v", last)
+    }
+}
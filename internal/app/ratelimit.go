@@ -0,0 +1,92 @@
+package app
+
+import (
+    "net"
+    "net/http"
+    "sync"
+    "time"
+
+    "golang.org/x/time/rate"
+)
+
+// limiterIdleTTL - через сколько забывать адрес, с которого не было запросов.
+const limiterIdleTTL = 10 * time.Minute
+
+// rateLimiter ограничивает частоту запросов с каждого IP-адреса.
+// Лимит можно менять на ходу: новые значения применяются и к уже известным адресам.
+type rateLimiter struct {
+    mu      sync.Mutex
+    limit   rate.Limit
+    burst   int
+    clients map[string]*client
+    swept   time.Time
+}
+
+type client struct {
+    limiter  *rate.Limiter
+    lastSeen time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+    l := &rateLimiter{clients: make(map[string]*client)}
+    l.set(rps, burst)
+    return l
+}
+
+// set меняет лимит. rps <= 0 отключает ограничение.
+func (l *rateLimiter) set(rps float64, burst int) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.limit = rate.Limit(rps)
+    if rps <= 0 {
+        l.limit = rate.Inf
+    }
+    l.burst = burst
+    for _, c := range l.clients {
+        c.limiter.SetLimit(l.limit)
+        c.limiter.SetBurst(burst)
+    }
+}
+
+func (l *rateLimiter) allow(addr string) bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if l.limit == rate.Inf {
+        return true
+    }
+
+    now := time.Now()
+    if now.Sub(l.swept) > limiterIdleTTL {
+        for key, c := range l.clients {
+            if now.Sub(c.lastSeen) > limiterIdleTTL {
+                delete(l.clients, key)
+            }
+        }
+        l.swept = now
+    }
+
+    c, ok := l.clients[addr]
+    if !ok {
+        c = &client{limiter: rate.NewLimiter(l.limit, l.burst)}
+        l.clients[addr] = c
+    }
+    c.lastSeen = now
+    return c.limiter.AllowN(now, 1)
+}
+
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        host, _, err := net.SplitHostPort(r.RemoteAddr)
+        if err != nil {
+            host = r.RemoteAddr
+        }
+        if !l.allow(host) {
+            w.Header().Set("Retry-After", "1")
+            http.Error(w, "Слишком много запросов", http.StatusTooManyRequests)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
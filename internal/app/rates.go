@@ -0,0 +1,68 @@
+package app
+
+import (
+    "encoding/json"
+    "log"
+    "mime"
+    "net/http"
+
+    "wb-order-hub/internal/dto"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/rates"
+)
+
+const maxRatesRequestBytes = 1 << 20
+
+// convert пересчитывает суммы заказа в валюту currency или, если она не задана, в валюту
+// отчётности. Ошибкой считается только отсутствие курса для явно запрошенной валюты:
+// без курса для валюты отчётности ответ отдаётся без пересчёта.
+func (a *App) convert(order models.Order, currency string) (*dto.ConvertedInfo, error) {
+    if a.opts.Rates == nil {
+        return nil, nil
+    }
+    requested := currency != ""
+    if !requested {
+        currency = a.opts.ReportingCurrency
+    }
+    if currency == "" {
+        return nil, nil
+    }
+    converted, err := dto.ToConverted(order, a.opts.Rates.Table(), currency)
+    if err != nil && !requested {
+        return nil, nil
+    }
+    return converted, err
+}
+
+// getRatesHandler отдаёт все загруженные курсы.
+func (a *App) getRatesHandler(w http.ResponseWriter, r *http.Request) {
+    list := a.opts.Rates.Table().Rates()
+    if list == nil {
+        list = []rates.Rate{}
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(list)
+}
+
+// postRatesHandler загружает курсы из тела запроса: CSV с Content-Type text/csv, иначе JSON.
+// Загрузка применяется целиком или, при ошибке в любом курсе, не применяется.
+func (a *App) postRatesHandler(w http.ResponseWriter, r *http.Request) {
+    format := rates.FormatJSON
+    if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+        format = rates.FormatCSV
+    }
+    list, err := rates.Parse(http.MaxBytesReader(w, r.Body, maxRatesRequestBytes), format)
+    if err != nil {
+        http.Error(w, "Некорректные курсы: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    if err := a.opts.Rates.Add(r.Context(), list); err != nil {
+        log.Printf("Не удалось сохранить курсы валют: %v", err)
+        http.Error(w, "Ошибка сохранения курсов", http.StatusInternalServerError)
+        return
+    }
+    log.Printf("Загружено курсов валют: %d", len(list))
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]int{"saved": len(list)})
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+    "encoding/json"
+    "errors"
+    "log"
+    "net/http"
+    "strconv"
+
+    "github.com/gorilla/mux"
+    "wb-order-hub/internal/dto"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// statusRequest - тело PATCH /order/{id}/status. Status - имя статуса (in_transit) или его код.
+type statusRequest struct {
+    RID     string          `json:"rid"`
+    Status  json.RawMessage `json:"status"`
+    Comment string          `json:"comment"`
+}
+
+const maxStatusRequestBytes = 4 << 10
+
+// getStatusHandler отдаёт статусы заказа и товаров с историей переходов.
+func (a *App) getStatusHandler(w http.ResponseWriter, r *http.Request) {
+    orderID := mux.Vars(r)["id"]
+    if orderID == "" || len(orderID) > models.MaxOrderUIDLength {
+        http.Error(w, "Некорректный ID заказа", http.StatusBadRequest)
+        return
+    }
+    order, err := a.repo.GetByUID(r.Context(), orderID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Заказ не найден", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        log.Printf("Не удалось получить заказ %s из БД: %v", orderID, err)
+        http.Error(w, "Ошибка получения заказа", http.StatusInternalServerError)
+        return
+    }
+    history, err := a.statuses.StatusHistory(r.Context(), orderID)
+    if err != nil && !errors.Is(err, repository.ErrNotFound) {
+        log.Printf("Не удалось получить историю статусов заказа %s: %v", orderID, err)
+        http.Error(w, "Ошибка получения истории статусов", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(dto.ToStatusResponse(order, history))
+}
+
+// patchStatusHandler переводит товар (или все товары заказа, если rid не задан) в новый статус.
+// Недопустимый переход - 409. Переходы публикуются в канал статусов.
+func (a *App) patchStatusHandler(w http.ResponseWriter, r *http.Request) {
+    orderID := mux.Vars(r)["id"]
+    if orderID == "" || len(orderID) > models.MaxOrderUIDLength {
+        http.Error(w, "Некорректный ID заказа", http.StatusBadRequest)
+        return
+    }
+    var req statusRequest
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStatusRequestBytes)).Decode(&req); err != nil {
+        http.Error(w, "Некорректное тело запроса: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    status, err := parseStatusValue(req.Status)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    changes, err := a.statuses.ChangeStatus(r.Context(), orderID, repository.StatusUpdate{
+        RID:     req.RID,
+        Status:  status,
+        Source:  models.StatusSourceAPI,
+        Comment: req.Comment,
+    })
+    var transitionErr *models.TransitionError
+    switch {
+    case errors.Is(err, repository.ErrNotFound):
+        http.Error(w, "Заказ не найден", http.StatusNotFound)
+        return
+    case errors.Is(err, models.ErrItemNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    case errors.As(err, &transitionErr):
+        http.Error(w, err.Error(), http.StatusConflict)
+        return
+    case err != nil:
+        log.Printf("Не удалось сменить статус заказа %s: %v", orderID, err)
+        http.Error(w, "Ошибка смены статуса", http.StatusInternalServerError)
+        return
+    }
+
+    if len(changes) > 0 {
+        if order, err := a.repo.GetByUID(r.Context(), orderID); err == nil {
+            a.cacheOrder(order)
+        } else {
+            // Устаревшая запись кэша хуже промаха.
+            a.cache.Delete(orderID)
+        }
+    }
+    info := dto.ToStatusChanges(changes)
+    a.publishStatusChanges(info)
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(struct {
+        Changes []dto.StatusChangeInfo `json:"changes"`
+    }{info})
+}
+
+// parseStatusValue принимает статус строкой ("in_transit", "202") или числом (202).
+func parseStatusValue(raw json.RawMessage) (models.ItemStatus, error) {
+    if len(raw) == 0 {
+        return 0, errors.New("status: не задан")
+    }
+    var name string
+    if err := json.Unmarshal(raw, &name); err != nil {
+        var code int
+        if err := json.Unmarshal(raw, &code); err != nil {
+            return 0, errors.New("status: ожидается имя или код статуса")
+        }
+        name = strconv.Itoa(code)
+    }
+    return models.ParseItemStatus(name)
+}
+
+// publishStatusChanges публикует переходы в Options.StatusChannel. Ошибка публикации
+// не отменяет смену статуса: она уже сохранена и видна в истории.
+func (a *App) publishStatusChanges(changes []dto.StatusChangeInfo) {
+    if a.sc == nil || a.opts.StatusChannel == "" {
+        return
+    }
+    for _, change := range changes {
+        data, err := json.Marshal(change)
+        if err != nil {
+            continue
+        }
+        if err := a.sc.Publish(a.opts.StatusChannel, data); err != nil {
+            log.Printf("Не удалось опубликовать смену статуса заказа %s в %s: %v", change.OrderUID, a.opts.StatusChannel, err)
+        }
+    }
+}
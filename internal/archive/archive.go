@@ -0,0 +1,261 @@
+// Package archive хранит исходные сообщения из канала заказов вместе с метаданными NATS,
+// чтобы можно было увидеть, что именно прислал производитель.
+package archive
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "sort"
+    "sync"
+    "time"
+
+    "wb-order-hub/internal/codec"
+    "wb-order-hub/internal/envelope"
+    "wb-order-hub/internal/events"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// ErrNotFound возвращается, если по заказу нет сохранённых сообщений.
+var ErrNotFound = errors.New("исходные сообщения заказа не найдены")
+
+// Message - сообщение в том виде, в каком оно пришло из канала.
+type Message struct {
+    Sequence    uint64    `json:"sequence"`
+    Subject     string    `json:"subject"`
+    Redelivered bool      `json:"redelivered"`
+    ReceivedAt  time.Time `json:"received_at"`
+    // OrderUID пуст, если из сообщения не удалось извлечь order_uid.
+    OrderUID string `json:"order_uid,omitempty"`
+    Data     []byte `json:"-"`
+}
+
+// Store - хранилище исходных сообщений.
+type Store interface {
+    Save(ctx context.Context, msg Message) error
+    // Messages возвращает сообщения заказа от последнего полученного к первому
+    // или ErrNotFound, если их нет.
+    Messages(ctx context.Context, uid string) ([]Message, error)
+    // Prune удаляет сообщения, полученные раньше before, и возвращает их число.
+    Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// OrderUID извлекает order_uid из сообщения, в конверте или без него, не проверяя остальные поля.
+func OrderUID(data []byte) string {
+    format, payload := envelope.Unwrap(data)
+    if format != codec.JSON {
+        order, err := codec.UnmarshalOrder(format, payload)
+        if err != nil || len(order.OrderUID) > models.MaxOrderUIDLength {
+            return ""
+        }
+        return order.OrderUID
+    }
+    var v struct {
+        OrderUID string `json:"order_uid"`
+    }
+    if json.Unmarshal(payload, &v) != nil || len(v.OrderUID) > models.MaxOrderUIDLength {
+        return ""
+    }
+    return v.OrderUID
+}
+
+// Compression - способ сжатия сообщений в хранилище.
+type Compression string
+
+const (
+    CompressionNone Compression = "none"
+    CompressionGzip Compression = "gzip"
+)
+
+// ParseCompression разбирает значение archive.compression. Пустая строка - CompressionNone.
+func ParseCompression(s string) (Compression, error) {
+    switch c := Compression(s); c {
+    case "":
+        return CompressionNone, nil
+    case CompressionNone, CompressionGzip:
+        return c, nil
+    }
+    return "", fmt.Errorf("неизвестное сжатие %q, ожидается none или gzip", s)
+}
+
+// Compress сжимает data. Способ сжатия хранится рядом с данными, чтобы смена
+// настройки не мешала читать уже сохранённые сообщения.
+func Compress(data []byte, c Compression) ([]byte, error) {
+    if c != CompressionGzip {
+        return data, nil
+    }
+    var buf bytes.Buffer
+    zw := gzip.NewWriter(&buf)
+    if _, err := zw.Write(data); err != nil {
+        return nil, err
+    }
+    if err := zw.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// Decompress восстанавливает данные, сжатые Compress.
+func Decompress(data []byte, c Compression) ([]byte, error) {
+    switch c {
+    case "", CompressionNone:
+        return data, nil
+    case CompressionGzip:
+        zr, err := gzip.NewReader(bytes.NewReader(data))
+        if err != nil {
+            return nil, err
+        }
+        defer zr.Close()
+        return io.ReadAll(zr)
+    }
+    return nil, fmt.Errorf("неизвестное сжатие %q", c)
+}
+
+// Source - исходные сообщения как источник истины для verify.Consistency: заказ берётся
+// из последнего корректного сообщения с полным заказом, и к нему по порядку версий
+// применяются события заказа, полученные после него. Если полный заказ приходил один раз,
+// учитываются и события, полученные раньше: они ждали появления заказа.
+type Source struct {
+    Store Store
+}
+
+func (s Source) Original(ctx context.Context, uid string) (models.Order, error) {
+    messages, err := s.Store.Messages(ctx, uid)
+    if errors.Is(err, ErrNotFound) {
+        return models.Order{}, repository.ErrNotFound
+    }
+    if err != nil {
+        return models.Order{}, err
+    }
+
+    var order models.Order
+    found, orders := false, 0
+    var after, before []events.Event
+    for _, msg := range messages {
+        if events.TypeOf(msg.Subject) != "" {
+            if e, err := events.Parse(msg.Data, msg.Subject); err == nil {
+                if found {
+                    before = append(before, e)
+                } else {
+                    after = append(after, e)
+                }
+            }
+            continue
+        }
+        if parsed, _, err := envelope.ParseOrder(msg.Data); err == nil {
+            if !found {
+                order, found = parsed, true
+            }
+            orders++
+        }
+    }
+    if !found {
+        return models.Order{}, repository.ErrNotFound
+    }
+    // Версия заказа после последнего полного сообщения неизвестна, если оно не первое.
+    var version int64
+    if orders == 1 {
+        after = append(after, before...)
+        version = 1
+    }
+    replay(&order, version, after)
+    return order, nil
+}
+
+// replay применяет события непрерывной цепочкой версий после version (0 - с самого раннего
+// события), как их применил сервис: на пропуске версии или неприменимом событии цепочка обрывается.
+func replay(order *models.Order, version int64, list []events.Event) {
+    sort.SliceStable(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+    last := version
+    for _, e := range list {
+        if last != 0 && e.Version == last {
+            // Повторная доставка.
+            continue
+        }
+        if last != 0 && e.Version != last+1 {
+            return
+        }
+        if err := e.Apply(order); err != nil {
+            return
+        }
+        last = e.Version
+    }
+}
+
+// Prune раз в interval удаляет сообщения старше retention до отмены ctx.
+func Prune(ctx context.Context, store Store, retention, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        deleted, err := store.Prune(ctx, time.Now().Add(-retention))
+        switch {
+        case err != nil && ctx.Err() == nil:
+            log.Printf("Не удалось удалить устаревшие исходные сообщения: %v", err)
+        case deleted > 0:
+            log.Printf("Удалено исходных сообщений старше %s: %d", retention, deleted)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Memory - хранилище сообщений в памяти для режима разработки и тестов.
+type Memory struct {
+    mu       sync.Mutex
+    messages []Message
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+    return &Memory{}
+}
+
+func (m *Memory) Save(_ context.Context, msg Message) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    msg.Data = bytes.Clone(msg.Data)
+    m.messages = append(m.messages, msg)
+    return nil
+}
+
+func (m *Memory) Messages(_ context.Context, uid string) ([]Message, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    var result []Message
+    for i := len(m.messages) - 1; i >= 0; i-- {
+        if msg := m.messages[i]; msg.OrderUID == uid {
+            msg.Data = bytes.Clone(msg.Data)
+            result = append(result, msg)
+        }
+    }
+    if len(result) == 0 {
+        return nil, ErrNotFound
+    }
+    // Сообщения сохраняются по мере получения, но время получения задаёт вызывающий.
+    sort.SliceStable(result, func(i, j int) bool { return result[i].ReceivedAt.After(result[j].ReceivedAt) })
+    return result, nil
+}
+
+func (m *Memory) Prune(_ context.Context, before time.Time) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    kept := m.messages[:0]
+    for _, msg := range m.messages {
+        if !msg.ReceivedAt.Before(before) {
+            kept = append(kept, msg)
+        }
+    }
+    deleted := int64(len(m.messages) - len(kept))
+    m.messages = kept
+    return deleted, nil
+}
@@ -0,0 +1,121 @@
+package archive
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func TestCompress(t *testing.T) {
+    data := bytes.Repeat([]byte(`{"order_uid":"a"}`), 100)
+    for _, c := range []Compression{CompressionNone, CompressionGzip} {
+        packed, err := Compress(data, c)
+        if err != nil {
+            t.Fatalf("%s: %v", c, err)
+        }
+        if c == CompressionGzip && len(packed) >= len(data) {
+            t.Errorf("gzip не сжал данные: %d байт из %d", len(packed), len(data))
+        }
+        unpacked, err := Decompress(packed, c)
+        if err != nil || !bytes.Equal(unpacked, data) {
+            t.Errorf("%s: данные не восстановлены: %v", c, err)
+        }
+    }
+    if _, err := ParseCompression("zstd"); err == nil {
+        t.Error("Неизвестное сжатие должно давать ошибку")
+    }
+}
+
+func TestOrderUID(t *testing.T) {
+    tests := map[string]string{
+        `{"order_uid":"abc","date_created":"yesterday"}`: "abc",
+        `{"track_number":"T"}`:                           "",
+        `not json`:                                       "",
+    }
+    for data, want := range tests {
+        if got := OrderUID([]byte(data)); got != want {
+            t.Errorf("OrderUID(%s) = %q, ожидалось %q", data, got, want)
+        }
+    }
+}
+
+func TestMemory(t *testing.T) {
+    ctx := context.Background()
+    store := NewMemory()
+    if _, err := store.Messages(ctx, "a"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("Ожидалась ErrNotFound, получили %v", err)
+    }
+
+    now := time.Now()
+    valid, _ := json.Marshal(repotest.SampleOrder("a"))
+    store.Save(ctx, Message{Sequence: 1, OrderUID: "a", ReceivedAt: now.Add(-48 * time.Hour), Data: valid})
+    store.Save(ctx, Message{Sequence: 2, OrderUID: "a", ReceivedAt: now.Add(-time.Hour), Data: []byte(`{"order_uid":"a","date_created":"yesterday"}`)})
+    store.Save(ctx, Message{Sequence: 3, OrderUID: "b", ReceivedAt: now, Data: []byte(`{}`)})
+
+    messages, err := store.Messages(ctx, "a")
+    if err != nil || len(messages) != 2 || messages[0].Sequence != 2 {
+        t.Fatalf("Ожидались сообщения 2 и 1, получили %+v, %v", messages, err)
+    }
+
+    // Источник берёт последнее корректное сообщение.
+    order, err := Source{store}.Original(ctx, "a")
+    if err != nil || order.TrackNumber != repotest.SampleOrder("a").TrackNumber {
+        t.Errorf("Original: %+v, %v", order, err)
+    }
+    if _, err := (Source{store}).Original(ctx, "b"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Без корректного сообщения ожидалась repository.ErrNotFound, получили %v", err)
+    }
+
+    deleted, err := store.Prune(ctx, now.Add(-24*time.Hour))
+    if err != nil || deleted != 1 {
+        t.Errorf("Prune: удалено %d, %v", deleted, err)
+    }
+    if _, err := (Source{store}).Original(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("После удаления корректного сообщения ожидалась repository.ErrNotFound, получили %v", err)
+    }
+}
+
+func TestSource_ReplaysEvents(t *testing.T) {
+    ctx := context.Background()
+    store := NewMemory()
+    now := time.Now()
+    save := func(offset time.Duration, subject, data string) {
+        store.Save(ctx, Message{Subject: subject, OrderUID: "a", ReceivedAt: now.Add(offset), Data: []byte(data)})
+    }
+    order, _ := json.Marshal(repotest.SampleOrder("a"))
+    amount := func(version, amount int) string {
+        return fmt.Sprintf(`{"order_uid": "a", "version": %d, "payload": {"transaction": "a", "amount": %d}}`, version, amount)
+    }
+
+    // Событие версии 3 пришло раньше заказа и ждало его, версия 2 - после заказа.
+    save(0, "orders.events.payment.updated", amount(3, 300))
+    save(time.Second, "orders", string(order))
+    save(2*time.Second, "orders.events.payment.updated", amount(2, 200))
+    save(3*time.Second, "orders.events.payment.updated", amount(2, 200))
+    save(4*time.Second, "orders.events.payment.updated", amount(5, 500))
+
+    got, err := Source{store}.Original(ctx, "a")
+    if err != nil || got.Payment.Amount != 300 {
+        t.Errorf("Ожидалась сумма из события версии 3 (версия 5 ждёт версию 4), получили %d, %v", got.Payment.Amount, err)
+    }
+
+    // Повторный полный заказ: события до него уже учтены в нём самом.
+    resent := repotest.SampleOrder("a")
+    resent.Payment.Amount = 700
+    data, _ := json.Marshal(resent)
+    save(5*time.Second, "orders", string(data))
+    if got, _ := (Source{store}).Original(ctx, "a"); got.Payment.Amount != 700 {
+        t.Errorf("Ожидалась сумма из последнего полного заказа, получили %d", got.Payment.Amount)
+    }
+    save(6*time.Second, "orders.events.payment.updated", amount(6, 600))
+    if got, _ := (Source{store}).Original(ctx, "a"); got.Payment.Amount != 600 {
+        t.Errorf("Ожидалась сумма из события после полного заказа, получили %d", got.Payment.Amount)
+    }
+}
@@ -0,0 +1,111 @@
+// Package broker подключается к NATS Streaming с аутентификацией и TLS из конфигурации.
+package broker
+
+import (
+    "errors"
+    "fmt"
+
+    "github.com/nats-io/nats.go"
+    "github.com/nats-io/nkeys"
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/config"
+)
+
+// Conn - соединение NATS Streaming, которое владеет нижележащим соединением NATS.
+type Conn struct {
+    stan.Conn
+    nc *nats.Conn
+}
+
+// Close закрывает соединение NATS Streaming, затем соединение NATS.
+// Durable-подписки при этом сохраняются на сервере.
+func (c *Conn) Close() error {
+    err := c.Conn.Close()
+    c.nc.Close()
+    return err
+}
+
+// NATS возвращает нижележащее соединение NATS, например для публикации в обычные subject'ы.
+func (c *Conn) NATS() *nats.Conn {
+    return c.nc
+}
+
+// Connect подключается к NATS по параметрам cfg и открывает поверх соединение NATS Streaming
+// с указанным clientID. Пароль, токен и seed nkey читаются из secrets при каждом
+// (пере)подключении, поэтому их обновление подхватывается без перезапуска.
+func Connect(cfg config.NATSConfig, clientID string, secrets *config.SecretStore, opts ...stan.Option) (*Conn, error) {
+    natsOpts, err := natsOptions(cfg, clientID, secrets)
+    if err != nil {
+        return nil, err
+    }
+
+    nc, err := nats.Connect(cfg.URL, natsOpts...)
+    if err != nil {
+        return nil, fmt.Errorf("не удалось подключиться к NATS: %w", err)
+    }
+
+    opts = append(opts, stan.NatsConn(nc))
+    sc, err := stan.Connect(cfg.ClusterID, clientID, opts...)
+    if err != nil {
+        nc.Close()
+        return nil, fmt.Errorf("не удалось подключиться к NATS Streaming: %w", err)
+    }
+    return &Conn{Conn: sc, nc: nc}, nil
+}
+
+func natsOptions(cfg config.NATSConfig, clientID string, secrets *config.SecretStore) ([]nats.Option, error) {
+    opts := []nats.Option{
+        nats.Name(clientID),
+        nats.MaxReconnects(-1),
+    }
+
+    switch {
+    case cfg.User != "":
+        user := cfg.User
+        opts = append(opts, nats.UserInfoHandler(func() (string, string) {
+            return user, secrets.Get("nats.password")
+        }))
+    case cfg.Token != "":
+        opts = append(opts, nats.TokenHandler(secrets.Func("nats.token")))
+    case cfg.NKeySeed != "":
+        pub, err := publicKey(cfg.NKeySeed)
+        if err != nil {
+            return nil, err
+        }
+        // Публичный ключ передаётся серверу при подключении, поэтому замена seed
+        // на seed другого пользователя требует перезапуска.
+        opts = append(opts, nats.Nkey(pub, func(nonce []byte) ([]byte, error) {
+            kp, err := nkeys.FromSeed([]byte(secrets.Get("nats.nkey_seed")))
+            if err != nil {
+                return nil, fmt.Errorf("некорректный seed nkey: %w", err)
+            }
+            defer kp.Wipe()
+            return kp.Sign(nonce)
+        }))
+    }
+
+    if cfg.TLSCert != "" {
+        opts = append(opts, nats.ClientCert(cfg.TLSCert, cfg.TLSKey))
+    }
+    if cfg.TLSCA != "" {
+        opts = append(opts, nats.RootCAs(cfg.TLSCA))
+    }
+    return opts, nil
+}
+
+func publicKey(seed string) (string, error) {
+    kp, err := nkeys.FromSeed([]byte(seed))
+    if err != nil {
+        return "", fmt.Errorf("некорректный seed nkey: %w", err)
+    }
+    defer kp.Wipe()
+
+    pub, err := kp.PublicKey()
+    if err != nil {
+        return "", err
+    }
+    if !nkeys.IsValidPublicUserKey(pub) {
+        return "", errors.New("seed nkey не принадлежит пользователю (ожидается SU...)")
+    }
+    return pub, nil
+}
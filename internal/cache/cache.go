@@ -49,4 +49,56 @@ func (c *Cache) Get(key string) (string, bool) {
     }
 
     return "", false
-}
\ No newline at end of file
+}
+
+// Resize меняет ёмкость кэша. При увеличении все записи сохраняются,
+// при уменьшении вытесняются самые старые.
+func (c *Cache) Resize(capacity int) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if len(c.items) > capacity {
+        c.items = c.items[len(c.items)-capacity:]
+    }
+    items := make([]item, len(c.items), capacity)
+    copy(items, c.items)
+    c.items = items
+    c.capacity = capacity
+}
+
+func (c *Cache) Len() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return len(c.items)
+}
+
+func (c *Cache) Capacity() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.capacity
+}
+
+// Delete удаляет запись, если она есть.
+func (c *Cache) Delete(key string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for i, it := range c.items {
+        if it.key == key {
+            c.items = append(c.items[:i], c.items[i+1:]...)
+            return
+        }
+    }
+}
+
+// Keys возвращает ключи в порядке добавления, от самого старого.
+func (c *Cache) Keys() []string {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    keys := make([]string, len(c.items))
+    for i, it := range c.items {
+        keys[i] = it.key
+    }
+    return keys
+}
@@ -74,4 +74,49 @@ func TestCache_UpdateExistingKey(t *testing.T) {
     if !ok {
         t.Error("Ошибка: key2 должен быть в кэше")
     }
-}
\ No newline at end of file
+}
+
+func TestCache_Resize(t *testing.T) {
+    c := New(2)
+    c.Set("key1", "value1")
+    c.Set("key2", "value2")
+
+    c.Resize(4)
+    c.Set("key3", "value3")
+    c.Set("key4", "value4")
+
+    for _, key := range []string{"key1", "key2", "key3", "key4"} {
+        if _, ok := c.Get(key); !ok {
+            t.Errorf("Ошибка: %s должен остаться в кэше после увеличения", key)
+        }
+    }
+
+    c.Resize(2)
+    if c.Len() != 2 {
+        t.Errorf("Ожидалось 2 записи после уменьшения, получили %d", c.Len())
+    }
+    if _, ok := c.Get("key1"); ok {
+        t.Error("Ошибка: key1 должен был быть вытеснен при уменьшении")
+    }
+    if _, ok := c.Get("key4"); !ok {
+        t.Error("Ошибка: key4 должен остаться в кэше")
+    }
+}
+
+func TestCache_DeleteKeys(t *testing.T) {
+    c := New(3)
+    c.Set("key1", "value1")
+    c.Set("key2", "value2")
+    c.Set("key3", "value3")
+
+    c.Delete("key2")
+    c.Delete("missing")
+    if keys := c.Keys(); len(keys) != 2 || keys[0] != "key1" || keys[1] != "key3" {
+        t.Errorf("Неожиданные ключи после удаления: %v", keys)
+    }
+
+    c.Set("key4", "value4")
+    if _, ok := c.Get("key1"); !ok {
+        t.Error("Ошибка: после удаления в кэше есть место, key1 не должен вытесняться")
+    }
+}
@@ -0,0 +1,203 @@
+// Package codec кодирует заказы в JSON, Protobuf (схема order.proto) и MessagePack.
+// Кодировка сообщения без конверта определяется по магическому префиксу: JSON идёт как есть,
+// бинарные кодировки начинаются с нулевого байта, с которого JSON начаться не может.
+package codec
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "mime"
+    "strconv"
+    "strings"
+
+    "github.com/vmihailenco/msgpack/v5"
+    "wb-order-hub/internal/models"
+)
+
+// Format - кодировка сообщения.
+type Format string
+
+const (
+    JSON     Format = "json"
+    Protobuf Format = "protobuf"
+    MsgPack  Format = "msgpack"
+)
+
+// Магические префиксы бинарных сообщений без конверта.
+var (
+    magicProtobuf = []byte("\x00WBP")
+    magicMsgPack  = []byte("\x00WBM")
+)
+
+// ParseFormat разбирает имя кодировки. Пустая строка - JSON.
+func ParseFormat(s string) (Format, error) {
+    switch f := Format(s); f {
+    case "":
+        return JSON, nil
+    case JSON, Protobuf, MsgPack:
+        return f, nil
+    }
+    return "", fmt.Errorf("неизвестная кодировка %q, ожидается json, protobuf или msgpack", s)
+}
+
+// ContentType возвращает MIME-тип кодировки.
+func (f Format) ContentType() string {
+    switch f {
+    case Protobuf:
+        return "application/x-protobuf"
+    case MsgPack:
+        return "application/msgpack"
+    }
+    return "application/json"
+}
+
+// Detect определяет кодировку сообщения по префиксу и возвращает его без префикса.
+func Detect(data []byte) (Format, []byte) {
+    switch {
+    case bytes.HasPrefix(data, magicProtobuf):
+        return Protobuf, data[len(magicProtobuf):]
+    case bytes.HasPrefix(data, magicMsgPack):
+        return MsgPack, data[len(magicMsgPack):]
+    }
+    return JSON, data
+}
+
+// Frame добавляет к сообщению префикс кодировки, чтобы его можно было отправить без конверта.
+func Frame(f Format, data []byte) []byte {
+    var magic []byte
+    switch f {
+    case Protobuf:
+        magic = magicProtobuf
+    case MsgPack:
+        magic = magicMsgPack
+    default:
+        return data
+    }
+    return append(append(make([]byte, 0, len(magic)+len(data)), magic...), data...)
+}
+
+// MarshalOrder кодирует заказ без префикса.
+func MarshalOrder(f Format, o models.Order) ([]byte, error) {
+    switch f {
+    case Protobuf:
+        return marshalProto(o), nil
+    case MsgPack:
+        return marshalMsgPackOrder(o)
+    }
+    return json.Marshal(o)
+}
+
+// UnmarshalOrder разбирает заказ без префикса, не проверяя его.
+func UnmarshalOrder(f Format, data []byte) (models.Order, error) {
+    switch f {
+    case Protobuf:
+        return unmarshalProto(data)
+    case MsgPack:
+        return unmarshalMsgPackOrder(data)
+    }
+    var o models.Order
+    err := json.Unmarshal(data, &o)
+    return o, err
+}
+
+// Marshal кодирует ответ API в JSON или MessagePack. Protobuf описан только для заказа: см. MarshalOrder.
+func Marshal(f Format, v any) ([]byte, error) {
+    if f == MsgPack {
+        return marshalMsgPack(v)
+    }
+    return json.Marshal(v)
+}
+
+// MessagePack использует те же имена полей, что и JSON.
+func marshalMsgPack(v any) ([]byte, error) {
+    var buf bytes.Buffer
+    enc := msgpack.NewEncoder(&buf)
+    enc.SetCustomStructTag("json")
+    if err := enc.Encode(v); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func unmarshalMsgPack(data []byte, v any) error {
+    dec := msgpack.NewDecoder(bytes.NewReader(data))
+    dec.SetCustomStructTag("json")
+    return dec.Decode(v)
+}
+
+// msgOrder - заказ в MessagePack: те же поля и типы значений, что в JSON-сообщении (дата создания -
+// строкой RFC3339, payment_dt - unix-временем). Поля, объявленные до встроенной модели,
+// затеняют её одноимённые поля.
+type msgOrder struct {
+    Payment      msgPayment `json:"payment"`
+    DateCreated  string     `json:"date_created"`
+    models.Order `msgpack:",inline"`
+}
+
+type msgPayment struct {
+    PaymentDt      int64 `json:"payment_dt"`
+    models.Payment `msgpack:",inline"`
+}
+
+func marshalMsgPackOrder(o models.Order) ([]byte, error) {
+    return marshalMsgPack(msgOrder{
+        Payment:     msgPayment{models.UnixSeconds(o.Payment.PaymentDt), o.Payment},
+        DateCreated: models.FormatTime(o.DateCreated),
+        Order:       o,
+    })
+}
+
+func unmarshalMsgPackOrder(data []byte) (models.Order, error) {
+    var m msgOrder
+    if err := unmarshalMsgPack(data, &m); err != nil {
+        return models.Order{}, err
+    }
+    created, err := models.ParseTime(m.DateCreated)
+    if err != nil {
+        return models.Order{}, fmt.Errorf("некорректная дата создания %q", m.DateCreated)
+    }
+    o := m.Order
+    o.DateCreated = created
+    o.Payment = m.Payment.Payment
+    o.Payment.PaymentDt = models.UnixTime(m.Payment.PaymentDt)
+    return o, nil
+}
+
+// Negotiate выбирает кодировку ответа по заголовку Accept с учётом q. Пустой заголовок и */* - JSON.
+// ok = false, если ни одна из поддерживаемых кодировок не подходит.
+func Negotiate(accept string) (f Format, ok bool) {
+    if strings.TrimSpace(accept) == "" {
+        return JSON, true
+    }
+    best := -1.0
+    for _, part := range strings.Split(accept, ",") {
+        mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil {
+            continue
+        }
+        q := 1.0
+        if value, found := params["q"]; found {
+            if q, err = strconv.ParseFloat(value, 64); err != nil {
+                continue
+            }
+        }
+        candidate, known := formatOf(mediaType)
+        if known && q > 0 && q > best {
+            f, best = candidate, q
+        }
+    }
+    return f, best > 0
+}
+
+func formatOf(mediaType string) (Format, bool) {
+    switch mediaType {
+    case "application/json", "application/*", "*/*":
+        return JSON, true
+    case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+        return Protobuf, true
+    case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+        return MsgPack, true
+    }
+    return "", false
+}
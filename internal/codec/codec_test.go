@@ -0,0 +1,128 @@
+package codec
+
+import (
+    "reflect"
+    "testing"
+
+    "google.golang.org/protobuf/encoding/protowire"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func TestOrderRoundTrip(t *testing.T) {
+    order := repotest.SampleOrder("codec")
+    order.Items = append(order.Items, models.Item{}, models.Item{ChrtID: -1, Price: -5, Status: models.ItemCancelled})
+    for _, f := range []Format{JSON, Protobuf, MsgPack} {
+        t.Run(string(f), func(t *testing.T) {
+            data, err := MarshalOrder(f, order)
+            if err != nil {
+                t.Fatal(err)
+            }
+            format, payload := Detect(Frame(f, data))
+            if format != f {
+                t.Fatalf("Detect: %s", format)
+            }
+            got, err := UnmarshalOrder(format, payload)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(got, order) {
+                t.Errorf("Заказ изменился:\n%+v\n%+v", got, order)
+            }
+        })
+    }
+}
+
+func TestUnmarshalProto(t *testing.T) {
+    // Неизвестные поля, в том числе fixed64, пропускаются.
+    var b encoder
+    b.string(1, "a")
+    b = protowire.AppendTag(b, 99, protowire.Fixed64Type)
+    b = protowire.AppendFixed64(b, 7)
+    b.string(100, "новое поле")
+    if order, err := unmarshalProto(b); err != nil || order.OrderUID != "a" {
+        t.Errorf("Неизвестные поля: %+v, %v", order, err)
+    }
+
+    // order_uid числом.
+    var wrong encoder
+    wrong.int(1, 5)
+    if _, err := unmarshalProto(wrong); err == nil {
+        t.Error("Поле неверного типа принято")
+    }
+    if _, err := unmarshalProto([]byte{0x0a, 0x05, 'a'}); err == nil {
+        t.Error("Обрезанное сообщение принято")
+    }
+}
+
+func TestNegotiate(t *testing.T) {
+    tests := []struct {
+        accept string
+        want   Format
+        ok     bool
+    }{
+        {"", JSON, true},
+        {"*/*", JSON, true},
+        {"application/json", JSON, true},
+        {"application/x-protobuf", Protobuf, true},
+        {"application/msgpack", MsgPack, true},
+        {"application/json;q=0.5, application/msgpack", MsgPack, true},
+        {"application/x-protobuf;q=0.9, application/json", JSON, true},
+        {"text/html, */*;q=0.1", JSON, true},
+        {"text/html", "", false},
+        {"application/json;q=0", "", false},
+    }
+    for _, tt := range tests {
+        got, ok := Negotiate(tt.accept)
+        if got != tt.want || ok != tt.ok {
+            t.Errorf("Negotiate(%q) = %s, %v; ожидалось %s, %v", tt.accept, got, ok, tt.want, tt.ok)
+        }
+    }
+}
+
+// Бенчмарки сравнивают разбор заказа из канала в разных кодировках:
+//
+//	go test -bench . -benchmem ./internal/codec
+func BenchmarkUnmarshalOrder(b *testing.B) {
+    order := benchOrder()
+    for _, f := range []Format{JSON, Protobuf, MsgPack} {
+        data, err := MarshalOrder(f, order)
+        if err != nil {
+            b.Fatal(err)
+        }
+        b.Run(string(f), func(b *testing.B) {
+            b.SetBytes(int64(len(data)))
+            b.ReportAllocs()
+            for b.Loop() {
+                if _, err := UnmarshalOrder(f, data); err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}
+
+func BenchmarkMarshalOrder(b *testing.B) {
+    order := benchOrder()
+    for _, f := range []Format{JSON, Protobuf, MsgPack} {
+        b.Run(string(f), func(b *testing.B) {
+            b.ReportAllocs()
+            for b.Loop() {
+                if _, err := MarshalOrder(f, order); err != nil {
+                    b.Fatal(err)
+                }
+            }
+        })
+    }
+}
+
+// benchOrder - заказ с десятью товарами, как крупный заказ из генератора.
+func benchOrder() models.Order {
+    order := repotest.SampleOrder("bench-order-b563feb7b2b84b6test")
+    for len(order.Items) < 10 {
+        item := order.Items[0]
+        item.RID = item.RID + string(rune('a'+len(order.Items)))
+        order.Items = append(order.Items, item)
+    }
+    return order
+}
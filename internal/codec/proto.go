@@ -0,0 +1,330 @@
+package codec
+
+import (
+    "fmt"
+    "time"
+
+    "google.golang.org/protobuf/encoding/protowire"
+    "wb-order-hub/internal/models"
+)
+
+// Кодирование заказа по схеме order.proto. Нулевые значения не пишутся, как в proto3,
+// неизвестные поля при разборе пропускаются.
+
+func marshalProto(o models.Order) []byte {
+    var b encoder
+    b.string(1, o.OrderUID)
+    b.string(2, o.TrackNumber)
+    b.string(3, o.Entry)
+    b.message(4, marshalDelivery(o.Delivery))
+    b.message(5, marshalPayment(o.Payment))
+    for _, item := range o.Items {
+        // Пустой товар тоже пишется: иначе он пропадёт из списка.
+        b = protowire.AppendTag(b, 6, protowire.BytesType)
+        b = protowire.AppendBytes(b, marshalItem(item))
+    }
+    b.string(7, o.Locale)
+    b.string(8, o.InternalSignature)
+    b.string(9, o.CustomerID)
+    b.string(10, o.DeliveryService)
+    b.string(11, o.Shardkey)
+    b.int(12, int64(o.SmID))
+    b.string(13, models.FormatTime(o.DateCreated))
+    b.string(14, o.OofShard)
+    return b
+}
+
+func marshalDelivery(d models.Delivery) []byte {
+    var b encoder
+    b.string(1, d.Name)
+    b.string(2, d.Phone)
+    b.string(3, d.Zip)
+    b.string(4, d.City)
+    b.string(5, d.Address)
+    b.string(6, d.Region)
+    b.string(7, d.Email)
+    return b
+}
+
+func marshalPayment(p models.Payment) []byte {
+    var b encoder
+    b.string(1, p.Transaction)
+    b.string(2, p.RequestID)
+    b.string(3, p.Currency)
+    b.string(4, p.Provider)
+    b.int(5, int64(p.Amount))
+    b.int(6, models.UnixSeconds(p.PaymentDt))
+    b.string(7, p.Bank)
+    b.int(8, int64(p.DeliveryCost))
+    b.int(9, int64(p.GoodsTotal))
+    b.int(10, int64(p.CustomFee))
+    return b
+}
+
+func marshalItem(i models.Item) []byte {
+    var b encoder
+    b.int(1, int64(i.ChrtID))
+    b.string(2, i.TrackNumber)
+    b.int(3, int64(i.Price))
+    b.string(4, i.RID)
+    b.string(5, i.Name)
+    b.int(6, int64(i.Sale))
+    b.string(7, i.Size)
+    b.int(8, int64(i.TotalPrice))
+    b.int(9, int64(i.NmID))
+    b.string(10, i.Brand)
+    b.int(11, int64(i.Status))
+    return b
+}
+
+func unmarshalProto(data []byte) (models.Order, error) {
+    var o models.Order
+    err := fields(data, func(f field) error {
+        switch f.num {
+        case 1:
+            return f.string(&o.OrderUID)
+        case 2:
+            return f.string(&o.TrackNumber)
+        case 3:
+            return f.string(&o.Entry)
+        case 4:
+            return f.message(func(b []byte) error { return unmarshalDelivery(b, &o.Delivery) })
+        case 5:
+            return f.message(func(b []byte) error { return unmarshalPayment(b, &o.Payment) })
+        case 6:
+            var item models.Item
+            if err := f.message(func(b []byte) error { return unmarshalItem(b, &item) }); err != nil {
+                return err
+            }
+            o.Items = append(o.Items, item)
+        case 7:
+            return f.string(&o.Locale)
+        case 8:
+            return f.string(&o.InternalSignature)
+        case 9:
+            return f.string(&o.CustomerID)
+        case 10:
+            return f.string(&o.DeliveryService)
+        case 11:
+            return f.string(&o.Shardkey)
+        case 12:
+            return f.int(&o.SmID)
+        case 13:
+            return f.time(&o.DateCreated)
+        case 14:
+            return f.string(&o.OofShard)
+        }
+        return nil
+    })
+    if err != nil {
+        return models.Order{}, err
+    }
+    return o, nil
+}
+
+func unmarshalDelivery(data []byte, d *models.Delivery) error {
+    return fields(data, func(f field) error {
+        switch f.num {
+        case 1:
+            return f.string(&d.Name)
+        case 2:
+            return f.string(&d.Phone)
+        case 3:
+            return f.string(&d.Zip)
+        case 4:
+            return f.string(&d.City)
+        case 5:
+            return f.string(&d.Address)
+        case 6:
+            return f.string(&d.Region)
+        case 7:
+            return f.string(&d.Email)
+        }
+        return nil
+    })
+}
+
+func unmarshalPayment(data []byte, p *models.Payment) error {
+    return fields(data, func(f field) error {
+        switch f.num {
+        case 1:
+            return f.string(&p.Transaction)
+        case 2:
+            return f.string(&p.RequestID)
+        case 3:
+            return f.string(&p.Currency)
+        case 4:
+            return f.string(&p.Provider)
+        case 5:
+            return f.amount(&p.Amount)
+        case 6:
+            var sec int64
+            err := f.int64(&sec)
+            p.PaymentDt = models.UnixTime(sec)
+            return err
+        case 7:
+            return f.string(&p.Bank)
+        case 8:
+            return f.amount(&p.DeliveryCost)
+        case 9:
+            return f.amount(&p.GoodsTotal)
+        case 10:
+            return f.amount(&p.CustomFee)
+        }
+        return nil
+    })
+}
+
+func unmarshalItem(data []byte, i *models.Item) error {
+    return fields(data, func(f field) error {
+        switch f.num {
+        case 1:
+            return f.int(&i.ChrtID)
+        case 2:
+            return f.string(&i.TrackNumber)
+        case 3:
+            return f.amount(&i.Price)
+        case 4:
+            return f.string(&i.RID)
+        case 5:
+            return f.string(&i.Name)
+        case 6:
+            return f.int(&i.Sale)
+        case 7:
+            return f.string(&i.Size)
+        case 8:
+            return f.amount(&i.TotalPrice)
+        case 9:
+            return f.int(&i.NmID)
+        case 10:
+            return f.string(&i.Brand)
+        case 11:
+            var status int
+            err := f.int(&status)
+            i.Status = models.ItemStatus(status)
+            return err
+        }
+        return nil
+    })
+}
+
+type encoder []byte
+
+func (b *encoder) string(num protowire.Number, s string) {
+    if s != "" {
+        *b = protowire.AppendTag(*b, num, protowire.BytesType)
+        *b = protowire.AppendString(*b, s)
+    }
+}
+
+func (b *encoder) int(num protowire.Number, v int64) {
+    if v != 0 {
+        *b = protowire.AppendTag(*b, num, protowire.VarintType)
+        *b = protowire.AppendVarint(*b, uint64(v))
+    }
+}
+
+func (b *encoder) message(num protowire.Number, m []byte) {
+    if len(m) > 0 {
+        *b = protowire.AppendTag(*b, num, protowire.BytesType)
+        *b = protowire.AppendBytes(*b, m)
+    }
+}
+
+// field - поле сообщения: число для varint, байты для length-delimited.
+type field struct {
+    num   protowire.Number
+    typ   protowire.Type
+    v     uint64
+    bytes []byte
+}
+
+// fields перебирает поля сообщения. Поля других типов (fixed32, fixed64) в схеме не используются
+// и пропускаются.
+func fields(data []byte, fn func(field) error) error {
+    for len(data) > 0 {
+        num, typ, n := protowire.ConsumeTag(data)
+        if n < 0 {
+            return protowire.ParseError(n)
+        }
+        data = data[n:]
+        f := field{num: num, typ: typ}
+        switch typ {
+        case protowire.VarintType:
+            f.v, n = protowire.ConsumeVarint(data)
+        case protowire.BytesType:
+            f.bytes, n = protowire.ConsumeBytes(data)
+        default:
+            n = protowire.ConsumeFieldValue(num, typ, data)
+        }
+        if n < 0 {
+            return fmt.Errorf("поле %d: %w", num, protowire.ParseError(n))
+        }
+        data = data[n:]
+        if typ != protowire.VarintType && typ != protowire.BytesType {
+            continue
+        }
+        if err := fn(f); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (f field) string(dst *string) error {
+    if f.typ != protowire.BytesType {
+        return f.wrongType()
+    }
+    *dst = string(f.bytes)
+    return nil
+}
+
+func (f field) int64(dst *int64) error {
+    if f.typ != protowire.VarintType {
+        return f.wrongType()
+    }
+    *dst = int64(f.v)
+    return nil
+}
+
+func (f field) int(dst *int) error {
+    var v int64
+    err := f.int64(&v)
+    *dst = int(v)
+    return err
+}
+
+func (f field) amount(dst *models.Amount) error {
+    var v int64
+    err := f.int64(&v)
+    *dst = models.Amount(v)
+    return err
+}
+
+// time разбирает время в RFC3339, как в JSON.
+func (f field) time(dst *time.Time) error {
+    var s string
+    if err := f.string(&s); err != nil {
+        return err
+    }
+    t, err := models.ParseTime(s)
+    if err != nil {
+        return fmt.Errorf("поле %d: некорректное время %q", f.num, s)
+    }
+    *dst = t
+    return nil
+}
+
+func (f field) message(unmarshal func([]byte) error) error {
+    if f.typ != protowire.BytesType {
+        return f.wrongType()
+    }
+    if err := unmarshal(f.bytes); err != nil {
+        return fmt.Errorf("поле %d: %w", f.num, err)
+    }
+    return nil
+}
+
+func (f field) wrongType() error {
+    return fmt.Errorf("поле %d: неожиданный тип %d", f.num, f.typ)
+}
@@ -0,0 +1,206 @@
+package codec
+
+import (
+    "bufio"
+    "os"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "google.golang.org/protobuf/proto"
+    "google.golang.org/protobuf/reflect/protodesc"
+    "google.golang.org/protobuf/reflect/protoreflect"
+    "google.golang.org/protobuf/types/descriptorpb"
+    "google.golang.org/protobuf/types/dynamicpb"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+// TestProtoSchema сверяет ручное кодирование из proto.go со схемой order.proto:
+// заказ, закодированный marshalProto, разбирается по дескриптору схемы без неизвестных полей,
+// поля схемы совпадают с полями модели по имени и значению, а закодированное
+// библиотекой protobuf сообщение разбирается unmarshalProto в исходный заказ.
+func TestProtoSchema(t *testing.T) {
+    desc := loadProtoSchema(t, "order.proto").Messages().ByName("Order")
+    if desc == nil {
+        t.Fatal("В order.proto нет сообщения Order")
+    }
+
+    // Все поля заполнены, чтобы перестановка номеров не прошла незамеченной.
+    order := repotest.SampleOrder("codec")
+    order.InternalSignature = "signature"
+    order.Payment.RequestID = "request"
+    order.Payment.CustomFee = 3
+
+    msg := dynamicpb.NewMessage(desc)
+    if err := proto.Unmarshal(marshalProto(order), msg); err != nil {
+        t.Fatalf("Сообщение marshalProto не разбирается по схеме: %v", err)
+    }
+    compareProto(t, "Order", msg, reflect.ValueOf(order))
+
+    data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got, err := unmarshalProto(data)
+    if err != nil {
+        t.Fatalf("unmarshalProto: %v", err)
+    }
+    if !reflect.DeepEqual(got, order) {
+        t.Errorf("Заказ изменился:\n%+v\n%+v", got, order)
+    }
+}
+
+// compareProto сравнивает поля сообщения с полями структуры модели с тем же JSON-именем.
+func compareProto(t *testing.T, path string, msg protoreflect.Message, v reflect.Value) {
+    t.Helper()
+    if unknown := msg.GetUnknown(); len(unknown) > 0 {
+        t.Errorf("%s: поля вне схемы или с другим типом: %x", path, unknown)
+    }
+
+    goFields := make(map[string]reflect.Value)
+    for i := 0; i < v.NumField(); i++ {
+        name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+        if name != "" && name != "-" {
+            goFields[name] = v.Field(i)
+        }
+    }
+
+    fields := msg.Descriptor().Fields()
+    for i := 0; i < fields.Len(); i++ {
+        fd := fields.Get(i)
+        name := string(fd.Name())
+        field, ok := goFields[name]
+        if !ok {
+            t.Errorf("%s.%s: поля нет в модели", path, name)
+            continue
+        }
+        delete(goFields, name)
+        if !msg.Has(fd) {
+            t.Errorf("%s.%s: поле не заполнено", path, name)
+            continue
+        }
+
+        switch {
+        case fd.IsList():
+            list := msg.Get(fd).List()
+            if list.Len() != field.Len() {
+                t.Errorf("%s.%s: %d элементов, в модели %d", path, name, list.Len(), field.Len())
+                continue
+            }
+            for j := 0; j < list.Len(); j++ {
+                compareProto(t, path+"."+name+"["+strconv.Itoa(j)+"]", list.Get(j).Message(), field.Index(j))
+            }
+        case fd.Kind() == protoreflect.MessageKind:
+            compareProto(t, path+"."+name, msg.Get(fd).Message(), field)
+        default:
+            if got, want := protoScalar(msg.Get(fd)), goScalar(field, fd.Kind()); got != want {
+                t.Errorf("%s.%s: %v, в модели %v", path, name, got, want)
+            }
+        }
+    }
+    for name := range goFields {
+        t.Errorf("%s.%s: поля модели нет в схеме", path, name)
+    }
+}
+
+func protoScalar(v protoreflect.Value) any {
+    switch x := v.Interface().(type) {
+    case int32:
+        return int64(x)
+    default:
+        return x
+    }
+}
+
+// goScalar приводит значение поля модели к типу поля схемы; время кодируется
+// строкой RFC3339 или unix-временем в секундах.
+func goScalar(v reflect.Value, kind protoreflect.Kind) any {
+    if t, ok := v.Interface().(time.Time); ok {
+        if kind == protoreflect.StringKind {
+            return models.FormatTime(t)
+        }
+        return models.UnixSeconds(t)
+    }
+    switch v.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return v.Int()
+    default:
+        return v.Interface()
+    }
+}
+
+var (
+    protoPackage = regexp.MustCompile(`^package\s+([\w.]+)\s*;$`)
+    protoMessage = regexp.MustCompile(`^message\s+(\w+)\s*\{$`)
+    protoField   = regexp.MustCompile(`^(repeated\s+)?(\w+)\s+(\w+)\s*=\s*(\d+)\s*;$`)
+    protoScalars = map[string]descriptorpb.FieldDescriptorProto_Type{
+        "string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+        "int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+        "int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+    }
+)
+
+// loadProtoSchema строит дескриптор файла по схеме. Разбирается только то подмножество
+// синтаксиса, что есть в order.proto: пакет, сообщения верхнего уровня и их поля.
+func loadProtoSchema(t *testing.T, name string) protoreflect.FileDescriptor {
+    t.Helper()
+    f, err := os.Open(name)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+
+    file := &descriptorpb.FileDescriptorProto{Name: proto.String(name), Syntax: proto.String("proto3")}
+    var message *descriptorpb.DescriptorProto
+    scanner := bufio.NewScanner(f)
+    for n := 1; scanner.Scan(); n++ {
+        line, _, _ := strings.Cut(scanner.Text(), "//")
+        line = strings.TrimSpace(line)
+        if m := protoField.FindStringSubmatch(line); m != nil && message != nil {
+            number, _ := strconv.Atoi(m[4])
+            field := &descriptorpb.FieldDescriptorProto{
+                Name:     proto.String(m[3]),
+                JsonName: proto.String(m[3]),
+                Number:   proto.Int32(int32(number)),
+                Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+            }
+            if m[1] != "" {
+                field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+            }
+            if typ, ok := protoScalars[m[2]]; ok {
+                field.Type = typ.Enum()
+            } else {
+                field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+                field.TypeName = proto.String("." + file.GetPackage() + "." + m[2])
+            }
+            message.Field = append(message.Field, field)
+            continue
+        }
+
+        switch m := protoMessage.FindStringSubmatch(line); {
+        case line == "" || line == `syntax = "proto3";`:
+        case protoPackage.MatchString(line):
+            file.Package = proto.String(protoPackage.FindStringSubmatch(line)[1])
+        case m != nil && message == nil:
+            message = &descriptorpb.DescriptorProto{Name: proto.String(m[1])}
+        case line == "}" && message != nil:
+            file.MessageType = append(file.MessageType, message)
+            message = nil
+        default:
+            t.Fatalf("%s:%d: строка не поддерживается: %q", name, n, line)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        t.Fatal(err)
+    }
+
+    fd, err := protodesc.NewFile(file, nil)
+    if err != nil {
+        t.Fatalf("Некорректная схема %s: %v", name, err)
+    }
+    return fd
+}
@@ -1,38 +1,351 @@
 package config
 
 import (
+    "encoding/base64"
+    "flag"
+    "fmt"
     "os"
+    "slices"
+    "time"
+
+    "wb-order-hub/internal/models"
 )
 
+// Config - полная конфигурация сервиса.
+//
+// Значения собираются по слоям, каждый следующий перекрывает предыдущий:
+// значения по умолчанию, файл YAML/JSON, переменные окружения, флаги командной строки.
+// Имя переменной окружения задаёт тег env, имя флага - путь из тегов yaml через точку
+// (например, -database.host).
+//
+// Поля с тегом reload:"true" можно менять без перезапуска (см. Diff).
 type Config struct {
-    DatabaseHost     string
-    DatabasePort     string
-    DatabaseUser     string
-    DatabasePassword string
-    DatabaseName     string
-    NatsURL          string
-    NatsClusterID    string
-    NatsClientID     string
-    ServerPort       string
-}
-
-func Load() *Config {
+    Database DatabaseConfig `yaml:"database" json:"database"`
+    NATS     NATSConfig     `yaml:"nats" json:"nats"`
+    HTTP     HTTPConfig     `yaml:"http" json:"http"`
+    Cache    CacheConfig    `yaml:"cache" json:"cache"`
+    Security SecurityConfig `yaml:"security" json:"security"`
+    Secrets  SecretsConfig  `yaml:"secrets" json:"secrets"`
+    Log      LogConfig      `yaml:"log" json:"log"`
+    Archive  ArchiveConfig  `yaml:"archive" json:"archive"`
+    Rates    RatesConfig    `yaml:"rates" json:"rates"`
+
+    // secretFiles - файлы, из которых прочитаны секреты, по пути поля (например, database.password).
+    secretFiles map[string]string
+}
+
+type DatabaseConfig struct {
+    Host              string   `yaml:"host" json:"host" env:"DB_HOST" usage:"адрес PostgreSQL"`
+    Port              int      `yaml:"port" json:"port" env:"DB_PORT" usage:"порт PostgreSQL"`
+    User              string   `yaml:"user" json:"user" env:"DB_USER" usage:"пользователь PostgreSQL"`
+    Password          string   `yaml:"password" json:"password" env:"DB_PASSWORD" secret:"true" reload:"true" usage:"пароль PostgreSQL"`
+    Name              string   `yaml:"name" json:"name" env:"DB_NAME" usage:"имя базы данных"`
+    MaxConns          int32    `yaml:"max_conns" json:"max_conns" env:"DB_MAX_CONNS" usage:"максимальный размер пула соединений"`
+    MinConns          int32    `yaml:"min_conns" json:"min_conns" env:"DB_MIN_CONNS" usage:"минимальный размер пула соединений"`
+    MaxConnLifetime   Duration `yaml:"max_conn_lifetime" json:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME" usage:"время жизни соединения"`
+    MaxConnIdleTime   Duration `yaml:"max_conn_idle_time" json:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME" usage:"время простоя соединения до закрытия"`
+    HealthCheckPeriod Duration `yaml:"health_check_period" json:"health_check_period" env:"DB_HEALTH_CHECK_PERIOD" usage:"период проверки простаивающих соединений"`
+    StatementTimeout  Duration `yaml:"statement_timeout" json:"statement_timeout" env:"DB_STATEMENT_TIMEOUT" usage:"statement_timeout для запросов"`
+
+    // DocumentCheckInterval - период сверки документов заказов с таблицами, 0 - не сверять.
+    DocumentCheckInterval Duration `yaml:"document_check_interval" json:"document_check_interval" env:"DB_DOCUMENT_CHECK_INTERVAL" usage:"период сверки документов заказов с таблицами (0 - не сверять)"`
+}
+
+type NATSConfig struct {
+    URL         string   `yaml:"url" json:"url" env:"NATS_URL" usage:"адрес NATS"`
+    ClusterID   string   `yaml:"cluster_id" json:"cluster_id" env:"NATS_CLUSTER_ID" usage:"ID кластера NATS Streaming"`
+    ClientID    string   `yaml:"client_id" json:"client_id" env:"NATS_CLIENT_ID" usage:"ID клиента NATS Streaming"`
+    Channel     string   `yaml:"channel" json:"channel" env:"NATS_CHANNEL" usage:"канал с заказами"`
+    DurableName string   `yaml:"durable_name" json:"durable_name" env:"NATS_DURABLE_NAME" usage:"имя durable-подписки"`
+    AckWait     Duration `yaml:"ack_wait" json:"ack_wait" env:"NATS_ACK_WAIT" usage:"время до повторной доставки неподтверждённого сообщения"`
+
+    // StatusChannel - канал сообщений о смене статуса через API, пустой - не публиковать.
+    StatusChannel string `yaml:"status_channel" json:"status_channel" env:"NATS_STATUS_CHANNEL" usage:"канал сообщений о смене статуса товаров"`
+    // EventsPrefix - общий префикс каналов событий заказа <prefix>.<тип>, пустой - не подписываться.
+    EventsPrefix string `yaml:"events_prefix" json:"events_prefix" env:"NATS_EVENTS_PREFIX" usage:"префикс каналов событий заказа"`
+
+    // Аутентификация: не более одного способа из user/password, token и nkey_seed.
+    User     string `yaml:"user" json:"user" env:"NATS_USER" usage:"пользователь NATS"`
+    Password string `yaml:"password" json:"password" env:"NATS_PASSWORD" secret:"true" reload:"true" usage:"пароль NATS"`
+    Token    string `yaml:"token" json:"token" env:"NATS_TOKEN" secret:"true" reload:"true" usage:"токен NATS"`
+    NKeySeed string `yaml:"nkey_seed" json:"nkey_seed" env:"NATS_NKEY_SEED" secret:"true" reload:"true" usage:"seed пользовательского nkey (SU...)"`
+    // TLS: клиентский сертификат с ключом и корневой сертификат сервера.
+    TLSCert string `yaml:"tls_cert" json:"tls_cert" env:"NATS_TLS_CERT" usage:"файл клиентского сертификата"`
+    TLSKey  string `yaml:"tls_key" json:"tls_key" env:"NATS_TLS_KEY" usage:"файл ключа клиентского сертификата"`
+    TLSCA   string `yaml:"tls_ca" json:"tls_ca" env:"NATS_TLS_CA" usage:"файл корневого сертификата сервера NATS"`
+}
+
+type HTTPConfig struct {
+    Port            int             `yaml:"port" json:"port" env:"SERVER_PORT" usage:"порт HTTP сервера"`
+    ReadTimeout     Duration        `yaml:"read_timeout" json:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"таймаут чтения запроса"`
+    WriteTimeout    Duration        `yaml:"write_timeout" json:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"таймаут записи ответа"`
+    ShutdownTimeout Duration        `yaml:"shutdown_timeout" json:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" usage:"срок корректной остановки сервиса"`
+    MaxHeaderSize   ByteSize        `yaml:"max_header_size" json:"max_header_size" env:"HTTP_MAX_HEADER_SIZE" usage:"максимальный размер заголовков запроса"`
+    WebDir          string          `yaml:"web_dir" json:"web_dir" env:"WEB_DIR" usage:"каталог веб-интерфейса вместо встроенного (для разработки)"`
+    RateLimit       RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+}
+
+// RateLimitConfig ограничивает частоту запросов к API с одного IP-адреса.
+type RateLimitConfig struct {
+    // RPS - запросов в секунду, 0 отключает ограничение.
+    RPS   float64 `yaml:"rps" json:"rps" env:"HTTP_RATE_LIMIT_RPS" reload:"true" usage:"запросов в секунду с одного адреса (0 - без ограничения)"`
+    Burst int     `yaml:"burst" json:"burst" env:"HTTP_RATE_LIMIT_BURST" reload:"true" usage:"допустимый всплеск запросов сверх rps"`
+}
+
+type CacheConfig struct {
+    Capacity int `yaml:"capacity" json:"capacity" env:"CACHE_CAPACITY" reload:"true" usage:"количество заказов в кэше"`
+}
+
+type LogConfig struct {
+    Level  string `yaml:"level" json:"level" env:"LOG_LEVEL" reload:"true" usage:"уровень логирования: debug, info, warn, error"`
+    Format string `yaml:"format" json:"format" env:"LOG_FORMAT" usage:"формат логов: text или json"`
+}
+
+// ArchiveConfig - хранение исходных сообщений из канала заказов.
+type ArchiveConfig struct {
+    Enabled bool `yaml:"enabled" json:"enabled" env:"ARCHIVE_ENABLED" usage:"сохранять исходные сообщения"`
+    // Retention - сколько хранить сообщения, 0 - бессрочно.
+    Retention   Duration `yaml:"retention" json:"retention" env:"ARCHIVE_RETENTION" usage:"срок хранения исходных сообщений (0 - бессрочно)"`
+    Compression string   `yaml:"compression" json:"compression" env:"ARCHIVE_COMPRESSION" usage:"сжатие исходных сообщений: none или gzip"`
+}
+
+// RatesConfig - курсы валют для пересчёта сумм заказов в валюту отчётности.
+type RatesConfig struct {
+    // File - курсы в CSV или JSON, которые сохраняются в хранилище курсов при запуске.
+    File string `yaml:"file" json:"file" env:"RATES_FILE" usage:"файл курсов валют CSV или JSON, загружаемый при запуске"`
+    // Currency - валюта отчётности, в которую пересчитываются суммы в ответе API. Пустая - не пересчитывать.
+    Currency string `yaml:"currency" json:"currency" env:"RATES_CURRENCY" usage:"валюта отчётности для ответа API (пусто - не пересчитывать)"`
+    // RefreshInterval - как часто перечитывать курсы из хранилища, 0 - только при запуске и загрузке через API.
+    RefreshInterval Duration `yaml:"refresh_interval" json:"refresh_interval" env:"RATES_REFRESH_INTERVAL" usage:"период перечитывания курсов валют из хранилища"`
+}
+
+type SecurityConfig struct {
+    APIKeys []string `yaml:"api_keys" json:"api_keys" env:"API_KEYS" secret:"true" reload:"true" usage:"ключи доступа к служебным эндпоинтам через запятую"`
+    // EncryptionKey - 32-байтный ключ в base64. Загружается и проверяется как остальные секреты,
+    // но пока ничем не используется: данные сервис не шифрует.
+    EncryptionKey string `yaml:"encryption_key" json:"encryption_key" env:"ENCRYPTION_KEY" secret:"true" usage:"резервный ключ (base64, 32 байта), сервисом пока не используется"`
+}
+
+// SecretsConfig описывает дополнительные источники секретов.
+//
+// Любой секрет можно прочитать из файла, указав путь в переменной <ИМЯ>_FILE
+// (например, DB_PASSWORD_FILE), или положить файл с именем переменной
+// (DB_PASSWORD или db_password) в каталог Dir, как это делают Docker и Kubernetes.
+type SecretsConfig struct {
+    Dir string `yaml:"dir" json:"dir" env:"SECRETS_DIR" usage:"каталог с файлами секретов"`
+    // RefreshInterval - как часто перечитывать файлы секретов. 0 отключает обновление.
+    RefreshInterval Duration `yaml:"refresh_interval" json:"refresh_interval" env:"SECRETS_REFRESH_INTERVAL" usage:"период перечитывания файлов секретов"`
+}
+
+// Default возвращает конфигурацию по умолчанию, соответствующую docker-compose.yml.
+func Default() *Config {
     return &Config{
-        DatabaseHost:     getEnv("DB_HOST", "127.0.0.1"),
-        DatabasePort:     getEnv("DB_PORT", "5433"),
-        DatabaseUser:     getEnv("DB_USER", "postgres"),
-        DatabasePassword: getEnv("DB_PASSWORD", "121212"),
-        DatabaseName:     getEnv("DB_NAME", "orders_db"),
-        NatsURL:          getEnv("NATS_URL", "nats://localhost:4222"),
-        NatsClusterID:    getEnv("NATS_CLUSTER_ID", "test-cluster"),
-        NatsClientID:     getEnv("NATS_CLIENT_ID", "order-service-sub"),
-        ServerPort:       getEnv("SERVER_PORT", "8080"),
+        Database: DatabaseConfig{
+            Host:              "127.0.0.1",
+            Port:              5433,
+            User:              "postgres",
+            Name:              "orders_db",
+            MaxConns:          10,
+            MaxConnLifetime:   Duration(time.Hour),
+            MaxConnIdleTime:   Duration(30 * time.Minute),
+            HealthCheckPeriod: Duration(time.Minute),
+            StatementTimeout:  Duration(5 * time.Second),
+
+            DocumentCheckInterval: Duration(time.Hour),
+        },
+        NATS: NATSConfig{
+            URL:         "nats://localhost:4222",
+            ClusterID:   "test-cluster",
+            ClientID:    "order-service-sub",
+            Channel:     "orders",
+            DurableName: "order-service-durable",
+            AckWait:     Duration(30 * time.Second),
+
+            StatusChannel: "orders.status",
+            EventsPrefix:  "orders.events",
+        },
+        HTTP: HTTPConfig{
+            Port:            8080,
+            ReadTimeout:     Duration(15 * time.Second),
+            WriteTimeout:    Duration(15 * time.Second),
+            ShutdownTimeout: Duration(10 * time.Second),
+            MaxHeaderSize:   ByteSize(1 << 20),
+            RateLimit: RateLimitConfig{
+                Burst: 20,
+            },
+        },
+        Cache: CacheConfig{
+            Capacity: 100,
+        },
+        Secrets: SecretsConfig{
+            RefreshInterval: Duration(30 * time.Second),
+        },
+        Log: LogConfig{
+            Level:  "info",
+            Format: "text",
+        },
+        Archive: ArchiveConfig{
+            Enabled:     true,
+            Retention:   Duration(30 * 24 * time.Hour),
+            Compression: "none",
+        },
+        Rates: RatesConfig{
+            RefreshInterval: Duration(5 * time.Minute),
+        },
+    }
+}
+
+// Load собирает конфигурацию из всех источников и проверяет её.
+// args - аргументы командной строки без имени программы. Путь к файлу
+// конфигурации задаётся флагом -config или переменной CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+    return LoadFlags(flag.NewFlagSet("order-hub", flag.ContinueOnError), args)
+}
+
+// LoadFlags работает как Load, но разбирает аргументы в fs, где вызывающий
+// может заранее объявить собственные флаги.
+func LoadFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+    cfg, err := ParseFlags(fs, args)
+    if err != nil {
+        return nil, err
+    }
+    if fs.NArg() > 0 {
+        return nil, fmt.Errorf("неожиданные аргументы: %v", fs.Args())
     }
+    return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
-    if value, exists := os.LookupEnv(key); exists {
-        return value
+// ParseFlags работает как LoadFlags, но допускает позиционные аргументы после флагов:
+// они остаются в fs.Args().
+func ParseFlags(fs *flag.FlagSet, args []string) (*Config, error) {
+    cfg := Default()
+
+    configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "файл конфигурации YAML или JSON")
+    overrides := registerFlags(fs, cfg)
+    if err := fs.Parse(args); err != nil {
+        return nil, err
+    }
+
+    if *configFile != "" {
+        if err := loadFile(cfg, *configFile); err != nil {
+            return nil, err
+        }
+    }
+
+    // Ошибки разбора окружения и флагов выводятся вместе с ошибками проверки.
+    errs := applyEnv(cfg, os.LookupEnv)
+    errs = append(errs, overrides.apply(cfg)...)
+    errs = append(errs, applySecretSources(cfg, os.LookupEnv, overrides.isSet)...)
+    if err := cfg.Validate(); err != nil {
+        errs = append(errs, err.(*ValidationError).Errors...)
+    }
+    if len(errs) > 0 {
+        return nil, &ValidationError{Errors: errs}
+    }
+    return cfg, nil
+}
+
+// Validate проверяет конфигурацию и возвращает сразу все найденные ошибки.
+func (c *Config) Validate() error {
+    var errs []error
+    check := func(ok bool, format string, args ...any) {
+        if !ok {
+            errs = append(errs, fmt.Errorf(format, args...))
+        }
     }
-    return defaultValue
-}
\ No newline at end of file
+
+    check(c.Database.Host != "", "database.host: не задан")
+    check(validPort(c.Database.Port), "database.port: %d вне диапазона 1-65535", c.Database.Port)
+    check(c.Database.User != "", "database.user: не задан")
+    check(c.Database.Name != "", "database.name: не задано")
+    check(c.Database.MaxConns > 0, "database.max_conns: должно быть больше нуля")
+    check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns,
+        "database.min_conns: должно быть от 0 до max_conns (%d)", c.Database.MaxConns)
+    check(c.Database.MaxConnLifetime >= 0, "database.max_conn_lifetime: не может быть отрицательным")
+    check(c.Database.MaxConnIdleTime >= 0, "database.max_conn_idle_time: не может быть отрицательным")
+    check(c.Database.HealthCheckPeriod >= 0, "database.health_check_period: не может быть отрицательным")
+    check(c.Database.StatementTimeout >= 0, "database.statement_timeout: не может быть отрицательным")
+    check(c.Database.DocumentCheckInterval >= 0, "database.document_check_interval: не может быть отрицательным")
+
+    check(c.NATS.URL != "", "nats.url: не задан")
+    check(validStanID(c.NATS.ClusterID), "nats.cluster_id: допустимы только латинские буквы, цифры, '-' и '_'")
+    check(validStanID(c.NATS.ClientID), "nats.client_id: допустимы только латинские буквы, цифры, '-' и '_'")
+    check(c.NATS.Channel != "", "nats.channel: не задан")
+    check(c.NATS.DurableName != "", "nats.durable_name: не задано")
+    check(c.NATS.AckWait.Std() >= time.Second, "nats.ack_wait: NATS Streaming требует не меньше 1s")
+    check(c.NATS.Password == "" || c.NATS.User != "", "nats.password: задан без nats.user")
+    authMethods := 0
+    for _, set := range []bool{c.NATS.User != "", c.NATS.Token != "", c.NATS.NKeySeed != ""} {
+        if set {
+            authMethods++
+        }
+    }
+    check(authMethods <= 1, "nats: задано несколько способов аутентификации, допустим один из user, token, nkey_seed")
+    check((c.NATS.TLSCert == "") == (c.NATS.TLSKey == ""), "nats.tls_cert, nats.tls_key: должны задаваться вместе")
+
+    check(validPort(c.HTTP.Port), "http.port: %d вне диапазона 1-65535", c.HTTP.Port)
+    check(c.HTTP.ReadTimeout >= 0, "http.read_timeout: не может быть отрицательным")
+    check(c.HTTP.WriteTimeout >= 0, "http.write_timeout: не может быть отрицательным")
+    check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout: должно быть больше нуля")
+    check(c.HTTP.MaxHeaderSize > 0, "http.max_header_size: должно быть больше нуля")
+    check(c.HTTP.RateLimit.RPS >= 0, "http.rate_limit.rps: не может быть отрицательным")
+    check(c.HTTP.RateLimit.RPS == 0 || c.HTTP.RateLimit.Burst > 0, "http.rate_limit.burst: должно быть больше нуля при заданном rps")
+
+    check(c.Cache.Capacity > 0, "cache.capacity: должно быть больше нуля")
+
+    if c.Security.EncryptionKey != "" {
+        key, err := base64.StdEncoding.DecodeString(c.Security.EncryptionKey)
+        check(err == nil && len(key) == 32, "security.encryption_key: ожидается 32 байта в base64")
+    }
+    check(c.Secrets.RefreshInterval >= 0, "secrets.refresh_interval: не может быть отрицательным")
+
+    check(slices.Contains([]string{"debug", "info", "warn", "error"}, c.Log.Level),
+        "log.level: %q, ожидается debug, info, warn или error", c.Log.Level)
+    check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: %q, ожидается text или json", c.Log.Format)
+
+    check(c.Archive.Retention >= 0, "archive.retention: не может быть отрицательным")
+    check(c.Archive.Compression == "none" || c.Archive.Compression == "gzip",
+        "archive.compression: %q, ожидается none или gzip", c.Archive.Compression)
+
+    check(c.Rates.Currency == "" || models.ValidCurrency(c.Rates.Currency),
+        "rates.currency: %q, ожидается код ISO 4217 из трёх заглавных букв", c.Rates.Currency)
+    check(c.Rates.RefreshInterval >= 0, "rates.refresh_interval: не может быть отрицательным")
+
+    if len(errs) == 0 {
+        return nil
+    }
+    return &ValidationError{Errors: errs}
+}
+
+// ValidationError содержит все ошибки проверки конфигурации.
+type ValidationError struct {
+    Errors []error
+}
+
+func (e *ValidationError) Error() string {
+    msg := "некорректная конфигурация:"
+    for _, err := range e.Errors {
+        msg += "\n  - " + err.Error()
+    }
+    return msg
+}
+
+func (e *ValidationError) Unwrap() []error {
+    return e.Errors
+}
+
+func validPort(port int) bool {
+    return port > 0 && port <= 65535
+}
+
+func validStanID(id string) bool {
+    if id == "" {
+        return false
+    }
+    for _, r := range id {
+        if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+            return false
+        }
+    }
+    return true
+}
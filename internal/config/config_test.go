@@ -0,0 +1,317 @@
+package config
+
+import (
+    "bytes"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// clearEnv убирает переменные окружения конфигурации (например, заданные в CI) на время теста.
+func clearEnv(t *testing.T) {
+    t.Helper()
+    for _, f := range fields(Default()) {
+        for _, name := range []string{f.env, f.env + "_FILE"} {
+            if value, ok := os.LookupEnv(name); ok && f.env != "" {
+                os.Unsetenv(name)
+                t.Cleanup(func() { os.Setenv(name, value) })
+            }
+        }
+    }
+    t.Setenv("CONFIG_FILE", "")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), name)
+    if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+    clearEnv(t)
+    path := writeFile(t, "config.yaml", `
+database:
+  host: file-host
+  port: 6000
+nats:
+  channel: file-channel
+http:
+  read_timeout: 20s
+cache:
+  capacity: 500
+`)
+    t.Setenv("DB_PORT", "7000")
+    t.Setenv("NATS_CHANNEL", "env-channel")
+
+    cfg, err := Load([]string{"-config", path, "-nats.channel", "flag-channel", "-http.max_header_size", "64KB"})
+    if err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+
+    if cfg.Database.Host != "file-host" {
+        t.Errorf("Значение из файла должно перекрыть значение по умолчанию, получили %q", cfg.Database.Host)
+    }
+    if cfg.Database.Port != 7000 {
+        t.Errorf("Окружение должно перекрыть файл, получили %d", cfg.Database.Port)
+    }
+    if cfg.NATS.Channel != "flag-channel" {
+        t.Errorf("Флаг должен перекрыть окружение и файл, получили %q", cfg.NATS.Channel)
+    }
+    if cfg.HTTP.ReadTimeout.Std() != 20*time.Second {
+        t.Errorf("Ожидался read_timeout 20s, получили %s", cfg.HTTP.ReadTimeout)
+    }
+    if cfg.HTTP.MaxHeaderSize != 64<<10 {
+        t.Errorf("Ожидался max_header_size 64KB, получили %d", cfg.HTTP.MaxHeaderSize)
+    }
+    if cfg.Cache.Capacity != 500 {
+        t.Errorf("Ожидалась capacity 500, получили %d", cfg.Cache.Capacity)
+    }
+    if cfg.Database.User != "postgres" {
+        t.Errorf("Незаданные значения должны остаться по умолчанию, получили %q", cfg.Database.User)
+    }
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+    clearEnv(t)
+    path := writeFile(t, "config.json", `{"http": {"port": 9090, "shutdown_timeout": "3s"}}`)
+
+    cfg, err := Load([]string{"-config", path})
+    if err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+    if cfg.HTTP.Port != 9090 || cfg.HTTP.ShutdownTimeout.Std() != 3*time.Second {
+        t.Errorf("Значения из JSON не применились: %+v", cfg.HTTP)
+    }
+}
+
+func TestLoad_UnknownFileKey(t *testing.T) {
+    clearEnv(t)
+    path := writeFile(t, "config.yaml", "database:\n  hots: typo\n")
+
+    if _, err := Load([]string{"-config", path}); err == nil {
+        t.Error("Ожидалась ошибка для неизвестного ключа")
+    }
+}
+
+func TestLoad_ReportsAllErrors(t *testing.T) {
+    clearEnv(t)
+    t.Setenv("DB_MAX_CONNS", "many")
+    t.Setenv("NATS_ACK_WAIT", "100ms")
+
+    _, err := Load([]string{"-cache.capacity", "0", "-http.port", "70000", "-database.statement_timeout", "soon"})
+    var verr *ValidationError
+    if !errors.As(err, &verr) {
+        t.Fatalf("Ожидалась ValidationError, получили %v", err)
+    }
+
+    for _, want := range []string{"database.max_conns", "nats.ack_wait", "cache.capacity", "http.port", "database.statement_timeout"} {
+        if !strings.Contains(err.Error(), want) {
+            t.Errorf("В ошибке нет упоминания %s:\n%v", want, err)
+        }
+    }
+}
+
+func TestByteSize_UnmarshalText(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    ByteSize
+        wantErr bool
+    }{
+        {"512", 512, false},
+        {"512B", 512, false},
+        {"64KB", 64 << 10, false},
+        {"64kib", 64 << 10, false},
+        {"1 MiB", 1 << 20, false},
+        {"2G", 2 << 30, false},
+        {"-1KB", 0, true},
+        {"big", 0, true},
+    }
+
+    for _, tt := range tests {
+        var got ByteSize
+        err := got.UnmarshalText([]byte(tt.in))
+        if (err != nil) != tt.wantErr {
+            t.Errorf("%q: ожидалась ошибка %v, получили %v", tt.in, tt.wantErr, err)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("%q: ожидалось %d, получили %d", tt.in, tt.want, got)
+        }
+    }
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+    cfg := Default()
+    cfg.Database.Password = "very-secret"
+
+    var buf bytes.Buffer
+    if err := cfg.Print(&buf, "yaml"); err != nil {
+        t.Fatalf("Print: %v", err)
+    }
+
+    if strings.Contains(buf.String(), "very-secret") {
+        t.Error("Пароль не должен попадать в вывод")
+    }
+    if !strings.Contains(buf.String(), redacted) {
+        t.Error("Вместо пароля должна выводиться заглушка")
+    }
+    if cfg.Database.Password != "very-secret" {
+        t.Error("Print не должен менять исходную конфигурацию")
+    }
+}
+
+func TestLoad_NoDefaultSecrets(t *testing.T) {
+    clearEnv(t)
+    cfg, err := Load(nil)
+    if err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+    for _, f := range fields(cfg) {
+        if f.secret && !f.value.IsZero() {
+            t.Errorf("Секрет %s не должен иметь значения по умолчанию", f.path)
+        }
+    }
+}
+
+func TestLoad_SecretFromFile(t *testing.T) {
+    clearEnv(t)
+    passwordFile := writeFile(t, "db_password", "p a'ss\\\n")
+    t.Setenv("DB_PASSWORD_FILE", passwordFile)
+
+    cfg, err := Load(nil)
+    if err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+    if cfg.Database.Password != `p a'ss\` {
+        t.Errorf("Пароль должен читаться из DB_PASSWORD_FILE без перевода строки, получили %q", cfg.Database.Password)
+    }
+
+    t.Setenv("DB_PASSWORD", "other")
+    if _, err := Load(nil); err == nil {
+        t.Error("Одновременные DB_PASSWORD и DB_PASSWORD_FILE должны давать ошибку")
+    }
+}
+
+func TestLoad_SecretsDir(t *testing.T) {
+    clearEnv(t)
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "api_keys"), []byte("key-1\nkey-2\n"), 0o600)
+    os.WriteFile(filepath.Join(dir, "NATS_TOKEN"), []byte("token"), 0o600)
+
+    cfg, err := Load([]string{"-secrets.dir", dir})
+    if err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+    if strings.Join(cfg.Security.APIKeys, ",") != "key-1,key-2" {
+        t.Errorf("Ключи должны читаться построчно, получили %v", cfg.Security.APIKeys)
+    }
+    if cfg.NATS.Token != "token" {
+        t.Errorf("Токен должен читаться из каталога секретов, получили %q", cfg.NATS.Token)
+    }
+
+    t.Setenv("NATS_TOKEN", "from-env")
+    cfg, err = Load([]string{"-secrets.dir", dir})
+    if err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+    if cfg.NATS.Token != "from-env" {
+        t.Errorf("Переменная окружения должна иметь приоритет над каталогом секретов, получили %q", cfg.NATS.Token)
+    }
+}
+
+func TestSecretStore_Refresh(t *testing.T) {
+    clearEnv(t)
+    passwordFile := writeFile(t, "db_password", "first")
+    t.Setenv("DB_PASSWORD_FILE", passwordFile)
+    cfg, err := Load(nil)
+    if err != nil {
+        t.Fatalf("Load: %v", err)
+    }
+
+    store := cfg.SecretStore()
+    var notified []string
+    store.OnChange(func(path string) {
+        notified = append(notified, path)
+    })
+
+    if changed, _ := store.Refresh(); len(changed) != 0 {
+        t.Errorf("Без изменений файла обновлений быть не должно, получили %v", changed)
+    }
+
+    os.WriteFile(passwordFile, []byte("second\n"), 0o600)
+    if _, err := store.Refresh(); err != nil {
+        t.Fatalf("Refresh: %v", err)
+    }
+    if got := store.Get("database.password"); got != "second" {
+        t.Errorf("Ожидался обновлённый пароль, получили %q", got)
+    }
+    if len(notified) != 1 || notified[0] != "database.password" {
+        t.Errorf("Ожидалось уведомление об обновлении database.password, получили %v", notified)
+    }
+}
+
+func TestSecretStore_Update(t *testing.T) {
+    cfg := Default()
+    cfg.Security.APIKeys = []string{"old"}
+    store := cfg.SecretStore()
+
+    next := Default()
+    next.Security.APIKeys = []string{"a", "b"}
+    next.Security.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+    changed := store.Update(next)
+
+    if len(changed) != 1 || changed[0] != "security.api_keys" {
+        t.Errorf("Ожидалось обновление только security.api_keys, получили %v", changed)
+    }
+    if got := store.List("security.api_keys"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+        t.Errorf("Ожидались новые ключи, получили %v", got)
+    }
+    if store.Get("security.encryption_key") != "" {
+        t.Error("Ключ шифрования не перезагружается на ходу и не должен обновляться")
+    }
+}
+
+func TestDiff(t *testing.T) {
+    old := Default()
+    next := Default()
+    next.HTTP.Port = 9090
+    next.Cache.Capacity = 200
+    next.Database.Password = "secret"
+
+    changes := Diff(old, next)
+    if len(changes) != 3 {
+        t.Fatalf("Ожидалось 3 изменения, получили %v", changes)
+    }
+    want := []string{"database.password: изменено", `http.port: "8080" -> "9090"`, `cache.capacity: "100" -> "200"`}
+    for i, change := range changes {
+        if change.String() != want[i] {
+            t.Errorf("Изменение %d: %q, ожидали %q", i, change.String(), want[i])
+        }
+    }
+    if changes[1].Reloadable || !changes[2].Reloadable {
+        t.Error("http.port требует перезапуска, cache.capacity - нет")
+    }
+}
+
+func TestValidate_NATSAuth(t *testing.T) {
+    cfg := Default()
+    cfg.NATS.User = "svc"
+    cfg.NATS.Token = "token"
+    cfg.NATS.TLSCert = "client.pem"
+
+    err := cfg.Validate()
+    if err == nil {
+        t.Fatal("Ожидалась ошибка проверки")
+    }
+    for _, want := range []string{"способов аутентификации", "nats.tls_cert"} {
+        if !strings.Contains(err.Error(), want) {
+            t.Errorf("В ошибке нет %q:\n%v", want, err)
+        }
+    }
+}
@@ -0,0 +1,40 @@
+package config
+
+import "fmt"
+
+// Change - изменение одного поля конфигурации.
+type Change struct {
+    Path string
+    // Reloadable - поле помечено тегом reload:"true" и может меняться без перезапуска.
+    Reloadable bool
+    Secret     bool
+    Old, New   string
+}
+
+func (c Change) String() string {
+    if c.Secret {
+        return c.Path + ": изменено"
+    }
+    return fmt.Sprintf("%s: %q -> %q", c.Path, c.Old, c.New)
+}
+
+// Diff возвращает различающиеся поля old и new в порядке объявления.
+func Diff(old, new *Config) []Change {
+    newFields := fields(new)
+
+    var changes []Change
+    for i, f := range fields(old) {
+        before, after := formatValue(f.value), formatValue(newFields[i].value)
+        if before == after {
+            continue
+        }
+        changes = append(changes, Change{
+            Path:       f.path,
+            Reloadable: f.reload,
+            Secret:     f.secret,
+            Old:        before,
+            New:        after,
+        })
+    }
+    return changes
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "reflect"
+
+    "gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Redacted возвращает копию конфигурации, в которой непустые секреты заменены заглушкой.
+func (c *Config) Redacted() *Config {
+    cp := *c
+    for _, f := range fields(&cp) {
+        if !f.secret {
+            continue
+        }
+        switch f.value.Kind() {
+        case reflect.String:
+            if f.value.String() != "" {
+                f.value.SetString(redacted)
+            }
+        case reflect.Slice:
+            masked := make([]string, f.value.Len())
+            for i := range masked {
+                masked[i] = redacted
+            }
+            if len(masked) > 0 {
+                f.value.Set(reflect.ValueOf(masked))
+            }
+        }
+    }
+    return &cp
+}
+
+// Print выводит действующую конфигурацию со скрытыми секретами в формате yaml или json.
+func (c *Config) Print(w io.Writer, format string) error {
+    cfg := c.Redacted()
+    switch format {
+    case "", "yaml":
+        enc := yaml.NewEncoder(w)
+        enc.SetIndent(2)
+        defer enc.Close()
+        return enc.Encode(cfg)
+    case "json":
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(cfg)
+    default:
+        return fmt.Errorf("неизвестный формат %q, ожидается yaml или json", format)
+    }
+}
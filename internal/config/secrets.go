@@ -0,0 +1,248 @@
+package config
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "sync"
+    "time"
+)
+
+// applySecretSources читает секреты из файлов <ИМЯ>_FILE и из каталога secrets.dir.
+// Файл из <ИМЯ>_FILE перекрывает файл и окружение, но не флаг; каталог используется,
+// только если секрет не задан ни переменной, ни флагом.
+func applySecretSources(cfg *Config, lookup func(string) (string, bool), flagSet func(string) bool) []error {
+    var errs []error
+    cfg.secretFiles = make(map[string]string)
+
+    for _, f := range fields(cfg) {
+        if !f.secret || f.env == "" || flagSet(f.path) {
+            continue
+        }
+
+        _, hasValue := lookup(f.env)
+        file, hasFile := lookup(f.env + "_FILE")
+        if hasValue && hasFile {
+            errs = append(errs, fmt.Errorf("%s: заданы одновременно %s и %s_FILE", f.path, f.env, f.env))
+            continue
+        }
+        if !hasFile && !hasValue && cfg.Secrets.Dir != "" {
+            file, hasFile = findSecretFile(cfg.Secrets.Dir, f.env)
+        }
+        if !hasFile {
+            continue
+        }
+
+        value, err := readSecretFile(file)
+        if err != nil {
+            errs = append(errs, fmt.Errorf("%s: %w", f.path, err))
+            continue
+        }
+        if err := setValue(f.value, value); err != nil {
+            errs = append(errs, fmt.Errorf("%s (%s): %w", f.path, file, err))
+            continue
+        }
+        cfg.secretFiles[f.path] = file
+    }
+    return errs
+}
+
+func findSecretFile(dir, env string) (string, bool) {
+    for _, name := range []string{env, strings.ToLower(env)} {
+        path := filepath.Join(dir, name)
+        if info, err := os.Stat(path); err == nil && !info.IsDir() {
+            return path, true
+        }
+    }
+    return "", false
+}
+
+func readSecretFile(path string) (string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", fmt.Errorf("не удалось прочитать файл секрета: %w", err)
+    }
+    return strings.TrimSpace(string(data)), nil
+}
+
+// SecretStore - текущие значения секретов конфигурации. Значения, прочитанные из файлов,
+// обновляются при изменении файлов (см. Watch), поэтому компоненты должны
+// запрашивать их через Get при каждом использовании, а не копировать при запуске.
+type SecretStore struct {
+    mu        sync.RWMutex
+    values    map[string]string
+    files     map[string]string
+    listeners []func(path string)
+}
+
+// SecretStore возвращает секреты конфигурации по путям полей (например, "database.password").
+func (c *Config) SecretStore() *SecretStore {
+    s := &SecretStore{
+        values: make(map[string]string),
+        files:  make(map[string]string),
+    }
+    cp := *c
+    for _, f := range fields(&cp) {
+        if f.secret {
+            s.values[f.path] = formatValue(f.value)
+        }
+    }
+    for path, file := range c.secretFiles {
+        s.files[path] = file
+    }
+    return s
+}
+
+// StaticSecrets возвращает набор секретов, который никогда не обновляется. Удобно в тестах.
+func StaticSecrets(values map[string]string) *SecretStore {
+    s := &SecretStore{values: make(map[string]string), files: map[string]string{}}
+    for path, value := range values {
+        s.values[path] = value
+    }
+    return s
+}
+
+func (s *SecretStore) Get(path string) string {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.values[path]
+}
+
+// List возвращает секрет-список, например security.api_keys.
+func (s *SecretStore) List(path string) []string {
+    return splitList(s.Get(path))
+}
+
+// Func возвращает функцию, читающую актуальное значение секрета.
+func (s *SecretStore) Func(path string) func() string {
+    return func() string {
+        return s.Get(path)
+    }
+}
+
+// OnChange регистрирует обработчик, вызываемый после обновления секрета.
+func (s *SecretStore) OnChange(fn func(path string)) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.listeners = append(s.listeners, fn)
+}
+
+// Update заменяет секреты, которые можно менять без перезапуска (reload:"true"),
+// значениями и файлами из cfg и возвращает пути изменившихся значений.
+func (s *SecretStore) Update(cfg *Config) []string {
+    cp := *cfg
+    var changed []string
+
+    s.mu.Lock()
+    for _, f := range fields(&cp) {
+        if !f.secret || !f.reload {
+            continue
+        }
+        if value := formatValue(f.value); s.values[f.path] != value {
+            s.values[f.path] = value
+            changed = append(changed, f.path)
+        }
+        if file, ok := cfg.secretFiles[f.path]; ok {
+            s.files[f.path] = file
+        } else {
+            delete(s.files, f.path)
+        }
+    }
+    listeners := append([]func(string){}, s.listeners...)
+    s.mu.Unlock()
+
+    for _, path := range changed {
+        for _, fn := range listeners {
+            fn(path)
+        }
+    }
+    return changed
+}
+
+// Refresh перечитывает файлы секретов и возвращает пути изменившихся значений.
+func (s *SecretStore) Refresh() ([]string, error) {
+    s.mu.RLock()
+    files := make(map[string]string, len(s.files))
+    for path, file := range s.files {
+        files[path] = file
+    }
+    s.mu.RUnlock()
+
+    var changed []string
+    var errs []error
+    for path, file := range files {
+        value, err := readSecretFile(file)
+        if err != nil {
+            errs = append(errs, fmt.Errorf("%s: %w", path, err))
+            continue
+        }
+        if isListSecret(path) {
+            value = strings.Join(splitList(value), ",")
+        }
+
+        s.mu.Lock()
+        if s.values[path] != value {
+            s.values[path] = value
+            changed = append(changed, path)
+        }
+        s.mu.Unlock()
+    }
+
+    s.mu.RLock()
+    listeners := append([]func(string){}, s.listeners...)
+    s.mu.RUnlock()
+    for _, path := range changed {
+        for _, fn := range listeners {
+            fn(path)
+        }
+    }
+
+    if len(errs) > 0 {
+        return changed, &ValidationError{Errors: errs}
+    }
+    return changed, nil
+}
+
+// Watch перечитывает файлы секретов с периодом interval до отмены ctx.
+// Файлы могут появиться позже, после перезагрузки конфигурации (см. Update).
+func (s *SecretStore) Watch(ctx context.Context, interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+
+        changed, err := s.Refresh()
+        if err != nil {
+            log.Printf("Не удалось перечитать секреты: %v", err)
+        }
+        for _, path := range changed {
+            log.Printf("Секрет %s обновлён", path)
+        }
+    }
+}
+
+var listSecrets = func() map[string]bool {
+    result := make(map[string]bool)
+    for _, f := range fields(Default()) {
+        if f.secret && f.value.Kind() == reflect.Slice {
+            result[f.path] = true
+        }
+    }
+    return result
+}()
+
+func isListSecret(path string) bool {
+    return listSecrets[path]
+}
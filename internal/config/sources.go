@@ -0,0 +1,239 @@
+package config
+
+import (
+    "bytes"
+    "encoding"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// field - конечное поле конфигурации, которое можно задать из окружения или флагом.
+type field struct {
+    path   string
+    env    string
+    usage  string
+    secret bool
+    reload bool
+    value  reflect.Value
+}
+
+// fields обходит конфигурацию и возвращает её конечные поля в порядке объявления.
+func fields(cfg *Config) []field {
+    var result []field
+    var walk func(v reflect.Value, prefix string)
+    walk = func(v reflect.Value, prefix string) {
+        t := v.Type()
+        for i := 0; i < t.NumField(); i++ {
+            sf := t.Field(i)
+            name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+            if name == "" || name == "-" {
+                continue
+            }
+            fv := v.Field(i)
+            if sf.Type.Kind() == reflect.Struct && !isTextValue(fv) {
+                walk(fv, prefix+name+".")
+                continue
+            }
+            result = append(result, field{
+                path:   prefix + name,
+                env:    sf.Tag.Get("env"),
+                usage:  sf.Tag.Get("usage"),
+                secret: sf.Tag.Get("secret") == "true",
+                reload: sf.Tag.Get("reload") == "true",
+                value:  fv,
+            })
+        }
+    }
+    walk(reflect.ValueOf(cfg).Elem(), "")
+    return result
+}
+
+func isTextValue(v reflect.Value) bool {
+    _, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+    return ok
+}
+
+// setValue присваивает полю значение из строки окружения или флага.
+// Списки задаются через запятую.
+func setValue(v reflect.Value, raw string) error {
+    if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+        return u.UnmarshalText([]byte(raw))
+    }
+
+    switch v.Kind() {
+    case reflect.String:
+        v.SetString(raw)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, v.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("некорректное целое число %q", raw)
+        }
+        v.SetInt(n)
+    case reflect.Float32, reflect.Float64:
+        f, err := strconv.ParseFloat(strings.TrimSpace(raw), v.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("некорректное число %q", raw)
+        }
+        v.SetFloat(f)
+    case reflect.Bool:
+        b, err := strconv.ParseBool(strings.TrimSpace(raw))
+        if err != nil {
+            return fmt.Errorf("некорректное логическое значение %q", raw)
+        }
+        v.SetBool(b)
+    case reflect.Slice:
+        if v.Type().Elem().Kind() != reflect.String {
+            return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+        }
+        v.Set(reflect.ValueOf(splitList(raw)))
+    default:
+        return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+    }
+    return nil
+}
+
+// splitList разбивает список, записанный через запятую или по одному элементу в строке.
+func splitList(raw string) []string {
+    var items []string
+    for _, item := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' }) {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// formatValue возвращает значение поля в том же виде, в котором его принимает setValue.
+func formatValue(v reflect.Value) string {
+    if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+        text, _ := m.MarshalText()
+        return string(text)
+    }
+    if v.Kind() == reflect.Slice {
+        items := make([]string, v.Len())
+        for i := range items {
+            items[i] = fmt.Sprint(v.Index(i).Interface())
+        }
+        return strings.Join(items, ",")
+    }
+    return fmt.Sprint(v.Interface())
+}
+
+// loadFile накладывает на cfg значения из файла. Формат определяется по расширению:
+// .json читается как JSON, остальные - как YAML. Неизвестные ключи считаются ошибкой.
+func loadFile(cfg *Config, path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("не удалось прочитать файл конфигурации: %w", err)
+    }
+
+    if strings.EqualFold(filepath.Ext(path), ".json") {
+        dec := json.NewDecoder(bytes.NewReader(data))
+        dec.DisallowUnknownFields()
+        err = dec.Decode(cfg)
+    } else {
+        dec := yaml.NewDecoder(bytes.NewReader(data))
+        dec.KnownFields(true)
+        err = dec.Decode(cfg)
+        if errors.Is(err, io.EOF) {
+            // Пустой файл не меняет значений по умолчанию.
+            err = nil
+        }
+    }
+    if err != nil {
+        return fmt.Errorf("некорректный файл конфигурации %s: %w", path, err)
+    }
+    return nil
+}
+
+// applyEnv накладывает на cfg значения переменных окружения и возвращает ошибки разбора всех переменных.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) []error {
+    var errs []error
+    for _, f := range fields(cfg) {
+        if f.env == "" {
+            continue
+        }
+        raw, ok := lookup(f.env)
+        if !ok {
+            continue
+        }
+        if err := setValue(f.value, raw); err != nil {
+            errs = append(errs, fmt.Errorf("%s (%s): %w", f.path, f.env, err))
+        }
+    }
+    return errs
+}
+
+// flagOverrides запоминает значения флагов, чтобы применить их после файла и окружения.
+type flagOverrides struct {
+    values map[string]string
+    order  []string
+}
+
+type flagValue struct {
+    name      string
+    overrides *flagOverrides
+    def       string
+}
+
+func (f *flagValue) String() string {
+    if f == nil {
+        return ""
+    }
+    return f.def
+}
+
+func (f *flagValue) Set(raw string) error {
+    if _, seen := f.overrides.values[f.name]; !seen {
+        f.overrides.order = append(f.overrides.order, f.name)
+    }
+    f.overrides.values[f.name] = raw
+    return nil
+}
+
+// registerFlags объявляет флаг для каждого поля конфигурации.
+func registerFlags(fs *flag.FlagSet, cfg *Config) *flagOverrides {
+    overrides := &flagOverrides{values: make(map[string]string)}
+    for _, f := range fields(cfg) {
+        usage := f.usage
+        if f.env != "" {
+            usage += " (" + f.env + ")"
+        }
+        def := formatValue(f.value)
+        if f.secret {
+            def = ""
+        }
+        fs.Var(&flagValue{name: f.path, overrides: overrides, def: def}, f.path, usage)
+    }
+    return overrides
+}
+
+func (o *flagOverrides) isSet(path string) bool {
+    _, ok := o.values[path]
+    return ok
+}
+
+func (o *flagOverrides) apply(cfg *Config) []error {
+    byPath := make(map[string]field)
+    for _, f := range fields(cfg) {
+        byPath[f.path] = f
+    }
+
+    var errs []error
+    for _, name := range o.order {
+        if err := setValue(byPath[name].value, o.values[name]); err != nil {
+            errs = append(errs, fmt.Errorf("-%s: %w", name, err))
+        }
+    }
+    return errs
+}
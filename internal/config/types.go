@@ -0,0 +1,80 @@
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Duration - time.Duration, которая в файле, окружении и флагах записывается строкой вида "15s" или "1h30m".
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+    return time.Duration(d)
+}
+
+func (d Duration) String() string {
+    return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+    return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+    parsed, err := time.ParseDuration(string(text))
+    if err != nil {
+        return fmt.Errorf("некорректная длительность %q", text)
+    }
+    *d = Duration(parsed)
+    return nil
+}
+
+// ByteSize - размер в байтах. Допускает суффиксы B, KB, MB, GB (по 1024)
+// и их варианты KiB, MiB, GiB, например "64KB" или "1MiB".
+type ByteSize int64
+
+var byteUnits = []struct {
+    suffix string
+    size   int64
+}{
+    {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+    {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+    {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+    {"B", 1},
+}
+
+func (s ByteSize) String() string {
+    for _, unit := range []struct {
+        suffix string
+        size   int64
+    }{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+        if s != 0 && int64(s)%unit.size == 0 {
+            return strconv.FormatInt(int64(s)/unit.size, 10) + unit.suffix
+        }
+    }
+    return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+func (s ByteSize) MarshalText() ([]byte, error) {
+    return []byte(s.String()), nil
+}
+
+func (s *ByteSize) UnmarshalText(text []byte) error {
+    value := strings.ToUpper(strings.TrimSpace(string(text)))
+    multiplier := int64(1)
+    for _, unit := range byteUnits {
+        if strings.HasSuffix(value, unit.suffix) {
+            value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+            multiplier = unit.size
+            break
+        }
+    }
+    n, err := strconv.ParseInt(value, 10, 64)
+    if err != nil || n < 0 {
+        return fmt.Errorf("некорректный размер %q", text)
+    }
+    *s = ByteSize(n * multiplier)
+    return nil
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/jackc/pgx/v5"
+    "wb-order-hub/internal/repository"
+)
+
+var _ repository.AnalyticsRepository = (*Repository)(nil)
+
+// Выражения разрезов аналитики. NULL группируется вместе с пустой строкой, как в модели.
+var (
+    orderKeys = map[repository.Dimension]string{
+        "":                           "''",
+        repository.ByDeliveryService: "coalesce(o.delivery_service, '')",
+        repository.ByBank:            "coalesce(p.bank, '')",
+        repository.ByProvider:        "coalesce(p.provider, '')",
+        repository.ByRegion:          "coalesce(d.region, '')",
+        repository.ByCity:            "coalesce(d.city, '')",
+    }
+    itemKeys = map[repository.Dimension]string{
+        repository.ByBrand: "coalesce(i.brand, '')",
+        repository.ByNmID:  "coalesce(i.nm_id::text, '')",
+    }
+)
+
+// rollupKeys - разрезы итогов заказов, которые есть в дневных итогах daily_orders.
+var rollupKeys = map[repository.Dimension]string{
+    "":                           "''",
+    repository.ByDeliveryService: "delivery_service",
+}
+
+// analyticsDay - день date_created в UTC.
+const analyticsDay = "(o.date_created AT TIME ZONE 'UTC')::date"
+
+// OrderTotals считает итоги заказов по дневным итогам daily_orders, если фильтр и разрез
+// это позволяют, иначе - одним запросом по индексу orders_date_created_idx; товары заказа
+// подсчитываются по индексу items_order_uid_idx.
+func (r *Repository) OrderTotals(ctx context.Context, filter repository.AnalyticsFilter, by repository.Dimension) ([]repository.OrderTotals, error) {
+    key, ok := orderKeys[by]
+    if !ok {
+        return nil, repository.CheckDimension(by, repository.OrderDimensions)
+    }
+    var rows pgx.Rows
+    var err error
+    if rollupKey, ok := rollupKeys[by]; ok && filter.Brand == "" && rollupFilter(filter) {
+        where, args := rollupClause(filter)
+        rows, err = r.pool.Query(ctx, `
+            SELECT day, currency, `+rollupKey+`, sum(orders)::bigint,
+                sum(revenue)::bigint, sum(items)::bigint, sum(sale)::bigint
+            FROM daily_orders
+            WHERE `+strings.Join(where, " AND ")+`
+            GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, args...)
+    } else {
+        where, args := analyticsClause(filter, "EXISTS (SELECT 1 FROM items b WHERE b.order_uid = o.order_uid AND b.brand = $%d)")
+        rows, err = r.pool.Query(ctx, `
+            SELECT `+analyticsDay+`, coalesce(p.currency, ''), `+key+`, count(*),
+                coalesce(sum(p.amount), 0)::bigint, coalesce(sum(i.items), 0)::bigint, coalesce(sum(i.sale), 0)::bigint
+            FROM orders o
+            LEFT JOIN payment p ON p.order_uid = o.order_uid
+            LEFT JOIN delivery d ON d.order_uid = o.order_uid
+            LEFT JOIN LATERAL (SELECT count(*) AS items, sum(sale) AS sale FROM items WHERE items.order_uid = o.order_uid) i ON true
+            WHERE `+strings.Join(where, " AND ")+`
+            GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, args...)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("не удалось выполнить запрос итогов заказов: %w", err)
+    }
+    totals, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (repository.OrderTotals, error) {
+        var t repository.OrderTotals
+        err := row.Scan(&t.Day, &t.Currency, &t.Key, &t.Orders, &t.Revenue, &t.Items, &t.Sale)
+        return t, err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось просканировать итоги заказов: %w", err)
+    }
+    return totals, nil
+}
+
+// ItemTotals по брендам считается по дневным итогам daily_brands, если фильтр это позволяет.
+func (r *Repository) ItemTotals(ctx context.Context, filter repository.AnalyticsFilter, by repository.Dimension) ([]repository.ItemTotals, error) {
+    key, ok := itemKeys[by]
+    if !ok {
+        return nil, repository.CheckDimension(by, repository.ItemDimensions)
+    }
+    var rows pgx.Rows
+    var err error
+    if by == repository.ByBrand && rollupFilter(filter) {
+        where, args := rollupClause(filter)
+        rows, err = r.pool.Query(ctx, `
+            SELECT day, currency, brand, sum(units)::bigint, sum(revenue)::bigint, sum(sale)::bigint
+            FROM daily_brands
+            WHERE `+strings.Join(where, " AND ")+`
+            GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, args...)
+    } else {
+        where, args := analyticsClause(filter, "i.brand = $%d")
+        rows, err = r.pool.Query(ctx, `
+            SELECT `+analyticsDay+`, coalesce(p.currency, ''), `+key+`, count(*),
+                coalesce(sum(i.total_price), 0)::bigint, coalesce(sum(i.sale), 0)::bigint
+            FROM items i
+            JOIN orders o ON o.order_uid = i.order_uid
+            LEFT JOIN payment p ON p.order_uid = o.order_uid
+            LEFT JOIN delivery d ON d.order_uid = o.order_uid
+            WHERE `+strings.Join(where, " AND ")+`
+            GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, args...)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("не удалось выполнить запрос итогов товаров: %w", err)
+    }
+    totals, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (repository.ItemTotals, error) {
+        var t repository.ItemTotals
+        err := row.Scan(&t.Day, &t.Currency, &t.Key, &t.Units, &t.Revenue, &t.Sale)
+        return t, err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось просканировать итоги товаров: %w", err)
+    }
+    return totals, nil
+}
+
+// analyticsClause строит условия фильтра аналитики над orders o, payment p и delivery d.
+// brandCondition - условие на бренд с местом для номера параметра.
+func analyticsClause(filter repository.AnalyticsFilter, brandCondition string) ([]string, []any) {
+    conditions := []string{"o.date_created IS NOT NULL"}
+    var args []any
+    add := func(condition string, value any) {
+        args = append(args, value)
+        conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+    }
+
+    if !filter.From.IsZero() {
+        add("o.date_created >= $%d", filter.From)
+    }
+    if !filter.To.IsZero() {
+        add("o.date_created < $%d", filter.To)
+    }
+    for _, f := range []struct{ condition, value string }{
+        {"p.currency = $%d", filter.Currency},
+        {"o.delivery_service = $%d", filter.DeliveryService},
+        {"p.bank = $%d", filter.Bank},
+        {"p.provider = $%d", filter.Provider},
+        {"d.region = $%d", filter.Region},
+        {"d.city = $%d", filter.City},
+        {brandCondition, filter.Brand},
+    } {
+        if f.value != "" {
+            add(f.condition, f.value)
+        }
+    }
+    return conditions, args
+}
+
+// rollupFilter сообщает, можно ли отобрать заказы по дневным итогам: границы периода -
+// начала дней UTC, а условия - только на валюту оплаты, службу доставки и бренд.
+func rollupFilter(filter repository.AnalyticsFilter) bool {
+    return repository.Day(filter.From).Equal(filter.From) && repository.Day(filter.To).Equal(filter.To) &&
+        filter.Bank == "" && filter.Provider == "" && filter.Region == "" && filter.City == ""
+}
+
+// rollupClause строит условия фильтра над таблицами дневных итогов; бренд есть только в daily_brands.
+func rollupClause(filter repository.AnalyticsFilter) ([]string, []any) {
+    conditions := []string{"true"}
+    var args []any
+    add := func(condition string, value any) {
+        args = append(args, value)
+        conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+    }
+
+    if !filter.From.IsZero() {
+        add("day >= $%d", filter.From.UTC())
+    }
+    if !filter.To.IsZero() {
+        add("day < $%d", filter.To.UTC())
+    }
+    for _, f := range []struct{ condition, value string }{
+        {"currency = $%d", filter.Currency},
+        {"delivery_service = $%d", filter.DeliveryService},
+        {"brand = $%d", filter.Brand},
+    } {
+        if f.value != "" {
+            add(f.condition, f.value)
+        }
+    }
+    return conditions, args
+}
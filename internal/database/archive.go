@@ -0,0 +1,84 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/pgxpool"
+    "wb-order-hub/internal/archive"
+)
+
+// Archive - реализация archive.Store поверх таблицы raw_messages.
+type Archive struct {
+    pool        *pgxpool.Pool
+    compression archive.Compression
+}
+
+var _ archive.Store = (*Archive)(nil)
+
+// NewArchive создаёт хранилище, сжимающее новые сообщения способом compression.
+// Уже сохранённые сообщения читаются тем способом, которым были записаны.
+func NewArchive(pool *pgxpool.Pool, compression archive.Compression) *Archive {
+    return &Archive{pool: pool, compression: compression}
+}
+
+func (a *Archive) Save(ctx context.Context, msg archive.Message) error {
+    payload, err := archive.Compress(msg.Data, a.compression)
+    if err != nil {
+        return fmt.Errorf("не удалось сжать сообщение %d: %w", msg.Sequence, err)
+    }
+    var orderUID *string
+    if msg.OrderUID != "" {
+        orderUID = &msg.OrderUID
+    }
+    _, err = a.pool.Exec(ctx, `
+        INSERT INTO raw_messages (sequence, subject, redelivered, received_at, order_uid, compression, payload)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+        int64(msg.Sequence), msg.Subject, msg.Redelivered, msg.ReceivedAt, orderUID, string(a.compression), payload,
+    )
+    if err != nil {
+        return fmt.Errorf("не удалось сохранить исходное сообщение %d: %w", msg.Sequence, err)
+    }
+    return nil
+}
+
+func (a *Archive) Messages(ctx context.Context, uid string) ([]archive.Message, error) {
+    rows, err := a.pool.Query(ctx, `
+        SELECT sequence, subject, redelivered, received_at, order_uid, compression, payload
+        FROM raw_messages WHERE order_uid = $1 ORDER BY received_at DESC, id DESC`, uid)
+    if err != nil {
+        return nil, fmt.Errorf("не удалось выполнить запрос к исходным сообщениям: %w", err)
+    }
+    messages, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (archive.Message, error) {
+        var msg archive.Message
+        var sequence int64
+        var compression string
+        if err := row.Scan(&sequence, &msg.Subject, &msg.Redelivered, &msg.ReceivedAt, &msg.OrderUID, &compression, &msg.Data); err != nil {
+            return msg, err
+        }
+        msg.Sequence = uint64(sequence)
+        data, err := archive.Decompress(msg.Data, archive.Compression(compression))
+        if err != nil {
+            return msg, fmt.Errorf("сообщение %d: %w", msg.Sequence, err)
+        }
+        msg.Data = data
+        return msg, nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось прочитать исходные сообщения: %w", err)
+    }
+    if len(messages) == 0 {
+        return nil, archive.ErrNotFound
+    }
+    return messages, nil
+}
+
+func (a *Archive) Prune(ctx context.Context, before time.Time) (int64, error) {
+    tag, err := a.pool.Exec(ctx, "DELETE FROM raw_messages WHERE received_at < $1", before)
+    if err != nil {
+        return 0, fmt.Errorf("не удалось удалить устаревшие исходные сообщения: %w", err)
+    }
+    return tag.RowsAffected(), nil
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+    "testing"
+    "time"
+)
+
+func TestNewPoolConfig(t *testing.T) {
+    password := `p a'ss\`
+    poolConfig, err := newPoolConfig(DBConfig{
+        Host: "db", Port: 5432, User: "order_hub", Password: password, DBName: "orders",
+        StatementTimeout: 5 * time.Second,
+    })
+    if err != nil {
+        t.Fatalf("newPoolConfig: %v", err)
+    }
+    if cc := poolConfig.ConnConfig; cc.Password != password || cc.User != "order_hub" || cc.Database != "orders" {
+        t.Errorf("Параметры подключения разобраны неверно: пароль %q, пользователь %q, база %q", cc.Password, cc.User, cc.Database)
+    }
+    if got := poolConfig.ConnConfig.RuntimeParams["statement_timeout"]; got != "5000" {
+        t.Errorf("statement_timeout: %q", got)
+    }
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "reflect"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "wb-order-hub/internal/metrics"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// Документ заказа - колонка orders.document: заказ целиком, как его возвращает GetByUID.
+// Он пишется в той же транзакции, что и таблицы заказа, и читается вместо них: заказ
+// и восстановление кэша обходятся одним запросом вместо четырёх. Источник истины - таблицы:
+// в них пишут события и смена статусов, по ним строится аналитика. CheckDocuments сверяет
+// с ними документы и перестраивает разошедшиеся.
+
+// documentOf кодирует заказ в документ. Время приводится к виду, в котором его
+// возвращают колонки TIMESTAMPTZ, а товары без списка кодируются как [],
+// чтобы заказ из документа совпадал с заказом из таблиц.
+func documentOf(order models.Order) ([]byte, error) {
+    order.DateCreated = storedTime(order.DateCreated)
+    order.Payment.PaymentDt = storedTime(order.Payment.PaymentDt)
+    if order.Items == nil {
+        order.Items = []models.Item{}
+    }
+    return json.Marshal(order)
+}
+
+func parseDocument(data []byte) (models.Order, error) {
+    var order models.Order
+    if err := json.Unmarshal(data, &order); err != nil {
+        return models.Order{}, err
+    }
+    if order.Items == nil {
+        order.Items = []models.Item{}
+    }
+    return order, nil
+}
+
+// scanBatch читает строки orderColumns, document. documents[i] - документ orders[i] или nil.
+func scanBatch(rows pgx.Rows) (orders []models.Order, documents [][]byte, err error) {
+    orders, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Order, error) {
+        var document []byte
+        order, err := scanOrderWith(row, &document)
+        documents = append(documents, document)
+        return order, err
+    })
+    if err != nil {
+        return nil, nil, fmt.Errorf("не удалось просканировать данные заказа: %w", err)
+    }
+    return orders, documents, nil
+}
+
+// fromDocuments заменяет заказы их документами. Заказы без документа или с неразборчивым
+// документом догружаются из таблиц.
+func fromDocuments(ctx context.Context, q querier, orders []models.Order, documents [][]byte) error {
+    var bare []int
+    for i, document := range documents {
+        if document == nil {
+            bare = append(bare, i)
+            continue
+        }
+        order, err := parseDocument(document)
+        if err != nil {
+            log.Printf("Документ заказа %s не разобран, заказ читается из таблиц: %v", orders[i].OrderUID, err)
+            bare = append(bare, i)
+            continue
+        }
+        orders[i] = order
+    }
+    if len(bare) == 0 {
+        return nil
+    }
+
+    loaded := make([]models.Order, len(bare))
+    for j, i := range bare {
+        loaded[j] = orders[i]
+    }
+    if err := loadDetails(ctx, q, loaded); err != nil {
+        return err
+    }
+    for j, i := range bare {
+        orders[i] = loaded[j]
+    }
+    return nil
+}
+
+// rebuildDocument перестраивает документ заказа по таблицам. Строка заказа должна быть
+// заблокирована транзакцией tx.
+func rebuildDocument(ctx context.Context, tx pgx.Tx, uid string) error {
+    rows, err := tx.Query(ctx, "SELECT "+orderColumns+" FROM orders WHERE order_uid = $1", uid)
+    if err != nil {
+        return fmt.Errorf("не удалось выполнить запрос к заказу: %w", err)
+    }
+    order, err := pgx.CollectOneRow(rows, scanOrder)
+    if err != nil {
+        return fmt.Errorf("не удалось просканировать данные заказа: %w", err)
+    }
+    orders := []models.Order{order}
+    if err := loadDetails(ctx, tx, orders); err != nil {
+        return err
+    }
+    return writeDocument(ctx, tx, orders[0])
+}
+
+// writeDocument записывает документ заказа.
+func writeDocument(ctx context.Context, tx pgx.Tx, order models.Order) error {
+    document, err := documentOf(order)
+    if err != nil {
+        return fmt.Errorf("не удалось закодировать документ заказа: %w", err)
+    }
+    if _, err := tx.Exec(ctx, "UPDATE orders SET document = $2 WHERE order_uid = $1", order.OrderUID, document); err != nil {
+        return fmt.Errorf("не удалось записать документ заказа: %w", err)
+    }
+    return nil
+}
+
+// DocumentReport - итог сверки документов заказов с таблицами.
+type DocumentReport struct {
+    Checked int
+    // Missing - заказы без документа, например записанные до его появления.
+    Missing []string
+    // Mismatched - заказы, документ которых расходится с таблицами или не разбирается.
+    Mismatched []string
+    Repaired   int
+}
+
+// CheckDocuments сверяет документы заказов под фильтром с таблицами. С repair отсутствующие
+// и разошедшиеся документы перестраиваются по таблицам.
+func (r *Repository) CheckDocuments(ctx context.Context, filter repository.ListFilter, repair bool) (DocumentReport, error) {
+    var report DocumentReport
+    err := r.batches(ctx, filter, func(orders []models.Order, documents [][]byte) error {
+        if err := loadDetails(ctx, r.pool, orders); err != nil {
+            return err
+        }
+        for i, order := range orders {
+            report.Checked++
+            if documents[i] == nil {
+                report.Missing = append(report.Missing, order.OrderUID)
+            } else if stored, err := parseDocument(documents[i]); err != nil || !reflect.DeepEqual(stored, order) {
+                report.Mismatched = append(report.Mismatched, order.OrderUID)
+            } else {
+                continue
+            }
+            if !repair {
+                continue
+            }
+            if err := r.repairDocument(ctx, order.OrderUID); err != nil {
+                return err
+            }
+            report.Repaired++
+        }
+        return nil
+    })
+    return report, err
+}
+
+// repairDocument перестраивает документ под блокировкой строки заказа, чтобы не затереть
+// изменение, записанное после сверки. Удалённый за это время заказ пропускается.
+func (r *Repository) repairDocument(ctx context.Context, uid string) error {
+    return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+        var locked string
+        err := tx.QueryRow(ctx, "SELECT order_uid FROM orders WHERE order_uid = $1 FOR UPDATE", uid).Scan(&locked)
+        if errors.Is(err, pgx.ErrNoRows) {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("не удалось заблокировать заказ: %w", err)
+        }
+        return rebuildDocument(ctx, tx, uid)
+    })
+}
+
+// WatchDocuments раз в interval сверяет все документы с таблицами и перестраивает
+// отсутствующие и разошедшиеся до отмены ctx.
+func (r *Repository) WatchDocuments(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+        report, err := r.CheckDocuments(ctx, repository.ListFilter{}, true)
+        metrics.DocumentsMismatched.Add(int64(len(report.Mismatched)))
+        metrics.DocumentsRepaired.Add(int64(report.Repaired))
+        for _, uid := range report.Mismatched {
+            log.Printf("Документ заказа %s расходился с таблицами", uid)
+        }
+        switch {
+        case err != nil && ctx.Err() == nil:
+            log.Printf("Не удалось сверить документы заказов: %v", err)
+        case len(report.Missing) > 0 || len(report.Mismatched) > 0:
+            log.Printf("Сверка документов заказов: проверено %d, без документа %d, расходились %d, перестроено %d",
+                report.Checked, len(report.Missing), len(report.Mismatched), report.Repaired)
+        }
+    }
+}
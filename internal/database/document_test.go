@@ -0,0 +1,38 @@
+package database
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func TestDocument(t *testing.T) {
+    order := repotest.SampleOrder("doc")
+    order.DateCreated = time.Date(2021, 11, 26, 9, 22, 19, 123456789, time.FixedZone("MSK", 3*60*60))
+    order.Extra = models.Extra{"source": []byte(`"app"`)}
+    order.Items[0].Extra = models.Extra{"tags": []byte(`["a","b"]`)}
+
+    document, err := documentOf(order)
+    if err != nil {
+        t.Fatalf("documentOf: %v", err)
+    }
+    got, err := parseDocument(document)
+    if err != nil {
+        t.Fatalf("parseDocument: %v", err)
+    }
+    // Время хранится так же, как его возвращает колонка TIMESTAMPTZ: в UTC с точностью до микросекунд.
+    want := order
+    want.DateCreated = time.Date(2021, 11, 26, 6, 22, 19, 123456000, time.UTC)
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("Заказ из документа отличается:\nполучили %+v\nожидали  %+v", got, want)
+    }
+
+    order.Items = nil
+    document, _ = documentOf(order)
+    if got, err = parseDocument(document); err != nil || !reflect.DeepEqual(got.Items, []models.Item{}) {
+        t.Errorf("Заказ без товаров должен читаться с пустым списком: %s, %v", document, err)
+    }
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/pgxpool"
+    "wb-order-hub/internal/events"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+var _ repository.VersionedRepository = (*Repository)(nil)
+
+func (r *Repository) Version(ctx context.Context, uid string) (int64, error) {
+    var version int64
+    err := r.pool.QueryRow(ctx, "SELECT version FROM orders WHERE order_uid = $1", uid).Scan(&version)
+    if errors.Is(err, pgx.ErrNoRows) {
+        return 0, repository.ErrNotFound
+    }
+    if err != nil {
+        return 0, fmt.Errorf("не удалось получить версию заказа: %w", err)
+    }
+    return version, nil
+}
+
+// Update блокирует строку заказа, проверяет версию, читает заказ в той же транзакции
+// и перезаписывает доставку, оплату, товары и документ. Поля самого заказа события не меняют.
+func (r *Repository) Update(ctx context.Context, uid string, version int64, update repository.Update) (models.Order, error) {
+    var order models.Order
+    err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+        rows, err := tx.Query(ctx, "SELECT "+orderColumns+", version FROM orders WHERE order_uid = $1 FOR UPDATE", uid)
+        if err != nil {
+            return fmt.Errorf("не удалось заблокировать заказ: %w", err)
+        }
+        var current int64
+        order, err = pgx.CollectOneRow(rows, func(row pgx.CollectableRow) (models.Order, error) {
+            return scanOrderWith(row, &current)
+        })
+        if errors.Is(err, pgx.ErrNoRows) {
+            return repository.ErrNotFound
+        }
+        if err != nil {
+            return fmt.Errorf("не удалось просканировать данные заказа: %w", err)
+        }
+        if current != version {
+            return repository.ErrVersionConflict
+        }
+
+        orders := []models.Order{order}
+        if err := loadDetails(ctx, tx, orders); err != nil {
+            return err
+        }
+        previous := orders[0]
+        order = previous
+        order.Items = append([]models.Item(nil), previous.Items...)
+        if err := update.Apply(&order); err != nil {
+            return err
+        }
+
+        rollupsBefore, err := readRollups(ctx, tx, uid)
+        if err != nil {
+            return err
+        }
+        if err := writeDetails(ctx, tx, order); err != nil {
+            return err
+        }
+        if err := writeRollups(ctx, tx, uid, rollupsBefore); err != nil {
+            return err
+        }
+        if err := insertStatusChanges(ctx, tx, models.StatusChanges(&previous, order), update.Source, update.Comment); err != nil {
+            return err
+        }
+        if _, err := tx.Exec(ctx, "UPDATE orders SET version = version + 1 WHERE order_uid = $1", uid); err != nil {
+            return fmt.Errorf("не удалось обновить версию заказа: %w", err)
+        }
+        return writeDocument(ctx, tx, order)
+    })
+    if err != nil {
+        return models.Order{}, err
+    }
+    return order, nil
+}
+
+// ParkingLot - реализация events.ParkingLot поверх таблицы parked_events.
+type ParkingLot struct {
+    pool *pgxpool.Pool
+}
+
+var _ events.ParkingLot = (*ParkingLot)(nil)
+
+func NewParkingLot(pool *pgxpool.Pool) *ParkingLot {
+    return &ParkingLot{pool: pool}
+}
+
+func (p *ParkingLot) Park(ctx context.Context, e events.Event) error {
+    data, err := json.Marshal(e)
+    if err != nil {
+        return err
+    }
+    _, err = p.pool.Exec(ctx, `
+        INSERT INTO parked_events (order_uid, version, type, event) VALUES ($1, $2, $3, $4)
+        ON CONFLICT (order_uid, version) DO NOTHING`,
+        e.OrderUID, e.Version, string(e.Type), data,
+    )
+    if err != nil {
+        return fmt.Errorf("не удалось сохранить отложенное событие: %w", err)
+    }
+    return nil
+}
+
+func (p *ParkingLot) Get(ctx context.Context, uid string, version int64) (events.Event, bool, error) {
+    var data []byte
+    err := p.pool.QueryRow(ctx, "SELECT event FROM parked_events WHERE order_uid = $1 AND version = $2", uid, version).Scan(&data)
+    if errors.Is(err, pgx.ErrNoRows) {
+        return events.Event{}, false, nil
+    }
+    if err != nil {
+        return events.Event{}, false, fmt.Errorf("не удалось прочитать отложенное событие: %w", err)
+    }
+    var e events.Event
+    if err := json.Unmarshal(data, &e); err != nil {
+        return events.Event{}, false, fmt.Errorf("отложенное событие заказа %s версии %d: %w", uid, version, err)
+    }
+    return e, true, nil
+}
+
+func (p *ParkingLot) Release(ctx context.Context, uid string, version int64) error {
+    if _, err := p.pool.Exec(ctx, "DELETE FROM parked_events WHERE order_uid = $1 AND version <= $2", uid, version); err != nil {
+        return fmt.Errorf("не удалось удалить отложенные события: %w", err)
+    }
+    return nil
+}
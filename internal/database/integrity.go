@@ -0,0 +1,73 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/jackc/pgx/v5"
+    "wb-order-hub/internal/repository"
+)
+
+var _ repository.IntegrityChecker = (*Repository)(nil)
+
+// childTables - таблицы частей заказа, связанные с orders по order_uid.
+var childTables = []string{"delivery", "payment", "items"}
+
+// OrphanRows ищет строки delivery, payment и items, для которых нет заказа.
+// Внешние ключи не дают их создать, но в базах, заполненных в обход сервиса
+// или до появления ключей, такие строки встречаются.
+func (r *Repository) OrphanRows(ctx context.Context) ([]repository.ChildRows, error) {
+    var parts []string
+    for _, table := range childTables {
+        parts = append(parts, fmt.Sprintf(`
+            SELECT '%[1]s', c.order_uid, count(*)::int FROM %[1]s c
+            WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = c.order_uid)
+            GROUP BY c.order_uid`, table))
+    }
+    return r.queryChildRows(ctx, strings.Join(parts, " UNION ALL ")+" ORDER BY 2, 1")
+}
+
+// MissingRows ищет заказы под фильтром, у которых нет строки доставки, оплаты или ни одного товара.
+func (r *Repository) MissingRows(ctx context.Context, filter repository.ListFilter) ([]repository.ChildRows, error) {
+    where, args := filterClause(filter)
+    var parts []string
+    for _, table := range childTables {
+        conditions := append([]string{fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s c WHERE c.order_uid = orders.order_uid)", table)}, where...)
+        parts = append(parts, fmt.Sprintf("SELECT '%s', order_uid, 0 FROM orders WHERE %s", table, strings.Join(conditions, " AND ")))
+    }
+    return r.queryChildRows(ctx, strings.Join(parts, " UNION ALL ")+" ORDER BY 2, 1", args...)
+}
+
+// DeleteOrphanRows удаляет строки частей заказа без заказа в одной транзакции.
+func (r *Repository) DeleteOrphanRows(ctx context.Context) (int64, error) {
+    var deleted int64
+    err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+        for _, table := range childTables {
+            tag, err := tx.Exec(ctx, fmt.Sprintf(
+                "DELETE FROM %s c WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = c.order_uid)", table))
+            if err != nil {
+                return fmt.Errorf("не удалось удалить строки %s без заказа: %w", table, err)
+            }
+            deleted += tag.RowsAffected()
+        }
+        return nil
+    })
+    return deleted, err
+}
+
+func (r *Repository) queryChildRows(ctx context.Context, query string, args ...any) ([]repository.ChildRows, error) {
+    rows, err := r.pool.Query(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("не удалось проверить части заказов: %w", err)
+    }
+    result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (repository.ChildRows, error) {
+        var c repository.ChildRows
+        err := row.Scan(&c.Table, &c.OrderUID, &c.Rows)
+        return c, err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось просканировать части заказов: %w", err)
+    }
+    return result, nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+    "context"
+    "embed"
+    "fmt"
+    "io/fs"
+    "path"
+    "sort"
+    "strings"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID - ключ advisory-блокировки, не дающей двум процессам применять миграции одновременно.
+const migrationLockID = 7311823
+
+// Migration - файл migrations/<версия>_<описание>.sql.
+type Migration struct {
+    Version string
+    Name    string
+    SQL     string
+}
+
+// Migrations возвращает все миграции в порядке применения.
+func Migrations() ([]Migration, error) {
+    entries, err := fs.Glob(migrationFiles, "migrations/*.sql")
+    if err != nil {
+        return nil, err
+    }
+    sort.Strings(entries)
+
+    migrations := make([]Migration, 0, len(entries))
+    for _, entry := range entries {
+        data, err := migrationFiles.ReadFile(entry)
+        if err != nil {
+            return nil, err
+        }
+        version, name, _ := strings.Cut(strings.TrimSuffix(path.Base(entry), ".sql"), "_")
+        migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+    }
+    return migrations, nil
+}
+
+// AppliedMigrations возвращает версии уже применённых миграций.
+func AppliedMigrations(ctx context.Context, pool *pgxpool.Pool) (map[string]bool, error) {
+    if err := ensureMigrationsTable(ctx, pool); err != nil {
+        return nil, err
+    }
+    rows, _ := pool.Query(ctx, "SELECT version FROM schema_migrations")
+    versions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+    if err != nil {
+        return nil, fmt.Errorf("не удалось прочитать применённые миграции: %w", err)
+    }
+
+    applied := make(map[string]bool, len(versions))
+    for _, v := range versions {
+        applied[v] = true
+    }
+    return applied, nil
+}
+
+// PendingMigrations возвращает встроенные миграции, ещё не применённые к базе, в порядке применения.
+func PendingMigrations(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+    migrations, err := Migrations()
+    if err != nil {
+        return nil, err
+    }
+    applied, err := AppliedMigrations(ctx, pool)
+    if err != nil {
+        return nil, err
+    }
+
+    var pending []Migration
+    for _, m := range migrations {
+        if !applied[m.Version] {
+            pending = append(pending, m)
+        }
+    }
+    return pending, nil
+}
+
+// Migrate применяет недостающие миграции, каждую в своей транзакции, и возвращает применённые.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+    migrations, err := Migrations()
+    if err != nil {
+        return nil, err
+    }
+
+    conn, err := pool.Acquire(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer conn.Release()
+    if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+        return nil, fmt.Errorf("не удалось получить блокировку миграций: %w", err)
+    }
+    defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+    applied, err := AppliedMigrations(ctx, pool)
+    if err != nil {
+        return nil, err
+    }
+
+    var done []Migration
+    for _, m := range migrations {
+        if applied[m.Version] {
+            continue
+        }
+        err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+            if _, err := tx.Exec(ctx, m.SQL); err != nil {
+                return err
+            }
+            _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+            return err
+        })
+        if err != nil {
+            return done, fmt.Errorf("миграция %s_%s: %w", m.Version, m.Name, err)
+        }
+        done = append(done, m)
+    }
+    return done, nil
+}
+
+func ensureMigrationsTable(ctx context.Context, pool *pgxpool.Pool) error {
+    _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+        version VARCHAR(50) PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    )`)
+    if err != nil {
+        return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+    }
+    return nil
+}
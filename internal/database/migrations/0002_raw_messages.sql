@@ -0,0 +1,16 @@
+-- Исходные сообщения из канала заказов с метаданными NATS Streaming.
+-- order_uid не ссылается на orders: сообщение хранится, даже если заказ некорректен или удалён.
+CREATE TABLE IF NOT EXISTS raw_messages (
+    id BIGSERIAL PRIMARY KEY,
+    sequence BIGINT NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    redelivered BOOLEAN NOT NULL DEFAULT FALSE,
+    received_at TIMESTAMPTZ NOT NULL,
+    order_uid VARCHAR(255),
+    -- compression - способ сжатия payload: none или gzip.
+    compression VARCHAR(10) NOT NULL DEFAULT 'none',
+    payload BYTEA NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS raw_messages_order_uid_idx ON raw_messages (order_uid, received_at DESC);
+CREATE INDEX IF NOT EXISTS raw_messages_received_at_idx ON raw_messages (received_at);
@@ -0,0 +1,16 @@
+-- История статусов товаров. from_status и order_from пусты, если товар появился в заказе с этим статусом.
+CREATE TABLE IF NOT EXISTS status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    rid VARCHAR(255) NOT NULL,
+    from_status INT,
+    to_status INT NOT NULL,
+    order_from VARCHAR(20),
+    order_to VARCHAR(20) NOT NULL,
+    -- source - откуда пришла смена статуса: message (полный заказ из канала) или api.
+    source VARCHAR(20) NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS status_history_order_uid_idx ON status_history (order_uid, changed_at, id);
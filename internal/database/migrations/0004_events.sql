@@ -0,0 +1,12 @@
+-- Версия заказа для применения событий: новый заказ получает версию 1, каждое событие увеличивает её на единицу.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+-- События, пришедшие раньше предшествующего события заказа. Заказа может ещё не быть, поэтому без внешнего ключа.
+CREATE TABLE IF NOT EXISTS parked_events (
+    order_uid VARCHAR(255) NOT NULL,
+    version BIGINT NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    event JSONB NOT NULL,
+    parked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (order_uid, version)
+);
@@ -0,0 +1,5 @@
+-- Поля сообщения, которых нет в модели (models.Extra). NULL - таких полей не было.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS extra JSONB;
+ALTER TABLE delivery ADD COLUMN IF NOT EXISTS extra JSONB;
+ALTER TABLE payment ADD COLUMN IF NOT EXISTS extra JSONB;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS extra JSONB;
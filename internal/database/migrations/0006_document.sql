@@ -0,0 +1,3 @@
+-- Заказ целиком для чтения одним запросом. Строки, записанные до появления колонки,
+-- остаются с NULL и читаются из таблиц, пока фоновая сверка не построит им документ.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS document JSONB;
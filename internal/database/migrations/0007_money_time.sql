@@ -0,0 +1,12 @@
+-- Суммы - в минимальных единицах валюты оплаты (models.Amount), payment_dt - время, а не unix-секунды.
+-- NUMERIC не переполняется на суммах в валютах с мелкими единицами, ноль в payment_dt означал отсутствие даты.
+ALTER TABLE payment
+    ALTER COLUMN amount TYPE NUMERIC(20, 0),
+    ALTER COLUMN delivery_cost TYPE NUMERIC(20, 0),
+    ALTER COLUMN goods_total TYPE NUMERIC(20, 0),
+    ALTER COLUMN custom_fee TYPE NUMERIC(20, 0),
+    ALTER COLUMN payment_dt TYPE TIMESTAMPTZ USING to_timestamp(NULLIF(payment_dt, 0));
+
+ALTER TABLE items
+    ALTER COLUMN price TYPE NUMERIC(20, 0),
+    ALTER COLUMN total_price TYPE NUMERIC(20, 0);
@@ -0,0 +1,9 @@
+-- Курсы валют: за единицу currency дают rate единиц quote. valid_to NULL - до следующего курса пары.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) NOT NULL,
+    quote CHAR(3) NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    rate NUMERIC NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (currency, quote, valid_from)
+);
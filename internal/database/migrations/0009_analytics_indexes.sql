@@ -0,0 +1,3 @@
+-- Аналитика отбирает заказы по дате создания и подсчитывает их товары по order_uid.
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created);
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
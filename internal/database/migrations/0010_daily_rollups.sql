@@ -0,0 +1,45 @@
+-- Дневные итоги для аналитики: день date_created (UTC) x валюта оплаты x служба доставки,
+-- по товарам - ещё и x бренд. Число заказов по брендам не складывается, поэтому таблиц две.
+-- Итоги меняются в транзакциях записи и удаления заказов, NULL хранится как пустая строка.
+CREATE TABLE IF NOT EXISTS daily_orders (
+    day              DATE NOT NULL,
+    currency         VARCHAR(10) NOT NULL,
+    delivery_service VARCHAR(50) NOT NULL,
+    orders           BIGINT NOT NULL,
+    revenue          NUMERIC NOT NULL,
+    items            BIGINT NOT NULL,
+    sale             BIGINT NOT NULL,
+    PRIMARY KEY (day, currency, delivery_service)
+);
+
+CREATE TABLE IF NOT EXISTS daily_brands (
+    day              DATE NOT NULL,
+    currency         VARCHAR(10) NOT NULL,
+    delivery_service VARCHAR(50) NOT NULL,
+    brand            VARCHAR(100) NOT NULL,
+    units            BIGINT NOT NULL,
+    revenue          NUMERIC NOT NULL,
+    sale             BIGINT NOT NULL,
+    PRIMARY KEY (day, currency, delivery_service, brand)
+);
+
+-- Итоги уже сохранённых заказов; те же запросы выполняет команда rollups.
+INSERT INTO daily_orders (day, currency, delivery_service, orders, revenue, items, sale)
+SELECT (o.date_created AT TIME ZONE 'UTC')::date, coalesce(p.currency, ''), coalesce(o.delivery_service, ''),
+    count(*), coalesce(sum(p.amount), 0), coalesce(sum(i.items), 0), coalesce(sum(i.sale), 0)
+FROM orders o
+LEFT JOIN payment p ON p.order_uid = o.order_uid
+LEFT JOIN LATERAL (SELECT count(*) AS items, sum(sale) AS sale FROM items WHERE items.order_uid = o.order_uid) i ON true
+WHERE o.date_created IS NOT NULL
+GROUP BY 1, 2, 3
+ON CONFLICT DO NOTHING;
+
+INSERT INTO daily_brands (day, currency, delivery_service, brand, units, revenue, sale)
+SELECT (o.date_created AT TIME ZONE 'UTC')::date, coalesce(p.currency, ''), coalesce(o.delivery_service, ''),
+    coalesce(i.brand, ''), count(*), coalesce(sum(i.total_price), 0), coalesce(sum(i.sale), 0)
+FROM items i
+JOIN orders o ON o.order_uid = i.order_uid
+LEFT JOIN payment p ON p.order_uid = o.order_uid
+WHERE o.date_created IS NOT NULL
+GROUP BY 1, 2, 3, 4
+ON CONFLICT DO NOTHING;
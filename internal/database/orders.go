@@ -0,0 +1,412 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/pgxpool"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// streamBatchSize - сколько заказов Stream читает из БД за один запрос.
+const streamBatchSize = 500
+
+// Repository - реализация repository.OrderRepository поверх PostgreSQL.
+type Repository struct {
+    pool *pgxpool.Pool
+}
+
+var _ repository.OrderRepository = (*Repository)(nil)
+
+func NewRepository(pool *pgxpool.Pool) *Repository {
+    return &Repository{pool: pool}
+}
+
+// Save сохраняет полный заказ в БД в одной транзакции.
+// Существующий заказ перезаписывается, список товаров заменяется целиком;
+// дневные итоги аналитики меняются на разницу между новой и прежней версией.
+// Заказ, уже изменённый событиями, не перезаписывается: Save возвращает repository.ErrSuperseded.
+func (r *Repository) Save(ctx context.Context, order models.Order) error {
+    document, err := documentOf(order)
+    if err != nil {
+        return fmt.Errorf("не удалось закодировать документ заказа: %w", err)
+    }
+
+    tx, err := r.pool.Begin(ctx)
+    if err != nil {
+        return fmt.Errorf("не удалось начать транзакцию: %w", err)
+    }
+    defer tx.Rollback(ctx)
+
+    version, err := lockOrder(ctx, tx, order.OrderUID)
+    if err != nil {
+        return err
+    }
+    if version > 1 {
+        return repository.ErrSuperseded
+    }
+    // Прежний вклад заказа в дневные итоги читается до перезаписи.
+    rollupsBefore, err := readRollups(ctx, tx, order.OrderUID)
+    if err != nil {
+        return err
+    }
+
+    // xmax = 0 только у вставленной строки: так видно, был ли заказ раньше.
+    // Строка заказа остаётся заблокированной до конца транзакции.
+    var inserted bool
+    err = tx.QueryRow(ctx, `
+        INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, extra, document)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (order_uid) DO UPDATE SET
+            track_number = EXCLUDED.track_number, entry = EXCLUDED.entry, locale = EXCLUDED.locale,
+            internal_signature = EXCLUDED.internal_signature, customer_id = EXCLUDED.customer_id,
+            delivery_service = EXCLUDED.delivery_service, shardkey = EXCLUDED.shardkey, sm_id = EXCLUDED.sm_id,
+            date_created = EXCLUDED.date_created, oof_shard = EXCLUDED.oof_shard, extra = EXCLUDED.extra,
+            document = EXCLUDED.document
+        RETURNING xmax = 0`,
+        order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+        order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, timestampValue(order.DateCreated), order.OofShard,
+        extraValue(order.Extra), document,
+    ).Scan(&inserted)
+    if err != nil {
+        return fmt.Errorf("не удалось вставить заказ: %w", err)
+    }
+    var previous *models.Order
+    if !inserted {
+        if previous, _, err = loadItemStatuses(ctx, tx, order.OrderUID); err != nil {
+            return err
+        }
+    }
+
+    if err = writeDetails(ctx, tx, order); err != nil {
+        return err
+    }
+
+    if err = insertStatusChanges(ctx, tx, models.StatusChanges(previous, order), models.StatusSourceMessage, ""); err != nil {
+        return err
+    }
+
+    if err = writeRollups(ctx, tx, order.OrderUID, rollupsBefore); err != nil {
+        return err
+    }
+
+    if err = tx.Commit(ctx); err != nil {
+        return fmt.Errorf("не удалось подтвердить транзакцию: %w", err)
+    }
+
+    log.Printf("Заказ %s успешно сохранен", order.OrderUID)
+    return nil
+}
+
+// writeDetails перезаписывает доставку, оплату и товары заказа.
+func writeDetails(ctx context.Context, tx pgx.Tx, order models.Order) error {
+    _, err := tx.Exec(ctx, `
+        INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email, extra)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (order_uid) DO UPDATE SET
+            name = EXCLUDED.name, phone = EXCLUDED.phone, zip = EXCLUDED.zip, city = EXCLUDED.city,
+            address = EXCLUDED.address, region = EXCLUDED.region, email = EXCLUDED.email, extra = EXCLUDED.extra`,
+        order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
+        order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+        extraValue(order.Delivery.Extra),
+    )
+    if err != nil {
+        return fmt.Errorf("не удалось вставить данные о доставке: %w", err)
+    }
+
+    _, err = tx.Exec(ctx, `
+        INSERT INTO payment (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee, extra)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (order_uid) DO UPDATE SET
+            transaction = EXCLUDED.transaction, request_id = EXCLUDED.request_id, currency = EXCLUDED.currency,
+            provider = EXCLUDED.provider, amount = EXCLUDED.amount, payment_dt = EXCLUDED.payment_dt,
+            bank = EXCLUDED.bank, delivery_cost = EXCLUDED.delivery_cost, goods_total = EXCLUDED.goods_total,
+            custom_fee = EXCLUDED.custom_fee, extra = EXCLUDED.extra`,
+        order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
+        order.Payment.Provider, order.Payment.Amount, timestampValue(order.Payment.PaymentDt), order.Payment.Bank,
+        order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
+        extraValue(order.Payment.Extra),
+    )
+    if err != nil {
+        return fmt.Errorf("не удалось вставить данные об оплате: %w", err)
+    }
+
+    if _, err = tx.Exec(ctx, "DELETE FROM items WHERE order_uid = $1", order.OrderUID); err != nil {
+        return fmt.Errorf("не удалось удалить прежние товары заказа: %w", err)
+    }
+
+    if len(order.Items) > 0 {
+        batch := &pgx.Batch{}
+        for _, item := range order.Items {
+            batch.Queue(`
+                INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, extra)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+                order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID,
+                item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+                extraValue(item.Extra),
+            )
+        }
+        if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+            return fmt.Errorf("не удалось вставить товар: %w", err)
+        }
+    }
+    return nil
+}
+
+// GetByUID читает заказ из документа, а без документа - из таблиц.
+func (r *Repository) GetByUID(ctx context.Context, uid string) (models.Order, error) {
+    rows, err := r.pool.Query(ctx, "SELECT "+orderColumns+", document FROM orders WHERE order_uid = $1", uid)
+    if err != nil {
+        return models.Order{}, fmt.Errorf("не удалось выполнить запрос к заказу: %w", err)
+    }
+    orders, documents, err := scanBatch(rows)
+    if err != nil {
+        return models.Order{}, err
+    }
+    if len(orders) == 0 {
+        return models.Order{}, repository.ErrNotFound
+    }
+    if err := fromDocuments(ctx, r.pool, orders, documents); err != nil {
+        return models.Order{}, err
+    }
+    return orders[0], nil
+}
+
+func (r *Repository) List(ctx context.Context, filter repository.ListFilter) ([]models.Order, error) {
+    orders := []models.Order{}
+    err := r.Stream(ctx, filter, func(order models.Order) error {
+        orders = append(orders, order)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return orders, nil
+}
+
+// Delete удаляет заказ и вычитает его вклад из дневных итогов в одной транзакции.
+func (r *Repository) Delete(ctx context.Context, uid string) error {
+    return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+        version, err := lockOrder(ctx, tx, uid)
+        if err != nil {
+            return err
+        }
+        if version == 0 {
+            return repository.ErrNotFound
+        }
+        rollupsBefore, err := readRollups(ctx, tx, uid)
+        if err != nil {
+            return err
+        }
+        // Доставка, оплата и товары удаляются каскадно.
+        if _, err := tx.Exec(ctx, "DELETE FROM orders WHERE order_uid = $1", uid); err != nil {
+            return fmt.Errorf("не удалось удалить заказ: %w", err)
+        }
+        return writeRollups(ctx, tx, uid, rollupsBefore)
+    })
+}
+
+// Stream читает заказы пачками по streamBatchSize, используя order_uid как курсор.
+// Заказы берутся из документов; доставка, оплата и товары заказов без документа
+// догружаются одним запросом на пачку.
+func (r *Repository) Stream(ctx context.Context, filter repository.ListFilter, fn func(models.Order) error) error {
+    return r.batches(ctx, filter, func(orders []models.Order, documents [][]byte) error {
+        if err := fromDocuments(ctx, r.pool, orders, documents); err != nil {
+            return err
+        }
+        for _, order := range orders {
+            if err := fn(order); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+// batches читает строки заказов под фильтром пачками по streamBatchSize, используя order_uid
+// как курсор, и передаёт в fn заказы без доставки, оплаты и товаров вместе с их документами.
+func (r *Repository) batches(ctx context.Context, filter repository.ListFilter, fn func(orders []models.Order, documents [][]byte) error) error {
+    where, args := filterClause(filter)
+    lastUID := ""
+    offset := filter.Offset
+    remaining := filter.Limit
+
+    for {
+        batchSize := streamBatchSize
+        if filter.Limit > 0 && remaining < batchSize {
+            batchSize = remaining
+        }
+
+        conditions := append([]string{}, where...)
+        batchArgs := append([]any{}, args...)
+        if lastUID != "" {
+            batchArgs = append(batchArgs, lastUID)
+            conditions = append(conditions, fmt.Sprintf("order_uid > $%d", len(batchArgs)))
+        }
+        query := "SELECT " + orderColumns + ", document FROM orders"
+        if len(conditions) > 0 {
+            query += " WHERE " + strings.Join(conditions, " AND ")
+        }
+        batchArgs = append(batchArgs, batchSize, offset)
+        query += fmt.Sprintf(" ORDER BY order_uid LIMIT $%d OFFSET $%d", len(batchArgs)-1, len(batchArgs))
+
+        rows, err := r.pool.Query(ctx, query, batchArgs...)
+        if err != nil {
+            return fmt.Errorf("не удалось выполнить запрос к заказам: %w", err)
+        }
+        orders, documents, err := scanBatch(rows)
+        if err != nil {
+            return err
+        }
+        if len(orders) == 0 {
+            return nil
+        }
+        // fn может заменить заказы документами, курсор запоминается до вызова.
+        last := orders[len(orders)-1].OrderUID
+        if err := fn(orders, documents); err != nil {
+            return err
+        }
+
+        if len(orders) < batchSize {
+            return nil
+        }
+        if filter.Limit > 0 {
+            remaining -= len(orders)
+            if remaining <= 0 {
+                return nil
+            }
+        }
+        // Смещение применяется только к первой пачке, дальше работает курсор.
+        offset = 0
+        lastUID = last
+    }
+}
+
+const orderColumns = "order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, extra"
+
+func scanOrder(row pgx.CollectableRow) (models.Order, error) {
+    return scanOrderWith(row)
+}
+
+// scanOrderWith сканирует orderColumns, а следующие за ними колонки - в rest.
+func scanOrderWith(row pgx.CollectableRow, rest ...any) (models.Order, error) {
+    var order models.Order
+    var dateCreated *time.Time
+    var orderExtra []byte
+    err := row.Scan(append([]any{&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
+        &order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+        &order.Shardkey, &order.SmID, &dateCreated, &order.OofShard, &orderExtra}, rest...)...)
+    if err != nil {
+        return order, err
+    }
+    order.DateCreated = timeOf(dateCreated)
+    order.Extra, err = models.ParseExtra(orderExtra)
+    return order, err
+}
+
+// extraValue - значение колонки extra: NULL, если неизвестных полей нет.
+func extraValue(extra models.Extra) any {
+    if len(extra) == 0 {
+        return nil
+    }
+    return extra
+}
+
+func filterClause(filter repository.ListFilter) ([]string, []any) {
+    var conditions []string
+    var args []any
+    add := func(condition string, value any) {
+        args = append(args, value)
+        conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+    }
+
+    if filter.CustomerID != "" {
+        add("customer_id = $%d", filter.CustomerID)
+    }
+    if filter.DeliveryService != "" {
+        add("delivery_service = $%d", filter.DeliveryService)
+    }
+    if filter.Locale != "" {
+        add("locale = $%d", filter.Locale)
+    }
+    if !filter.CreatedFrom.IsZero() {
+        add("date_created >= $%d", filter.CreatedFrom)
+    }
+    if !filter.CreatedTo.IsZero() {
+        add("date_created < $%d", filter.CreatedTo)
+    }
+    return conditions, args
+}
+
+// querier - пул соединений или транзакция.
+type querier interface {
+    Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// loadDetails заполняет доставку, оплату и товары для пачки заказов.
+// Отсутствующие строки доставки и оплаты оставляют нулевые значения.
+func loadDetails(ctx context.Context, q querier, orders []models.Order) error {
+    index := make(map[string]int, len(orders))
+    uids := make([]string, len(orders))
+    for i, order := range orders {
+        index[order.OrderUID] = i
+        uids[i] = order.OrderUID
+        orders[i].Items = []models.Item{}
+    }
+
+    rows, err := q.Query(ctx, "SELECT order_uid, name, phone, zip, city, address, region, email, extra FROM delivery WHERE order_uid = ANY($1)", uids)
+    if err != nil {
+        return fmt.Errorf("не удалось выполнить запрос к доставке: %w", err)
+    }
+    var uid string
+    var extra []byte
+    var d models.Delivery
+    _, err = pgx.ForEachRow(rows, []any{&uid, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email, &extra}, func() error {
+        var err error
+        d.Extra, err = models.ParseExtra(extra)
+        orders[index[uid]].Delivery = d
+        return err
+    })
+    if err != nil {
+        return fmt.Errorf("не удалось просканировать данные о доставке: %w", err)
+    }
+
+    rows, err = q.Query(ctx, "SELECT order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee, extra FROM payment WHERE order_uid = ANY($1)", uids)
+    if err != nil {
+        return fmt.Errorf("не удалось выполнить запрос к оплате: %w", err)
+    }
+    var p models.Payment
+    var paymentDt *time.Time
+    _, err = pgx.ForEachRow(rows, []any{&uid, &p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount, &paymentDt, &p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee, &extra}, func() error {
+        var err error
+        p.PaymentDt = timeOf(paymentDt)
+        p.Extra, err = models.ParseExtra(extra)
+        orders[index[uid]].Payment = p
+        return err
+    })
+    if err != nil {
+        return fmt.Errorf("не удалось просканировать данные об оплате: %w", err)
+    }
+
+    rows, err = q.Query(ctx, "SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, extra FROM items WHERE order_uid = ANY($1) ORDER BY id", uids)
+    if err != nil {
+        return fmt.Errorf("не удалось выполнить запрос к товарам: %w", err)
+    }
+    var item models.Item
+    _, err = pgx.ForEachRow(rows, []any{&uid, &item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status, &extra}, func() error {
+        var err error
+        item.Extra, err = models.ParseExtra(extra)
+        i := index[uid]
+        orders[i].Items = append(orders[i].Items, item)
+        return err
+    })
+    if err != nil {
+        return fmt.Errorf("не удалось просканировать товары: %w", err)
+    }
+    return nil
+}
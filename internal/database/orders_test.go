@@ -0,0 +1,238 @@
+package database
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "os"
+    "reflect"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/archive"
+    "wb-order-hub/internal/config"
+    "wb-order-hub/internal/events"
+    "wb-order-hub/internal/rates"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+// Тесты выполняются только при наличии базы данных: в CI её параметры передаются через DB_HOST и соседние переменные.
+func TestRepository(t *testing.T) {
+    if _, ok := os.LookupEnv("DB_HOST"); !ok {
+        t.Skip("DB_HOST не задан, тесты PostgreSQL пропущены")
+    }
+
+    ctx := context.Background()
+    cfg, err := config.Load(nil)
+    if err != nil {
+        t.Fatalf("Некорректная конфигурация: %v", err)
+    }
+    pool, err := NewPool(ctx, ConfigFrom(cfg.Database))
+    if err != nil {
+        t.Fatalf("NewPool: %v", err)
+    }
+    defer pool.Close()
+
+    if _, err := Migrate(ctx, pool); err != nil {
+        t.Fatalf("Не удалось применить миграции: %v", err)
+    }
+    if applied, err := Migrate(ctx, pool); err != nil || len(applied) != 0 {
+        t.Fatalf("Повторный запуск миграций должен ничего не делать, применены %v, ошибка %v", applied, err)
+    }
+    if pending, err := PendingMigrations(ctx, pool); err != nil || len(pending) != 0 {
+        t.Fatalf("После миграций не должно остаться ожидающих, осталось %v, ошибка %v", pending, err)
+    }
+
+    repotest.Run(t, func(t *testing.T) repository.OrderRepository {
+        if _, err := pool.Exec(ctx, "TRUNCATE orders, daily_orders, daily_brands CASCADE"); err != nil {
+            t.Fatalf("Не удалось очистить таблицы: %v", err)
+        }
+        return NewRepository(pool)
+    })
+
+    t.Run("Integrity", func(t *testing.T) {
+        if _, err := pool.Exec(ctx, "TRUNCATE orders CASCADE"); err != nil {
+            t.Fatal(err)
+        }
+        repo := NewRepository(pool)
+        repo.Save(ctx, repotest.SampleOrder("full"))
+        if _, err := pool.Exec(ctx, "INSERT INTO orders (order_uid, locale) VALUES ('bare', 'en')"); err != nil {
+            t.Fatal(err)
+        }
+
+        missing, err := repo.MissingRows(ctx, repository.ListFilter{Locale: "en"})
+        if err != nil || len(missing) != 3 || missing[0].OrderUID != "bare" {
+            t.Errorf("MissingRows: %+v, %v", missing, err)
+        }
+        if orphans, err := repo.OrphanRows(ctx); err != nil || len(orphans) != 0 {
+            t.Errorf("OrphanRows: %+v, %v", orphans, err)
+        }
+        if deleted, err := repo.DeleteOrphanRows(ctx); err != nil || deleted != 0 {
+            t.Errorf("DeleteOrphanRows: %d, %v", deleted, err)
+        }
+    })
+
+    t.Run("Documents", func(t *testing.T) {
+        if _, err := pool.Exec(ctx, "TRUNCATE orders CASCADE"); err != nil {
+            t.Fatal(err)
+        }
+        repo := NewRepository(pool)
+        for _, uid := range []string{"changed", "legacy", "same"} {
+            if err := repo.Save(ctx, repotest.SampleOrder(uid)); err != nil {
+                t.Fatalf("Save: %v", err)
+            }
+        }
+        // Правка в обход сервиса и заказ, записанный до появления документа.
+        if _, err := pool.Exec(ctx, "UPDATE items SET brand = 'Other' WHERE order_uid = 'changed'"); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := pool.Exec(ctx, "UPDATE orders SET document = NULL WHERE order_uid = 'legacy'"); err != nil {
+            t.Fatal(err)
+        }
+        if got, err := repo.GetByUID(ctx, "legacy"); err != nil || !reflect.DeepEqual(got, repotest.SampleOrder("legacy")) {
+            t.Errorf("Заказ без документа должен читаться из таблиц: %+v, %v", got, err)
+        }
+
+        report, err := repo.CheckDocuments(ctx, repository.ListFilter{}, false)
+        if err != nil || report.Checked != 3 || !reflect.DeepEqual(report.Missing, []string{"legacy"}) ||
+            !reflect.DeepEqual(report.Mismatched, []string{"changed"}) || report.Repaired != 0 {
+            t.Fatalf("CheckDocuments: %+v, %v", report, err)
+        }
+        if report, err = repo.CheckDocuments(ctx, repository.ListFilter{}, true); err != nil || report.Repaired != 2 {
+            t.Fatalf("CheckDocuments с исправлением: %+v, %v", report, err)
+        }
+        if got, _ := repo.GetByUID(ctx, "changed"); got.Items[0].Brand != "Other" {
+            t.Errorf("Документ не перестроен по таблицам: %+v", got.Items)
+        }
+        if report, err = repo.CheckDocuments(ctx, repository.ListFilter{}, false); err != nil || len(report.Missing)+len(report.Mismatched) != 0 {
+            t.Errorf("После исправления документы должны совпадать с таблицами: %+v, %v", report, err)
+        }
+    })
+
+    t.Run("Rollups", func(t *testing.T) {
+        if _, err := pool.Exec(ctx, "TRUNCATE orders, daily_orders, daily_brands CASCADE"); err != nil {
+            t.Fatal(err)
+        }
+        repo := NewRepository(pool)
+        created := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+        for _, uid := range []string{"kept", "moved", "deleted"} {
+            order := repotest.SampleOrder(uid)
+            order.DateCreated = created
+            if err := repo.Save(ctx, order); err != nil {
+                t.Fatalf("Save: %v", err)
+            }
+        }
+        // Перезапись переносит вклад заказа в другой день, службу доставки и бренд.
+        moved := repotest.SampleOrder("moved")
+        moved.DateCreated = created.AddDate(0, 0, 1)
+        moved.DeliveryService, moved.Items[0].Brand = "other", "Other"
+        if err := repo.Save(ctx, moved); err != nil {
+            t.Fatalf("Save: %v", err)
+        }
+        if err := repo.Delete(ctx, "deleted"); err != nil {
+            t.Fatalf("Delete: %v", err)
+        }
+        if report, err := repo.RebuildRollups(ctx, false); err != nil || len(report.Diffs) != 0 {
+            t.Fatalf("Итоги должны совпадать с пересчётом: %v, %v", report.Diffs, err)
+        }
+
+        // Границы по началам дней читаются из итогов, остальные - из базовых таблиц; ответы совпадают.
+        fromRollups, err := repo.OrderTotals(ctx, repository.AnalyticsFilter{From: repository.Day(created)}, repository.ByDeliveryService)
+        if err != nil || len(fromRollups) != 2 || fromRollups[0].Orders != 1 || fromRollups[1].Key != "other" {
+            t.Fatalf("OrderTotals по итогам: %+v, %v", fromRollups, err)
+        }
+        fromTables, err := repo.OrderTotals(ctx, repository.AnalyticsFilter{From: created.Add(-time.Hour)}, repository.ByDeliveryService)
+        if err != nil || !reflect.DeepEqual(fromTables, fromRollups) {
+            t.Errorf("OrderTotals по таблицам: %+v, %v", fromTables, err)
+        }
+        brands, err := repo.ItemTotals(ctx, repository.AnalyticsFilter{Brand: "Other"}, repository.ByBrand)
+        if err != nil || len(brands) != 1 || !brands[0].Day.Equal(repository.Day(moved.DateCreated)) {
+            t.Errorf("ItemTotals по итогам: %+v, %v", brands, err)
+        }
+
+        // Правка в обход сервиса находится сверкой и исправляется пересчётом.
+        if _, err := pool.Exec(ctx, "UPDATE daily_orders SET orders = orders + 1"); err != nil {
+            t.Fatal(err)
+        }
+        if report, err := repo.RebuildRollups(ctx, true); err != nil || len(report.Diffs) != 2 || !report.Rebuilt {
+            t.Fatalf("RebuildRollups: %+v, %v", report, err)
+        }
+        if report, err := repo.RebuildRollups(ctx, false); err != nil || len(report.Diffs) != 0 {
+            t.Errorf("После пересчёта итоги должны совпадать: %v, %v", report.Diffs, err)
+        }
+    })
+
+    t.Run("Archive", func(t *testing.T) {
+        if _, err := pool.Exec(ctx, "TRUNCATE raw_messages"); err != nil {
+            t.Fatal(err)
+        }
+        now := time.Now()
+        for _, c := range []archive.Compression{archive.CompressionNone, archive.CompressionGzip} {
+            store := NewArchive(pool, c)
+            msg := archive.Message{Sequence: 5, Subject: "orders", Redelivered: true, ReceivedAt: now, OrderUID: "raw-" + string(c), Data: []byte(` {"order_uid": "x"}`)}
+            if err := store.Save(ctx, msg); err != nil {
+                t.Fatalf("Save: %v", err)
+            }
+            messages, err := store.Messages(ctx, msg.OrderUID)
+            if err != nil || len(messages) != 1 || !bytes.Equal(messages[0].Data, msg.Data) ||
+                messages[0].Sequence != 5 || !messages[0].Redelivered {
+                t.Errorf("%s: Messages: %+v, %v", c, messages, err)
+            }
+        }
+        store := NewArchive(pool, archive.CompressionNone)
+        store.Save(ctx, archive.Message{Subject: "orders", ReceivedAt: now.Add(-48 * time.Hour), Data: []byte("old")})
+        if deleted, err := store.Prune(ctx, now.Add(-24*time.Hour)); err != nil || deleted != 1 {
+            t.Errorf("Prune: %d, %v", deleted, err)
+        }
+        if _, err := store.Messages(ctx, "missing"); !errors.Is(err, archive.ErrNotFound) {
+            t.Errorf("Ожидалась archive.ErrNotFound, получили %v", err)
+        }
+    })
+
+    t.Run("ExchangeRates", func(t *testing.T) {
+        if _, err := pool.Exec(ctx, "TRUNCATE exchange_rates"); err != nil {
+            t.Fatal(err)
+        }
+        store := NewExchangeRates(pool)
+        from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+        usd := rates.Rate{Currency: "USD", Quote: "RUB", Value: "92.4513", ValidFrom: from, ValidTo: from.AddDate(0, 1, 0)}
+        eur := rates.Rate{Currency: "EUR", Quote: "RUB", Value: "100", ValidFrom: from}
+        if err := store.SaveRates(ctx, []rates.Rate{usd, eur}); err != nil {
+            t.Fatalf("SaveRates: %v", err)
+        }
+        usd.Value = "92.5"
+        if err := store.SaveRates(ctx, []rates.Rate{usd}); err != nil {
+            t.Fatalf("SaveRates: %v", err)
+        }
+        list, err := store.Rates(ctx)
+        if err != nil || !reflect.DeepEqual(rates.NewTable(list).Rates(), []rates.Rate{eur, usd}) {
+            t.Errorf("Rates: %+v, %v", list, err)
+        }
+    })
+
+    t.Run("ParkingLot", func(t *testing.T) {
+        if _, err := pool.Exec(ctx, "TRUNCATE parked_events"); err != nil {
+            t.Fatal(err)
+        }
+        lot := NewParkingLot(pool)
+        for _, version := range []int64{3, 4, 3} {
+            e := events.Event{Type: events.OrderCancelled, OrderUID: "parked", Version: version, Payload: []byte(`{"reason": "x"}`)}
+            if err := lot.Park(ctx, e); err != nil {
+                t.Fatalf("Park: %v", err)
+            }
+        }
+        if e, ok, err := lot.Get(ctx, "parked", 4); err != nil || !ok || e.Type != events.OrderCancelled || e.Comment() != "x" {
+            t.Errorf("Get: %+v, %v, %v", e, ok, err)
+        }
+        if err := lot.Release(ctx, "parked", 3); err != nil {
+            t.Fatalf("Release: %v", err)
+        }
+        if _, ok, _ := lot.Get(ctx, "parked", 3); ok {
+            t.Error("Событие версии 3 должно быть удалено")
+        }
+        if _, ok, _ := lot.Get(ctx, "parked", 4); !ok {
+            t.Error("Событие версии 4 должно остаться")
+        }
+    })
+}
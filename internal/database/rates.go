@@ -0,0 +1,62 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/pgxpool"
+    "wb-order-hub/internal/rates"
+)
+
+// ExchangeRates - реализация rates.Store поверх таблицы exchange_rates.
+type ExchangeRates struct {
+    pool *pgxpool.Pool
+}
+
+var _ rates.Store = (*ExchangeRates)(nil)
+
+func NewExchangeRates(pool *pgxpool.Pool) *ExchangeRates {
+    return &ExchangeRates{pool: pool}
+}
+
+func (e *ExchangeRates) Rates(ctx context.Context) ([]rates.Rate, error) {
+    rows, err := e.pool.Query(ctx, "SELECT currency, quote, rate::text, valid_from, valid_to FROM exchange_rates")
+    if err != nil {
+        return nil, fmt.Errorf("не удалось выполнить запрос к курсам валют: %w", err)
+    }
+    list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (rates.Rate, error) {
+        var r rates.Rate
+        var validTo *time.Time
+        if err := row.Scan(&r.Currency, &r.Quote, &r.Value, &r.ValidFrom, &validTo); err != nil {
+            return r, err
+        }
+        r.ValidFrom = r.ValidFrom.UTC()
+        r.ValidTo = timeOf(validTo)
+        return r, nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось просканировать курсы валют: %w", err)
+    }
+    return list, nil
+}
+
+// SaveRates сохраняет курсы в одной транзакции: загрузка либо применяется целиком, либо не применяется.
+func (e *ExchangeRates) SaveRates(ctx context.Context, list []rates.Rate) error {
+    // Курс передаётся текстом: так десятичная запись не проходит через float.
+    return pgx.BeginFunc(ctx, e.pool, func(tx pgx.Tx) error {
+        batch := &pgx.Batch{}
+        for _, r := range list {
+            batch.Queue(`
+                INSERT INTO exchange_rates (currency, quote, valid_from, valid_to, rate) VALUES ($1, $2, $3, $4, $5::text::numeric)
+                ON CONFLICT (currency, quote, valid_from) DO UPDATE SET valid_to = EXCLUDED.valid_to, rate = EXCLUDED.rate`,
+                r.Currency, r.Quote, r.ValidFrom, timestampValue(r.ValidTo), r.Value,
+            )
+        }
+        if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+            return fmt.Errorf("не удалось сохранить курсы валют: %w", err)
+        }
+        return nil
+    })
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/jackc/pgx/v5"
+)
+
+// rollup - таблица дневных итогов и запрос, считающий её строки по базовым таблицам.
+type rollup struct {
+    table  string
+    keys   []string
+    values []string
+    // query выбирает колонки keys и values; %s - дополнительное условие на заказы o.
+    query string
+}
+
+// rollups - таблицы дневных итогов из миграции 0010_daily_rollups.sql в порядке блокировки.
+var rollups = []rollup{
+    {
+        table:  "daily_orders",
+        keys:   []string{"day", "currency", "delivery_service"},
+        values: []string{"orders", "revenue", "items", "sale"},
+        query: `
+            SELECT ` + analyticsDay + ` AS day, coalesce(p.currency, '') AS currency,
+                coalesce(o.delivery_service, '') AS delivery_service, count(*) AS orders,
+                coalesce(sum(p.amount), 0) AS revenue, coalesce(sum(i.items), 0)::bigint AS items,
+                coalesce(sum(i.sale), 0)::bigint AS sale
+            FROM orders o
+            LEFT JOIN payment p ON p.order_uid = o.order_uid
+            LEFT JOIN LATERAL (SELECT count(*) AS items, sum(sale) AS sale FROM items WHERE items.order_uid = o.order_uid) i ON true
+            WHERE o.date_created IS NOT NULL%s
+            GROUP BY 1, 2, 3`,
+    },
+    {
+        table:  "daily_brands",
+        keys:   []string{"day", "currency", "delivery_service", "brand"},
+        values: []string{"units", "revenue", "sale"},
+        query: `
+            SELECT ` + analyticsDay + ` AS day, coalesce(p.currency, '') AS currency,
+                coalesce(o.delivery_service, '') AS delivery_service, coalesce(i.brand, '') AS brand,
+                count(*) AS units, coalesce(sum(i.total_price), 0) AS revenue, coalesce(sum(i.sale), 0)::bigint AS sale
+            FROM items i
+            JOIN orders o ON o.order_uid = i.order_uid
+            LEFT JOIN payment p ON p.order_uid = o.order_uid
+            WHERE o.date_created IS NOT NULL%s
+            GROUP BY 1, 2, 3, 4`,
+    },
+}
+
+// orderQuery - запрос строк итогов, в которые входит один заказ $1.
+func (r rollup) orderQuery() string {
+    return fmt.Sprintf(r.query, " AND o.order_uid = $1")
+}
+
+// orderLockSpace - первый ключ advisory-блокировок заказов, второй - hashtext(order_uid).
+const orderLockSpace = 2
+
+// lockOrder блокирует заказ до конца транзакции и возвращает его версию, 0 - заказа нет.
+// Ещё не вставленный заказ блокируется по order_uid: иначе из двух транзакций,
+// одновременно вставляющих один заказ, вторая не вычла бы из итогов вклад первой.
+func lockOrder(ctx context.Context, tx pgx.Tx, uid string) (int64, error) {
+    if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", orderLockSpace, uid); err != nil {
+        return 0, fmt.Errorf("не удалось заблокировать заказ: %w", err)
+    }
+    var version int64
+    err := tx.QueryRow(ctx, "SELECT version FROM orders WHERE order_uid = $1 FOR UPDATE", uid).Scan(&version)
+    if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+        return 0, fmt.Errorf("не удалось заблокировать заказ: %w", err)
+    }
+    return version, nil
+}
+
+// readRollups возвращает вклад заблокированного заказа в каждую таблицу итогов, строки в JSON.
+// Вклад читается до перезаписи или удаления заказа и передаётся в writeRollups.
+func readRollups(ctx context.Context, tx pgx.Tx, uid string) ([]string, error) {
+    previous := make([]string, len(rollups))
+    for i, r := range rollups {
+        err := tx.QueryRow(ctx, "SELECT coalesce(json_agg(s), '[]')::text FROM ("+r.orderQuery()+") s", uid).Scan(&previous[i])
+        if err != nil {
+            return nil, fmt.Errorf("не удалось прочитать итоги %s заказа: %w", r.table, err)
+        }
+    }
+    return previous, nil
+}
+
+// writeRollups прибавляет к итогам разницу между текущим вкладом заказа и прежним из readRollups.
+// Изменённые строки обновляются одним запросом на таблицу в порядке ключа, чтобы записи разных
+// заказов не блокировали их навстречу друг другу. Запрос возвращает ключи строк, обнулённых
+// вычитанием, и удаляются только они: эти строки уже заблокированы обновлением.
+func writeRollups(ctx context.Context, tx pgx.Tx, uid string, previous []string) error {
+    for i, r := range rollups {
+        keys := strings.Join(r.keys, ", ")
+        matches := make([]string, len(r.keys))
+        for j, k := range r.keys {
+            matches[j] = fmt.Sprintf("t.%[1]s = e.%[1]s", k)
+        }
+        sums := make([]string, len(r.values))
+        negated := make([]string, len(r.values))
+        changed := make([]string, len(r.values))
+        sets := make([]string, len(r.values))
+        for j, v := range r.values {
+            sums[j] = fmt.Sprintf("sum(%s)", v)
+            negated[j] = "-" + v
+            changed[j] = fmt.Sprintf("sum(%s) <> 0", v)
+            sets[j] = fmt.Sprintf("%[1]s = t.%[1]s + EXCLUDED.%[1]s", v)
+        }
+        var emptied string
+        err := tx.QueryRow(ctx, fmt.Sprintf(`
+            WITH upserted AS (
+                INSERT INTO %[1]s AS t (%[2]s, %[3]s)
+                SELECT %[2]s, %[4]s FROM (
+                    SELECT %[2]s, %[3]s FROM (%[5]s) c
+                    UNION ALL
+                    SELECT %[2]s, %[6]s FROM json_populate_recordset(NULL::%[1]s, $2::json)
+                ) d
+                GROUP BY %[2]s HAVING %[7]s ORDER BY %[2]s
+                ON CONFLICT (%[2]s) DO UPDATE SET %[8]s
+                RETURNING %[2]s, %[9]s
+            )
+            SELECT coalesce(json_agg(e ORDER BY %[2]s), '[]')::text
+            FROM (SELECT %[2]s FROM upserted WHERE %[9]s = 0) e`,
+            r.table, keys, strings.Join(r.values, ", "), strings.Join(sums, ", "), r.orderQuery(),
+            strings.Join(negated, ", "), strings.Join(changed, " OR "), strings.Join(sets, ", "), r.values[0]),
+            uid, previous[i]).Scan(&emptied)
+        if err != nil {
+            return fmt.Errorf("не удалось обновить итоги %s: %w", r.table, err)
+        }
+        if emptied == "[]" {
+            continue
+        }
+        _, err = tx.Exec(ctx, fmt.Sprintf(
+            "DELETE FROM %[1]s t USING json_populate_recordset(NULL::%[1]s, $1::json) e WHERE %[2]s AND t.%[3]s = 0",
+            r.table, strings.Join(matches, " AND "), r.values[0]), emptied)
+        if err != nil {
+            return fmt.Errorf("не удалось удалить пустые итоги %s: %w", r.table, err)
+        }
+    }
+    return nil
+}
+
+// RollupDiff - строка дневных итогов, расходящаяся с пересчётом по базовым таблицам.
+type RollupDiff struct {
+    Table string
+    // Key - значения ключа строки через "/", например 2024-03-04/RUB/meest.
+    Key string
+    // Stored и Rebuilt - значения в таблице и по пересчёту; пустая строка - строки нет.
+    Stored  string
+    Rebuilt string
+}
+
+func (d RollupDiff) String() string {
+    return fmt.Sprintf("%s %s: в таблице %q, по пересчёту %q", d.Table, d.Key, d.Stored, d.Rebuilt)
+}
+
+// RollupReport - итог сверки дневных итогов с пересчётом.
+type RollupReport struct {
+    Diffs []RollupDiff
+    // Rebuilt - таблицы итогов заменены пересчётом.
+    Rebuilt bool
+}
+
+// RebuildRollups пересчитывает дневные итоги по базовым таблицам и сверяет с сохранёнными.
+// С apply при расхождениях таблицы итогов заменяются пересчётом. Таблицы итогов блокируются
+// до конца транзакции, поэтому записи заказов ждут окончания сверки и не теряются.
+func (r *Repository) RebuildRollups(ctx context.Context, apply bool) (RollupReport, error) {
+    var report RollupReport
+    err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+        tables := make([]string, len(rollups))
+        for i, t := range rollups {
+            tables[i] = t.table
+        }
+        if _, err := tx.Exec(ctx, "LOCK TABLE "+strings.Join(tables, ", ")+" IN EXCLUSIVE MODE"); err != nil {
+            return fmt.Errorf("не удалось заблокировать таблицы итогов: %w", err)
+        }
+
+        for _, t := range rollups {
+            diffs, err := compareRollup(ctx, tx, t)
+            if err != nil {
+                return err
+            }
+            report.Diffs = append(report.Diffs, diffs...)
+        }
+        if !apply || len(report.Diffs) == 0 {
+            return nil
+        }
+
+        for _, t := range rollups {
+            if _, err := tx.Exec(ctx, "DELETE FROM "+t.table); err != nil {
+                return fmt.Errorf("не удалось очистить итоги %s: %w", t.table, err)
+            }
+            _, err := tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (%s, %s) %s", t.table,
+                strings.Join(t.keys, ", "), strings.Join(t.values, ", "), fmt.Sprintf(t.query, "")))
+            if err != nil {
+                return fmt.Errorf("не удалось пересчитать итоги %s: %w", t.table, err)
+            }
+        }
+        report.Rebuilt = true
+        return nil
+    })
+    if err != nil {
+        return RollupReport{}, err
+    }
+    return report, nil
+}
+
+// compareRollup сравнивает таблицу итогов с пересчётом по ключу.
+func compareRollup(ctx context.Context, tx pgx.Tx, r rollup) ([]RollupDiff, error) {
+    stored := make([]string, len(r.values))
+    rebuilt := make([]string, len(r.values))
+    for i, v := range r.values {
+        stored[i] = fmt.Sprintf("'%[1]s=' || s.%[1]s", v)
+        rebuilt[i] = fmt.Sprintf("'%[1]s=' || n.%[1]s", v)
+    }
+    keys := strings.Join(r.keys, ", ")
+    rows, err := tx.Query(ctx, fmt.Sprintf(`
+        WITH rebuilt AS (%[1]s)
+        SELECT concat_ws('/', %[2]s), concat_ws(' ', %[3]s), concat_ws(' ', %[4]s)
+        FROM %[5]s s FULL JOIN rebuilt n USING (%[2]s)
+        WHERE (%[6]s) IS DISTINCT FROM (%[7]s)
+        ORDER BY %[2]s`,
+        fmt.Sprintf(r.query, ""), keys, strings.Join(stored, ", "), strings.Join(rebuilt, ", "), r.table,
+        "s."+strings.Join(r.values, ", s."), "n."+strings.Join(r.values, ", n.")))
+    if err != nil {
+        return nil, fmt.Errorf("не удалось сверить итоги %s: %w", r.table, err)
+    }
+    diffs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RollupDiff, error) {
+        d := RollupDiff{Table: r.table}
+        err := row.Scan(&d.Key, &d.Stored, &d.Rebuilt)
+        return d, err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось просканировать расхождения итогов %s: %w", r.table, err)
+    }
+    return diffs, nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+var _ repository.StatusRepository = (*Repository)(nil)
+
+// ChangeStatus блокирует строку заказа, чтобы смена статуса не пересеклась с Save
+// того же заказа, и обновляет только изменившиеся товары и документ заказа.
+func (r *Repository) ChangeStatus(ctx context.Context, uid string, update repository.StatusUpdate) ([]models.StatusChange, error) {
+    var changes []models.StatusChange
+    err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+        var locked string
+        err := tx.QueryRow(ctx, "SELECT order_uid FROM orders WHERE order_uid = $1 FOR UPDATE", uid).Scan(&locked)
+        if errors.Is(err, pgx.ErrNoRows) {
+            return repository.ErrNotFound
+        }
+        if err != nil {
+            return fmt.Errorf("не удалось заблокировать заказ: %w", err)
+        }
+
+        order, ids, err := loadItemStatuses(ctx, tx, uid)
+        if err != nil {
+            return err
+        }
+        before := append([]models.Item(nil), order.Items...)
+        if changes, err = order.ChangeStatus(update.RID, update.Status); err != nil {
+            return err
+        }
+        for i, item := range order.Items {
+            if item.Status == before[i].Status {
+                continue
+            }
+            if _, err := tx.Exec(ctx, "UPDATE items SET status = $1 WHERE id = $2", item.Status, ids[i]); err != nil {
+                return fmt.Errorf("не удалось обновить статус товара %s: %w", item.RID, err)
+            }
+        }
+        if err := insertStatusChanges(ctx, tx, changes, update.Source, update.Comment); err != nil {
+            return err
+        }
+        if len(changes) == 0 {
+            return nil
+        }
+        return rebuildDocument(ctx, tx, uid)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return changes, nil
+}
+
+func (r *Repository) StatusHistory(ctx context.Context, uid string) ([]models.StatusChange, error) {
+    rows, err := r.pool.Query(ctx, `
+        SELECT order_uid, rid, COALESCE(from_status, 0), to_status, COALESCE(order_from, ''), order_to, source, comment, changed_at
+        FROM status_history WHERE order_uid = $1 ORDER BY changed_at, id`, uid)
+    if err != nil {
+        return nil, fmt.Errorf("не удалось выполнить запрос к истории статусов: %w", err)
+    }
+    history, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StatusChange, error) {
+        var c models.StatusChange
+        err := row.Scan(&c.OrderUID, &c.RID, &c.From, &c.To, &c.OrderFrom, &c.OrderTo, &c.Source, &c.Comment, &c.ChangedAt)
+        return c, err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось прочитать историю статусов: %w", err)
+    }
+    if len(history) == 0 {
+        var exists bool
+        if err := r.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)", uid).Scan(&exists); err != nil {
+            return nil, fmt.Errorf("не удалось выполнить запрос к заказу: %w", err)
+        }
+        if !exists {
+            return nil, repository.ErrNotFound
+        }
+    }
+    return history, nil
+}
+
+// loadItemStatuses читает rid и статусы товаров заказа вместе с их id в порядке добавления.
+// Возвращённого заказа достаточно для models.Order.ChangeStatus и models.Order.Status.
+func loadItemStatuses(ctx context.Context, tx pgx.Tx, uid string) (*models.Order, []int64, error) {
+    rows, err := tx.Query(ctx, "SELECT id, rid, status FROM items WHERE order_uid = $1 ORDER BY id", uid)
+    if err != nil {
+        return nil, nil, fmt.Errorf("не удалось выполнить запрос к товарам: %w", err)
+    }
+    order := &models.Order{OrderUID: uid}
+    var ids []int64
+    var id int64
+    var item models.Item
+    _, err = pgx.ForEachRow(rows, []any{&id, &item.RID, &item.Status}, func() error {
+        ids = append(ids, id)
+        order.Items = append(order.Items, item)
+        return nil
+    })
+    if err != nil {
+        return nil, nil, fmt.Errorf("не удалось просканировать товары: %w", err)
+    }
+    return order, ids, nil
+}
+
+// insertStatusChanges записывает переходы в историю и заполняет их Source, Comment и ChangedAt.
+func insertStatusChanges(ctx context.Context, tx pgx.Tx, changes []models.StatusChange, source, comment string) error {
+    if len(changes) == 0 {
+        return nil
+    }
+    now := time.Now().UTC()
+    batch := &pgx.Batch{}
+    for i := range changes {
+        c := &changes[i]
+        c.Source, c.Comment, c.ChangedAt = source, comment, now
+        var from *int
+        if c.From != 0 {
+            code := int(c.From)
+            from = &code
+        }
+        var orderFrom *string
+        if c.OrderFrom != "" {
+            status := string(c.OrderFrom)
+            orderFrom = &status
+        }
+        batch.Queue(`
+            INSERT INTO status_history (order_uid, rid, from_status, to_status, order_from, order_to, source, comment, changed_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+            c.OrderUID, c.RID, from, int(c.To), orderFrom, string(c.OrderTo), c.Source, c.Comment, c.ChangedAt,
+        )
+    }
+    if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+        return fmt.Errorf("не удалось записать историю статусов: %w", err)
+    }
+    return nil
+}
@@ -1,6 +1,15 @@
 package dto
 
-import "wb-order-hub/internal/models"
+import (
+    "errors"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/rates"
+)
+
+// displayTime - формат времени для людей в Display.
+const displayTime = "02.01.2006 15:04:05 MST"
 
 type OrderResponse struct {
     OrderUID        string        `json:"order_uid"`
@@ -13,6 +22,30 @@ type OrderResponse struct {
     CustomerID      string        `json:"customer_id"`
     DeliveryService string        `json:"delivery_service"`
     DateCreated     string        `json:"date_created"`
+    // Status выводится из статусов товаров.
+    Status          string        `json:"status"`
+    StatusLabel     string        `json:"status_label"`
+
+    // Display - даты и суммы для показа людям; поля выше - для программ.
+    Display OrderDisplay `json:"display"`
+
+    // Extra заполняется по запросу: GET /order/{id}?extra=true.
+    Extra *ExtraInfo `json:"extra,omitempty"`
+    // Converted заполняется, если задана валюта отчётности: GET /order/{id}?currency=RUB или rates.currency.
+    Converted *ConvertedInfo `json:"converted,omitempty"`
+}
+
+type OrderDisplay struct {
+    DateCreated string `json:"date_created"`
+}
+
+// ExtraInfo - поля из сообщения производителя, которых нет в модели. Items соответствует
+// списку товаров: у товара без таких полей - null.
+type ExtraInfo struct {
+    Order    models.Extra   `json:"order,omitempty"`
+    Delivery models.Extra   `json:"delivery,omitempty"`
+    Payment  models.Extra   `json:"payment,omitempty"`
+    Items    []models.Extra `json:"items,omitempty"`
 }
 
 type DeliveryInfo struct {
@@ -29,29 +62,52 @@ type PaymentInfo struct {
     Transaction    string `json:"transaction"`
     Currency      string `json:"currency"`
     Provider      string `json:"provider"`
-    Amount        int    `json:"amount"`
+    Amount        int64  `json:"amount"`
     PaymentDt     int64  `json:"payment_dt"`
     Bank          string `json:"bank"`
-    DeliveryCost  int    `json:"delivery_cost"`
-    GoodsTotal    int    `json:"goods_total"`
-    CustomFee     int    `json:"custom_fee"`
+    DeliveryCost  int64  `json:"delivery_cost"`
+    GoodsTotal    int64  `json:"goods_total"`
+    CustomFee     int64  `json:"custom_fee"`
+
+    // Суммы - в минимальных единицах валюты, Exponent - число знаков после запятой в ней.
+    // payment_dt - unix-время в секундах.
+    Exponent int            `json:"exponent"`
+    Display  PaymentDisplay `json:"display"`
+}
+
+type PaymentDisplay struct {
+    Amount       string `json:"amount"`
+    PaymentDt    string `json:"payment_dt"`
+    DeliveryCost string `json:"delivery_cost"`
+    GoodsTotal   string `json:"goods_total"`
+    CustomFee    string `json:"custom_fee"`
 }
 
 type ItemInfo struct {
     ChrtID      int    `json:"chrt_id"`
     TrackNumber string `json:"track_number"`
-    Price       int    `json:"price"`
+    Price       int64  `json:"price"`
     RID         string `json:"rid"`
     Name        string `json:"name"`
     Sale        int    `json:"sale"`
     Size        string `json:"size"`
-    TotalPrice  int    `json:"total_price"`
+    TotalPrice  int64  `json:"total_price"`
     NmID        int    `json:"nm_id"`
     Brand       string `json:"brand"`
     Status      int    `json:"status"`
+    StatusName  string `json:"status_name"`
+    StatusLabel string `json:"status_label"`
+
+    Display ItemDisplay `json:"display"`
+}
+
+type ItemDisplay struct {
+    Price      string `json:"price"`
+    TotalPrice string `json:"total_price"`
 }
 
 func ToResponse(order models.Order) OrderResponse {
+    payment := order.Payment
     return OrderResponse{
         OrderUID:        order.OrderUID,
         TrackNumber:     order.TrackNumber,
@@ -69,12 +125,21 @@ func ToResponse(order models.Order) OrderResponse {
             Transaction:   order.Payment.Transaction,
             Currency:     order.Payment.Currency,
             Provider:     order.Payment.Provider,
-            Amount:       order.Payment.Amount,
-            PaymentDt:    order.Payment.PaymentDt,
+            Amount:       int64(order.Payment.Amount),
+            PaymentDt:    models.UnixSeconds(order.Payment.PaymentDt),
             Bank:         order.Payment.Bank,
-            DeliveryCost: order.Payment.DeliveryCost,
-            GoodsTotal:   order.Payment.GoodsTotal,
-            CustomFee:    order.Payment.CustomFee,
+            DeliveryCost: int64(order.Payment.DeliveryCost),
+            GoodsTotal:   int64(order.Payment.GoodsTotal),
+            CustomFee:    int64(order.Payment.CustomFee),
+
+            Exponent: models.CurrencyExponent(payment.Currency),
+            Display: PaymentDisplay{
+                Amount:       payment.Money(payment.Amount).String(),
+                PaymentDt:    displayTimeOf(payment.PaymentDt),
+                DeliveryCost: payment.Money(payment.DeliveryCost).String(),
+                GoodsTotal:   payment.Money(payment.GoodsTotal).String(),
+                CustomFee:    payment.Money(payment.CustomFee).String(),
+            },
         },
         Items: func() []ItemInfo {
             var items []ItemInfo
@@ -82,15 +147,22 @@ func ToResponse(order models.Order) OrderResponse {
                 items = append(items, ItemInfo{
                     ChrtID:      item.ChrtID,
                     TrackNumber: item.TrackNumber,
-                    Price:       item.Price,
+                    Price:       int64(item.Price),
                     RID:         item.RID,
                     Name:        item.Name,
                     Sale:        item.Sale,
                     Size:        item.Size,
-                    TotalPrice:  item.TotalPrice,
+                    TotalPrice:  int64(item.TotalPrice),
                     NmID:        item.NmID,
                     Brand:       item.Brand,
-                    Status:      item.Status,
+                    Status:      int(item.Status),
+                    StatusName:  item.Status.Name(),
+                    StatusLabel: item.Status.Label(),
+
+                    Display: ItemDisplay{
+                        Price:      payment.Money(item.Price).String(),
+                        TotalPrice: payment.Money(item.TotalPrice).String(),
+                    },
                 })
             }
             return items
@@ -98,6 +170,94 @@ func ToResponse(order models.Order) OrderResponse {
         Locale:          order.Locale,
         CustomerID:      order.CustomerID,
         DeliveryService: order.DeliveryService,
-        DateCreated:     order.DateCreated,
+        DateCreated:     models.FormatTime(order.DateCreated),
+        Status:          string(order.Status()),
+        StatusLabel:     order.Status().Label(),
+
+        Display: OrderDisplay{
+            DateCreated: displayTimeOf(order.DateCreated),
+        },
+    }
+}
+
+// displayTimeOf форматирует время для людей; нулевое время - пустая строка.
+func displayTimeOf(t time.Time) string {
+    if t.IsZero() {
+        return ""
+    }
+    return t.UTC().Format(displayTime)
+}
+
+// ConvertedInfo - суммы оплаты в валюте отчётности по курсу на дату создания заказа.
+type ConvertedInfo struct {
+    Currency string `json:"currency"`
+    Exponent int    `json:"exponent"`
+    // Rate - сколько единиц Currency дают за единицу валюты оплаты.
+    Rate         string `json:"rate"`
+    Amount       int64  `json:"amount"`
+    DeliveryCost int64  `json:"delivery_cost"`
+    GoodsTotal   int64  `json:"goods_total"`
+    CustomFee    int64  `json:"custom_fee"`
+
+    Display ConvertedDisplay `json:"display"`
+}
+
+type ConvertedDisplay struct {
+    Amount       string `json:"amount"`
+    DeliveryCost string `json:"delivery_cost"`
+    GoodsTotal   string `json:"goods_total"`
+    CustomFee    string `json:"custom_fee"`
+}
+
+// ToConverted пересчитывает суммы оплаты в валюту currency по курсу на дату создания заказа.
+// Возвращает ошибку rates.ErrNoRate, если курса на эту дату нет.
+func ToConverted(order models.Order, table *rates.Table, currency string) (*ConvertedInfo, error) {
+    if order.DateCreated.IsZero() {
+        return nil, errors.New("у заказа нет даты создания, курс не определить")
     }
-}
\ No newline at end of file
+    payment := order.Payment
+    rate, err := table.Lookup(payment.Currency, currency, order.DateCreated)
+    if err != nil {
+        return nil, err
+    }
+    convert := func(a models.Amount) models.Money {
+        return rates.ConvertAt(payment.Money(a), currency, rate)
+    }
+    amount, deliveryCost := convert(payment.Amount), convert(payment.DeliveryCost)
+    goodsTotal, customFee := convert(payment.GoodsTotal), convert(payment.CustomFee)
+    return &ConvertedInfo{
+        Currency:     currency,
+        Exponent:     models.CurrencyExponent(currency),
+        Rate:         rates.FormatRate(rate),
+        Amount:       int64(amount.Amount),
+        DeliveryCost: int64(deliveryCost.Amount),
+        GoodsTotal:   int64(goodsTotal.Amount),
+        CustomFee:    int64(customFee.Amount),
+
+        Display: ConvertedDisplay{
+            Amount:       amount.String(),
+            DeliveryCost: deliveryCost.String(),
+            GoodsTotal:   goodsTotal.String(),
+            CustomFee:    customFee.String(),
+        },
+    }, nil
+}
+
+// ToExtra собирает неизвестные поля заказа, доставки, оплаты и товаров.
+func ToExtra(order models.Order) *ExtraInfo {
+    extra := &ExtraInfo{
+        Order:    order.Extra,
+        Delivery: order.Delivery.Extra,
+        Payment:  order.Payment.Extra,
+    }
+    for i, item := range order.Items {
+        if len(item.Extra) == 0 {
+            continue
+        }
+        if extra.Items == nil {
+            extra.Items = make([]models.Extra, len(order.Items))
+        }
+        extra.Items[i] = item.Extra
+    }
+    return extra
+}
@@ -0,0 +1,89 @@
+package dto
+
+import (
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+// StatusResponse - текущие статусы заказа и товаров с историей переходов.
+type StatusResponse struct {
+    OrderUID    string             `json:"order_uid"`
+    Status      string             `json:"status"`
+    StatusLabel string             `json:"status_label"`
+    Items       []ItemStatusInfo   `json:"items"`
+    History     []StatusChangeInfo `json:"history"`
+}
+
+type ItemStatusInfo struct {
+    RID         string `json:"rid"`
+    Name        string `json:"name"`
+    Status      int    `json:"status"`
+    StatusName  string `json:"status_name"`
+    StatusLabel string `json:"status_label"`
+}
+
+// StatusChangeInfo - переход статуса товара. Он же публикуется в канал статусов.
+// From равен нулю, если товар появился в заказе сразу с этим статусом.
+type StatusChangeInfo struct {
+    OrderUID     string    `json:"order_uid"`
+    RID          string    `json:"rid"`
+    From         int       `json:"from,omitempty"`
+    FromName     string    `json:"from_name,omitempty"`
+    FromLabel    string    `json:"from_label,omitempty"`
+    To           int       `json:"to"`
+    ToName       string    `json:"to_name"`
+    ToLabel      string    `json:"to_label"`
+    OrderFrom    string    `json:"order_from,omitempty"`
+    OrderTo      string    `json:"order_to"`
+    OrderToLabel string    `json:"order_to_label"`
+    Source       string    `json:"source"`
+    Comment      string    `json:"comment,omitempty"`
+    ChangedAt    time.Time `json:"changed_at"`
+}
+
+func ToStatusResponse(order models.Order, history []models.StatusChange) StatusResponse {
+    response := StatusResponse{
+        OrderUID:    order.OrderUID,
+        Status:      string(order.Status()),
+        StatusLabel: order.Status().Label(),
+        Items:       make([]ItemStatusInfo, 0, len(order.Items)),
+        History:     ToStatusChanges(history),
+    }
+    for _, item := range order.Items {
+        response.Items = append(response.Items, ItemStatusInfo{
+            RID:         item.RID,
+            Name:        item.Name,
+            Status:      int(item.Status),
+            StatusName:  item.Status.Name(),
+            StatusLabel: item.Status.Label(),
+        })
+    }
+    return response
+}
+
+func ToStatusChanges(changes []models.StatusChange) []StatusChangeInfo {
+    result := make([]StatusChangeInfo, 0, len(changes))
+    for _, change := range changes {
+        info := StatusChangeInfo{
+            OrderUID:     change.OrderUID,
+            RID:          change.RID,
+            To:           int(change.To),
+            ToName:       change.To.Name(),
+            ToLabel:      change.To.Label(),
+            OrderFrom:    string(change.OrderFrom),
+            OrderTo:      string(change.OrderTo),
+            OrderToLabel: change.OrderTo.Label(),
+            Source:       change.Source,
+            Comment:      change.Comment,
+            ChangedAt:    change.ChangedAt,
+        }
+        if change.From != 0 {
+            info.From = int(change.From)
+            info.FromName = change.From.Name()
+            info.FromLabel = change.From.Label()
+        }
+        result = append(result, info)
+    }
+    return result
+}
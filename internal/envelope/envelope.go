@@ -0,0 +1,206 @@
+// Package envelope разбирает конверт сообщения: тип, версию схемы, кодировку и метаданные производителя
+// вокруг payload. Конверт необязателен: JSON без него считается payload первой версии схемы,
+// поэтому производители могут переходить на конверт и новые схемы независимо от сервиса.
+package envelope
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "wb-order-hub/internal/codec"
+    "wb-order-hub/internal/models"
+)
+
+// TypeOrder - тип сообщения с полным заказом. События заказа используют свои типы (events.Type).
+const TypeOrder = "order"
+
+// ErrUnsupportedVersion - схема payload новее, чем умеет сервис. Такое сообщение не отбрасывается:
+// оно будет обработано после обновления сервиса.
+var ErrUnsupportedVersion = errors.New("версия схемы новее поддерживаемой")
+
+// Envelope - конверт сообщения.
+type Envelope struct {
+    Type          string    `json:"type"`
+    SchemaVersion int       `json:"schema_version"`
+    MessageID     string    `json:"message_id,omitempty"`
+    ProducedAt    time.Time `json:"produced_at,omitzero"`
+    Producer      string    `json:"producer,omitempty"`
+    // Encoding - кодировка payload. В JSON-конверте бинарный payload передаётся строкой base64,
+    // после Open в Payload лежат сами байты.
+    Encoding codec.Format    `json:"encoding,omitempty"`
+    Payload  json.RawMessage `json:"payload"`
+    // Legacy - сообщение пришло без конверта.
+    Legacy bool `json:"-"`
+}
+
+// Upconverter переводит payload из версии схемы N в N+1.
+type Upconverter func(payload json.RawMessage) (json.RawMessage, error)
+
+// Registry - версии схем по типам сообщений и переводы между ними.
+type Registry struct {
+    upconverters map[string]map[int]Upconverter
+}
+
+func NewRegistry() *Registry {
+    return &Registry{upconverters: make(map[string]map[int]Upconverter)}
+}
+
+// Default - схемы сообщений сервиса. Переводы регистрируются рядом с изменением модели.
+var Default = NewRegistry()
+
+// Register регистрирует перевод payload типа typ из версии from в from+1.
+// Вызывается при инициализации пакета, до разбора сообщений.
+func (r *Registry) Register(typ string, from int, up Upconverter) {
+    if r.upconverters[typ] == nil {
+        r.upconverters[typ] = make(map[int]Upconverter)
+    }
+    r.upconverters[typ][from] = up
+}
+
+// Current возвращает текущую версию схемы типа: следующую за последним переводом, без переводов - 1.
+func (r *Registry) Current(typ string) int {
+    version := 1
+    for r.upconverters[typ][version] != nil {
+        version++
+    }
+    return version
+}
+
+// Open разбирает сообщение и поднимает payload до текущей версии схемы. typ - тип, ожидаемый
+// в канале: он подставляется, если в конверте тип не указан, а указанный должен с ним совпадать.
+// JSON без конверта возвращается как payload версии 1 с Legacy = true. Бинарный payload без конверта
+// (с префиксом кодировки) всегда соответствует текущей схеме order.proto: она меняется только
+// добавлением полей.
+func (r *Registry) Open(data []byte, typ string) (Envelope, error) {
+    env, ok, err := detect(data)
+    if err != nil {
+        return Envelope{}, err
+    }
+    if !ok {
+        format, payload := codec.Detect(data)
+        env = Envelope{Type: typ, SchemaVersion: 1, Encoding: format, Payload: payload, Legacy: true}
+        if format != codec.JSON {
+            env.SchemaVersion = r.Current(typ)
+        }
+    }
+    switch {
+    case env.Type == "":
+        env.Type = typ
+    case typ != "" && env.Type != typ:
+        return Envelope{}, fmt.Errorf("сообщение типа %q, ожидался %q", env.Type, typ)
+    }
+    if env.Type == "" && !env.Legacy {
+        return Envelope{}, errors.New("в конверте не указан тип сообщения")
+    }
+
+    current := r.Current(env.Type)
+    switch {
+    case env.SchemaVersion < 1:
+        return Envelope{}, fmt.Errorf("некорректная версия схемы %d", env.SchemaVersion)
+    case env.SchemaVersion > current:
+        return Envelope{}, fmt.Errorf("%w: %s версии %d, сервис понимает до %d", ErrUnsupportedVersion, env.Type, env.SchemaVersion, current)
+    }
+    if env.SchemaVersion < current && env.Encoding != codec.JSON {
+        return Envelope{}, fmt.Errorf("перевод %s из версии %d доступен только для JSON, получен %s", env.Type, env.SchemaVersion, env.Encoding)
+    }
+    for ; env.SchemaVersion < current; env.SchemaVersion++ {
+        payload, err := r.upconverters[env.Type][env.SchemaVersion](env.Payload)
+        if err != nil {
+            return Envelope{}, fmt.Errorf("перевод %s из версии %d: %v", env.Type, env.SchemaVersion, err)
+        }
+        env.Payload = payload
+    }
+    return env, nil
+}
+
+// detect распознаёт конверт: JSON-объект с полями schema_version и payload.
+// У заказа и события заказа поля schema_version нет.
+func detect(data []byte) (Envelope, bool, error) {
+    var probe struct {
+        Envelope
+        SchemaVersion *int `json:"schema_version"`
+    }
+    if len(data) == 0 || data[0] == 0 || json.Unmarshal(data, &probe) != nil || probe.SchemaVersion == nil || len(probe.Payload) == 0 {
+        return Envelope{}, false, nil
+    }
+    env := probe.Envelope
+    env.SchemaVersion = *probe.SchemaVersion
+    format, err := codec.ParseFormat(string(env.Encoding))
+    if err != nil {
+        return Envelope{}, false, err
+    }
+    env.Encoding = format
+    if format != codec.JSON {
+        var payload []byte
+        if err := json.Unmarshal(env.Payload, &payload); err != nil {
+            return Envelope{}, false, fmt.Errorf("payload в кодировке %s должен быть строкой base64: %v", format, err)
+        }
+        env.Payload = payload
+    }
+    return env, true, nil
+}
+
+// Wrap заворачивает payload текущей версии схемы типа typ в кодировке f в конверт со случайным message_id.
+func Wrap(typ, producer string, f codec.Format, payload []byte) ([]byte, error) {
+    id := make([]byte, 8)
+    rand.Read(id)
+    env := Envelope{
+        Type:          typ,
+        SchemaVersion: Default.Current(typ),
+        MessageID:     hex.EncodeToString(id),
+        ProducedAt:    time.Now().UTC(),
+        Producer:      producer,
+        Payload:       payload,
+    }
+    if f != codec.JSON {
+        env.Encoding = f
+        encoded, err := json.Marshal(payload)
+        if err != nil {
+            return nil, err
+        }
+        env.Payload = encoded
+    }
+    return json.Marshal(env)
+}
+
+// Unwrap возвращает кодировку и payload конверта как есть, без перевода между версиями,
+// а для сообщения без конверта - его кодировку и содержимое без префикса.
+func Unwrap(data []byte) (codec.Format, []byte) {
+    if env, ok, err := detect(data); ok && err == nil {
+        return env.Encoding, env.Payload
+    }
+    return codec.Detect(data)
+}
+
+// Open разбирает сообщение по схемам Default.
+func Open(data []byte, typ string) (Envelope, error) {
+    return Default.Open(data, typ)
+}
+
+// ParseOrder разбирает сообщение с заказом в конверте или без него. Ошибка, кроме
+// ErrUnsupportedVersion, означает, что сообщение некорректно и повторная обработка не поможет.
+func ParseOrder(data []byte) (models.Order, Envelope, error) {
+    env, err := Open(data, TypeOrder)
+    if err != nil {
+        return models.Order{}, Envelope{}, err
+    }
+    if env.Encoding == codec.JSON {
+        order, err := models.ParseOrder(env.Payload)
+        if err != nil {
+            return models.Order{}, Envelope{}, err
+        }
+        return order, env, nil
+    }
+    order, err := codec.UnmarshalOrder(env.Encoding, env.Payload)
+    if err != nil {
+        return models.Order{}, Envelope{}, fmt.Errorf("ошибка десериализации %s: %v", env.Encoding, err)
+    }
+    if err := order.Validate(); err != nil {
+        return models.Order{}, Envelope{}, err
+    }
+    return order, env, nil
+}
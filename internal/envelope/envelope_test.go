@@ -0,0 +1,148 @@
+package envelope
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+
+    "wb-order-hub/internal/codec"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func TestOpen(t *testing.T) {
+    r := NewRegistry()
+    // v1 -> v2: amount переименовано в total; v2 -> v3: total в копейках.
+    r.Register("test", 1, func(p json.RawMessage) (json.RawMessage, error) {
+        var v struct {
+            Amount int `json:"amount"`
+        }
+        if err := json.Unmarshal(p, &v); err != nil {
+            return nil, err
+        }
+        return json.Marshal(map[string]int{"total": v.Amount})
+    })
+    r.Register("test", 2, func(p json.RawMessage) (json.RawMessage, error) {
+        var v struct {
+            Total int `json:"total"`
+        }
+        if err := json.Unmarshal(p, &v); err != nil {
+            return nil, err
+        }
+        return json.Marshal(map[string]int{"total": v.Total * 100})
+    })
+    if got := r.Current("test"); got != 3 {
+        t.Fatalf("Current = %d, ожидалась 3", got)
+    }
+
+    tests := []struct {
+        name    string
+        data    string
+        typ     string
+        want    string
+        legacy  bool
+        wantErr error
+    }{
+        {"Без конверта", `{"amount": 5}`, "test", `{"total":500}`, true, nil},
+        {"Версия 2", `{"type": "test", "schema_version": 2, "payload": {"total": 5}}`, "test", `{"total":500}`, false, nil},
+        {"Текущая версия", `{"type": "test", "schema_version": 3, "payload": {"total": 5}}`, "test", `{"total": 5}`, false, nil},
+        {"Тип из канала", `{"schema_version": 3, "payload": {"total": 5}}`, "test", `{"total": 5}`, false, nil},
+        {"Новее поддерживаемой", `{"type": "test", "schema_version": 4, "payload": {}}`, "test", "", false, ErrUnsupportedVersion},
+        {"Другой тип", `{"type": "order", "schema_version": 1, "payload": {}}`, "test", "", false, errAny},
+        {"Нулевая версия", `{"type": "test", "schema_version": 0, "payload": {}}`, "test", "", false, errAny},
+        {"Без типа", `{"schema_version": 1, "payload": {}}`, "", "", false, errAny},
+        {"Ошибка перевода", `{"type": "test", "schema_version": 1, "payload": []}`, "test", "", false, errAny},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            env, err := r.Open([]byte(tt.data), tt.typ)
+            if tt.wantErr != nil {
+                if err == nil || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+                    t.Fatalf("Ожидалась ошибка %v, получили %v", tt.wantErr, err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Open: %v", err)
+            }
+            if string(env.Payload) != tt.want || env.Legacy != tt.legacy || env.SchemaVersion != 3 || env.Type != "test" {
+                t.Errorf("Получили %+v, payload %s", env, env.Payload)
+            }
+        })
+    }
+}
+
+// errAny - в тесте подходит любая ошибка.
+var errAny = errors.New("любая ошибка")
+
+func TestParseOrder(t *testing.T) {
+    order := repotest.SampleOrder("env")
+    payload, _ := json.Marshal(order)
+
+    got, env, err := ParseOrder(payload)
+    if err != nil || got.OrderUID != "env" || !env.Legacy {
+        t.Fatalf("Заказ без конверта: %+v, %v", env, err)
+    }
+
+    wrapped := `{"type": "order", "schema_version": 1, "message_id": "m-1", "producer": "wb-shop",
+        "produced_at": "2026-01-02T03:04:05Z", "payload": ` + string(payload) + `}`
+    got, env, err = ParseOrder([]byte(wrapped))
+    if err != nil || got.OrderUID != "env" || env.Legacy || env.MessageID != "m-1" || env.Producer != "wb-shop" || env.ProducedAt.IsZero() {
+        t.Fatalf("Заказ в конверте: %+v, %v", env, err)
+    }
+    wrappedByUs, err := Wrap(TypeOrder, "test", codec.JSON, payload)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, env, err := ParseOrder(wrappedByUs); err != nil || got.OrderUID != "env" || env.MessageID == "" || env.Producer != "test" {
+        t.Errorf("Wrap: %+v, %v", env, err)
+    }
+    for _, data := range [][]byte{[]byte(wrapped), payload} {
+        if format, unwrapped := Unwrap(data); format != codec.JSON || !bytes.Equal(unwrapped, payload) {
+            t.Errorf("Unwrap вернул %s %s", format, unwrapped)
+        }
+    }
+
+    if _, _, err := ParseOrder([]byte(strings.Replace(wrapped, `"schema_version": 1`, `"schema_version": 99`, 1))); !errors.Is(err, ErrUnsupportedVersion) {
+        t.Errorf("Ожидалась ErrUnsupportedVersion, получили %v", err)
+    }
+    if _, _, err := ParseOrder([]byte(`{"type": "order", "schema_version": 1, "payload": {"order_uid": ""}}`)); err == nil {
+        t.Error("Некорректный заказ в конверте принят")
+    }
+}
+
+func TestParseOrder_Binary(t *testing.T) {
+    order := repotest.SampleOrder("bin")
+    for _, f := range []codec.Format{codec.Protobuf, codec.MsgPack} {
+        t.Run(string(f), func(t *testing.T) {
+            payload, err := codec.MarshalOrder(f, order)
+            if err != nil {
+                t.Fatal(err)
+            }
+            wrapped, err := Wrap(TypeOrder, "test", f, payload)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for name, data := range map[string][]byte{"С префиксом": codec.Frame(f, payload), "В конверте": wrapped} {
+                got, env, err := ParseOrder(data)
+                if err != nil || env.Encoding != f || got.OrderUID != "bin" || len(got.Items) != len(order.Items) {
+                    t.Errorf("%s: %+v, %v", name, env, err)
+                }
+                if format, unwrapped := Unwrap(data); format != f || !bytes.Equal(unwrapped, payload) {
+                    t.Errorf("%s: Unwrap вернул %s", name, format)
+                }
+            }
+        })
+    }
+
+    if _, _, err := ParseOrder([]byte(`{"type": "order", "schema_version": 1, "encoding": "xml", "payload": "PGE+"}`)); err == nil {
+        t.Error("Неизвестная кодировка принята")
+    }
+    if _, _, err := ParseOrder([]byte(`{"type": "order", "schema_version": 1, "encoding": "protobuf", "payload": {}}`)); err == nil {
+        t.Error("Бинарный payload не строкой принят")
+    }
+    if _, _, err := ParseOrder(codec.Frame(codec.Protobuf, []byte{0xff})); err == nil {
+        t.Error("Повреждённый protobuf принят")
+    }
+}
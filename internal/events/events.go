@@ -0,0 +1,231 @@
+// Package events применяет к сохранённым заказам частичные изменения: события
+// payment.updated, delivery.address_changed, item.status_changed и order.cancelled.
+// Каждый тип события приходит в свой канал, поэтому порядок между ними не гарантирован:
+// события упорядочиваются по версии заказа, а пришедшие раньше предшественника откладываются.
+package events
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+
+    "wb-order-hub/internal/codec"
+    "wb-order-hub/internal/envelope"
+    "wb-order-hub/internal/models"
+)
+
+// ErrInvalid помечает события, которые нельзя применить ни сейчас, ни при повторной доставке.
+var ErrInvalid = errors.New("некорректное событие")
+
+// Type - тип события.
+type Type string
+
+const (
+    PaymentUpdated         Type = "payment.updated"
+    DeliveryAddressChanged Type = "delivery.address_changed"
+    ItemStatusChanged      Type = "item.status_changed"
+    OrderCancelled         Type = "order.cancelled"
+)
+
+// Types возвращает все типы событий. На каждый сервис подписывается отдельно.
+func Types() []Type {
+    return []Type{PaymentUpdated, DeliveryAddressChanged, ItemStatusChanged, OrderCancelled}
+}
+
+// Subject возвращает канал событий типа t: <prefix>.<тип>.
+func Subject(prefix string, t Type) string {
+    return prefix + "." + string(t)
+}
+
+// TypeOf определяет тип события по каналу; для чужого канала возвращает пустую строку.
+func TypeOf(subject string) Type {
+    for _, t := range Types() {
+        if subject == string(t) || strings.HasSuffix(subject, "."+string(t)) {
+            return t
+        }
+    }
+    return ""
+}
+
+// Event - событие заказа. Version - версия заказа после события: оно применяется к заказу
+// версии Version-1. Новый заказ получает версию 1, поэтому первое событие имеет версию 2.
+type Event struct {
+    ID       string          `json:"event_id,omitempty"`
+    Type     Type            `json:"type"`
+    OrderUID string          `json:"order_uid"`
+    Version  int64           `json:"version"`
+    Payload  json.RawMessage `json:"payload"`
+}
+
+// DeliveryAddress - содержимое delivery.address_changed. Получатель и контакты не меняются.
+type DeliveryAddress struct {
+    Zip     string `json:"zip"`
+    City    string `json:"city"`
+    Address string `json:"address"`
+    Region  string `json:"region"`
+}
+
+// ItemStatus - содержимое item.status_changed. Status - код статуса, как в заказе.
+type ItemStatus struct {
+    RID     string            `json:"rid"`
+    Status  models.ItemStatus `json:"status"`
+    Comment string            `json:"comment,omitempty"`
+}
+
+// Cancellation - содержимое order.cancelled.
+type Cancellation struct {
+    Reason string `json:"reason,omitempty"`
+}
+
+// Parse разбирает событие из канала subject, в конверте (тип конверта - тип события) или без него.
+// Тип можно не указывать в сообщении: тогда он берётся из канала, а указанный должен с каналом совпадать.
+func Parse(data []byte, subject string) (Event, error) {
+    fromSubject := TypeOf(subject)
+    env, err := envelope.Open(data, string(fromSubject))
+    if err != nil {
+        return Event{}, err
+    }
+    if env.Encoding != codec.JSON {
+        return Event{}, fmt.Errorf("события принимаются только в JSON, получен %s", env.Encoding)
+    }
+    var e Event
+    if err := json.Unmarshal(env.Payload, &e); err != nil {
+        return Event{}, fmt.Errorf("ошибка десериализации события: %v", err)
+    }
+    if e.Type == "" {
+        e.Type = Type(env.Type)
+    } else if env.Type != "" && e.Type != Type(env.Type) {
+        return Event{}, fmt.Errorf("событие %s в сообщении типа %s", e.Type, env.Type)
+    }
+    if e.ID == "" {
+        e.ID = env.MessageID
+    }
+    if err := e.Validate(); err != nil {
+        return Event{}, err
+    }
+    return e, nil
+}
+
+// Validate проверяет заголовок события и разбирает его содержимое.
+func (e Event) Validate() error {
+    if e.OrderUID == "" {
+        return errors.New("пустой order_uid")
+    }
+    if len(e.OrderUID) > models.MaxOrderUIDLength {
+        return fmt.Errorf("order_uid длиннее %d символов", models.MaxOrderUIDLength)
+    }
+    if e.Version < 2 {
+        return fmt.Errorf("версия события %d: первое событие заказа имеет версию 2", e.Version)
+    }
+    _, err := e.change()
+    return err
+}
+
+// Apply применяет событие к заказу. Ошибки оборачивают ErrInvalid.
+func (e Event) Apply(order *models.Order) error {
+    change, err := e.change()
+    if err != nil {
+        return err
+    }
+    if err := change(order); err != nil {
+        return fmt.Errorf("%w: %s версии %d: %w", ErrInvalid, e.Type, e.Version, err)
+    }
+    return nil
+}
+
+// Comment - комментарий к переходам статусов, сделанным событием.
+func (e Event) Comment() string {
+    switch e.Type {
+    case ItemStatusChanged:
+        var p ItemStatus
+        json.Unmarshal(e.Payload, &p)
+        return p.Comment
+    case OrderCancelled:
+        var p Cancellation
+        json.Unmarshal(e.Payload, &p)
+        return p.Reason
+    }
+    return ""
+}
+
+// change разбирает содержимое события и возвращает изменение заказа.
+func (e Event) change() (func(*models.Order) error, error) {
+    switch e.Type {
+    case PaymentUpdated:
+        var p models.Payment
+        if err := decodePayload(e, &p); err != nil {
+            return nil, err
+        }
+        // Неизвестные поля оплаты сохраняются, как и в полном заказе.
+        p, _ = models.ParsePayment(e.Payload)
+        return func(o *models.Order) error {
+            o.Payment = p
+            return nil
+        }, nil
+
+    case DeliveryAddressChanged:
+        var p DeliveryAddress
+        if err := decodePayload(e, &p); err != nil {
+            return nil, err
+        }
+        if p.City == "" || p.Address == "" {
+            return nil, fmt.Errorf("%w: %s без города или адреса", ErrInvalid, e.Type)
+        }
+        return func(o *models.Order) error {
+            o.Delivery.Zip, o.Delivery.City, o.Delivery.Address, o.Delivery.Region = p.Zip, p.City, p.Address, p.Region
+            return nil
+        }, nil
+
+    case ItemStatusChanged:
+        var p ItemStatus
+        if err := decodePayload(e, &p); err != nil {
+            return nil, err
+        }
+        if p.RID == "" || !p.Status.Known() {
+            return nil, fmt.Errorf("%w: %s без rid или с неизвестным статусом %d", ErrInvalid, e.Type, p.Status)
+        }
+        // Как и в полном заказе, переходы от производителя не проверяются.
+        return func(o *models.Order) error {
+            for i := range o.Items {
+                if o.Items[i].RID == p.RID {
+                    o.Items[i].Status = p.Status
+                    return nil
+                }
+            }
+            return fmt.Errorf("%w: %s", models.ErrItemNotFound, p.RID)
+        }, nil
+
+    case OrderCancelled:
+        var p Cancellation
+        if err := decodePayload(e, &p); err != nil {
+            return nil, err
+        }
+        // Доставленные и возвращённые товары отменой не затрагиваются.
+        return func(o *models.Order) error {
+            for i, item := range o.Items {
+                switch item.Status {
+                case models.ItemDelivered, models.ItemReturned, models.ItemCancelled:
+                default:
+                    o.Items[i].Status = models.ItemCancelled
+                }
+            }
+            return nil
+        }, nil
+    }
+    return nil, fmt.Errorf("%w: неизвестный тип %q", ErrInvalid, e.Type)
+}
+
+func decodePayload(e Event, v any) error {
+    if len(e.Payload) == 0 {
+        // Отмене содержимое не обязательно.
+        if e.Type == OrderCancelled {
+            return nil
+        }
+        return fmt.Errorf("%w: %s без payload", ErrInvalid, e.Type)
+    }
+    if err := json.Unmarshal(e.Payload, v); err != nil {
+        return fmt.Errorf("%w: payload %s: %v", ErrInvalid, e.Type, err)
+    }
+    return nil
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+    "errors"
+    "testing"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        name    string
+        data    string
+        subject string
+        want    Type
+        wantErr bool
+    }{
+        {"Тип из канала", `{"order_uid": "a", "version": 2, "payload": {"reason": "клиент"}}`, "orders.events.order.cancelled", OrderCancelled, false},
+        {"Тип в сообщении", `{"type": "payment.updated", "order_uid": "a", "version": 2, "payload": {"amount": 1}}`, "", PaymentUpdated, false},
+        {"Тип не совпадает с каналом", `{"type": "payment.updated", "order_uid": "a", "version": 2, "payload": {}}`, "orders.events.order.cancelled", "", true},
+        {"Неизвестный тип", `{"type": "order.lost", "order_uid": "a", "version": 2, "payload": {}}`, "", "", true},
+        {"Без order_uid", `{"version": 2}`, "orders.events.order.cancelled", "", true},
+        {"Версия создания заказа", `{"order_uid": "a", "version": 1}`, "orders.events.order.cancelled", "", true},
+        {"Без payload", `{"order_uid": "a", "version": 2}`, "orders.events.payment.updated", "", true},
+        {"Адрес без города", `{"order_uid": "a", "version": 2, "payload": {"address": "x"}}`, "orders.events.delivery.address_changed", "", true},
+        {"Неизвестный статус", `{"order_uid": "a", "version": 2, "payload": {"rid": "x", "status": 999}}`, "orders.events.item.status_changed", "", true},
+        {"Не JSON", `not json`, "orders.events.order.cancelled", "", true},
+        {"В конверте", `{"type": "order.cancelled", "schema_version": 1, "message_id": "m1", "payload": {"order_uid": "a", "version": 2}}`, "orders.events.order.cancelled", OrderCancelled, false},
+        {"Конверт другого типа", `{"type": "order", "schema_version": 1, "payload": {"order_uid": "a", "version": 2}}`, "orders.events.order.cancelled", "", true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e, err := Parse([]byte(tt.data), tt.subject)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Parse: %v", err)
+            }
+            if err == nil && e.Type != tt.want {
+                t.Errorf("Ожидался тип %s, получили %s", tt.want, e.Type)
+            }
+        })
+    }
+}
+
+func TestApply(t *testing.T) {
+    apply := func(t *testing.T, order *models.Order, typ Type, payload string) error {
+        t.Helper()
+        return Event{Type: typ, OrderUID: order.OrderUID, Version: 2, Payload: []byte(payload)}.Apply(order)
+    }
+
+    order := repotest.SampleOrder("a")
+    if err := apply(t, &order, PaymentUpdated, `{"transaction": "a", "currency": "RUB", "amount": 500}`); err != nil || order.Payment.Amount != 500 || order.Payment.Currency != "RUB" {
+        t.Errorf("payment.updated: %+v, %v", order.Payment, err)
+    }
+    if err := apply(t, &order, PaymentUpdated, `{"transaction": "a", "amount": 500, "cashback": 5}`); err != nil || string(order.Payment.Extra["cashback"]) != "5" {
+        t.Errorf("payment.updated с неизвестным полем: %+v, %v", order.Payment, err)
+    }
+    if err := apply(t, &order, DeliveryAddressChanged, `{"city": "Москва", "address": "Тверская 1"}`); err != nil ||
+        order.Delivery.City != "Москва" || order.Delivery.Zip != "" || order.Delivery.Name != "Test Testov" {
+        t.Errorf("delivery.address_changed: %+v, %v", order.Delivery, err)
+    }
+    rid := order.Items[0].RID
+    if err := apply(t, &order, ItemStatusChanged, `{"rid": "`+rid+`", "status": 203}`); err != nil || order.Items[0].Status != models.ItemDelivered {
+        t.Errorf("item.status_changed: %+v, %v", order.Items, err)
+    }
+    if err := apply(t, &order, ItemStatusChanged, `{"rid": "missing", "status": 203}`); !errors.Is(err, ErrInvalid) || !errors.Is(err, models.ErrItemNotFound) {
+        t.Errorf("Ожидалась ErrInvalid для неизвестного товара, получили %v", err)
+    }
+
+    order.Items = append(order.Items, order.Items[0])
+    order.Items[1].Status = models.ItemAssembled
+    if err := apply(t, &order, OrderCancelled, ``); err != nil {
+        t.Fatal(err)
+    }
+    if order.Items[0].Status != models.ItemDelivered || order.Items[1].Status != models.ItemCancelled {
+        t.Errorf("order.cancelled должен отменять только недоставленные товары: %+v", order.Items)
+    }
+}
@@ -0,0 +1,212 @@
+package events
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// SourceEvent - источник переходов статусов, сделанных событиями, в истории статусов.
+const SourceEvent = "event"
+
+// maxConflicts - сколько раз подряд событие переприменяется после конфликта версий.
+const maxConflicts = 10
+
+// ParkingLot хранит события, пришедшие раньше предшественника.
+type ParkingLot interface {
+    // Park откладывает событие. Повторное откладывание той же версии заказа ничего не меняет.
+    Park(ctx context.Context, e Event) error
+    // Get возвращает отложенное событие заказа с указанной версией.
+    Get(ctx context.Context, uid string, version int64) (Event, bool, error)
+    // Release удаляет отложенные события заказа с версией не больше version.
+    Release(ctx context.Context, uid string, version int64) error
+}
+
+// Outcome - что стало с событием.
+type Outcome int
+
+const (
+    // Applied - событие применено.
+    Applied Outcome = iota
+    // Parked - предшественник ещё не пришёл, событие отложено.
+    Parked
+    // Duplicate - заказ уже в версии события или новее, событие пропущено.
+    Duplicate
+)
+
+func (o Outcome) String() string {
+    switch o {
+    case Applied:
+        return "применено"
+    case Parked:
+        return "отложено"
+    }
+    return "уже применено"
+}
+
+// Result - итог обработки события.
+type Result struct {
+    Outcome Outcome
+    // Released - сколько отложенных событий применено вслед за этим.
+    Released int
+    // Order - заказ после последнего применённого события, nil - заказ не менялся.
+    Order *models.Order
+}
+
+// Processor применяет события к заказам хранилища с оптимистичной блокировкой по версии.
+type Processor struct {
+    repo   repository.VersionedRepository
+    parked ParkingLot
+}
+
+func NewProcessor(repo repository.VersionedRepository, parked ParkingLot) *Processor {
+    return &Processor{repo: repo, parked: parked}
+}
+
+// Handle применяет событие, если заказ находится в предыдущей версии, откладывает его,
+// если предшественник ещё не применён, и пропускает, если событие уже применено.
+// Вслед за применённым событием применяются дождавшиеся его отложенные.
+func (p *Processor) Handle(ctx context.Context, e Event) (Result, error) {
+    var result Result
+    for conflicts := 0; ; conflicts++ {
+        current, err := p.version(ctx, e.OrderUID)
+        if err != nil {
+            return result, err
+        }
+        switch {
+        case e.Version <= current:
+            result.Outcome = Duplicate
+            return result, nil
+        case e.Version > current+1:
+            if err := p.parked.Park(ctx, e); err != nil {
+                return result, fmt.Errorf("не удалось отложить событие: %w", err)
+            }
+            result.Outcome = Parked
+            // Предшественник мог примениться, пока событие откладывалось, и не найти его.
+            return result, p.release(ctx, e.OrderUID, &result)
+        }
+
+        order, err := p.repo.Update(ctx, e.OrderUID, current, p.update(e))
+        if isConflict(err) && conflicts < maxConflicts {
+            continue
+        }
+        if err != nil {
+            return result, err
+        }
+        result.Outcome = Applied
+        result.Order = &order
+        return result, p.release(ctx, e.OrderUID, &result)
+    }
+}
+
+// Resume применяет отложенные события, следующие за текущей версией заказа, и возвращает
+// заказ после последнего из них (nil - ни одно не применено). Вызывается после сохранения
+// полного заказа: события могли прийти раньше него.
+func (p *Processor) Resume(ctx context.Context, uid string) (*models.Order, error) {
+    var result Result
+    err := p.release(ctx, uid, &result)
+    return result.Order, err
+}
+
+// release по очереди применяет отложенные события заказа, пока находится следующее.
+func (p *Processor) release(ctx context.Context, uid string, result *Result) error {
+    for {
+        current, err := p.version(ctx, uid)
+        if err != nil || current == 0 {
+            return err
+        }
+        next, ok, err := p.parked.Get(ctx, uid, current+1)
+        if err != nil {
+            return fmt.Errorf("не удалось получить отложенное событие: %w", err)
+        }
+        if !ok {
+            return nil
+        }
+        order, err := p.repo.Update(ctx, uid, current, p.update(next))
+        switch {
+        case isConflict(err):
+            // Версию продвинул другой обработчик: перечитываем её.
+            continue
+        case errors.Is(err, ErrInvalid):
+            // Событие не применится никогда. Заказ остаётся в прежней версии,
+            // а следующие события - отложенными до исправления производителем.
+            log.Printf("Отложенное событие заказа %s удалено: %v", uid, err)
+            return p.parked.Release(ctx, uid, next.Version)
+        case err != nil:
+            return err
+        }
+        if err := p.parked.Release(ctx, uid, next.Version); err != nil {
+            return fmt.Errorf("не удалось удалить применённое событие: %w", err)
+        }
+        result.Released++
+        result.Order = &order
+    }
+}
+
+func (p *Processor) update(e Event) repository.Update {
+    return repository.Update{Apply: e.Apply, Source: SourceEvent, Comment: e.Comment()}
+}
+
+// version возвращает версию заказа, 0 - заказа ещё нет.
+func (p *Processor) version(ctx context.Context, uid string) (int64, error) {
+    version, err := p.repo.Version(ctx, uid)
+    if errors.Is(err, repository.ErrNotFound) {
+        return 0, nil
+    }
+    return version, err
+}
+
+// isConflict: заказ изменился или был удалён между чтением версии и Update.
+func isConflict(err error) bool {
+    return errors.Is(err, repository.ErrVersionConflict) || errors.Is(err, repository.ErrNotFound)
+}
+
+// Memory - отложенные события в памяти для режима разработки и тестов.
+type Memory struct {
+    mu     sync.Mutex
+    events map[string]map[int64]Event
+}
+
+var _ ParkingLot = (*Memory)(nil)
+
+func NewMemory() *Memory {
+    return &Memory{events: make(map[string]map[int64]Event)}
+}
+
+func (m *Memory) Park(_ context.Context, e Event) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.events[e.OrderUID] == nil {
+        m.events[e.OrderUID] = make(map[int64]Event)
+    }
+    if _, ok := m.events[e.OrderUID][e.Version]; !ok {
+        m.events[e.OrderUID][e.Version] = e
+    }
+    return nil
+}
+
+func (m *Memory) Get(_ context.Context, uid string, version int64) (Event, bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    e, ok := m.events[uid][version]
+    return e, ok, nil
+}
+
+func (m *Memory) Release(_ context.Context, uid string, version int64) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for v := range m.events[uid] {
+        if v <= version {
+            delete(m.events[uid], v)
+        }
+    }
+    if len(m.events[uid]) == 0 {
+        delete(m.events, uid)
+    }
+    return nil
+}
@@ -0,0 +1,124 @@
+package events
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "testing"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func amountEvent(version int64, amount int) Event {
+    return Event{
+        Type:     PaymentUpdated,
+        OrderUID: "a",
+        Version:  version,
+        Payload:  []byte(fmt.Sprintf(`{"transaction": "a", "amount": %d}`, amount)),
+    }
+}
+
+func TestProcessor_OutOfOrder(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemory()
+    parked := NewMemory()
+    p := NewProcessor(repo, parked)
+
+    // События пришли раньше самого заказа.
+    for _, e := range []Event{amountEvent(3, 300), amountEvent(4, 400)} {
+        if result, err := p.Handle(ctx, e); err != nil || result.Outcome != Parked {
+            t.Fatalf("Событие %d: %+v, %v", e.Version, result, err)
+        }
+    }
+    repo.Save(ctx, repotest.SampleOrder("a"))
+    if order, err := p.Resume(ctx, "a"); err != nil || order != nil {
+        t.Fatalf("Без события версии 2 отложенные не применяются: %v, %v", order, err)
+    }
+
+    result, err := p.Handle(ctx, amountEvent(2, 200))
+    if err != nil || result.Outcome != Applied || result.Released != 2 {
+        t.Fatalf("Handle: %+v, %v", result, err)
+    }
+    if result.Order.Payment.Amount != 400 {
+        t.Errorf("Ожидалась сумма из последнего события, получили %d", result.Order.Payment.Amount)
+    }
+    if version, _ := repo.Version(ctx, "a"); version != 4 {
+        t.Errorf("Ожидалась версия 4, получили %d", version)
+    }
+    if _, ok, _ := parked.Get(ctx, "a", 4); ok {
+        t.Error("Применённые события должны быть удалены из отложенных")
+    }
+
+    // Повторная доставка.
+    if result, err := p.Handle(ctx, amountEvent(3, 300)); err != nil || result.Outcome != Duplicate || result.Order != nil {
+        t.Errorf("Повтор: %+v, %v", result, err)
+    }
+    if order, _ := repo.GetByUID(ctx, "a"); order.Payment.Amount != 400 {
+        t.Errorf("Повтор не должен менять заказ, сумма %d", order.Payment.Amount)
+    }
+}
+
+func TestProcessor_ResumeAfterOrder(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemory()
+    p := NewProcessor(repo, NewMemory())
+
+    p.Handle(ctx, Event{Type: ItemStatusChanged, OrderUID: "a", Version: 2, Payload: []byte(`{"rid": "ab4219087a764ae0btest", "status": 203, "comment": "вручен"}`)})
+    repo.Save(ctx, repotest.SampleOrder("a"))
+    order, err := p.Resume(ctx, "a")
+    if err != nil || order == nil || order.Items[0].Status != models.ItemDelivered {
+        t.Fatalf("Resume: %+v, %v", order, err)
+    }
+    history, _ := repo.StatusHistory(ctx, "a")
+    if last := history[len(history)-1]; last.Source != SourceEvent || last.Comment != "вручен" {
+        t.Errorf("Переход из события записан неверно: %+v", last)
+    }
+}
+
+func TestProcessor_InvalidParkedEvent(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemory()
+    parked := NewMemory()
+    p := NewProcessor(repo, parked)
+    repo.Save(ctx, repotest.SampleOrder("a"))
+
+    p.Handle(ctx, Event{Type: ItemStatusChanged, OrderUID: "a", Version: 3, Payload: []byte(`{"rid": "missing", "status": 203}`)})
+    if _, err := p.Handle(ctx, amountEvent(2, 200)); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok, _ := parked.Get(ctx, "a", 3); ok {
+        t.Error("Неприменимое отложенное событие должно быть удалено")
+    }
+    if version, _ := repo.Version(ctx, "a"); version != 2 {
+        t.Errorf("Ожидалась версия 2, получили %d", version)
+    }
+}
+
+func TestProcessor_Concurrent(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemory()
+    p := NewProcessor(repo, NewMemory())
+    repo.Save(ctx, repotest.SampleOrder("a"))
+
+    // События разных типов приходят из разных каналов в произвольном порядке.
+    const last = 50
+    var wg sync.WaitGroup
+    for version := int64(last); version >= 2; version-- {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if _, err := p.Handle(ctx, amountEvent(version, int(version))); err != nil {
+                t.Errorf("Событие %d: %v", version, err)
+            }
+        }()
+    }
+    wg.Wait()
+
+    order, _ := repo.GetByUID(ctx, "a")
+    version, _ := repo.Version(ctx, "a")
+    if version != last || order.Payment.Amount != last {
+        t.Errorf("Ожидалась версия %d, получили %d с суммой %d", last, version, order.Payment.Amount)
+    }
+}
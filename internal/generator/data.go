@@ -0,0 +1,133 @@
+package generator
+
+import "wb-order-hub/internal/models"
+
+// market - набор согласованных между собой значений для одной страны:
+// локаль, валюта, города, банки и службы доставки.
+type market struct {
+    locale   string
+    currency string
+    // priceScale переводит базовую цену товара в валюту рынка.
+    priceScale  int
+    phonePrefix string
+    cities      []city
+    banks       []string
+    services    []string
+    firstNames  []string
+    lastNames   []string
+}
+
+type city struct {
+    name, region, zip string
+}
+
+var markets = []market{
+    {
+        locale:      "ru",
+        currency:    "RUB",
+        priceScale:  90,
+        phonePrefix: "+79",
+        cities: []city{
+            {"Moscow", "Moscow", "101000"},
+            {"Saint Petersburg", "Leningrad Oblast", "190000"},
+            {"Kazan", "Tatarstan", "420000"},
+            {"Novosibirsk", "Novosibirsk Oblast", "630000"},
+            {"Yekaterinburg", "Sverdlovsk Oblast", "620000"},
+        },
+        banks:      []string{"sber", "alpha", "tinkoff", "vtb"},
+        services:   []string{"wb-courier", "cdek", "boxberry", "pochta"},
+        firstNames: []string{"Ivan", "Anna", "Sergey", "Olga", "Dmitry", "Maria"},
+        lastNames:  []string{"Ivanov", "Smirnova", "Kuznetsov", "Popova", "Sokolov", "Volkova"},
+    },
+    {
+        locale:      "en",
+        currency:    "USD",
+        priceScale:  1,
+        phonePrefix: "+972",
+        cities: []city{
+            {"Kiryat Mozkin", "Kraiot", "2639809"},
+            {"Haifa", "Haifa District", "3100000"},
+            {"Tel Aviv", "Tel Aviv District", "6100000"},
+        },
+        banks:      []string{"alpha", "leumi", "hapoalim"},
+        services:   []string{"meest", "dhl"},
+        firstNames: []string{"Test", "David", "Sarah", "Michael", "Rachel"},
+        lastNames:  []string{"Testov", "Cohen", "Levi", "Mizrahi", "Peretz"},
+    },
+    {
+        locale:      "kz",
+        currency:    "KZT",
+        priceScale:  450,
+        phonePrefix: "+77",
+        cities: []city{
+            {"Almaty", "Almaty", "050000"},
+            {"Astana", "Astana", "010000"},
+            {"Shymkent", "Shymkent", "160000"},
+        },
+        banks:      []string{"kaspi", "halyk", "forte"},
+        services:   []string{"wb-courier", "cdek", "kazpost"},
+        firstNames: []string{"Aidar", "Aigerim", "Nursultan", "Dana"},
+        lastNames:  []string{"Nurlanov", "Abenova", "Seitkali", "Zhumabayeva"},
+    },
+    {
+        locale:      "by",
+        currency:    "BYN",
+        priceScale:  3,
+        phonePrefix: "+37529",
+        cities: []city{
+            {"Minsk", "Minsk", "220000"},
+            {"Brest", "Brest Region", "224000"},
+        },
+        banks:      []string{"belarusbank", "priorbank"},
+        services:   []string{"wb-courier", "belpost"},
+        firstNames: []string{"Alexei", "Tatsiana", "Pavel", "Hanna"},
+        lastNames:  []string{"Kovalenko", "Novik", "Shevchuk", "Melnik"},
+    },
+    {
+        locale:      "de",
+        currency:    "EUR",
+        priceScale:  1,
+        phonePrefix: "+4915",
+        cities: []city{
+            {"Berlin", "Berlin", "10115"},
+            {"Munich", "Bavaria", "80331"},
+            {"Hamburg", "Hamburg", "20095"},
+        },
+        banks:      []string{"deutsche", "commerzbank", "n26"},
+        services:   []string{"dhl", "hermes", "dpd"},
+        firstNames: []string{"Lukas", "Sophie", "Jonas", "Lena"},
+        lastNames:  []string{"Muller", "Schmidt", "Schneider", "Fischer"},
+    },
+}
+
+// product - товар каталога. Цена указана в базовых единицах и масштабируется под валюту рынка.
+type product struct {
+    brand     string
+    name      string
+    nmID      int
+    basePrice int
+    sizes     []string
+}
+
+var catalog = []product{
+    {"Vivienne Sabo", "Mascaras", 2389212, 6, []string{"0"}},
+    {"L'Oreal Paris", "Lipstick", 2389301, 12, []string{"0"}},
+    {"Nike", "Sneakers Air Max", 1450021, 130, []string{"40", "41", "42", "43", "44"}},
+    {"Adidas", "Hoodie", 1450177, 65, []string{"S", "M", "L", "XL"}},
+    {"Levi's", "Jeans 501", 3312004, 80, []string{"30", "32", "34", "36"}},
+    {"Zara", "Dress", 3312590, 45, []string{"XS", "S", "M", "L"}},
+    {"Samsung", "Galaxy Buds", 5120034, 150, []string{"0"}},
+    {"Apple", "USB-C Cable", 5120410, 19, []string{"0"}},
+    {"Xiaomi", "Power Bank 10000", 5121882, 25, []string{"0"}},
+    {"LEGO", "Classic Bricks", 7003311, 35, []string{"0"}},
+    {"IKEA", "Mug Set", 7003920, 10, []string{"0"}},
+    {"Tefal", "Frying Pan", 7004105, 40, []string{"24", "28"}},
+}
+
+var (
+    providers = []string{"wbpay", "card", "sbp"}
+    // deliveryCosts - базовая стоимость доставки, масштабируется под валюту рынка.
+    deliveryCosts = []int{0, 0, 2, 5, 15}
+    itemStatuses  = []models.ItemStatus{models.ItemInTransit, models.ItemInTransit, models.ItemInTransit, models.ItemAccepted, models.ItemAssembled, models.ItemDelivered}
+    streets       = []string{"Ploshad Mira", "Lenina", "Central Ave", "Main St", "Hauptstrasse", "Abay Ave"}
+)
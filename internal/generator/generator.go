@@ -0,0 +1,184 @@
+// Package generator создаёт правдоподобные и внутренне согласованные заказы для
+// нагрузочного тестирования. При одинаковых параметрах последовательность заказов
+// всегда одна и та же.
+package generator
+
+import (
+    "encoding/json"
+    "fmt"
+    "math/rand/v2"
+    "strconv"
+    "strings"
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+// Options - параметры генератора.
+type Options struct {
+    Seed uint64
+    // Start - дата создания первого заказа; по умолчанию 2024-01-01 UTC.
+    Start time.Time
+    // Interval - средний промежуток между датами создания заказов; по умолчанию минута.
+    Interval time.Duration
+    // Customers - сколько разных покупателей делают заказы; по умолчанию 1000.
+    Customers int
+    // MaxItems - максимальное число товаров в заказе; по умолчанию 5.
+    MaxItems int
+}
+
+// Generator создаёт заказы. Не безопасен для одновременного использования.
+type Generator struct {
+    opts    Options
+    rng     *rand.Rand
+    created time.Time
+}
+
+func New(opts Options) *Generator {
+    if opts.Start.IsZero() {
+        opts.Start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    }
+    if opts.Interval <= 0 {
+        opts.Interval = time.Minute
+    }
+    if opts.Customers <= 0 {
+        opts.Customers = 1000
+    }
+    if opts.MaxItems <= 0 {
+        opts.MaxItems = 5
+    }
+    return &Generator{
+        opts:    opts,
+        rng:     rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15)),
+        created: opts.Start,
+    }
+}
+
+// Next возвращает следующий заказ. Суммы в минимальных единицах валюты рынка и согласованы:
+// total_price товара - цена со скидкой, goods_total - сумма total_price,
+// amount = goods_total + delivery_cost + custom_fee.
+func (g *Generator) Next() models.Order {
+    m := markets[g.rng.IntN(len(markets))]
+    c := m.cities[g.rng.IntN(len(m.cities))]
+    uid := g.hex(16) + "gen"
+    track := "WBIL" + g.letters(10)
+
+    customer := g.rng.IntN(g.opts.Customers)
+    first := m.firstNames[customer%len(m.firstNames)]
+    last := m.lastNames[(customer/len(m.firstNames))%len(m.lastNames)]
+
+    // Цены каталога - в основных единицах валюты.
+    unit := models.Amount(1)
+    for range models.CurrencyExponent(m.currency) {
+        unit *= 10
+    }
+
+    items := make([]models.Item, 1+g.rng.IntN(g.opts.MaxItems))
+    var goodsTotal models.Amount
+    for i := range items {
+        p := catalog[g.rng.IntN(len(catalog))]
+        price := models.Amount(p.basePrice*m.priceScale+g.rng.IntN(p.basePrice*m.priceScale/10+1)) * unit
+        sale := 5 * g.rng.IntN(11)
+        total := price * models.Amount(100-sale) / 100
+        items[i] = models.Item{
+            ChrtID:      1_000_000 + g.rng.IntN(9_000_000),
+            TrackNumber: track,
+            Price:       price,
+            RID:         g.hex(18) + "gen",
+            Name:        p.name,
+            Sale:        sale,
+            Size:        p.sizes[g.rng.IntN(len(p.sizes))],
+            TotalPrice:  total,
+            NmID:        p.nmID,
+            Brand:       p.brand,
+            Status:      itemStatuses[g.rng.IntN(len(itemStatuses))],
+        }
+        goodsTotal += total
+    }
+
+    deliveryCost := models.Amount(deliveryCosts[g.rng.IntN(len(deliveryCosts))]*m.priceScale) * unit
+    var customFee models.Amount
+    if g.rng.IntN(10) == 0 {
+        customFee = goodsTotal / 100
+    }
+
+    g.created = g.created.Add(time.Duration(g.rng.Int64N(2 * int64(g.opts.Interval))))
+    created := g.created.Truncate(time.Second).UTC()
+
+    return models.Order{
+        OrderUID:    uid,
+        TrackNumber: track,
+        Entry:       "WBIL",
+        Delivery: models.Delivery{
+            Name:    first + " " + last,
+            Phone:   m.phonePrefix + g.digits(7),
+            Zip:     c.zip,
+            City:    c.name,
+            Address: fmt.Sprintf("%s %d", streets[g.rng.IntN(len(streets))], 1+g.rng.IntN(150)),
+            Region:  c.region,
+            Email:   fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), customer),
+        },
+        Payment: models.Payment{
+            Transaction:  uid,
+            Currency:     m.currency,
+            Provider:     providers[g.rng.IntN(len(providers))],
+            Amount:       goodsTotal + deliveryCost + customFee,
+            PaymentDt:    created.Add(time.Duration(g.rng.IntN(600)) * time.Second),
+            Bank:         m.banks[g.rng.IntN(len(m.banks))],
+            DeliveryCost: deliveryCost,
+            GoodsTotal:   goodsTotal,
+            CustomFee:    customFee,
+        },
+        Items:           items,
+        Locale:          m.locale,
+        CustomerID:      "customer-" + strconv.Itoa(customer),
+        DeliveryService: m.services[g.rng.IntN(len(m.services))],
+        Shardkey:        strconv.Itoa(g.rng.IntN(10)),
+        SmID:            1 + g.rng.IntN(200),
+        DateCreated:     created,
+        OofShard:        strconv.Itoa(1 + g.rng.IntN(2)),
+    }
+}
+
+// Invalid возвращает сообщение, которое сервис должен отклонить: битый JSON,
+// заказ без order_uid или с некорректной датой создания.
+func (g *Generator) Invalid() []byte {
+    order := g.Next()
+    switch g.rng.IntN(3) {
+    case 0:
+        data, _ := json.Marshal(order)
+        return data[:len(data)/2]
+    case 1:
+        order.OrderUID = ""
+    default:
+        var fields map[string]any
+        data, _ := json.Marshal(order)
+        json.Unmarshal(data, &fields)
+        fields["date_created"] = "вчера"
+        data, _ = json.Marshal(fields)
+        return data
+    }
+    data, _ := json.Marshal(order)
+    return data
+}
+
+func (g *Generator) hex(n int) string {
+    const alphabet = "0123456789abcdef"
+    return g.pick(alphabet, n)
+}
+
+func (g *Generator) letters(n int) string {
+    return g.pick("ABCDEFGHIJKLMNOPQRSTUVWXYZ", n)
+}
+
+func (g *Generator) digits(n int) string {
+    return g.pick("0123456789", n)
+}
+
+func (g *Generator) pick(alphabet string, n int) string {
+    b := make([]byte, n)
+    for i := range b {
+        b[i] = alphabet[g.rng.IntN(len(alphabet))]
+    }
+    return string(b)
+}
@@ -0,0 +1,111 @@
+package generator
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+func TestGenerator_Deterministic(t *testing.T) {
+    a, b := New(Options{Seed: 42}), New(Options{Seed: 42})
+    for i := 0; i < 50; i++ {
+        if x, y := a.Next(), b.Next(); !reflect.DeepEqual(x, y) {
+            t.Fatalf("Заказ %d отличается при одинаковом seed:\n%+v\n%+v", i, x, y)
+        }
+    }
+
+    if reflect.DeepEqual(New(Options{Seed: 1}).Next(), New(Options{Seed: 2}).Next()) {
+        t.Error("Разные seed должны давать разные заказы")
+    }
+}
+
+func TestGenerator_Consistent(t *testing.T) {
+    g := New(Options{Seed: 7})
+    uids := make(map[string]bool)
+    var previous time.Time
+
+    for i := 0; i < 500; i++ {
+        order := g.Next()
+        if uids[order.OrderUID] {
+            t.Fatalf("order_uid %s повторяется", order.OrderUID)
+        }
+        uids[order.OrderUID] = true
+
+        if len(order.Items) == 0 {
+            t.Fatalf("Заказ %s без товаров", order.OrderUID)
+        }
+        var goods models.Amount
+        for _, item := range order.Items {
+            if item.TotalPrice != item.Price*models.Amount(100-item.Sale)/100 {
+                t.Errorf("Товар %s: total_price %d не соответствует цене %d со скидкой %d%%", item.RID, item.TotalPrice, item.Price, item.Sale)
+            }
+            if item.TrackNumber != order.TrackNumber {
+                t.Errorf("Товар %s: track_number %s отличается от заказа %s", item.RID, item.TrackNumber, order.TrackNumber)
+            }
+            goods += item.TotalPrice
+        }
+        p := order.Payment
+        if p.GoodsTotal != goods {
+            t.Errorf("Заказ %s: goods_total %d, сумма товаров %d", order.OrderUID, p.GoodsTotal, goods)
+        }
+        if p.Amount != p.GoodsTotal+p.DeliveryCost+p.CustomFee {
+            t.Errorf("Заказ %s: amount %d не равен goods_total + delivery_cost + custom_fee", order.OrderUID, p.Amount)
+        }
+        if p.Transaction != order.OrderUID {
+            t.Errorf("Заказ %s: transaction %s", order.OrderUID, p.Transaction)
+        }
+
+        created := order.DateCreated
+        if created.IsZero() {
+            t.Fatalf("Заказ %s без даты создания", order.OrderUID)
+        }
+        if created.Before(previous) {
+            t.Errorf("Даты создания должны не убывать: %s после %s", created, previous)
+        }
+        previous = created
+        if p.PaymentDt.Before(created) {
+            t.Errorf("Заказ %s: оплата раньше создания", order.OrderUID)
+        }
+    }
+}
+
+func TestGenerator_Variety(t *testing.T) {
+    g := New(Options{Seed: 3})
+    seen := map[string]map[string]bool{
+        "locale": {}, "currency": {}, "service": {}, "brand": {},
+    }
+    multiItem := false
+    for i := 0; i < 300; i++ {
+        order := g.Next()
+        seen["locale"][order.Locale] = true
+        seen["currency"][order.Payment.Currency] = true
+        seen["service"][order.DeliveryService] = true
+        for _, item := range order.Items {
+            seen["brand"][item.Brand] = true
+        }
+        multiItem = multiItem || len(order.Items) > 1
+    }
+
+    for name, values := range seen {
+        if len(values) < 3 {
+            t.Errorf("Слишком мало разных значений %s: %v", name, values)
+        }
+    }
+    if !multiItem {
+        t.Error("Ожидались заказы с несколькими товарами")
+    }
+}
+
+func TestGenerator_Invalid(t *testing.T) {
+    g := New(Options{Seed: 5})
+    for i := 0; i < 30; i++ {
+        data := g.Invalid()
+        var order models.Order
+        if err := json.Unmarshal(data, &order); err == nil && order.OrderUID != "" {
+            t.Errorf("Сообщение должно быть некорректным: %s", data)
+        }
+    }
+}
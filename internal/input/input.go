@@ -0,0 +1,202 @@
+// Package input читает сообщения с заказами из файлов, каталогов и стандартного ввода
+// в формате JSON или NDJSON.
+package input
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+)
+
+// Stdin - имя входа, обозначающее стандартный ввод.
+const Stdin = "-"
+
+// Format - формат входных данных.
+type Format string
+
+const (
+    // FormatAuto определяет формат по расширению: .ndjson и .jsonl - NDJSON, остальное - JSON.
+    // Стандартный ввод читается как NDJSON.
+    FormatAuto Format = "auto"
+    // FormatJSON - один или несколько JSON-документов подряд; массив раскрывается в отдельные сообщения.
+    FormatJSON Format = "json"
+    // FormatNDJSON - по сообщению в строке. Строки публикуются как есть, даже если это не JSON.
+    FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat проверяет название формата.
+func ParseFormat(name string) (Format, error) {
+    switch f := Format(name); f {
+    case FormatAuto, FormatJSON, FormatNDJSON:
+        return f, nil
+    }
+    return "", fmt.Errorf("неизвестный формат %q, ожидается auto, json или ndjson", name)
+}
+
+// Message - сообщение, прочитанное из входа.
+type Message struct {
+    // Source - файл и номер документа или строки, например orders.ndjson:3.
+    Source string
+    Data   []byte
+}
+
+// inputExtensions - расширения файлов, которые берутся из каталогов.
+var inputExtensions = []string{".json", ".ndjson", ".jsonl"}
+
+// Expand раскрывает аргументы командной строки в список входов: каталоги - в файлы
+// .json, .ndjson и .jsonl внутри них (рекурсивно, по алфавиту), шаблоны - в подходящие файлы.
+// "-" обозначает стандартный ввод.
+func Expand(args []string) ([]string, error) {
+    var inputs []string
+    for _, arg := range args {
+        if arg == Stdin {
+            inputs = append(inputs, Stdin)
+            continue
+        }
+
+        paths := []string{arg}
+        if strings.ContainsAny(arg, "*?[") {
+            matches, err := filepath.Glob(arg)
+            if err != nil {
+                return nil, fmt.Errorf("некорректный шаблон %q: %w", arg, err)
+            }
+            if len(matches) == 0 {
+                return nil, fmt.Errorf("шаблону %q не соответствует ни один файл", arg)
+            }
+            paths = matches
+        }
+
+        for _, path := range paths {
+            info, err := os.Stat(path)
+            if err != nil {
+                return nil, err
+            }
+            if !info.IsDir() {
+                inputs = append(inputs, path)
+                continue
+            }
+            files, err := dirInputs(path)
+            if err != nil {
+                return nil, err
+            }
+            inputs = append(inputs, files...)
+        }
+    }
+    return inputs, nil
+}
+
+func dirInputs(dir string) ([]string, error) {
+    var files []string
+    err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if !d.IsDir() && slices.Contains(inputExtensions, strings.ToLower(filepath.Ext(path))) {
+            files = append(files, path)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("не удалось прочитать каталог %s: %w", dir, err)
+    }
+    return files, nil
+}
+
+// formatOf возвращает формат входа name для формата format.
+func formatOf(name string, format Format) Format {
+    if format != FormatAuto {
+        return format
+    }
+    if name == Stdin {
+        return FormatNDJSON
+    }
+    switch strings.ToLower(filepath.Ext(name)) {
+    case ".ndjson", ".jsonl":
+        return FormatNDJSON
+    }
+    return FormatJSON
+}
+
+// ReadInput открывает вход name (файл или "-") и передаёт его сообщения в fn.
+func ReadInput(name string, stdin io.Reader, format Format, fn func(Message) error) error {
+    r := stdin
+    if name != Stdin {
+        f, err := os.Open(name)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        r = f
+    }
+    return Read(r, name, formatOf(name, format), fn)
+}
+
+// Read разбирает r в формате format и передаёт сообщения в fn. name используется в Message.Source.
+func Read(r io.Reader, name string, format Format, fn func(Message) error) error {
+    if format == FormatNDJSON {
+        return readLines(r, name, fn)
+    }
+    return readDocuments(r, name, fn)
+}
+
+func readLines(r io.Reader, name string, fn func(Message) error) error {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64*1024), 16<<20)
+    line := 0
+    for scanner.Scan() {
+        line++
+        data := bytes.TrimSpace(scanner.Bytes())
+        if len(data) == 0 {
+            continue
+        }
+        msg := Message{Source: fmt.Sprintf("%s:%d", name, line), Data: bytes.Clone(data)}
+        if err := fn(msg); err != nil {
+            return err
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("%s: %w", name, err)
+    }
+    return nil
+}
+
+func readDocuments(r io.Reader, name string, fn func(Message) error) error {
+    dec := json.NewDecoder(r)
+    doc := 0
+    for {
+        var raw json.RawMessage
+        err := dec.Decode(&raw)
+        if errors.Is(err, io.EOF) {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("%s: некорректный JSON: %w", name, err)
+        }
+
+        doc++
+        if !bytes.HasPrefix(raw, []byte("[")) {
+            if err := fn(Message{Source: fmt.Sprintf("%s:%d", name, doc), Data: raw}); err != nil {
+                return err
+            }
+            continue
+        }
+
+        var items []json.RawMessage
+        if err := json.Unmarshal(raw, &items); err != nil {
+            return fmt.Errorf("%s: %w", name, err)
+        }
+        for i, item := range items {
+            if err := fn(Message{Source: fmt.Sprintf("%s:%d[%d]", name, doc, i), Data: item}); err != nil {
+                return err
+            }
+        }
+    }
+}
@@ -0,0 +1,99 @@
+package input
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+    t.Helper()
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestExpand(t *testing.T) {
+    dir := t.TempDir()
+    writeFile(t, filepath.Join(dir, "a.json"), "{}")
+    writeFile(t, filepath.Join(dir, "b.ndjson"), "{}")
+    writeFile(t, filepath.Join(dir, "notes.txt"), "")
+    writeFile(t, filepath.Join(dir, "nested", "c.jsonl"), "{}")
+
+    got, err := Expand([]string{dir, filepath.Join(dir, "*.json"), Stdin})
+    if err != nil {
+        t.Fatalf("Expand: %v", err)
+    }
+    want := []string{
+        filepath.Join(dir, "a.json"),
+        filepath.Join(dir, "b.ndjson"),
+        filepath.Join(dir, "nested", "c.jsonl"),
+        filepath.Join(dir, "a.json"),
+        Stdin,
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("Получили %v, ожидали %v", got, want)
+    }
+
+    if _, err := Expand([]string{filepath.Join(dir, "*.xml")}); err == nil {
+        t.Error("Ожидалась ошибка для шаблона без совпадений")
+    }
+    if _, err := Expand([]string{filepath.Join(dir, "missing.json")}); err == nil {
+        t.Error("Ожидалась ошибка для несуществующего файла")
+    }
+}
+
+func TestRead(t *testing.T) {
+    tests := []struct {
+        name    string
+        format  Format
+        input   string
+        want    []string
+        wantErr bool
+    }{
+        {"Один документ", FormatJSON, "{\n  \"order_uid\": \"a\"\n}", []string{`in:1 {
+  "order_uid": "a"
+}`}, false},
+        {"Массив", FormatJSON, `[{"a":1},{"b":2}]`, []string{`in:1[0] {"a":1}`, `in:1[1] {"b":2}`}, false},
+        {"Несколько документов", FormatJSON, "{\"a\":1} {\"b\":2}", []string{`in:1 {"a":1}`, `in:2 {"b":2}`}, false},
+        {"Некорректный JSON", FormatJSON, `{"a":`, nil, true},
+        {"NDJSON", FormatNDJSON, "{\"a\":1}\n\nnot json\n", []string{`in:1 {"a":1}`, `in:3 not json`}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got []string
+            err := Read(strings.NewReader(tt.input), "in", tt.format, func(msg Message) error {
+                got = append(got, msg.Source+" "+string(msg.Data))
+                return nil
+            })
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Ошибка %v, ожидалась: %v", err, tt.wantErr)
+            }
+            if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("Получили %q, ожидали %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestFormatOf(t *testing.T) {
+    tests := map[string]Format{
+        "orders.json":   FormatJSON,
+        "orders.NDJSON": FormatNDJSON,
+        "orders.jsonl":  FormatNDJSON,
+        Stdin:           FormatNDJSON,
+    }
+    for name, want := range tests {
+        if got := formatOf(name, FormatAuto); got != want {
+            t.Errorf("%s: получили %s, ожидали %s", name, got, want)
+        }
+    }
+    if got := formatOf("orders.jsonl", FormatJSON); got != FormatJSON {
+        t.Errorf("Явный формат должен иметь приоритет, получили %s", got)
+    }
+}
@@ -0,0 +1,216 @@
+// Package loadtest публикует сгенерированные заказы с заданной интенсивностью и измеряет,
+// через сколько каждый заказ становится доступен в HTTP API.
+package loadtest
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math/rand/v2"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "golang.org/x/time/rate"
+    "wb-order-hub/internal/generator"
+    "wb-order-hub/internal/publisher"
+)
+
+// recentOrders - из скольких последних заказов выбираются дубликаты.
+const recentOrders = 100
+
+// Options - параметры нагрузки.
+type Options struct {
+    // Count - сколько сообщений опубликовать, включая некорректные и дубликаты.
+    Count int
+    // Rate - сообщений в секунду; 0 - без ограничения.
+    Rate float64
+    // Concurrency - сколько заказов одновременно может ожидать появления в API; 0 - без ограничения.
+    // Действует только вместе с APIURL.
+    Concurrency int
+    // InvalidFraction и DuplicateFraction - доли некорректных сообщений и повторов уже
+    // опубликованных заказов, от 0 до 1.
+    InvalidFraction   float64
+    DuplicateFraction float64
+    // Seed определяет, какие сообщения будут некорректными или повторами.
+    Seed uint64
+
+    // APIURL - адрес сервиса, например http://localhost:8080. Пустое значение отключает
+    // измерение сквозной задержки.
+    APIURL string
+    // PollInterval - период опроса GET /order/{id}; по умолчанию 20ms.
+    PollInterval time.Duration
+    // Timeout - сколько ждать появления заказа; по умолчанию 30s.
+    Timeout time.Duration
+    Client  *http.Client
+}
+
+// Report - итог нагрузки.
+type Report struct {
+    Valid      int
+    Invalid    int
+    Duplicates int
+    Publish    publisher.Summary
+
+    // Seen - заказы, появившиеся в API, Missing - не появившиеся за Timeout.
+    Seen    int
+    Missing int
+    // Latency - от публикации до первого успешного ответа GET /order/{id}.
+    Latency publisher.LatencyStats
+}
+
+// Run публикует opts.Count сообщений через pub и ждёт их подтверждения и появления в API.
+func Run(ctx context.Context, gen *generator.Generator, pub *publisher.Publisher, opts Options) (Report, error) {
+    if opts.PollInterval <= 0 {
+        opts.PollInterval = 20 * time.Millisecond
+    }
+    if opts.Timeout <= 0 {
+        opts.Timeout = 30 * time.Second
+    }
+    if opts.Client == nil {
+        opts.Client = http.DefaultClient
+    }
+
+    limit := rate.Inf
+    if opts.Rate > 0 {
+        limit = rate.Limit(opts.Rate)
+    }
+    limiter := rate.NewLimiter(limit, 1)
+    rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed+1))
+
+    var sem chan struct{}
+    if opts.Concurrency > 0 && opts.APIURL != "" {
+        sem = make(chan struct{}, opts.Concurrency)
+    }
+
+    var (
+        report  Report
+        recent  [][]byte
+        polls   sync.WaitGroup
+        mu      sync.Mutex
+        latency []time.Duration
+        runErr  error
+    )
+    for i := 1; i <= opts.Count; i++ {
+        if err := limiter.Wait(ctx); err != nil {
+            runErr = err
+            break
+        }
+        source := fmt.Sprintf("generated:%d", i)
+
+        roll := rng.Float64()
+        switch {
+        case roll < opts.InvalidFraction:
+            report.Invalid++
+            pub.Publish(source, gen.Invalid())
+            continue
+        case roll < opts.InvalidFraction+opts.DuplicateFraction && len(recent) > 0:
+            report.Duplicates++
+            pub.Publish(source, recent[rng.IntN(len(recent))])
+            continue
+        }
+
+        order := gen.Next()
+        data, err := json.Marshal(order)
+        if err != nil {
+            pub.Fail(source, err)
+            continue
+        }
+        if len(recent) == recentOrders {
+            recent = recent[1:]
+        }
+        recent = append(recent, data)
+        report.Valid++
+
+        if sem != nil {
+            select {
+            case sem <- struct{}{}:
+            case <-ctx.Done():
+                runErr = ctx.Err()
+            }
+            if runErr != nil {
+                break
+            }
+        }
+        start := time.Now()
+        pub.Publish(source, data)
+        if opts.APIURL == "" {
+            continue
+        }
+
+        polls.Add(1)
+        go func(uid string) {
+            defer polls.Done()
+            if sem != nil {
+                defer func() { <-sem }()
+            }
+            seen := waitForOrder(ctx, opts, uid, start)
+            mu.Lock()
+            defer mu.Unlock()
+            if seen < 0 {
+                report.Missing++
+                return
+            }
+            report.Seen++
+            latency = append(latency, seen)
+        }(order.OrderUID)
+    }
+
+    waitCtx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+    defer cancel()
+    if err := pub.Wait(waitCtx); err != nil && runErr == nil {
+        runErr = err
+    }
+    polls.Wait()
+
+    report.Publish = pub.Summary()
+    report.Latency = publisher.NewLatencyStats(latency)
+    return report, runErr
+}
+
+// waitForOrder опрашивает GET /order/{uid} и возвращает время от start до первого
+// успешного ответа или -1, если заказ не появился за opts.Timeout.
+func waitForOrder(ctx context.Context, opts Options, uid string, start time.Time) time.Duration {
+    ctx, cancel := context.WithDeadline(ctx, start.Add(opts.Timeout))
+    defer cancel()
+
+    target := strings.TrimSuffix(opts.APIURL, "/") + "/order/" + url.PathEscape(uid)
+    ticker := time.NewTicker(opts.PollInterval)
+    defer ticker.Stop()
+    for {
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+        if err != nil {
+            return -1
+        }
+        resp, err := opts.Client.Do(req)
+        if err == nil {
+            io.Copy(io.Discard, resp.Body)
+            resp.Body.Close()
+            if resp.StatusCode == http.StatusOK {
+                return time.Since(start)
+            }
+        }
+
+        // 404 - заказ ещё не обработан; прочие ошибки (например, 429) тоже повторяются до таймаута.
+        select {
+        case <-ctx.Done():
+            return -1
+        case <-ticker.C:
+        }
+    }
+}
+
+// Write выводит итог в w в читаемом виде.
+func (r Report) Write(w io.Writer) {
+    fmt.Fprintf(w, "Сообщений: корректных %d, некорректных %d, повторов %d\n", r.Valid, r.Invalid, r.Duplicates)
+    r.Publish.Write(w)
+    if r.Seen+r.Missing > 0 {
+        fmt.Fprintf(w, "Появились в API: %d, не дождались: %d\n", r.Seen, r.Missing)
+    }
+    if r.Seen > 0 {
+        fmt.Fprintf(w, "Сквозная задержка: %s\n", r.Latency)
+    }
+}
@@ -0,0 +1,116 @@
+package loadtest
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/generator"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/publisher"
+)
+
+// fakeService сохраняет опубликованные заказы с задержкой и отдаёт их по GET /order/{id}.
+type fakeService struct {
+    mu       sync.Mutex
+    orders   map[string]bool
+    messages int
+    inflight int
+    maxWait  int
+}
+
+func (s *fakeService) PublishAsync(_ string, data []byte, ah stan.AckHandler) (string, error) {
+    s.mu.Lock()
+    s.messages++
+    s.inflight++
+    s.maxWait = max(s.maxWait, s.inflight)
+    s.mu.Unlock()
+
+    go func() {
+        ah("", nil)
+        var order models.Order
+        if json.Unmarshal(data, &order) != nil || order.OrderUID == "" {
+            return
+        }
+        time.Sleep(5 * time.Millisecond)
+        s.mu.Lock()
+        s.orders[order.OrderUID] = true
+        s.mu.Unlock()
+    }()
+    return "", nil
+}
+
+func (s *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    uid := strings.TrimPrefix(r.URL.Path, "/order/")
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if !s.orders[uid] {
+        http.NotFound(w, r)
+        return
+    }
+    s.inflight--
+    w.WriteHeader(http.StatusOK)
+}
+
+func TestRun(t *testing.T) {
+    service := &fakeService{orders: make(map[string]bool)}
+    server := httptest.NewServer(service)
+    defer server.Close()
+
+    report, err := Run(context.Background(), generator.New(generator.Options{Seed: 1}), publisher.New(service, "orders"), Options{
+        Count:             200,
+        Concurrency:       10,
+        InvalidFraction:   0.1,
+        DuplicateFraction: 0.1,
+        Seed:              1,
+        APIURL:            server.URL,
+        PollInterval:      time.Millisecond,
+        Timeout:           5 * time.Second,
+    })
+    if err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+
+    if report.Valid+report.Invalid+report.Duplicates != 200 || service.messages != 200 {
+        t.Errorf("Ожидалось 200 сообщений, получили %+v (опубликовано %d)", report, service.messages)
+    }
+    if report.Invalid < 5 || report.Duplicates < 5 {
+        t.Errorf("Ожидались примерно 10%% некорректных и повторов, получили %d и %d", report.Invalid, report.Duplicates)
+    }
+    if report.Publish.Published != 200 || report.Publish.Failed != 0 {
+        t.Errorf("Неожиданный итог публикации: %+v", report.Publish)
+    }
+    if report.Seen != report.Valid || report.Missing != 0 {
+        t.Errorf("Все корректные заказы должны появиться в API: %+v", report)
+    }
+    if report.Latency.Min < 5*time.Millisecond {
+        t.Errorf("Задержка не может быть меньше времени обработки: %s", report.Latency.Min)
+    }
+    if service.maxWait > 10+report.Invalid+report.Duplicates {
+        t.Errorf("Одновременно ожидали %d заказов при ограничении 10", service.maxWait)
+    }
+}
+
+func TestRun_Rate(t *testing.T) {
+    service := &fakeService{orders: make(map[string]bool)}
+    start := time.Now()
+    report, err := Run(context.Background(), generator.New(generator.Options{}), publisher.New(service, "orders"), Options{
+        Count: 6,
+        Rate:  50,
+    })
+    if err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+        t.Errorf("6 сообщений при 50 в секунду опубликованы за %s", elapsed)
+    }
+    if report.Seen != 0 || report.Valid != 6 {
+        t.Errorf("Без APIURL задержка не измеряется: %+v", report)
+    }
+}
@@ -0,0 +1,50 @@
+// Package logging настраивает журнал сервиса. Сообщения стандартного пакета log
+// пишутся через slog с уровнем INFO, поэтому существующие вызовы log.Printf
+// подчиняются общему уровню.
+package logging
+
+import (
+    "fmt"
+    "log/slog"
+    "os"
+
+    "wb-order-hub/internal/config"
+)
+
+// level - текущий уровень журнала, меняется без пересоздания обработчика.
+var level = new(slog.LevelVar)
+
+// Setup устанавливает журнал по умолчанию в формате cfg.Format с уровнем cfg.Level.
+func Setup(cfg config.LogConfig) error {
+    if err := SetLevel(cfg.Level); err != nil {
+        return err
+    }
+
+    opts := &slog.HandlerOptions{Level: level}
+    var handler slog.Handler
+    switch cfg.Format {
+    case "json":
+        handler = slog.NewJSONHandler(os.Stderr, opts)
+    case "text", "":
+        handler = slog.NewTextHandler(os.Stderr, opts)
+    default:
+        return fmt.Errorf("неизвестный формат логов %q", cfg.Format)
+    }
+    slog.SetDefault(slog.New(handler))
+    return nil
+}
+
+// SetLevel меняет уровень журнала: debug, info, warn или error.
+func SetLevel(name string) error {
+    var l slog.Level
+    if err := l.UnmarshalText([]byte(name)); err != nil {
+        return fmt.Errorf("неизвестный уровень логов %q", name)
+    }
+    level.Set(l)
+    return nil
+}
+
+// Level возвращает текущий уровень журнала.
+func Level() slog.Level {
+    return level.Level()
+}
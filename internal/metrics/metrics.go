@@ -0,0 +1,45 @@
+// Package metrics содержит счётчики сервиса, публикуемые через expvar (/debug/vars).
+package metrics
+
+import (
+    "expvar"
+    "time"
+)
+
+var (
+    MessagesReceived = expvar.NewInt("messages_received")
+    MessagesAcked    = expvar.NewInt("messages_acked")
+    MessagesInvalid  = expvar.NewInt("messages_invalid")
+    MessagesFailed   = expvar.NewInt("messages_failed")
+    // MessagesRejected - сообщения, пришедшие во время остановки и оставленные для повторной доставки.
+    MessagesRejected = expvar.NewInt("messages_rejected_on_shutdown")
+    // MessagesAbandoned - сообщения, обработка которых была прервана по истечении срока остановки.
+    MessagesAbandoned = expvar.NewInt("messages_abandoned_on_shutdown")
+    MessagesInFlight  = expvar.NewInt("messages_in_flight")
+
+    // EventsApplied - применённые события заказа, включая применённые после ожидания предшественника.
+    EventsApplied = expvar.NewInt("events_applied")
+    // EventsParked - события, отложенные до прихода предшественника.
+    EventsParked = expvar.NewInt("events_parked")
+    // EventsDuplicate - повторно доставленные события, уже применённые к заказу.
+    EventsDuplicate = expvar.NewInt("events_duplicate")
+    // OrdersSuperseded - повторно доставленные полные заказы, к которым уже применены события.
+    OrdersSuperseded = expvar.NewInt("orders_superseded")
+
+    // DocumentsMismatched - документы заказов, разошедшиеся с таблицами, по данным фоновой сверки.
+    DocumentsMismatched = expvar.NewInt("documents_mismatched")
+    // DocumentsRepaired - документы, перестроенные фоновой сверкой, включая построенные впервые.
+    DocumentsRepaired = expvar.NewInt("documents_repaired")
+
+    // ShutdownPhase - текущий этап остановки сервиса, пустая строка до её начала.
+    ShutdownPhase = expvar.NewString("shutdown_phase")
+    // ShutdownPhaseDuration - длительность каждого этапа остановки в миллисекундах.
+    ShutdownPhaseDuration = expvar.NewMap("shutdown_phase_duration_ms")
+)
+
+// ObserveShutdownPhase записывает длительность завершённого этапа остановки.
+func ObserveShutdownPhase(phase string, d time.Duration) {
+    ms := new(expvar.Int)
+    ms.Set(d.Milliseconds())
+    ShutdownPhaseDuration.Set(phase, ms)
+}
@@ -0,0 +1,394 @@
+package models
+
+import (
+    "bytes"
+    "encoding/json"
+    "reflect"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Extra - поля сообщения, которых нет в модели: имя поля и его значение в JSON.
+// Производители добавляют поля раньше, чем они появляются в модели; такие поля не теряются:
+// они сохраняются вместе с заказом и возвращаются при сериализации в JSON.
+// Значения хранятся в каноническом виде (без пробелов, ключи объектов по алфавиту),
+// чтобы заказ из базы совпадал с исходным.
+type Extra map[string]json.RawMessage
+
+// fieldSet - имена JSON-полей структуры в нижнем регистре. Для вложенной структуры
+// или списка структур значение - поля этой структуры, для остальных полей - nil.
+type fieldSet map[string]fieldSet
+
+var (
+    orderFields    = jsonFields(reflect.TypeFor[Order]())
+    deliveryFields = orderFields["delivery"]
+    paymentFields  = orderFields["payment"]
+    itemFields     = orderFields["items"]
+)
+
+// UnmarshalJSON разбирает заказ вместе с неизвестными полями заказа, доставки, оплаты и товаров.
+// Разбор заказа - горячий путь приёма сообщений, поэтому неизвестные поля ищутся одним проходом
+// по сообщению, а разбираются, только если нашлись.
+func (o *Order) UnmarshalJSON(data []byte) error {
+    var v orderJSON
+    if err := json.Unmarshal(data, &v); err != nil {
+        return err
+    }
+    created, err := parseDateCreated(v.DateCreated)
+    if err != nil {
+        return err
+    }
+    *o = Order(v.plainOrder)
+    o.DateCreated = created
+    if !hasUnknown(data, orderFields) {
+        return nil
+    }
+
+    fields, err := objectFields(data)
+    if err != nil {
+        return err
+    }
+    o.Extra = unknownFields(fields, orderFields)
+    if raw := field(fields, "delivery"); raw != nil {
+        if o.Delivery.Extra, err = extraOf(raw, deliveryFields); err != nil {
+            return err
+        }
+    }
+    if raw := field(fields, "payment"); raw != nil {
+        if o.Payment.Extra, err = extraOf(raw, paymentFields); err != nil {
+            return err
+        }
+    }
+    if raw := field(fields, "items"); raw != nil {
+        var items []json.RawMessage
+        if err := json.Unmarshal(raw, &items); err != nil {
+            return err
+        }
+        for i := range min(len(items), len(o.Items)) {
+            if o.Items[i].Extra, err = extraOf(items[i], itemFields); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// ParsePayment разбирает оплату вместе с неизвестными полями.
+func ParsePayment(data []byte) (Payment, error) {
+    var p Payment
+    if err := json.Unmarshal(data, &p); err != nil {
+        return Payment{}, err
+    }
+    extra, err := extraOf(data, paymentFields)
+    p.Extra = extra
+    return p, err
+}
+
+// MarshalJSON дописывает неизвестные поля в заказ, доставку, оплату и товары.
+// Без них заказ кодируется как обычная структура.
+func (o Order) MarshalJSON() ([]byte, error) {
+    plain := orderJSON{plainOrder(o), FormatTime(o.DateCreated)}
+    if !o.hasExtra() {
+        return json.Marshal(plain)
+    }
+
+    delivery, err := marshalWithExtra(o.Delivery, o.Delivery.Extra, deliveryFields)
+    if err != nil {
+        return nil, err
+    }
+    payment, err := marshalWithExtra(o.Payment, o.Payment.Extra, paymentFields)
+    if err != nil {
+        return nil, err
+    }
+    var items []json.RawMessage
+    if o.Items != nil {
+        items = make([]json.RawMessage, len(o.Items))
+    }
+    for i, item := range o.Items {
+        if items[i], err = marshalWithExtra(item, item.Extra, itemFields); err != nil {
+            return nil, err
+        }
+    }
+    // Поля верхнего уровня затеняют одноимённые поля заказа.
+    return marshalWithExtra(struct {
+        orderJSON
+        Delivery json.RawMessage   `json:"delivery"`
+        Payment  json.RawMessage   `json:"payment"`
+        Items    []json.RawMessage `json:"items"`
+    }{plain, delivery, payment, items}, o.Extra, orderFields)
+}
+
+// orderJSON - заказ в сообщении: пустая date_created означает, что дата не указана.
+type orderJSON struct {
+    plainOrder
+    DateCreated string `json:"date_created"`
+}
+
+type plainOrder Order
+
+func (o Order) hasExtra() bool {
+    if len(o.Extra) > 0 || len(o.Delivery.Extra) > 0 || len(o.Payment.Extra) > 0 {
+        return true
+    }
+    for _, item := range o.Items {
+        if len(item.Extra) > 0 {
+            return true
+        }
+    }
+    return false
+}
+
+// marshalWithExtra кодирует v и дописывает в объект поля extra по алфавиту.
+// Поля extra, совпадающие с полями модели, пропускаются.
+func marshalWithExtra(v any, extra Extra, known fieldSet) ([]byte, error) {
+    data, err := json.Marshal(v)
+    if err != nil || len(extra) == 0 {
+        return data, err
+    }
+    names := make([]string, 0, len(extra))
+    for name := range extra {
+        if _, ok := known[strings.ToLower(name)]; !ok {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+
+    buf := bytes.NewBuffer(data[:len(data)-1])
+    for _, name := range names {
+        key, _ := json.Marshal(name)
+        buf.WriteByte(',')
+        buf.Write(key)
+        buf.WriteByte(':')
+        buf.Write(extra[name])
+    }
+    buf.WriteByte('}')
+    return buf.Bytes(), nil
+}
+
+// ParseExtra разбирает объект с неизвестными полями, например колонку extra. Пустой объект и null - nil.
+func ParseExtra(data []byte) (Extra, error) {
+    if len(data) == 0 {
+        return nil, nil
+    }
+    fields, err := objectFields(data)
+    if err != nil {
+        return nil, err
+    }
+    return unknownFields(fields, nil), nil
+}
+
+// extraOf возвращает поля объекта data, которых нет в known.
+func extraOf(data []byte, known fieldSet) (Extra, error) {
+    fields, err := objectFields(data)
+    if err != nil {
+        return nil, err
+    }
+    return unknownFields(fields, known), nil
+}
+
+func objectFields(data []byte) (map[string]json.RawMessage, error) {
+    var fields map[string]json.RawMessage
+    err := json.Unmarshal(data, &fields)
+    return fields, err
+}
+
+// unknownFields отбирает поля не из known. Как и encoding/json, имена сравниваются без учёта регистра.
+func unknownFields(fields map[string]json.RawMessage, known fieldSet) Extra {
+    var extra Extra
+    for name, value := range fields {
+        if _, ok := known[strings.ToLower(name)]; ok {
+            continue
+        }
+        if extra == nil {
+            extra = make(Extra)
+        }
+        extra[name] = canonical(value)
+    }
+    return extra
+}
+
+// field возвращает поле объекта по имени без учёта регистра.
+func field(fields map[string]json.RawMessage, name string) json.RawMessage {
+    for key, value := range fields {
+        if strings.EqualFold(key, name) && string(value) != "null" {
+            return value
+        }
+    }
+    return nil
+}
+
+// canonical приводит JSON к каноническому виду. Числа сохраняются как есть, без перевода в float64.
+func canonical(value json.RawMessage) json.RawMessage {
+    dec := json.NewDecoder(bytes.NewReader(value))
+    dec.UseNumber()
+    var v any
+    if dec.Decode(&v) != nil {
+        return value
+    }
+    data, err := json.Marshal(v)
+    if err != nil {
+        return value
+    }
+    return data
+}
+
+// jsonFields возвращает поля структуры t, включая поля вложенных структур и списков структур.
+func jsonFields(t reflect.Type) fieldSet {
+    fields := make(fieldSet, t.NumField())
+    for i := range t.NumField() {
+        name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+        if name == "" || name == "-" {
+            continue
+        }
+        var nested fieldSet
+        switch ft := t.Field(i).Type; {
+        case ft == reflect.TypeFor[time.Time]():
+        case ft.Kind() == reflect.Struct:
+            nested = jsonFields(ft)
+        case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+            nested = jsonFields(ft.Elem())
+        }
+        fields[strings.ToLower(name)] = nested
+    }
+    return fields
+}
+
+// hasUnknown проверяет, есть ли в корректном JSON data поля не из known, в том числе во вложенных
+// объектах. Имена с escape-последовательностями и заглавными буквами считаются неизвестными:
+// их проверит полный разбор.
+func hasUnknown(data []byte, known fieldSet) bool {
+    s := scanner{data: data}
+    return s.value(known)
+}
+
+// scanner проходит по JSON, уже проверенному encoding/json, не разбирая значения.
+type scanner struct {
+    data []byte
+    pos  int
+}
+
+// value пропускает значение; объекты и списки объектов с известными полями проверяет по known.
+func (s *scanner) value(known fieldSet) bool {
+    s.space()
+    if s.pos >= len(s.data) {
+        return false
+    }
+    switch s.data[s.pos] {
+    case '{':
+        if known != nil {
+            return s.object(known)
+        }
+    case '[':
+        if known != nil {
+            return s.array(known)
+        }
+    }
+    s.skip()
+    return false
+}
+
+func (s *scanner) object(known fieldSet) bool {
+    s.pos++ // {
+    for {
+        s.space()
+        if s.data[s.pos] == '}' {
+            s.pos++
+            return false
+        }
+        key, escaped := s.string()
+        nested, ok := known[string(key)]
+        if escaped || !ok {
+            return true
+        }
+        s.space()
+        s.pos++ // :
+        if s.value(nested) {
+            return true
+        }
+        s.space()
+        if s.data[s.pos] == ',' {
+            s.pos++
+        }
+    }
+}
+
+func (s *scanner) array(known fieldSet) bool {
+    s.pos++ // [
+    for {
+        s.space()
+        if s.data[s.pos] == ']' {
+            s.pos++
+            return false
+        }
+        if s.value(known) {
+            return true
+        }
+        s.space()
+        if s.data[s.pos] == ',' {
+            s.pos++
+        }
+    }
+}
+
+// string читает строку и возвращает её содержимое без кавычек.
+func (s *scanner) string() ([]byte, bool) {
+    start := s.pos + 1
+    end := start
+    for {
+        end += bytes.IndexByte(s.data[end:], '"')
+        // Кавычка экранирована, если перед ней нечётное число обратных слешей.
+        slashes := 0
+        for end-slashes > start && s.data[end-slashes-1] == '\\' {
+            slashes++
+        }
+        if slashes%2 == 0 {
+            break
+        }
+        end++
+    }
+    s.pos = end + 1
+    value := s.data[start:end]
+    return value, bytes.IndexByte(value, '\\') >= 0
+}
+
+// skip пропускает значение любого типа.
+func (s *scanner) skip() {
+    depth := 0
+    for s.pos < len(s.data) {
+        switch s.data[s.pos] {
+        case '"':
+            s.string()
+        case '{', '[':
+            depth++
+            s.pos++
+        case '}', ']':
+            if depth == 0 {
+                return
+            }
+            depth--
+            s.pos++
+        case ',':
+            if depth == 0 {
+                return
+            }
+            s.pos++
+        default:
+            s.pos++
+        }
+        if depth == 0 && s.pos > 0 && (s.data[s.pos-1] == '"' || s.data[s.pos-1] == '}' || s.data[s.pos-1] == ']') {
+            return
+        }
+    }
+}
+
+func (s *scanner) space() {
+    for s.pos < len(s.data) {
+        switch s.data[s.pos] {
+        case ' ', '\t', '\n', '\r':
+            s.pos++
+        default:
+            return
+        }
+    }
+}
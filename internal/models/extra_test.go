@@ -0,0 +1,126 @@
+package models
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+const orderWithExtra = `{
+    "order_uid": "x", "Track_Number": "T", "loyalty": {"tier": "gold", "points": 12345678901234567890},
+    "delivery": {"city": "Moscow", "floor": 5},
+    "payment": {"amount": 10, "installments": [1, 2]},
+    "items": [{"rid": "a"}, {"rid": "b", "color": "red"}],
+    "date_created": "2021-11-26T06:22:19Z"
+}`
+
+func TestOrderExtra(t *testing.T) {
+    order, err := ParseOrder([]byte(orderWithExtra))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := Order{
+        OrderUID:    "x",
+        TrackNumber: "T",
+        DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+        // Числа не теряют точность, ключи объектов по алфавиту.
+        Extra:    Extra{"loyalty": json.RawMessage(`{"points":12345678901234567890,"tier":"gold"}`)},
+        Delivery: Delivery{City: "Moscow", Extra: Extra{"floor": json.RawMessage(`5`)}},
+        Payment:  Payment{Amount: 10, Extra: Extra{"installments": json.RawMessage(`[1,2]`)}},
+        Items:    []Item{{RID: "a"}, {RID: "b", Extra: Extra{"color": json.RawMessage(`"red"`)}}},
+    }
+    if !reflect.DeepEqual(order, want) {
+        t.Fatalf("Получили\n%+v\nожидали\n%+v", order, want)
+    }
+
+    data, err := json.Marshal(order)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, field := range []string{`"loyalty":{`, `"floor":5`, `"installments":[1,2]`, `"color":"red"`} {
+        if !strings.Contains(string(data), field) {
+            t.Errorf("В JSON нет %s: %s", field, data)
+        }
+    }
+    again, err := ParseOrder(data)
+    if err != nil || !reflect.DeepEqual(again, order) {
+        t.Errorf("Заказ изменился при повторном разборе: %+v, %v", again, err)
+    }
+}
+
+func TestOrderExtra_None(t *testing.T) {
+    // Регистр и escape-последовательности в именах, кавычки и скобки в значениях.
+    order, err := ParseOrder([]byte(`{"order_uid": "x", "ORDER_UID": "y", "delivery": {"Name": "n \\\" } ] \\\\"}, "items": [{"r\u0069d": "a"}]}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if order.Extra != nil || order.Delivery.Extra != nil || order.Items[0].Extra != nil {
+        t.Errorf("Известные поля попали в Extra: %+v", order)
+    }
+
+    // Поле Extra с именем поля модели не должно дублировать его в JSON.
+    order.Extra = Extra{"order_uid": json.RawMessage(`"z"`)}
+    data, _ := json.Marshal(order)
+    if strings.Count(string(data), "order_uid") != 1 {
+        t.Errorf("Поле продублировано: %s", data)
+    }
+}
+
+func TestParsePayment(t *testing.T) {
+    p, err := ParsePayment([]byte(`{"amount": 5, "cashback": {"rate": 0.05}}`))
+    if err != nil || p.Amount != 5 || string(p.Extra["cashback"]) != `{"rate":0.05}` {
+        t.Errorf("ParsePayment: %+v, %v", p, err)
+    }
+}
+
+// FuzzHasUnknown сверяет быстрый поиск неизвестных полей с полным разбором через objectFields
+// и unknownFields: если полный разбор находит неизвестное поле, hasUnknown не должен его пропустить.
+func FuzzHasUnknown(f *testing.F) {
+    for _, seed := range []string{
+        orderWithExtra,
+        `{"order_uid": "x", "ORDER_UID": "y", "delivery": {"Name": "n \\\" } ] \\\\"}, "items": [{"rid": "a"}]}`,
+        `{"items": [{"rid": "a"}, {"rid": "b", "color": [{"x": 1}]}], "payment": null}`,
+        `{"delivery": {"city": "{\"floor\": 5}"}, "items": [], "sm_id": -15, "rate": 1.5e3}`,
+        `null`,
+    } {
+        f.Add([]byte(seed))
+    }
+    f.Fuzz(func(t *testing.T, data []byte) {
+        type plain Order
+        // hasUnknown вызывается только для сообщений, которые уже разобрал encoding/json.
+        if json.Unmarshal(data, new(plain)) != nil {
+            t.Skip()
+        }
+        if unknownIn(data, orderFields) && !hasUnknown(data, orderFields) {
+            t.Errorf("hasUnknown пропустил неизвестное поле: %s", data)
+        }
+    })
+}
+
+// unknownIn - полный разбор: есть ли в объекте или списке объектов data поля не из known.
+func unknownIn(data json.RawMessage, known fieldSet) bool {
+    var items []json.RawMessage
+    if json.Unmarshal(data, &items) == nil {
+        for _, item := range items {
+            if unknownIn(item, known) {
+                return true
+            }
+        }
+        return false
+    }
+    fields, err := objectFields(data)
+    if err != nil {
+        return false
+    }
+    if len(unknownFields(fields, known)) > 0 {
+        return true
+    }
+    for name, value := range fields {
+        if nested := known[strings.ToLower(name)]; nested != nil && unknownIn(value, nested) {
+            return true
+        }
+    }
+    return false
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Order - основная структура заказа
 type Order struct {
     OrderUID          string    `json:"order_uid"`
@@ -14,8 +16,11 @@ type Order struct {
     DeliveryService   string    `json:"delivery_service"`
     Shardkey          string    `json:"shardkey"`
     SmID              int       `json:"sm_id"`
-    DateCreated       string    `json:"date_created"`
+    DateCreated       time.Time `json:"date_created"`
     OofShard          string    `json:"oof_shard"`
+
+    // Extra - поля сообщения, которых нет в модели (см. extra.go).
+    Extra Extra `json:"-"`
 }
 
 // Delivery - информация о доставке
@@ -27,33 +32,39 @@ type Delivery struct {
     Address string `json:"address"`
     Region  string `json:"region"`
     Email   string `json:"email"`
+
+    Extra Extra `json:"-"`
 }
 
 // Payment - информация об оплате
 type Payment struct {
-    Transaction  string `json:"transaction"`
-    RequestID    string `json:"request_id"`
-    Currency     string `json:"currency"`
-    Provider     string `json:"provider"`
-    Amount       int    `json:"amount"`
-    PaymentDt    int64  `json:"payment_dt"`
-    Bank         string `json:"bank"`
-    DeliveryCost int    `json:"delivery_cost"`
-    GoodsTotal   int    `json:"goods_total"`
-    CustomFee    int    `json:"custom_fee"`
+    Transaction  string    `json:"transaction"`
+    RequestID    string    `json:"request_id"`
+    Currency     string    `json:"currency"`
+    Provider     string    `json:"provider"`
+    Amount       Amount    `json:"amount"`
+    PaymentDt    time.Time `json:"payment_dt"`
+    Bank         string    `json:"bank"`
+    DeliveryCost Amount    `json:"delivery_cost"`
+    GoodsTotal   Amount    `json:"goods_total"`
+    CustomFee    Amount    `json:"custom_fee"`
+
+    Extra Extra `json:"-"`
 }
 
 // Item - информация о товаре в заказе
 type Item struct {
-    ChrtID      int    `json:"chrt_id"`
-    TrackNumber string `json:"track_number"`
-    Price       int    `json:"price"`
-    RID         string `json:"rid"`
-    Name        string `json:"name"`
-    Sale        int    `json:"sale"`
-    Size        string `json:"size"`
-    TotalPrice  int    `json:"total_price"`
-    NmID        int    `json:"nm_id"`
-    Brand       string `json:"brand"`
-    Status      int    `json:"status"`
+    ChrtID      int        `json:"chrt_id"`
+    TrackNumber string     `json:"track_number"`
+    Price       Amount     `json:"price"`
+    RID         string     `json:"rid"`
+    Name        string     `json:"name"`
+    Sale        int        `json:"sale"`
+    Size        string     `json:"size"`
+    TotalPrice  Amount     `json:"total_price"`
+    NmID        int        `json:"nm_id"`
+    Brand       string     `json:"brand"`
+    Status      ItemStatus `json:"status"`
+
+    Extra Extra `json:"-"`
 }
\ No newline at end of file
@@ -0,0 +1,82 @@
+package models
+
+import (
+    "strconv"
+    "strings"
+)
+
+// Amount - сумма в минимальных единицах валюты: копейках, центах, тиынах. Валюта всех сумм
+// заказа - Payment.Currency. В сообщении суммы передаются целыми числами в тех же единицах.
+type Amount int64
+
+// Money - сумма в валюте.
+type Money struct {
+    Amount Amount
+    // Currency - буквенный код ISO 4217.
+    Currency string
+}
+
+// currencyExponents - валюты ISO 4217, у которых число знаков после запятой отличается от двух.
+var currencyExponents = map[string]int{
+    "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+    "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+    "BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+    "CLF": 4, "UYW": 4,
+}
+
+// CurrencyExponent возвращает число знаков после запятой в валюте по ISO 4217:
+// сколько десятичных разрядов занимают минимальные единицы. У большинства валют - два.
+func CurrencyExponent(currency string) int {
+    if exp, ok := currencyExponents[currency]; ok {
+        return exp
+    }
+    return 2
+}
+
+// ValidCurrency проверяет, что код валюты похож на код ISO 4217: три заглавные латинские буквы.
+func ValidCurrency(currency string) bool {
+    if len(currency) != 3 {
+        return false
+    }
+    for _, c := range currency {
+        if c < 'A' || c > 'Z' {
+            return false
+        }
+    }
+    return true
+}
+
+// Money возвращает сумму оплаты в её валюте.
+func (p Payment) Money(a Amount) Money {
+    return Money{Amount: a, Currency: p.Currency}
+}
+
+// Exponent - число знаков после запятой в валюте суммы.
+func (m Money) Exponent() int {
+    return CurrencyExponent(m.Currency)
+}
+
+// Decimal возвращает сумму в основных единицах валюты: 1817 центов - "18.17", 1817 иен - "1817".
+func (m Money) Decimal() string {
+    exp := m.Exponent()
+    digits := strconv.FormatInt(int64(m.Amount), 10)
+    sign := ""
+    if m.Amount < 0 {
+        sign, digits = "-", digits[1:]
+    }
+    if exp == 0 {
+        return sign + digits
+    }
+    if len(digits) <= exp {
+        digits = strings.Repeat("0", exp-len(digits)+1) + digits
+    }
+    return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String возвращает сумму с кодом валюты: "18.17 USD".
+func (m Money) String() string {
+    if m.Currency == "" {
+        return m.Decimal()
+    }
+    return m.Decimal() + " " + m.Currency
+}
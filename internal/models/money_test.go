@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestMoney(t *testing.T) {
+    tests := []struct {
+        money   Money
+        decimal string
+        text    string
+    }{
+        {Money{1817, "USD"}, "18.17", "18.17 USD"},
+        {Money{5, "RUB"}, "0.05", "0.05 RUB"},
+        {Money{-120, "KZT"}, "-1.20", "-1.20 KZT"},
+        {Money{1817, "JPY"}, "1817", "1817 JPY"},
+        {Money{1500, "KWD"}, "1.500", "1.500 KWD"},
+        {Money{7, "KWD"}, "0.007", "0.007 KWD"},
+        {Money{0, ""}, "0.00", "0.00"},
+    }
+    for _, tt := range tests {
+        if got := tt.money.Decimal(); got != tt.decimal {
+            t.Errorf("%d %s: Decimal() = %q, ожидалось %q", tt.money.Amount, tt.money.Currency, got, tt.decimal)
+        }
+        if got := tt.money.String(); got != tt.text {
+            t.Errorf("%d %s: String() = %q, ожидалось %q", tt.money.Amount, tt.money.Currency, got, tt.text)
+        }
+    }
+
+    for currency, valid := range map[string]bool{"USD": true, "RUB": true, "usd": false, "RU": false, "RUB1": false, "": false} {
+        if ValidCurrency(currency) != valid {
+            t.Errorf("ValidCurrency(%q) = %v", currency, !valid)
+        }
+    }
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "time"
+)
+
+// ItemStatus - статус товара. В сообщениях и в базе передаётся числовым кодом.
+type ItemStatus int
+
+const (
+    ItemAccepted  ItemStatus = 200
+    ItemAssembled ItemStatus = 201
+    ItemInTransit ItemStatus = 202
+    ItemDelivered ItemStatus = 203
+    ItemCancelled ItemStatus = 300
+    ItemReturned  ItemStatus = 301
+)
+
+type itemStatusInfo struct {
+    name  string
+    label string
+    next  []ItemStatus
+}
+
+// itemStatuses - каталог статусов товара и допустимые переходы.
+var itemStatuses = map[ItemStatus]itemStatusInfo{
+    ItemAccepted:  {"accepted", "Принят", []ItemStatus{ItemAssembled, ItemCancelled}},
+    ItemAssembled: {"assembled", "Собран", []ItemStatus{ItemInTransit, ItemCancelled}},
+    ItemInTransit: {"in_transit", "В пути", []ItemStatus{ItemDelivered, ItemReturned}},
+    ItemDelivered: {"delivered", "Доставлен", []ItemStatus{ItemReturned}},
+    ItemCancelled: {"cancelled", "Отменён", nil},
+    ItemReturned:  {"returned", "Возвращён", nil},
+}
+
+// ItemStatuses возвращает все известные статусы товара в порядке кодов.
+func ItemStatuses() []ItemStatus {
+    return []ItemStatus{ItemAccepted, ItemAssembled, ItemInTransit, ItemDelivered, ItemCancelled, ItemReturned}
+}
+
+// ParseItemStatus принимает имя статуса (in_transit) или его код (202).
+func ParseItemStatus(s string) (ItemStatus, error) {
+    if code, err := strconv.Atoi(s); err == nil {
+        if status := ItemStatus(code); status.Known() {
+            return status, nil
+        }
+    }
+    for status, info := range itemStatuses {
+        if info.name == s {
+            return status, nil
+        }
+    }
+    return 0, fmt.Errorf("неизвестный статус товара %q", s)
+}
+
+// Known сообщает, есть ли статус в каталоге. Производители могут присылать и другие коды:
+// они сохраняются как есть, но перевести товар из такого статуса через API нельзя.
+func (s ItemStatus) Known() bool {
+    _, ok := itemStatuses[s]
+    return ok
+}
+
+// Name - машинное имя статуса, для неизвестного - его код.
+func (s ItemStatus) Name() string {
+    if info, ok := itemStatuses[s]; ok {
+        return info.name
+    }
+    return strconv.Itoa(int(s))
+}
+
+// Label - название статуса для людей.
+func (s ItemStatus) Label() string {
+    if info, ok := itemStatuses[s]; ok {
+        return info.label
+    }
+    return "Неизвестный статус " + strconv.Itoa(int(s))
+}
+
+// Terminal сообщает, что из статуса нет переходов.
+func (s ItemStatus) Terminal() bool {
+    info, ok := itemStatuses[s]
+    return ok && len(info.next) == 0
+}
+
+// CanTransition сообщает, можно ли перевести товар из статуса s в to.
+func (s ItemStatus) CanTransition(to ItemStatus) bool {
+    for _, next := range itemStatuses[s].next {
+        if next == to {
+            return true
+        }
+    }
+    return false
+}
+
+// OrderStatus - статус заказа. Хранится не отдельно, а выводится из статусов товаров.
+type OrderStatus string
+
+const (
+    OrderAccepted   OrderStatus = "accepted"
+    OrderAssembling OrderStatus = "assembling"
+    OrderInTransit  OrderStatus = "in_transit"
+    OrderDelivered  OrderStatus = "delivered"
+    OrderReturned   OrderStatus = "returned"
+    OrderCancelled  OrderStatus = "cancelled"
+)
+
+var orderStatusLabels = map[OrderStatus]string{
+    OrderAccepted:   "Принят",
+    OrderAssembling: "Собирается",
+    OrderInTransit:  "В пути",
+    OrderDelivered:  "Доставлен",
+    OrderReturned:   "Возвращён",
+    OrderCancelled:  "Отменён",
+}
+
+// Label - название статуса для людей.
+func (s OrderStatus) Label() string {
+    if label, ok := orderStatusLabels[s]; ok {
+        return label
+    }
+    return string(s)
+}
+
+// Status выводит статус заказа из статусов товаров. Отменённые товары не учитываются,
+// пока в заказе есть другие; заказ движется вперёд по самому дальнему товару, а доставленным
+// считается, когда все оставшиеся товары доставлены или возвращены.
+func (o Order) Status() OrderStatus {
+    var active, assembled, moving, done, delivered int
+    for _, item := range o.Items {
+        switch item.Status {
+        case ItemCancelled:
+            continue
+        case ItemAssembled:
+            assembled++
+        case ItemInTransit:
+            moving++
+        case ItemDelivered:
+            delivered++
+            done++
+        case ItemReturned:
+            done++
+        }
+        active++
+    }
+    switch {
+    case active == 0 && len(o.Items) > 0:
+        return OrderCancelled
+    case active > 0 && done == active && delivered == 0:
+        return OrderReturned
+    case active > 0 && done == active:
+        return OrderDelivered
+    case moving > 0 || done > 0:
+        return OrderInTransit
+    case assembled > 0:
+        return OrderAssembling
+    }
+    return OrderAccepted
+}
+
+// Источники смены статуса в StatusChange.Source.
+const (
+    StatusSourceMessage = "message"
+    StatusSourceAPI     = "api"
+)
+
+// StatusChange - переход одного товара в истории статусов заказа.
+// From равен нулю, если товар появился в заказе с этим статусом.
+type StatusChange struct {
+    OrderUID  string      `json:"order_uid"`
+    RID       string      `json:"rid"`
+    From      ItemStatus  `json:"from"`
+    To        ItemStatus  `json:"to"`
+    OrderFrom OrderStatus `json:"order_from,omitempty"`
+    OrderTo   OrderStatus `json:"order_to"`
+    Source    string      `json:"source"`
+    Comment   string      `json:"comment,omitempty"`
+    ChangedAt time.Time   `json:"changed_at"`
+}
+
+// ErrItemNotFound возвращается, если в заказе нет товара с указанным rid.
+var ErrItemNotFound = errors.New("товар не найден в заказе")
+
+// TransitionError - недопустимый переход статуса товара.
+type TransitionError struct {
+    RID      string
+    From, To ItemStatus
+}
+
+func (e *TransitionError) Error() string {
+    return fmt.Sprintf("товар %s: переход %s -> %s недопустим", e.RID, e.From.Name(), e.To.Name())
+}
+
+// ChangeStatus переводит в статус to товар с указанным rid или, если rid пуст, все товары
+// заказа, кроме находящихся в конечном статусе. Товары, уже находящиеся в статусе to,
+// пропускаются, поэтому повторный запрос ничего не меняет. Если хотя бы один переход
+// недопустим, заказ не меняется. Возвращает переходы без Source и ChangedAt.
+func (o *Order) ChangeStatus(rid string, to ItemStatus) ([]StatusChange, error) {
+    if !to.Known() {
+        return nil, fmt.Errorf("неизвестный статус товара %d", to)
+    }
+    var targets []int
+    found := false
+    for i, item := range o.Items {
+        if rid != "" && item.RID != rid {
+            continue
+        }
+        found = true
+        if item.Status != to && (rid != "" || !item.Status.Terminal()) {
+            targets = append(targets, i)
+        }
+    }
+    if rid != "" && !found {
+        return nil, fmt.Errorf("%w: %s", ErrItemNotFound, rid)
+    }
+    for _, i := range targets {
+        if item := o.Items[i]; !item.Status.CanTransition(to) {
+            return nil, &TransitionError{RID: item.RID, From: item.Status, To: to}
+        }
+    }
+
+    before := *o
+    before.Items = append([]Item(nil), o.Items...)
+    for _, i := range targets {
+        o.Items[i].Status = to
+    }
+    return StatusChanges(&before, *o), nil
+}
+
+// StatusChanges возвращает переходы товаров между сохранённым заказом old (nil - заказа не было)
+// и новой версией. Товары сопоставляются по rid. Переходы из сообщений не проверяются:
+// производитель - источник истины для полного заказа.
+func StatusChanges(old *Order, updated Order) []StatusChange {
+    previous := make(map[string]ItemStatus)
+    var orderFrom OrderStatus
+    if old != nil {
+        for _, item := range old.Items {
+            previous[item.RID] = item.Status
+        }
+        orderFrom = old.Status()
+    }
+    orderTo := updated.Status()
+
+    var changes []StatusChange
+    for _, item := range updated.Items {
+        from, existed := previous[item.RID]
+        if existed && from == item.Status {
+            continue
+        }
+        changes = append(changes, StatusChange{
+            OrderUID:  updated.OrderUID,
+            RID:       item.RID,
+            From:      from,
+            To:        item.Status,
+            OrderFrom: orderFrom,
+            OrderTo:   orderTo,
+        })
+    }
+    return changes
+}
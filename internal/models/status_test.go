@@ -0,0 +1,126 @@
+package models
+
+import (
+    "errors"
+    "testing"
+)
+
+func orderWithStatuses(statuses ...ItemStatus) Order {
+    order := Order{OrderUID: "a"}
+    for i, status := range statuses {
+        order.Items = append(order.Items, Item{RID: string(rune('a' + i)), Status: status})
+    }
+    return order
+}
+
+func TestParseItemStatus(t *testing.T) {
+    for _, s := range []string{"in_transit", "202"} {
+        if status, err := ParseItemStatus(s); err != nil || status != ItemInTransit {
+            t.Errorf("ParseItemStatus(%q) = %v, %v", s, status, err)
+        }
+    }
+    for _, s := range []string{"", "lost", "204"} {
+        if _, err := ParseItemStatus(s); err == nil {
+            t.Errorf("ParseItemStatus(%q): ожидалась ошибка", s)
+        }
+    }
+}
+
+func TestOrderStatus(t *testing.T) {
+    tests := []struct {
+        name     string
+        statuses []ItemStatus
+        want     OrderStatus
+    }{
+        {"Без товаров", nil, OrderAccepted},
+        {"Все приняты", []ItemStatus{ItemAccepted, ItemAccepted}, OrderAccepted},
+        {"Часть собрана", []ItemStatus{ItemAccepted, ItemAssembled}, OrderAssembling},
+        {"Часть в пути", []ItemStatus{ItemAssembled, ItemInTransit}, OrderInTransit},
+        {"Часть доставлена", []ItemStatus{ItemInTransit, ItemDelivered}, OrderInTransit},
+        {"Все доставлены, отмена не мешает", []ItemStatus{ItemDelivered, ItemCancelled}, OrderDelivered},
+        {"Доставлены и возвращены", []ItemStatus{ItemDelivered, ItemReturned}, OrderDelivered},
+        {"Все возвращены", []ItemStatus{ItemReturned, ItemCancelled}, OrderReturned},
+        {"Все отменены", []ItemStatus{ItemCancelled, ItemCancelled}, OrderCancelled},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := orderWithStatuses(tt.statuses...).Status(); got != tt.want {
+                t.Errorf("Ожидался статус %s, получили %s", tt.want, got)
+            }
+        })
+    }
+}
+
+func TestChangeStatus(t *testing.T) {
+    t.Run("Один товар", func(t *testing.T) {
+        order := orderWithStatuses(ItemAccepted, ItemAccepted)
+        changes, err := order.ChangeStatus("b", ItemAssembled)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(changes) != 1 || changes[0].RID != "b" || changes[0].From != ItemAccepted || changes[0].OrderTo != OrderAssembling {
+            t.Errorf("Неожиданные переходы: %+v", changes)
+        }
+        if order.Items[0].Status != ItemAccepted || order.Items[1].Status != ItemAssembled {
+            t.Errorf("Неожиданные статусы: %+v", order.Items)
+        }
+    })
+
+    t.Run("Все товары, конечные пропускаются", func(t *testing.T) {
+        order := orderWithStatuses(ItemAssembled, ItemCancelled, ItemInTransit)
+        changes, err := order.ChangeStatus("", ItemInTransit)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(changes) != 1 || changes[0].RID != "a" {
+            t.Errorf("Неожиданные переходы: %+v", changes)
+        }
+        if order.Items[1].Status != ItemCancelled {
+            t.Errorf("Отменённый товар не должен меняться: %+v", order.Items)
+        }
+    })
+
+    t.Run("Недопустимый переход не меняет заказ", func(t *testing.T) {
+        order := orderWithStatuses(ItemAssembled, ItemAccepted)
+        _, err := order.ChangeStatus("", ItemInTransit)
+        var transitionErr *TransitionError
+        if !errors.As(err, &transitionErr) || transitionErr.RID != "b" {
+            t.Fatalf("Ожидалась ошибка перехода для товара b, получили %v", err)
+        }
+        if order.Items[0].Status != ItemAssembled {
+            t.Errorf("Заказ изменён несмотря на ошибку: %+v", order.Items)
+        }
+    })
+
+    t.Run("Нет товара", func(t *testing.T) {
+        order := orderWithStatuses(ItemAccepted)
+        if _, err := order.ChangeStatus("x", ItemAssembled); !errors.Is(err, ErrItemNotFound) {
+            t.Errorf("Ожидалась ErrItemNotFound, получили %v", err)
+        }
+    })
+
+    t.Run("Повтор ничего не меняет", func(t *testing.T) {
+        order := orderWithStatuses(ItemAssembled)
+        if changes, err := order.ChangeStatus("a", ItemAssembled); err != nil || len(changes) != 0 {
+            t.Errorf("Ожидалось отсутствие переходов, получили %+v, %v", changes, err)
+        }
+    })
+}
+
+func TestStatusChanges(t *testing.T) {
+    old := orderWithStatuses(ItemAccepted, ItemAssembled)
+    updated := orderWithStatuses(ItemAccepted, ItemInTransit, ItemAccepted)
+    changes := StatusChanges(&old, updated)
+    if len(changes) != 2 {
+        t.Fatalf("Ожидалось 2 перехода, получили %+v", changes)
+    }
+    if changes[0].RID != "b" || changes[0].From != ItemAssembled || changes[0].OrderFrom != OrderAssembling {
+        t.Errorf("Неожиданный переход: %+v", changes[0])
+    }
+    if changes[1].RID != "c" || changes[1].From != 0 {
+        t.Errorf("Новый товар должен появиться без исходного статуса: %+v", changes[1])
+    }
+    if changes := StatusChanges(nil, old); len(changes) != 2 || changes[0].OrderFrom != "" {
+        t.Errorf("Для нового заказа ожидались переходы всех товаров: %+v", changes)
+    }
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+    "encoding/json"
+    "fmt"
+    "time"
+)
+
+// Время в модели хранится в UTC: так его возвращает колонка TIMESTAMPTZ, и заказ из хранилища
+// совпадает с сохранённым. Нулевое время - дата не указана.
+
+// ParseTime разбирает время в формате RFC3339 и переводит его в UTC. Пустая строка - нулевое время.
+func ParseTime(s string) (time.Time, error) {
+    if s == "" {
+        return time.Time{}, nil
+    }
+    t, err := time.Parse(time.RFC3339Nano, s)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return t.UTC(), nil
+}
+
+// FormatTime форматирует время в RFC3339 в UTC. Нулевое время - пустая строка.
+func FormatTime(t time.Time) string {
+    if t.IsZero() {
+        return ""
+    }
+    return t.UTC().Format(time.RFC3339Nano)
+}
+
+// UnixTime переводит unix-время в секундах в UTC. Ноль - нулевое время.
+func UnixTime(sec int64) time.Time {
+    if sec == 0 {
+        return time.Time{}
+    }
+    return time.Unix(sec, 0).UTC()
+}
+
+// UnixSeconds - unix-время в секундах, для нулевого времени - ноль.
+func UnixSeconds(t time.Time) int64 {
+    if t.IsZero() {
+        return 0
+    }
+    return t.Unix()
+}
+
+// paymentJSON - оплата в сообщении: payment_dt передаётся unix-временем в секундах.
+type paymentJSON struct {
+    plainPayment
+    PaymentDt int64 `json:"payment_dt"`
+}
+
+type plainPayment Payment
+
+func (p Payment) MarshalJSON() ([]byte, error) {
+    return json.Marshal(paymentJSON{plainPayment(p), UnixSeconds(p.PaymentDt)})
+}
+
+func (p *Payment) UnmarshalJSON(data []byte) error {
+    var v paymentJSON
+    if err := json.Unmarshal(data, &v); err != nil {
+        return err
+    }
+    *p = Payment(v.plainPayment)
+    p.PaymentDt = UnixTime(v.PaymentDt)
+    return nil
+}
+
+// parseDateCreated разбирает date_created из сообщения.
+func parseDateCreated(s string) (time.Time, error) {
+    t, err := ParseTime(s)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("некорректная дата создания %q", s)
+    }
+    return t, nil
+}
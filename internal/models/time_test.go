@@ -0,0 +1,40 @@
+package models
+
+import (
+    "encoding/json"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestOrderTime(t *testing.T) {
+    order, err := ParseOrder([]byte(`{"order_uid": "x", "date_created": "2021-11-26T09:22:19.5+03:00", "payment": {"payment_dt": 1637907727}}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := time.Date(2021, 11, 26, 6, 22, 19, 5e8, time.UTC); order.DateCreated != want {
+        t.Errorf("date_created: %v, ожидалось %v", order.DateCreated, want)
+    }
+    if want := time.Date(2021, 11, 26, 6, 22, 7, 0, time.UTC); order.Payment.PaymentDt != want {
+        t.Errorf("payment_dt: %v, ожидалось %v", order.Payment.PaymentDt, want)
+    }
+    data, _ := json.Marshal(order)
+    if !strings.Contains(string(data), `"date_created":"2021-11-26T06:22:19.5Z"`) || !strings.Contains(string(data), `"payment_dt":1637907727`) {
+        t.Errorf("Время закодировано не так, как пришло: %s", data)
+    }
+
+    // Пустая дата - дата не указана, и кодируется обратно пустой строкой.
+    order, err = ParseOrder([]byte(`{"order_uid": "x", "date_created": ""}`))
+    if err != nil || !order.DateCreated.IsZero() || !order.Payment.PaymentDt.IsZero() {
+        t.Fatalf("Пустая дата: %v, %v", order.DateCreated, err)
+    }
+    if data, _ := json.Marshal(order); !strings.Contains(string(data), `"date_created":""`) || !strings.Contains(string(data), `"payment_dt":0`) {
+        t.Errorf("Пустые даты закодированы как %s", data)
+    }
+
+    for _, date := range []string{"вчера", "2021-11-26", "2021-11-26 06:22:19Z", "2021-11-26T06:22:19"} {
+        if _, err := ParseOrder([]byte(`{"order_uid": "x", "date_created": "` + date + `"}`)); err == nil || !strings.Contains(err.Error(), "некорректная дата создания") {
+            t.Errorf("%s: ожидалась ошибка даты, получили %v", date, err)
+        }
+    }
+}
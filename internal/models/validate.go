@@ -0,0 +1,37 @@
+package models
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+)
+
+// MaxOrderUIDLength совпадает с размером колонки orders.order_uid.
+const MaxOrderUIDLength = 255
+
+// ParseOrder разбирает заказ из JSON и проверяет его. Любая ошибка означает,
+// что сообщение некорректно и повторная обработка не поможет.
+func ParseOrder(data []byte) (Order, error) {
+    var order Order
+    if err := json.Unmarshal(data, &order); err != nil {
+        return Order{}, fmt.Errorf("ошибка десериализации: %v", err)
+    }
+    if err := order.Validate(); err != nil {
+        return Order{}, err
+    }
+    return order, nil
+}
+
+// Validate проверяет поля, без которых заказ нельзя сохранить.
+func (o Order) Validate() error {
+    if o.OrderUID == "" {
+        return errors.New("пустой order_uid")
+    }
+    if len(o.OrderUID) > MaxOrderUIDLength {
+        return fmt.Errorf("order_uid длиннее %d символов", MaxOrderUIDLength)
+    }
+    if o.Payment.Currency != "" && !ValidCurrency(o.Payment.Currency) {
+        return fmt.Errorf("некорректный код валюты %q", o.Payment.Currency)
+    }
+    return nil
+}
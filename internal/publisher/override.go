@@ -0,0 +1,162 @@
+package publisher
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "text/template"
+    "time"
+
+    "wb-order-hub/internal/input"
+)
+
+// TemplateData - значения, доступные в шаблонах переопределений.
+type TemplateData struct {
+    // Index - порядковый номер сообщения в запуске, начиная с 1.
+    Index int
+    // UID - order_uid сообщения: исходный в шаблоне -uid и уже заменённый в шаблонах -set.
+    UID string
+    // OriginalUID - order_uid из входных данных.
+    OriginalUID string
+    // Source - вход и позиция сообщения, например orders.ndjson:3.
+    Source string
+    // Time - время подготовки сообщения.
+    Time time.Time
+}
+
+// Rand возвращает случайную hex-строку из n байт, например {{.Rand 8}}.
+func (TemplateData) Rand(n int) string {
+    b := make([]byte, n)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
+
+// Overrides изменяют поля сообщений перед публикацией.
+type Overrides struct {
+    uid  *template.Template
+    sets []setting
+}
+
+type setting struct {
+    path  []string
+    value *template.Template
+}
+
+// SetUID задаёт шаблон order_uid, например "load-{{.Index}}".
+func (o *Overrides) SetUID(text string) error {
+    tmpl, err := template.New("uid").Option("missingkey=error").Parse(text)
+    if err != nil {
+        return fmt.Errorf("некорректный шаблон order_uid: %w", err)
+    }
+    o.uid = tmpl
+    return nil
+}
+
+// Set добавляет переопределение в виде путь=шаблон, например "delivery.city=Moscow"
+// или "payment.transaction={{.UID}}". Значение, являющееся корректным JSON (число,
+// логическое значение, объект), подставляется как JSON, иначе - как строка.
+func (o *Overrides) Set(assignment string) error {
+    path, text, ok := strings.Cut(assignment, "=")
+    if !ok || path == "" {
+        return fmt.Errorf("переопределение %q: ожидается путь=значение", assignment)
+    }
+    tmpl, err := template.New(path).Option("missingkey=error").Parse(text)
+    if err != nil {
+        return fmt.Errorf("переопределение %s: некорректный шаблон: %w", path, err)
+    }
+    o.sets = append(o.sets, setting{path: strings.Split(path, "."), value: tmpl})
+    return nil
+}
+
+// Empty сообщает, что переопределений нет и сообщения публикуются как есть.
+func (o *Overrides) Empty() bool {
+    return o.uid == nil && len(o.sets) == 0
+}
+
+// Apply возвращает сообщение с применёнными переопределениями. index - номер сообщения с 1.
+func (o *Overrides) Apply(msg input.Message, index int) ([]byte, error) {
+    if o.Empty() {
+        return msg.Data, nil
+    }
+
+    dec := json.NewDecoder(bytes.NewReader(msg.Data))
+    dec.UseNumber()
+    var doc map[string]any
+    if err := dec.Decode(&doc); err != nil {
+        return nil, fmt.Errorf("переопределения применимы только к JSON-объекту: %w", err)
+    }
+
+    uid, _ := doc["order_uid"].(string)
+    data := TemplateData{Index: index, UID: uid, OriginalUID: uid, Source: msg.Source, Time: time.Now()}
+    if o.uid != nil {
+        value, err := execute(o.uid, data)
+        if err != nil {
+            return nil, err
+        }
+        doc["order_uid"] = value
+        data.UID = value
+    }
+
+    for _, s := range o.sets {
+        text, err := execute(s.value, data)
+        if err != nil {
+            return nil, err
+        }
+        var value any = text
+        var parsed any
+        if json.Unmarshal([]byte(text), &parsed) == nil {
+            value = json.RawMessage(text)
+        }
+        if err := setPath(doc, s.path, value); err != nil {
+            return nil, err
+        }
+    }
+    return json.Marshal(doc)
+}
+
+func execute(tmpl *template.Template, data TemplateData) (string, error) {
+    var buf strings.Builder
+    if err := tmpl.Execute(&buf, data); err != nil {
+        return "", fmt.Errorf("шаблон %s: %w", tmpl.Name(), err)
+    }
+    return buf.String(), nil
+}
+
+// setPath записывает value по пути path, создавая недостающие объекты.
+// Числовой элемент пути обращается к элементу массива, например items.0.price.
+func setPath(doc map[string]any, path []string, value any) error {
+    var current any = doc
+    for i, key := range path {
+        last := i == len(path)-1
+        switch node := current.(type) {
+        case map[string]any:
+            if last {
+                node[key] = value
+                return nil
+            }
+            next, ok := node[key]
+            if !ok || next == nil {
+                next = map[string]any{}
+                node[key] = next
+            }
+            current = next
+        case []any:
+            idx, err := strconv.Atoi(key)
+            if err != nil || idx < 0 || idx >= len(node) {
+                return fmt.Errorf("%s: нет элемента %q", strings.Join(path[:i], "."), key)
+            }
+            if last {
+                node[idx] = value
+                return nil
+            }
+            current = node[idx]
+        default:
+            return fmt.Errorf("%s: не объект и не массив", strings.Join(path[:i], "."))
+        }
+    }
+    return nil
+}
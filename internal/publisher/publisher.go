@@ -0,0 +1,162 @@
+package publisher
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log"
+    "math"
+    "slices"
+    "sync"
+    "time"
+
+    "github.com/nats-io/stan.go"
+)
+
+// Conn - часть stan.Conn, нужная для асинхронной публикации.
+type Conn interface {
+    PublishAsync(subject string, data []byte, ah stan.AckHandler) (string, error)
+}
+
+// Publisher публикует сообщения асинхронно и собирает статистику подтверждений.
+// Число неподтверждённых сообщений ограничивает само подключение
+// (stan.MaxPubAcksInflight): при достижении лимита Publish ждёт подтверждений.
+type Publisher struct {
+    conn    Conn
+    channel string
+    started time.Time
+
+    pending sync.WaitGroup
+    mu      sync.Mutex
+    stats   Summary
+    // latencies - время от публикации до подтверждения каждого успешно опубликованного сообщения.
+    latencies []time.Duration
+}
+
+// New создаёт Publisher, публикующий в channel.
+func New(conn Conn, channel string) *Publisher {
+    return &Publisher{conn: conn, channel: channel, started: time.Now()}
+}
+
+// Publish отправляет сообщение и возвращается, не дожидаясь подтверждения.
+// Ошибки учитываются в итоге и пишутся в журнал с указанием source.
+func (p *Publisher) Publish(source string, data []byte) {
+    p.pending.Add(1)
+    start := time.Now()
+    _, err := p.conn.PublishAsync(p.channel, data, func(_ string, err error) {
+        defer p.pending.Done()
+        if err != nil {
+            p.Fail(source, fmt.Errorf("нет подтверждения: %w", err))
+            return
+        }
+        latency := time.Since(start)
+        p.mu.Lock()
+        p.stats.Published++
+        p.latencies = append(p.latencies, latency)
+        p.mu.Unlock()
+    })
+    if err != nil {
+        p.pending.Done()
+        p.Fail(source, err)
+    }
+}
+
+// Fail учитывает сообщение, которое не удалось подготовить или отправить.
+func (p *Publisher) Fail(source string, err error) {
+    log.Printf("Сообщение %s не опубликовано: %v", source, err)
+    p.mu.Lock()
+    p.stats.Failed++
+    p.mu.Unlock()
+}
+
+// Wait ждёт подтверждения всех отправленных сообщений или отмены ctx.
+func (p *Publisher) Wait(ctx context.Context) error {
+    done := make(chan struct{})
+    go func() {
+        p.pending.Wait()
+        close(done)
+    }()
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return fmt.Errorf("не дождались подтверждений: %w", ctx.Err())
+    }
+}
+
+// Summary возвращает итог на текущий момент.
+func (p *Publisher) Summary() Summary {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    s := p.stats
+    s.Duration = time.Since(p.started)
+    s.Latency = NewLatencyStats(p.latencies)
+    return s
+}
+
+// Summary - итог публикации.
+type Summary struct {
+    Published int
+    Failed    int
+    Duration  time.Duration
+    Latency   LatencyStats
+}
+
+// LatencyStats - распределение задержки подтверждений.
+type LatencyStats struct {
+    Min, Avg, P50, P95, P99, Max time.Duration
+}
+
+// NewLatencyStats считает распределение по неотсортированным значениям.
+func NewLatencyStats(values []time.Duration) LatencyStats {
+    if len(values) == 0 {
+        return LatencyStats{}
+    }
+    sorted := slices.Clone(values)
+    slices.Sort(sorted)
+
+    var total time.Duration
+    for _, v := range sorted {
+        total += v
+    }
+    return LatencyStats{
+        Min: sorted[0],
+        Avg: total / time.Duration(len(sorted)),
+        P50: Percentile(sorted, 50),
+        P95: Percentile(sorted, 95),
+        P99: Percentile(sorted, 99),
+        Max: sorted[len(sorted)-1],
+    }
+}
+
+// Percentile возвращает p-й перцентиль отсортированных значений (метод ближайшего ранга).
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+    return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// Write выводит итог в w в читаемом виде.
+func (s Summary) Write(w io.Writer) {
+    rate := 0.0
+    if s.Duration > 0 {
+        rate = float64(s.Published) / s.Duration.Seconds()
+    }
+    fmt.Fprintf(w, "Опубликовано: %d, ошибок: %d, время: %s (%.1f сообщ./с)\n",
+        s.Published, s.Failed, s.Duration.Round(time.Millisecond), rate)
+    if s.Published > 0 {
+        fmt.Fprintf(w, "Задержка подтверждения: %s\n", s.Latency)
+    }
+}
+
+func (l LatencyStats) String() string {
+    return fmt.Sprintf("min %s, avg %s, p50 %s, p95 %s, p99 %s, max %s",
+        round(l.Min), round(l.Avg), round(l.P50), round(l.P95), round(l.P99), round(l.Max))
+}
+
+func round(d time.Duration) time.Duration {
+    return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,170 @@
+package publisher
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "reflect"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/input"
+    "wb-order-hub/internal/stanserver"
+)
+
+func TestOverrides(t *testing.T) {
+    var o Overrides
+    if err := o.SetUID("load-{{.Index}}-{{.OriginalUID}}"); err != nil {
+        t.Fatal(err)
+    }
+    for _, set := range []string{"payment.transaction={{.UID}}", "items.0.price=100", "delivery.city=Moscow", "extra.flag=true"} {
+        if err := o.Set(set); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    msg := input.Message{Source: "in:1", Data: []byte(`{"order_uid":"b563","payment":{"transaction":"b563","amount":1817},"items":[{"price":453}],"delivery":{"city":"Kiryat Mozkin"}}`)}
+    data, err := o.Apply(msg, 7)
+    if err != nil {
+        t.Fatalf("Apply: %v", err)
+    }
+
+    var got map[string]any
+    json.Unmarshal(data, &got)
+    want := map[string]any{
+        "order_uid": "load-7-b563",
+        "payment":   map[string]any{"transaction": "load-7-b563", "amount": float64(1817)},
+        "items":     []any{map[string]any{"price": float64(100)}},
+        "delivery":  map[string]any{"city": "Moscow"},
+        "extra":     map[string]any{"flag": true},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("Получили %s", data)
+    }
+
+    if _, err := o.Apply(input.Message{Data: []byte("not json")}, 1); err == nil {
+        t.Error("Ожидалась ошибка для сообщения не в формате JSON")
+    }
+
+    var bad Overrides
+    if err := bad.Set("no-assignment"); err == nil {
+        t.Error("Ожидалась ошибка для переопределения без '='")
+    }
+    if err := bad.Set("items.5.price=1"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := bad.Apply(msg, 1); err == nil {
+        t.Error("Ожидалась ошибка для несуществующего элемента массива")
+    }
+
+    var none Overrides
+    if data, _ := none.Apply(input.Message{Data: []byte("not json")}, 1); string(data) != "not json" {
+        t.Error("Без переопределений сообщение должно публиковаться как есть")
+    }
+}
+
+// fakeConn подтверждает сообщения асинхронно. Сообщение "nack" получает ошибку подтверждения,
+// "reject" не принимается к отправке.
+type fakeConn struct {
+    mu        sync.Mutex
+    published []string
+}
+
+func (c *fakeConn) PublishAsync(subject string, data []byte, ah stan.AckHandler) (string, error) {
+    if string(data) == "reject" {
+        return "", errors.New("соединение закрыто")
+    }
+    c.mu.Lock()
+    c.published = append(c.published, string(data))
+    c.mu.Unlock()
+    go func() {
+        if string(data) == "nack" {
+            ah("", errors.New("timeout"))
+            return
+        }
+        ah("", nil)
+    }()
+    return "", nil
+}
+
+func TestPublisher(t *testing.T) {
+    conn := &fakeConn{}
+    p := New(conn, "orders")
+    for _, data := range []string{"1", "nack", "2", "reject", "3"} {
+        p.Publish("in", []byte(data))
+    }
+    p.Fail("in", errors.New("некорректный шаблон"))
+
+    if err := p.Wait(context.Background()); err != nil {
+        t.Fatalf("Wait: %v", err)
+    }
+    s := p.Summary()
+    if s.Published != 3 || s.Failed != 3 {
+        t.Errorf("Ожидалось 3 опубликованных и 3 ошибки, получили %+v", s)
+    }
+    if s.Latency.Min > s.Latency.P50 || s.Latency.P50 > s.Latency.Max {
+        t.Errorf("Некорректное распределение задержки: %+v", s.Latency)
+    }
+}
+
+func TestPercentile(t *testing.T) {
+    var values []time.Duration
+    for i := 1; i <= 100; i++ {
+        values = append(values, time.Duration(i))
+    }
+    for p, want := range map[float64]time.Duration{50: 50, 95: 95, 99: 99, 100: 100, 0: 1} {
+        if got := Percentile(values, p); got != want {
+            t.Errorf("p%v: получили %d, ожидали %d", p, got, want)
+        }
+    }
+}
+
+func TestPublisher_StanServer(t *testing.T) {
+    server, err := stanserver.Start(stanserver.Options{ClusterID: "publisher-test", Port: -1})
+    if err != nil {
+        t.Fatalf("Не удалось запустить NATS Streaming: %v", err)
+    }
+    defer server.Shutdown()
+
+    sc, err := stan.Connect(server.ClusterID(), "publisher-test", stan.NatsURL(server.URL()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer sc.Close()
+
+    received := make(chan string, 10)
+    sub, err := sc.Subscribe("orders", func(m *stan.Msg) { received <- string(m.Data) }, stan.DeliverAllAvailable())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer sub.Close()
+
+    p := New(sc, "orders")
+    err = input.Read(strings.NewReader("{\"n\":1}\n{\"n\":2}\n"), "stdin", input.FormatNDJSON, func(msg input.Message) error {
+        p.Publish(msg.Source, msg.Data)
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := p.Wait(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if s := p.Summary(); s.Published != 2 || s.Failed != 0 {
+        t.Errorf("Ожидалось 2 опубликованных сообщения, получили %+v", s)
+    }
+
+    for _, want := range []string{`{"n":1}`, `{"n":2}`} {
+        select {
+        case got := <-received:
+            if got != want {
+                t.Errorf("Получили %s, ожидали %s", got, want)
+            }
+        case <-time.After(5 * time.Second):
+            t.Fatal("Сообщение не доставлено")
+        }
+    }
+}
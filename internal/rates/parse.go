@@ -0,0 +1,173 @@
+package rates
+
+import (
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Форматы файла курсов.
+const (
+    FormatCSV  = "csv"
+    FormatJSON = "json"
+)
+
+// csvColumns - колонки CSV с курсами. valid_to можно не указывать.
+var csvColumns = []string{"currency", "quote", "rate", "valid_from", "valid_to"}
+
+// rateJSON - курс в файле и в API: даты задаются как 2006-01-02 (начало дня UTC) или в RFC3339.
+type rateJSON struct {
+    Currency  string      `json:"currency"`
+    Quote     string      `json:"quote"`
+    Value     json.Number `json:"rate"`
+    ValidFrom string      `json:"valid_from"`
+    ValidTo   string      `json:"valid_to"`
+}
+
+// Parse читает курсы в формате format (csv или json) и проверяет их.
+//
+// CSV начинается со строки заголовка с колонками currency, quote, rate, valid_from и, если
+// нужно, valid_to в любом порядке. JSON - массив объектов с теми же полями; rate - число или строка.
+func Parse(r io.Reader, format string) ([]Rate, error) {
+    var list []Rate
+    var err error
+    switch format {
+    case FormatCSV:
+        list, err = parseCSV(r)
+    case FormatJSON:
+        list, err = parseJSON(r)
+    default:
+        return nil, fmt.Errorf("неизвестный формат курсов %q, ожидается csv или json", format)
+    }
+    if err != nil {
+        return nil, err
+    }
+    for i, rate := range list {
+        if err := rate.Validate(); err != nil {
+            return nil, fmt.Errorf("курс %d: %w", i+1, err)
+        }
+    }
+    return list, nil
+}
+
+// ParseFile читает курсы из файла; формат определяется по расширению .csv или .json.
+func ParseFile(path string) ([]Rate, error) {
+    format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+    if format != FormatCSV && format != FormatJSON {
+        return nil, fmt.Errorf("%s: ожидается файл .csv или .json", path)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    list, err := Parse(bytes.NewReader(data), format)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return list, nil
+}
+
+func parseCSV(r io.Reader) ([]Rate, error) {
+    reader := csv.NewReader(r)
+    reader.TrimLeadingSpace = true
+    header, err := reader.Read()
+    if errors.Is(err, io.EOF) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    index := make(map[string]int)
+    for i, name := range header {
+        index[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    for _, name := range csvColumns[:4] {
+        if _, ok := index[name]; !ok {
+            return nil, fmt.Errorf("в заголовке CSV нет колонки %s", name)
+        }
+    }
+
+    var list []Rate
+    for line := 2; ; line++ {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            return list, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+        field := func(name string) string {
+            if i, ok := index[name]; ok && i < len(record) {
+                return strings.TrimSpace(record[i])
+            }
+            return ""
+        }
+        rate, err := rateJSON{
+            Currency:  field("currency"),
+            Quote:     field("quote"),
+            Value:     json.Number(field("rate")),
+            ValidFrom: field("valid_from"),
+            ValidTo:   field("valid_to"),
+        }.rate()
+        if err != nil {
+            return nil, fmt.Errorf("строка %d: %w", line, err)
+        }
+        list = append(list, rate)
+    }
+}
+
+func parseJSON(r io.Reader) ([]Rate, error) {
+    var raw []rateJSON
+    if err := json.NewDecoder(r).Decode(&raw); err != nil {
+        return nil, fmt.Errorf("ожидается массив курсов: %w", err)
+    }
+    list := make([]Rate, 0, len(raw))
+    for i, v := range raw {
+        rate, err := v.rate()
+        if err != nil {
+            return nil, fmt.Errorf("курс %d: %w", i+1, err)
+        }
+        list = append(list, rate)
+    }
+    return list, nil
+}
+
+func (v rateJSON) rate() (Rate, error) {
+    from, err := parseDate(v.ValidFrom)
+    if err != nil {
+        return Rate{}, fmt.Errorf("valid_from: %w", err)
+    }
+    to, err := parseDate(v.ValidTo)
+    if err != nil {
+        return Rate{}, fmt.Errorf("valid_to: %w", err)
+    }
+    return Rate{
+        Currency:  strings.ToUpper(v.Currency),
+        Quote:     strings.ToUpper(v.Quote),
+        Value:     v.Value.String(),
+        ValidFrom: from,
+        ValidTo:   to,
+    }, nil
+}
+
+// parseDate разбирает дату 2006-01-02 (начало дня UTC) или время в RFC3339. Пустая строка - нулевое время.
+func parseDate(s string) (time.Time, error) {
+    if s == "" {
+        return time.Time{}, nil
+    }
+    if t, err := time.Parse(time.DateOnly, s); err == nil {
+        return t, nil
+    }
+    t, err := time.Parse(time.RFC3339Nano, s)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("ожидается дата 2006-01-02 или RFC3339, получено %q", s)
+    }
+    return t.UTC(), nil
+}
@@ -0,0 +1,324 @@
+// Package rates хранит курсы валют со сроками действия и пересчитывает по ним суммы заказов
+// в валюту отчётности.
+package rates
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "math/big"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+// ErrNoRate возвращается, если на дату нет курса ни напрямую, ни через третью валюту.
+var ErrNoRate = errors.New("нет курса")
+
+// Rate - курс: за единицу Currency дают Value единиц Quote. Курс действует с ValidFrom
+// до ValidTo (не включая) или, если ValidTo не задан, до начала следующего курса той же пары.
+type Rate struct {
+    Currency string `json:"currency"`
+    Quote    string `json:"quote"`
+    // Value - десятичная запись курса, например "92.4513".
+    Value     string    `json:"rate"`
+    ValidFrom time.Time `json:"valid_from"`
+    ValidTo   time.Time `json:"valid_to,omitzero"`
+}
+
+// Validate проверяет коды валют, курс и сроки действия.
+func (r Rate) Validate() error {
+    switch {
+    case !models.ValidCurrency(r.Currency):
+        return fmt.Errorf("некорректный код валюты %q", r.Currency)
+    case !models.ValidCurrency(r.Quote):
+        return fmt.Errorf("некорректный код валюты %q", r.Quote)
+    case r.Currency == r.Quote:
+        return fmt.Errorf("курс %s к самой себе", r.Currency)
+    case r.ValidFrom.IsZero():
+        return fmt.Errorf("курс %s/%s без даты начала действия", r.Currency, r.Quote)
+    case !r.ValidTo.IsZero() && !r.ValidTo.After(r.ValidFrom):
+        return fmt.Errorf("курс %s/%s: valid_to не позже valid_from", r.Currency, r.Quote)
+    }
+    if v, ok := parseValue(r.Value); !ok || v.Sign() <= 0 {
+        return fmt.Errorf("курс %s/%s: ожидается положительное число, получено %q", r.Currency, r.Quote, r.Value)
+    }
+    return nil
+}
+
+func parseValue(s string) (*big.Rat, bool) {
+    // SetString принимает и дроби вида 1/3, курс же записывается десятичным числом.
+    if s == "" || strings.ContainsAny(s, "/eE") {
+        return nil, false
+    }
+    return new(big.Rat).SetString(s)
+}
+
+// covers сообщает, действует ли курс в момент at без учёта следующих курсов пары.
+func (r Rate) covers(at time.Time) bool {
+    return !at.Before(r.ValidFrom) && (r.ValidTo.IsZero() || at.Before(r.ValidTo))
+}
+
+type pair struct {
+    currency, quote string
+}
+
+// Table - неизменяемый набор курсов для пересчёта. Нулевое значение и nil - таблица без курсов.
+type Table struct {
+    // rates - курсы пары по возрастанию ValidFrom.
+    rates      map[pair][]Rate
+    currencies []string
+}
+
+// NewTable строит таблицу из проверенных курсов. Из курсов пары с одной датой начала действует последний.
+func NewTable(list []Rate) *Table {
+    t := &Table{rates: make(map[pair][]Rate)}
+    for _, r := range list {
+        p := pair{r.Currency, r.Quote}
+        rates := t.rates[p]
+        if i := slices.IndexFunc(rates, func(x Rate) bool { return x.ValidFrom.Equal(r.ValidFrom) }); i >= 0 {
+            rates[i] = r
+            continue
+        }
+        t.rates[p] = append(rates, r)
+        for _, c := range []string{r.Currency, r.Quote} {
+            if !slices.Contains(t.currencies, c) {
+                t.currencies = append(t.currencies, c)
+            }
+        }
+    }
+    for _, rates := range t.rates {
+        slices.SortFunc(rates, func(a, b Rate) int { return a.ValidFrom.Compare(b.ValidFrom) })
+    }
+    slices.Sort(t.currencies)
+    return t
+}
+
+// Rates возвращает все курсы таблицы по парам и датам.
+func (t *Table) Rates() []Rate {
+    if t == nil {
+        return nil
+    }
+    var list []Rate
+    for _, rates := range t.rates {
+        list = append(list, rates...)
+    }
+    slices.SortFunc(list, compareRates)
+    return list
+}
+
+func compareRates(a, b Rate) int {
+    if c := strings.Compare(a.Currency, b.Currency); c != 0 {
+        return c
+    }
+    if c := strings.Compare(a.Quote, b.Quote); c != 0 {
+        return c
+    }
+    return a.ValidFrom.Compare(b.ValidFrom)
+}
+
+// direct ищет курс пары, действующий в момент at: последний начавшийся, если его срок не истёк.
+func (t *Table) direct(currency, quote string, at time.Time) (*big.Rat, bool) {
+    rates := t.rates[pair{currency, quote}]
+    i, _ := slices.BinarySearchFunc(rates, at, func(r Rate, at time.Time) int {
+        if r.ValidFrom.After(at) {
+            return 1
+        }
+        return -1
+    })
+    if i == 0 || !rates[i-1].covers(at) {
+        return nil, false
+    }
+    return parseValue(rates[i-1].Value)
+}
+
+// pairRate ищет курс пары или обратной пары.
+func (t *Table) pairRate(currency, quote string, at time.Time) (*big.Rat, bool) {
+    if v, ok := t.direct(currency, quote, at); ok {
+        return v, true
+    }
+    if v, ok := t.direct(quote, currency, at); ok {
+        return v.Inv(v), true
+    }
+    return nil, false
+}
+
+// Lookup возвращает, сколько единиц quote дают за единицу currency в момент at: по курсу пары,
+// обратной пары или через третью валюту.
+func (t *Table) Lookup(currency, quote string, at time.Time) (*big.Rat, error) {
+    if currency == quote {
+        return big.NewRat(1, 1), nil
+    }
+    if t != nil {
+        if v, ok := t.pairRate(currency, quote, at); ok {
+            return v, nil
+        }
+        for _, via := range t.currencies {
+            if via == currency || via == quote {
+                continue
+            }
+            first, ok := t.pairRate(currency, via, at)
+            if !ok {
+                continue
+            }
+            if second, ok := t.pairRate(via, quote, at); ok {
+                return first.Mul(first, second), nil
+            }
+        }
+    }
+    return nil, fmt.Errorf("%w %s/%s на %s", ErrNoRate, currency, quote, at.UTC().Format(time.RFC3339))
+}
+
+// FormatRate записывает курс десятичным числом: не больше восьми знаков после запятой, без нулей в конце.
+func FormatRate(rate *big.Rat) string {
+    s := rate.FloatString(8)
+    s = strings.TrimRight(s, "0")
+    return strings.TrimSuffix(s, ".")
+}
+
+// Convert пересчитывает сумму в валюту quote по курсу на момент at с учётом числа знаков
+// после запятой в обеих валютах. Результат округляется до минимальной единицы, половина - от нуля.
+func (t *Table) Convert(m models.Money, quote string, at time.Time) (models.Money, error) {
+    rate, err := t.Lookup(m.Currency, quote, at)
+    if err != nil {
+        return models.Money{}, err
+    }
+    return ConvertAt(m, quote, rate), nil
+}
+
+// ConvertAt пересчитывает сумму в валюту quote по курсу rate, найденному Lookup.
+func ConvertAt(m models.Money, quote string, rate *big.Rat) models.Money {
+    v := new(big.Rat).SetInt64(int64(m.Amount))
+    v.Mul(v, rate)
+    v.Mul(v, pow10(models.CurrencyExponent(quote)-m.Exponent()))
+    return models.Money{Amount: models.Amount(round(v)), Currency: quote}
+}
+
+func pow10(exp int) *big.Rat {
+    p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(exp, -exp))), nil)
+    if exp < 0 {
+        return new(big.Rat).SetFrac(big.NewInt(1), p)
+    }
+    return new(big.Rat).SetInt(p)
+}
+
+// round округляет до целого, половину - от нуля.
+func round(v *big.Rat) int64 {
+    num := new(big.Int).Abs(v.Num())
+    q, r := new(big.Int).QuoRem(num, v.Denom(), new(big.Int))
+    if r.Lsh(r, 1).Cmp(v.Denom()) >= 0 {
+        q.Add(q, big.NewInt(1))
+    }
+    if v.Sign() < 0 {
+        q.Neg(q)
+    }
+    return q.Int64()
+}
+
+// Store - хранилище курсов.
+type Store interface {
+    // Rates возвращает все сохранённые курсы.
+    Rates(ctx context.Context) ([]Rate, error)
+    // SaveRates сохраняет курсы; курс той же пары с той же датой начала заменяется.
+    SaveRates(ctx context.Context, rates []Rate) error
+}
+
+// Book - курсы из хранилища, загруженные в память для пересчёта без обращения к нему.
+type Book struct {
+    store Store
+
+    mu    sync.RWMutex
+    table *Table
+}
+
+// NewBook создаёт справочник курсов над store. До Refresh он пуст.
+func NewBook(store Store) *Book {
+    return &Book{store: store, table: NewTable(nil)}
+}
+
+// Table возвращает курсы, загруженные последним Refresh или Add.
+func (b *Book) Table() *Table {
+    b.mu.RLock()
+    defer b.mu.RUnlock()
+    return b.table
+}
+
+// Refresh перечитывает курсы из хранилища.
+func (b *Book) Refresh(ctx context.Context) error {
+    list, err := b.store.Rates(ctx)
+    if err != nil {
+        return err
+    }
+    b.mu.Lock()
+    b.table = NewTable(list)
+    b.mu.Unlock()
+    return nil
+}
+
+// Add проверяет курсы, сохраняет их и перечитывает справочник.
+func (b *Book) Add(ctx context.Context, list []Rate) error {
+    for i, r := range list {
+        if err := r.Validate(); err != nil {
+            return fmt.Errorf("курс %d: %w", i+1, err)
+        }
+    }
+    if err := b.store.SaveRates(ctx, list); err != nil {
+        return err
+    }
+    return b.Refresh(ctx)
+}
+
+// Watch раз в interval перечитывает курсы до отмены ctx, чтобы курсы, загруженные
+// через другой экземпляр сервиса, применялись и здесь.
+func (b *Book) Watch(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+        if err := b.Refresh(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("Не удалось перечитать курсы валют: %v", err)
+        }
+    }
+}
+
+// Memory - хранилище курсов в памяти для режима разработки и тестов.
+type Memory struct {
+    mu    sync.Mutex
+    rates []Rate
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+    return &Memory{}
+}
+
+func (m *Memory) Rates(context.Context) ([]Rate, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return slices.Clone(m.rates), nil
+}
+
+func (m *Memory) SaveRates(_ context.Context, list []Rate) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for _, r := range list {
+        i := slices.IndexFunc(m.rates, func(x Rate) bool {
+            return x.Currency == r.Currency && x.Quote == r.Quote && x.ValidFrom.Equal(r.ValidFrom)
+        })
+        if i >= 0 {
+            m.rates[i] = r
+        } else {
+            m.rates = append(m.rates, r)
+        }
+    }
+    return nil
+}
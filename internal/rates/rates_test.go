@@ -0,0 +1,151 @@
+package rates
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+func date(s string) time.Time {
+    t, _ := time.Parse(time.DateOnly, s)
+    return t
+}
+
+func TestParse(t *testing.T) {
+    csvData := "currency,quote,rate,valid_from,valid_to\n" +
+        "usd,RUB,92.5,2024-01-01,\n" +
+        "EUR,RUB,100.25,2024-01-01T00:00:00+03:00,2024-02-01\n"
+    fromCSV, err := Parse(strings.NewReader(csvData), FormatCSV)
+    if err != nil {
+        t.Fatalf("CSV: %v", err)
+    }
+    jsonData := `[{"currency": "USD", "quote": "RUB", "rate": 92.5, "valid_from": "2024-01-01"},
+        {"currency": "EUR", "quote": "RUB", "rate": "100.25", "valid_from": "2023-12-31T21:00:00Z", "valid_to": "2024-02-01"}]`
+    fromJSON, err := Parse(strings.NewReader(jsonData), FormatJSON)
+    if err != nil {
+        t.Fatalf("JSON: %v", err)
+    }
+
+    for _, list := range [][]Rate{fromCSV, fromJSON} {
+        if len(list) != 2 {
+            t.Fatalf("Ожидалось два курса, получили %+v", list)
+        }
+        if r := list[0]; r.Currency != "USD" || r.Value != "92.5" || !r.ValidFrom.Equal(date("2024-01-01")) || !r.ValidTo.IsZero() {
+            t.Errorf("Курс USD: %+v", r)
+        }
+        if r := list[1]; !r.ValidFrom.Equal(date("2023-12-31").Add(21*time.Hour)) || !r.ValidTo.Equal(date("2024-02-01")) {
+            t.Errorf("Сроки курса EUR: %+v", r)
+        }
+    }
+
+    for name, data := range map[string]string{
+        "без колонки rate":    "currency,quote,valid_from\nUSD,RUB,2024-01-01\n",
+        "отрицательный курс":  "currency,quote,rate,valid_from\nUSD,RUB,-1,2024-01-01\n",
+        "дробь":               "currency,quote,rate,valid_from\nUSD,RUB,1/3,2024-01-01\n",
+        "некорректная валюта": "currency,quote,rate,valid_from\nUS,RUB,1,2024-01-01\n",
+        "некорректная дата":   "currency,quote,rate,valid_from\nUSD,RUB,1,01.01.2024\n",
+        "срок раньше начала":  "currency,quote,rate,valid_from,valid_to\nUSD,RUB,1,2024-02-01,2024-01-01\n",
+        "курс валюты к себе":  "currency,quote,rate,valid_from\nRUB,RUB,1,2024-01-01\n",
+        "без даты начала":     "currency,quote,rate,valid_from\nUSD,RUB,1,\n",
+    } {
+        if _, err := Parse(strings.NewReader(data), FormatCSV); err == nil {
+            t.Errorf("%s: ожидалась ошибка", name)
+        }
+    }
+}
+
+func TestTable(t *testing.T) {
+    table := NewTable([]Rate{
+        {Currency: "USD", Quote: "RUB", Value: "90", ValidFrom: date("2024-01-01")},
+        {Currency: "USD", Quote: "RUB", Value: "95", ValidFrom: date("2024-02-01")},
+        {Currency: "EUR", Quote: "RUB", Value: "100", ValidFrom: date("2024-01-01"), ValidTo: date("2024-01-15")},
+        {Currency: "KZT", Quote: "RUB", Value: "0.2", ValidFrom: date("2024-01-01")},
+    })
+
+    tests := []struct {
+        name     string
+        from, to string
+        at       string
+        want     string
+    }{
+        {"Прямой курс", "USD", "RUB", "2024-01-20", "90"},
+        {"Следующий курс пары", "USD", "RUB", "2024-02-01", "95"},
+        {"Обратный курс", "RUB", "USD", "2024-01-20", "0.01111111"},
+        {"Через третью валюту", "USD", "KZT", "2024-01-20", "450"},
+        {"Та же валюта", "JPY", "JPY", "2020-01-01", "1"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rate, err := table.Lookup(tt.from, tt.to, date(tt.at))
+            if err != nil {
+                t.Fatalf("Lookup: %v", err)
+            }
+            if got := FormatRate(rate); got != tt.want {
+                t.Errorf("Ожидался курс %s, получили %s", tt.want, got)
+            }
+        })
+    }
+
+    for name, at := range map[string]string{"До начала действия": "2023-12-31", "После valid_to": "2024-01-15"} {
+        if _, err := table.Lookup("EUR", "RUB", date(at)); !errors.Is(err, ErrNoRate) {
+            t.Errorf("%s: ожидалась ErrNoRate, получили %v", name, err)
+        }
+    }
+}
+
+func TestConvert(t *testing.T) {
+    table := NewTable([]Rate{
+        {Currency: "USD", Quote: "RUB", Value: "92.5", ValidFrom: date("2024-01-01")},
+        {Currency: "USD", Quote: "JPY", Value: "148.123", ValidFrom: date("2024-01-01")},
+        {Currency: "KWD", Quote: "USD", Value: "3.25", ValidFrom: date("2024-01-01")},
+    })
+    at := date("2024-03-01")
+
+    tests := []struct {
+        from models.Money
+        to   string
+        want models.Amount
+    }{
+        // 18.17 USD * 92.5 = 1680.725 RUB.
+        {models.Money{Amount: 1817, Currency: "USD"}, "RUB", 168073},
+        {models.Money{Amount: -1817, Currency: "USD"}, "RUB", -168073},
+        // 18.17 USD * 148.123 = 2691.39... JPY без копеек.
+        {models.Money{Amount: 1817, Currency: "USD"}, "JPY", 2691},
+        // 1.005 KWD * 3.25 = 3.26625 USD.
+        {models.Money{Amount: 1005, Currency: "KWD"}, "USD", 327},
+    }
+    for _, tt := range tests {
+        got, err := table.Convert(tt.from, tt.to, at)
+        if err != nil || got.Amount != tt.want || got.Currency != tt.to {
+            t.Errorf("%s в %s: ожидалось %d, получили %+v, %v", tt.from, tt.to, tt.want, got, err)
+        }
+    }
+}
+
+func TestBook(t *testing.T) {
+    ctx := context.Background()
+    book := NewBook(NewMemory())
+    if _, err := book.Table().Lookup("USD", "RUB", date("2024-01-01")); !errors.Is(err, ErrNoRate) {
+        t.Fatalf("Пустой справочник не должен находить курс: %v", err)
+    }
+
+    usd := Rate{Currency: "USD", Quote: "RUB", Value: "90", ValidFrom: date("2024-01-01")}
+    if err := book.Add(ctx, []Rate{usd}); err != nil {
+        t.Fatalf("Add: %v", err)
+    }
+    usd.Value = "91"
+    if err := book.Add(ctx, []Rate{usd}); err != nil {
+        t.Fatalf("Add: %v", err)
+    }
+    if list := book.Table().Rates(); len(list) != 1 || list[0].Value != "91" {
+        t.Errorf("Курс той же пары с той же датой должен заменяться: %+v", list)
+    }
+
+    if err := book.Add(ctx, []Rate{{Currency: "USD", Quote: "EUR", Value: "0", ValidFrom: date("2024-01-01")}}); err == nil {
+        t.Error("Некорректный курс не должен сохраняться")
+    }
+}
@@ -0,0 +1,131 @@
+// Package reload перечитывает конфигурацию работающего сервиса и применяет
+// изменения, не требующие перезапуска.
+package reload
+
+import (
+    "context"
+    "errors"
+    "log"
+    "os"
+    "slices"
+    "strings"
+    "sync"
+
+    "wb-order-hub/internal/config"
+)
+
+// Result - итог перезагрузки конфигурации.
+type Result struct {
+    // Applied - пути полей, изменения которых применены.
+    Applied []string `json:"applied"`
+    // Rejected - описания изменений, требующих перезапуска.
+    Rejected []string `json:"rejected,omitempty"`
+}
+
+// ErrRestartRequired возвращается, если новая конфигурация меняет поля, которые
+// нельзя применить на ходу. В этом случае не применяется ни одно изменение.
+var ErrRestartRequired = errors.New("изменения требуют перезапуска сервиса")
+
+type handler struct {
+    paths []string
+    apply func(cfg *config.Config)
+}
+
+// Reloader хранит действующую конфигурацию и применяет изменения через
+// зарегистрированные обработчики.
+type Reloader struct {
+    mu       sync.Mutex
+    current  *config.Config
+    load     func() (*config.Config, error)
+    handlers []handler
+}
+
+// New создаёт Reloader для конфигурации current; load заново собирает конфигурацию
+// из тех же источников, что и при запуске.
+func New(current *config.Config, load func() (*config.Config, error)) *Reloader {
+    return &Reloader{current: current, load: load}
+}
+
+// Handle регистрирует обработчик изменений полей paths. Путь может быть префиксом
+// (например, "http.rate_limit"); обработчик вызывается один раз, сколько бы его полей
+// ни изменилось. Поля с тегом reload:"true" без обработчика считаются требующими перезапуска.
+func (r *Reloader) Handle(apply func(cfg *config.Config), paths ...string) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.handlers = append(r.handlers, handler{paths: paths, apply: apply})
+}
+
+// Reload перечитывает конфигурацию. Если она некорректна или меняет поля, требующие
+// перезапуска, действующая конфигурация не меняется.
+func (r *Reloader) Reload() (Result, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    next, err := r.load()
+    if err != nil {
+        return Result{Applied: []string{}}, err
+    }
+
+    result := Result{Applied: []string{}}
+    var pending []int
+    for _, change := range config.Diff(r.current, next) {
+        h := r.handlerFor(change.Path)
+        if !change.Reloadable || h < 0 {
+            result.Rejected = append(result.Rejected, change.String())
+            continue
+        }
+        result.Applied = append(result.Applied, change.Path)
+        if !slices.Contains(pending, h) {
+            pending = append(pending, h)
+        }
+    }
+    if len(result.Rejected) > 0 {
+        result.Applied = []string{}
+        return result, ErrRestartRequired
+    }
+
+    for _, h := range pending {
+        r.handlers[h].apply(next)
+    }
+    r.current = next
+    return result, nil
+}
+
+func (r *Reloader) handlerFor(path string) int {
+    for i, h := range r.handlers {
+        for _, prefix := range h.paths {
+            if path == prefix || strings.HasPrefix(path, prefix+".") {
+                return i
+            }
+        }
+    }
+    return -1
+}
+
+// Watch перезагружает конфигурацию при каждом сигнале из signals до отмены ctx.
+func (r *Reloader) Watch(ctx context.Context, signals <-chan os.Signal) {
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-signals:
+        }
+        r.ReloadAndLog()
+    }
+}
+
+// ReloadAndLog выполняет Reload и записывает результат в журнал.
+func (r *Reloader) ReloadAndLog() (Result, error) {
+    result, err := r.Reload()
+    switch {
+    case errors.Is(err, ErrRestartRequired):
+        log.Printf("Конфигурация не перезагружена: %v:\n  - %s", err, strings.Join(result.Rejected, "\n  - "))
+    case err != nil:
+        log.Printf("Конфигурация не перезагружена: %v", err)
+    case len(result.Applied) == 0:
+        log.Println("Конфигурация перечитана, изменений нет")
+    default:
+        log.Printf("Конфигурация перезагружена, применены изменения: %s", strings.Join(result.Applied, ", "))
+    }
+    return result, err
+}
@@ -0,0 +1,108 @@
+package reload
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+
+    "wb-order-hub/internal/config"
+)
+
+// newReloader возвращает Reloader, который при перезагрузке применяет к текущей
+// конфигурации функцию *edit.
+func newReloader(edit *func(cfg *config.Config)) (*Reloader, *config.Config) {
+    current := config.Default()
+    return New(current, func() (*config.Config, error) {
+        next := *current
+        (*edit)(&next)
+        if err := next.Validate(); err != nil {
+            return nil, err
+        }
+        return &next, nil
+    }), current
+}
+
+func TestReload_AppliesReloadableChanges(t *testing.T) {
+    edit := func(cfg *config.Config) {
+        cfg.Cache.Capacity = 500
+        cfg.HTTP.RateLimit.RPS = 10
+        cfg.HTTP.RateLimit.Burst = 5
+    }
+    r, _ := newReloader(&edit)
+
+    var capacity, rateCalls int
+    r.Handle(func(cfg *config.Config) { capacity = cfg.Cache.Capacity }, "cache.capacity")
+    r.Handle(func(cfg *config.Config) { rateCalls++ }, "http.rate_limit")
+
+    result, err := r.Reload()
+    if err != nil {
+        t.Fatalf("Reload: %v", err)
+    }
+    want := []string{"http.rate_limit.rps", "http.rate_limit.burst", "cache.capacity"}
+    if !reflect.DeepEqual(result.Applied, want) {
+        t.Errorf("Применены %v, ожидали %v", result.Applied, want)
+    }
+    if capacity != 500 {
+        t.Errorf("Обработчик кэша получил ёмкость %d, ожидали 500", capacity)
+    }
+    if rateCalls != 1 {
+        t.Errorf("Обработчик лимита должен вызываться один раз, вызовов: %d", rateCalls)
+    }
+
+    // Повторная перезагрузка с той же конфигурацией ничего не меняет.
+    result, err = r.Reload()
+    if err != nil || len(result.Applied) != 0 || rateCalls != 1 {
+        t.Errorf("Ожидалась перезагрузка без изменений, получили %+v, %v", result, err)
+    }
+}
+
+func TestReload_RejectsRestartRequired(t *testing.T) {
+    edit := func(cfg *config.Config) {
+        cfg.Cache.Capacity = 500
+        cfg.HTTP.Port = 9090
+        cfg.Security.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+    }
+    r, current := newReloader(&edit)
+
+    applied := false
+    r.Handle(func(*config.Config) { applied = true }, "cache.capacity")
+
+    result, err := r.Reload()
+    if !errors.Is(err, ErrRestartRequired) {
+        t.Fatalf("Ожидалась ошибка ErrRestartRequired, получили %v", err)
+    }
+    if applied || len(result.Applied) != 0 {
+        t.Error("При изменениях, требующих перезапуска, ничего не должно применяться")
+    }
+    want := []string{`http.port: "8080" -> "9090"`, "security.encryption_key: изменено"}
+    if !reflect.DeepEqual(result.Rejected, want) {
+        t.Errorf("Отклонены %q, ожидали %q", result.Rejected, want)
+    }
+    if r.current != current {
+        t.Error("Действующая конфигурация не должна меняться")
+    }
+}
+
+func TestReload_UnhandledFieldRequiresRestart(t *testing.T) {
+    edit := func(cfg *config.Config) { cfg.Log.Level = "debug" }
+    r, _ := newReloader(&edit)
+
+    if _, err := r.Reload(); !errors.Is(err, ErrRestartRequired) {
+        t.Errorf("Поле без обработчика должно требовать перезапуска, получили %v", err)
+    }
+}
+
+func TestReload_InvalidConfig(t *testing.T) {
+    edit := func(cfg *config.Config) { cfg.Cache.Capacity = 0 }
+    r, current := newReloader(&edit)
+    r.Handle(func(*config.Config) { t.Error("Некорректная конфигурация не должна применяться") }, "cache.capacity")
+
+    _, err := r.Reload()
+    var verr *config.ValidationError
+    if !errors.As(err, &verr) {
+        t.Fatalf("Ожидалась ошибка проверки, получили %v", err)
+    }
+    if r.current != current {
+        t.Error("Действующая конфигурация не должна меняться")
+    }
+}
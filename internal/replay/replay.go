@@ -0,0 +1,266 @@
+// Package replay повторно обрабатывает сообщения канала NATS Streaming с заданной позиции,
+// например чтобы заполнить новую базу данных.
+package replay
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/envelope"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// Position - сообщение канала.
+type Position struct {
+    Sequence uint64
+    Time     time.Time
+}
+
+// LastPosition возвращает последнее сообщение канала. Если за wait сообщение не пришло,
+// канал считается пустым и возвращается нулевая позиция.
+func LastPosition(ctx context.Context, sc stan.Conn, channel string, wait time.Duration) (Position, error) {
+    last := make(chan Position, 1)
+    sub, err := sc.Subscribe(channel, func(m *stan.Msg) {
+        select {
+        case last <- Position{Sequence: m.Sequence, Time: time.Unix(0, m.Timestamp)}:
+        default:
+        }
+    }, stan.StartWithLastReceived())
+    if err != nil {
+        return Position{}, fmt.Errorf("не удалось подписаться на канал %s: %w", channel, err)
+    }
+    defer sub.Unsubscribe()
+
+    timer := time.NewTimer(wait)
+    defer timer.Stop()
+    select {
+    case pos := <-last:
+        return pos, nil
+    case <-timer.C:
+        return Position{}, nil
+    case <-ctx.Done():
+        return Position{}, ctx.Err()
+    }
+}
+
+// Policy определяет, что делать с заказом, который уже был в хранилище до начала повтора.
+type Policy string
+
+const (
+    // PolicyOverwrite перезаписывает заказ версией из канала. Заказы, уже изменённые событиями,
+    // не перезаписываются и при ней: хранилище возвращает repository.ErrSuperseded.
+    PolicyOverwrite Policy = "overwrite"
+    // PolicySkip оставляет сохранённый заказ без изменений.
+    PolicySkip Policy = "skip"
+)
+
+// ParsePolicy разбирает значение флага -conflict. Пустая строка - PolicyOverwrite.
+func ParsePolicy(s string) (Policy, error) {
+    switch p := Policy(s); p {
+    case "":
+        return PolicyOverwrite, nil
+    case PolicyOverwrite, PolicySkip:
+        return p, nil
+    }
+    return "", fmt.Errorf("неизвестная политика конфликтов %q, ожидается overwrite или skip", s)
+}
+
+// Options - откуда начинать повтор. Если не задано ни StartSequence, ни StartTime,
+// канал обрабатывается с первого сообщения.
+type Options struct {
+    Channel       string
+    StartSequence uint64
+    StartTime     time.Time
+    // Conflict - политика для заказов, уже сохранённых до повтора; по умолчанию PolicyOverwrite.
+    Conflict Policy
+    // LastWait - сколько ждать последнее сообщение канала, чтобы узнать, где остановиться.
+    LastWait time.Duration
+    // Progress, если задан, вызывается каждые ProgressInterval (по умолчанию секунда)
+    // и один раз по окончании повтора.
+    Progress         func(Progress)
+    ProgressInterval time.Duration
+}
+
+// Stats - итог повтора.
+type Stats struct {
+    Saved   int
+    Invalid int
+    // Skipped - заказы, оставленные без изменений по PolicySkip или уже изменённые событиями.
+    Skipped int
+    // Last - последнее сообщение канала на момент запуска: на нём повтор останавливается.
+    Last Position
+}
+
+// Progress - состояние повтора: обработано сообщений до Sequence из Stats.Last.Sequence.
+type Progress struct {
+    Stats
+    First    uint64
+    Sequence uint64
+    Elapsed  time.Duration
+}
+
+// Percent - доля обработанных сообщений от первого до последнего.
+func (p Progress) Percent() float64 {
+    if p.Sequence == 0 || p.Sequence < p.First || p.Last.Sequence < p.First {
+        return 0
+    }
+    return float64(p.Sequence-p.First+1) / float64(p.Last.Sequence-p.First+1) * 100
+}
+
+// Rate - сообщений в секунду с начала повтора.
+func (p Progress) Rate() float64 {
+    if p.Elapsed <= 0 || p.Sequence == 0 || p.Sequence < p.First {
+        return 0
+    }
+    return float64(p.Sequence-p.First+1) / p.Elapsed.Seconds()
+}
+
+// String - строка для журнала, например "120/400 (30%), 85 сообщ./с, сохранено 118, ...".
+func (p Progress) String() string {
+    return fmt.Sprintf("%d/%d (%.0f%%), %.0f сообщ./с, сохранено %d, пропущено существующих %d, некорректных %d",
+        p.Sequence, p.Last.Sequence, p.Percent(), p.Rate(), p.Saved, p.Skipped, p.Invalid)
+}
+
+// Run читает канал временной подпиской от заданной позиции до последнего сообщения
+// на момент запуска и сохраняет заказы в repo. Сохранение - upsert, поэтому повтор
+// можно запускать несколько раз. Заказы, которые уже были в хранилище, перезаписываются
+// или пропускаются по opts.Conflict; повторы одного заказа внутри канала всегда
+// применяются по порядку. Некорректные сообщения пропускаются, ошибка хранилища
+// прерывает повтор.
+func Run(ctx context.Context, sc stan.Conn, repo repository.OrderRepository, opts Options) (Stats, error) {
+    if opts.LastWait <= 0 {
+        opts.LastWait = time.Second
+    }
+    if opts.ProgressInterval <= 0 {
+        opts.ProgressInterval = time.Second
+    }
+    policy, err := ParsePolicy(string(opts.Conflict))
+    if err != nil {
+        return Stats{}, err
+    }
+
+    var stats Stats
+    last, err := LastPosition(ctx, sc, opts.Channel, opts.LastWait)
+    if err != nil {
+        return stats, err
+    }
+    stats.Last = last
+    if last.Sequence == 0 || last.Sequence < opts.StartSequence ||
+        (!opts.StartTime.IsZero() && last.Time.Before(opts.StartTime)) {
+        return stats, nil
+    }
+
+    start := stan.DeliverAllAvailable()
+    switch {
+    case opts.StartSequence > 0:
+        start = stan.StartAtSequence(opts.StartSequence)
+    case !opts.StartTime.IsZero():
+        start = stan.StartAtTime(opts.StartTime)
+    }
+
+    // После отмены ctx обработчик может ещё работать, пока закрывается подписка.
+    var mu sync.Mutex
+    began := time.Now()
+    var first, current uint64
+    // replayed - заказы, уже сохранённые этим повтором: они не считаются конфликтом.
+    replayed := make(map[string]bool)
+    progress := func() Progress {
+        return Progress{Stats: stats, First: first, Sequence: current, Elapsed: time.Since(began)}
+    }
+    done := make(chan error, 1)
+    finish := func(err error) {
+        select {
+        case done <- err:
+        default:
+        }
+    }
+    save := func(order models.Order) error {
+        if policy == PolicySkip && !replayed[order.OrderUID] {
+            _, err := repo.GetByUID(ctx, order.OrderUID)
+            if err == nil {
+                stats.Skipped++
+                return nil
+            }
+            if !errors.Is(err, repository.ErrNotFound) {
+                return err
+            }
+        }
+        err := repo.Save(ctx, order)
+        if errors.Is(err, repository.ErrSuperseded) {
+            stats.Skipped++
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        replayed[order.OrderUID] = true
+        stats.Saved++
+        return nil
+    }
+    sub, err := sc.Subscribe(opts.Channel, func(m *stan.Msg) {
+        mu.Lock()
+        defer mu.Unlock()
+        if ctx.Err() != nil || m.Sequence > last.Sequence {
+            return
+        }
+        if first == 0 {
+            first = m.Sequence
+        }
+        current = m.Sequence
+        order, _, err := envelope.ParseOrder(m.Data)
+        if err != nil {
+            log.Printf("Сообщение %d пропущено: %v", m.Sequence, err)
+            stats.Invalid++
+        } else if err := save(order); err != nil {
+            finish(fmt.Errorf("сообщение %d: не удалось сохранить заказ %s: %w", m.Sequence, order.OrderUID, err))
+            return
+        }
+        m.Ack()
+        if m.Sequence == last.Sequence {
+            finish(nil)
+        }
+    }, start, stan.SetManualAckMode(), stan.MaxInflight(1))
+    if err != nil {
+        return stats, fmt.Errorf("не удалось подписаться на канал %s: %w", opts.Channel, err)
+    }
+
+    var tick <-chan time.Time
+    if opts.Progress != nil {
+        ticker := time.NewTicker(opts.ProgressInterval)
+        defer ticker.Stop()
+        tick = ticker.C
+    }
+wait:
+    for {
+        select {
+        case err = <-done:
+            break wait
+        case <-ctx.Done():
+            err = ctx.Err()
+            break wait
+        case <-tick:
+            mu.Lock()
+            p := progress()
+            mu.Unlock()
+            if p.Sequence > 0 {
+                opts.Progress(p)
+            }
+        }
+    }
+    sub.Unsubscribe()
+    mu.Lock()
+    defer mu.Unlock()
+    if opts.Progress != nil {
+        opts.Progress(progress())
+    }
+    if errors.Is(err, context.Canceled) {
+        err = errors.New("повтор прерван")
+    }
+    return stats, err
+}
@@ -0,0 +1,207 @@
+package replay
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+    "wb-order-hub/internal/stanserver"
+)
+
+func connect(t *testing.T) stan.Conn {
+    t.Helper()
+    server, err := stanserver.Start(stanserver.Options{ClusterID: "replay-test", Port: -1})
+    if err != nil {
+        t.Fatalf("Не удалось запустить NATS Streaming: %v", err)
+    }
+    t.Cleanup(server.Shutdown)
+
+    sc, err := stan.Connect(server.ClusterID(), "replay-test", stan.NatsURL(server.URL()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { sc.Close() })
+    return sc
+}
+
+func publish(t *testing.T, sc stan.Conn, messages ...string) {
+    t.Helper()
+    for _, msg := range messages {
+        if err := sc.Publish("orders", []byte(msg)); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+func orderJSON(uid string) string {
+    data, _ := json.Marshal(repotest.SampleOrder(uid))
+    return string(data)
+}
+
+func uids(t *testing.T, repo repository.OrderRepository) []string {
+    orders, err := repo.List(context.Background(), repository.ListFilter{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    var result []string
+    for _, o := range orders {
+        result = append(result, o.OrderUID)
+    }
+    return result
+}
+
+func TestRun(t *testing.T) {
+    sc := connect(t)
+    ctx := context.Background()
+
+    stats, err := Run(ctx, sc, repository.NewMemory(), Options{Channel: "orders", LastWait: 200 * time.Millisecond})
+    if err != nil || stats.Saved != 0 || stats.Last.Sequence != 0 {
+        t.Fatalf("Пустой канал: %+v, %v", stats, err)
+    }
+
+    publish(t, sc, orderJSON("a"), "not json", orderJSON("b"), orderJSON("c"))
+
+    repo := repository.NewMemory()
+    stats, err = Run(ctx, sc, repo, Options{Channel: "orders"})
+    if err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    if stats.Saved != 3 || stats.Invalid != 1 || stats.Last.Sequence != 4 {
+        t.Errorf("Неожиданный итог: %+v", stats)
+    }
+    if got := uids(t, repo); len(got) != 3 {
+        t.Errorf("Ожидалось 3 заказа, получили %v", got)
+    }
+
+    repo = repository.NewMemory()
+    stats, err = Run(ctx, sc, repo, Options{Channel: "orders", StartSequence: 3})
+    if err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    if got := uids(t, repo); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+        t.Errorf("С сообщения 3 ожидались заказы b и c, получили %v", got)
+    }
+
+    stats, err = Run(ctx, sc, repository.NewMemory(), Options{Channel: "orders", StartTime: time.Now().Add(time.Hour)})
+    if err != nil || stats.Saved != 0 {
+        t.Errorf("Начало после последнего сообщения: %+v, %v", stats, err)
+    }
+}
+
+// failingRepository не может сохранить ни одного заказа.
+type failingRepository struct {
+    repository.OrderRepository
+}
+
+func (failingRepository) Save(context.Context, models.Order) error {
+    return errors.New("соединение разорвано")
+}
+
+func TestRun_StoreError(t *testing.T) {
+    sc := connect(t)
+    publish(t, sc, orderJSON("a"), orderJSON("b"))
+
+    stats, err := Run(context.Background(), sc, failingRepository{}, Options{Channel: "orders"})
+    if err == nil {
+        t.Fatal("Ошибка хранилища должна прерывать повтор")
+    }
+    if stats.Saved != 0 {
+        t.Errorf("Неожиданный итог: %+v", stats)
+    }
+}
+
+func customerJSON(uid, customer string) string {
+    order := repotest.SampleOrder(uid)
+    order.CustomerID = customer
+    data, _ := json.Marshal(order)
+    return string(data)
+}
+
+func customer(t *testing.T, repo repository.OrderRepository, uid string) string {
+    t.Helper()
+    order, err := repo.GetByUID(context.Background(), uid)
+    if err != nil {
+        t.Fatalf("Заказ %s: %v", uid, err)
+    }
+    return order.CustomerID
+}
+
+func TestRun_Conflict(t *testing.T) {
+    sc := connect(t)
+    ctx := context.Background()
+    publish(t, sc, customerJSON("a", "v1"), customerJSON("b", "v1"), customerJSON("a", "v2"), customerJSON("b", "v2"))
+
+    seeded := func() repository.OrderRepository {
+        repo := repository.NewMemory()
+        order := repotest.SampleOrder("a")
+        order.CustomerID = "v0"
+        if err := repo.Save(ctx, order); err != nil {
+            t.Fatal(err)
+        }
+        return repo
+    }
+
+    repo := seeded()
+    stats, err := Run(ctx, sc, repo, Options{Channel: "orders", Conflict: PolicySkip})
+    if err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    if stats.Saved != 2 || stats.Skipped != 2 {
+        t.Errorf("skip: неожиданный итог %+v", stats)
+    }
+    // a был в хранилище до повтора, b впервые сохранён повтором и дальше обновляется.
+    if got := customer(t, repo, "a"); got != "v0" {
+        t.Errorf("skip: заказ a перезаписан: %s", got)
+    }
+    if got := customer(t, repo, "b"); got != "v2" {
+        t.Errorf("skip: заказ b = %s, ожидалась последняя версия v2", got)
+    }
+
+    repo = seeded()
+    stats, err = Run(ctx, sc, repo, Options{Channel: "orders", Conflict: PolicyOverwrite})
+    if err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    if stats.Saved != 4 || stats.Skipped != 0 {
+        t.Errorf("overwrite: неожиданный итог %+v", stats)
+    }
+    if got := customer(t, repo, "a"); got != "v2" {
+        t.Errorf("overwrite: заказ a = %s, ожидалась v2", got)
+    }
+
+    if _, err := Run(ctx, sc, repo, Options{Channel: "orders", Conflict: "merge"}); err == nil {
+        t.Error("Неизвестная политика должна давать ошибку")
+    }
+}
+
+func TestRun_Progress(t *testing.T) {
+    sc := connect(t)
+    publish(t, sc, orderJSON("a"), orderJSON("b"), orderJSON("c"), orderJSON("d"))
+
+    var reports []Progress
+    _, err := Run(context.Background(), sc, repository.NewMemory(), Options{
+        Channel:       "orders",
+        StartSequence: 2,
+        Progress:      func(p Progress) { reports = append(reports, p) },
+    })
+    if err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    if len(reports) == 0 {
+        t.Fatal("Прогресс не сообщался")
+    }
+    final := reports[len(reports)-1]
+    if final.First != 2 || final.Sequence != 4 || final.Saved != 3 || final.Percent() != 100 {
+        t.Errorf("Неожиданный итоговый прогресс: %+v (%.0f%%)", final, final.Percent())
+    }
+    if s := final.String(); !strings.HasPrefix(s, "4/4 (100%)") {
+        t.Errorf("Неожиданное описание прогресса: %s", s)
+    }
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+    "cmp"
+    "context"
+    "fmt"
+    "slices"
+    "strconv"
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+// AnalyticsFilter - условия отбора заказов для аналитики. Пустые поля не участвуют в отборе.
+type AnalyticsFilter struct {
+    // From и To ограничивают date_created полуинтервалом [From, To).
+    // Заказы без даты создания в аналитику не попадают.
+    From time.Time
+    To   time.Time
+    // Currency - валюта оплаты.
+    Currency        string
+    DeliveryService string
+    Bank            string
+    Provider        string
+    Region          string
+    City            string
+    // Brand оставляет в итогах товаров только товары бренда, а в итогах заказов - заказы,
+    // в которых такой товар есть.
+    Brand string
+}
+
+// Dimension - разрез аналитики: поле, по значениям которого группируются заказы или товары.
+type Dimension string
+
+const (
+    ByDeliveryService Dimension = "delivery_service"
+    ByBank            Dimension = "bank"
+    ByProvider        Dimension = "provider"
+    ByRegion          Dimension = "region"
+    ByCity            Dimension = "city"
+    // ByBrand и ByNmID - разрезы товаров.
+    ByBrand Dimension = "brand"
+    ByNmID  Dimension = "nm_id"
+)
+
+// OrderDimensions и ItemDimensions - разрезы итогов заказов и товаров.
+var (
+    OrderDimensions = []Dimension{ByDeliveryService, ByBank, ByProvider, ByRegion, ByCity}
+    ItemDimensions  = []Dimension{ByBrand, ByNmID}
+)
+
+// OrderTotals - итоги заказов за день (UTC) в одной валюте оплаты с одним значением разреза.
+type OrderTotals struct {
+    Day      time.Time
+    Currency string
+    // Key - значение разреза, без разреза - пустая строка.
+    Key    string
+    Orders int64
+    // Revenue - сумма payment.amount.
+    Revenue models.Amount
+    // Items и Sale - число товаров заказов и сумма их скидок в процентах, для средней скидки.
+    Items int64
+    Sale  int64
+}
+
+// ItemTotals - итоги товаров за день (UTC) в одной валюте оплаты с одним значением разреза.
+type ItemTotals struct {
+    Day      time.Time
+    Currency string
+    Key      string
+    // Units - число товаров, Revenue - сумма их total_price, Sale - сумма скидок в процентах.
+    Units   int64
+    Revenue models.Amount
+    Sale    int64
+}
+
+// AnalyticsRepository реализуют хранилища, умеющие считать итоги для аналитики.
+// Итоги возвращаются по возрастанию дня, валюты и значения разреза.
+type AnalyticsRepository interface {
+    // OrderTotals группирует заказы под фильтром по дню date_created, валюте оплаты
+    // и значению разреза by из OrderDimensions; пустой by - без разреза.
+    OrderTotals(ctx context.Context, filter AnalyticsFilter, by Dimension) ([]OrderTotals, error)
+    // ItemTotals группирует товары заказов под фильтром по дню date_created, валюте оплаты
+    // и значению разреза by из ItemDimensions.
+    ItemTotals(ctx context.Context, filter AnalyticsFilter, by Dimension) ([]ItemTotals, error)
+}
+
+// Day возвращает начало дня t в UTC: дни аналитики считаются в UTC.
+func Day(t time.Time) time.Time {
+    return t.UTC().Truncate(24 * time.Hour)
+}
+
+// CheckDimension проверяет, что by - один из разрезов dimensions.
+func CheckDimension(by Dimension, dimensions []Dimension) error {
+    if !slices.Contains(dimensions, by) {
+        return fmt.Errorf("разрез %q не поддерживается, ожидается один из %v", by, dimensions)
+    }
+    return nil
+}
+
+// matches проверяет условия фильтра на заказ, кроме бренда.
+func (f AnalyticsFilter) matches(order models.Order) bool {
+    created := order.DateCreated
+    switch {
+    case created.IsZero(),
+        !f.From.IsZero() && created.Before(f.From),
+        !f.To.IsZero() && !created.Before(f.To),
+        f.Currency != "" && order.Payment.Currency != f.Currency,
+        f.DeliveryService != "" && order.DeliveryService != f.DeliveryService,
+        f.Bank != "" && order.Payment.Bank != f.Bank,
+        f.Provider != "" && order.Payment.Provider != f.Provider,
+        f.Region != "" && order.Delivery.Region != f.Region,
+        f.City != "" && order.Delivery.City != f.City:
+        return false
+    }
+    return true
+}
+
+// orderKey - значение разреза by у заказа.
+func orderKey(order models.Order, by Dimension) string {
+    switch by {
+    case ByDeliveryService:
+        return order.DeliveryService
+    case ByBank:
+        return order.Payment.Bank
+    case ByProvider:
+        return order.Payment.Provider
+    case ByRegion:
+        return order.Delivery.Region
+    case ByCity:
+        return order.Delivery.City
+    }
+    return ""
+}
+
+// itemKey - значение разреза by у товара.
+func itemKey(item models.Item, by Dimension) string {
+    if by == ByNmID {
+        return strconv.Itoa(item.NmID)
+    }
+    return item.Brand
+}
+
+func compareTotals(dayA, dayB time.Time, currencyA, currencyB, keyA, keyB string) int {
+    if c := dayA.Compare(dayB); c != 0 {
+        return c
+    }
+    return cmp.Or(cmp.Compare(currencyA, currencyB), cmp.Compare(keyA, keyB))
+}
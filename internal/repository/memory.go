@@ -0,0 +1,358 @@
+package repository
+
+import (
+    "context"
+    "maps"
+    "slices"
+    "sort"
+    "sync"
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+// MemoryRepository - хранилище заказов в оперативной памяти с той же семантикой, что и Postgres.
+// Используется в тестах и при локальном запуске без базы данных.
+type MemoryRepository struct {
+    mu      sync.RWMutex
+    orders  map[string]models.Order
+    history map[string][]models.StatusChange
+    version map[string]int64
+}
+
+var (
+    _ StatusRepository    = (*MemoryRepository)(nil)
+    _ VersionedRepository = (*MemoryRepository)(nil)
+    _ AnalyticsRepository = (*MemoryRepository)(nil)
+)
+
+func NewMemory() *MemoryRepository {
+    return &MemoryRepository{
+        orders:  make(map[string]models.Order),
+        history: make(map[string][]models.StatusChange),
+        version: make(map[string]int64),
+    }
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, order models.Order) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    // Время хранится в UTC, как его вернёт колонка TIMESTAMPTZ.
+    stored := cloneOrder(order)
+    stored.DateCreated = order.DateCreated.UTC()
+    stored.Payment.PaymentDt = order.Payment.PaymentDt.UTC()
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var old *models.Order
+    if previous, ok := r.orders[order.OrderUID]; ok {
+        if r.version[order.OrderUID] > 1 {
+            return ErrSuperseded
+        }
+        old = &previous
+    }
+    r.recordStatus(models.StatusChanges(old, stored), models.StatusSourceMessage, "")
+    r.orders[order.OrderUID] = stored
+    if old == nil {
+        r.version[order.OrderUID] = 1
+    }
+    return nil
+}
+
+func (r *MemoryRepository) Version(ctx context.Context, uid string) (int64, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    version, ok := r.version[uid]
+    if !ok {
+        return 0, ErrNotFound
+    }
+    return version, nil
+}
+
+func (r *MemoryRepository) Update(ctx context.Context, uid string, version int64, update Update) (models.Order, error) {
+    if err := ctx.Err(); err != nil {
+        return models.Order{}, err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored, ok := r.orders[uid]
+    if !ok {
+        return models.Order{}, ErrNotFound
+    }
+    if r.version[uid] != version {
+        return models.Order{}, ErrVersionConflict
+    }
+    order := cloneOrder(stored)
+    if err := update.Apply(&order); err != nil {
+        return models.Order{}, err
+    }
+    r.recordStatus(models.StatusChanges(&stored, order), update.Source, update.Comment)
+    r.orders[uid] = order
+    r.version[uid]++
+    return cloneOrder(order), nil
+}
+
+func (r *MemoryRepository) ChangeStatus(ctx context.Context, uid string, update StatusUpdate) ([]models.StatusChange, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored, ok := r.orders[uid]
+    if !ok {
+        return nil, ErrNotFound
+    }
+    order := cloneOrder(stored)
+    changes, err := order.ChangeStatus(update.RID, update.Status)
+    if err != nil {
+        return nil, err
+    }
+    r.orders[uid] = order
+    return r.recordStatus(changes, update.Source, update.Comment), nil
+}
+
+func (r *MemoryRepository) StatusHistory(ctx context.Context, uid string) ([]models.StatusChange, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    if _, ok := r.orders[uid]; !ok {
+        return nil, ErrNotFound
+    }
+    return append([]models.StatusChange{}, r.history[uid]...), nil
+}
+
+// recordStatus дописывает переходы в историю. Вызывается под r.mu.
+func (r *MemoryRepository) recordStatus(changes []models.StatusChange, source, comment string) []models.StatusChange {
+    now := time.Now().UTC()
+    for i := range changes {
+        changes[i].Source = source
+        changes[i].Comment = comment
+        changes[i].ChangedAt = now
+        r.history[changes[i].OrderUID] = append(r.history[changes[i].OrderUID], changes[i])
+    }
+    return changes
+}
+
+func (r *MemoryRepository) GetByUID(ctx context.Context, uid string) (models.Order, error) {
+    if err := ctx.Err(); err != nil {
+        return models.Order{}, err
+    }
+
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    order, ok := r.orders[uid]
+    if !ok {
+        return models.Order{}, ErrNotFound
+    }
+    return cloneOrder(order), nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, filter ListFilter) ([]models.Order, error) {
+    orders := []models.Order{}
+    err := r.Stream(ctx, filter, func(order models.Order) error {
+        orders = append(orders, order)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return orders, nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, uid string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, ok := r.orders[uid]; !ok {
+        return ErrNotFound
+    }
+    delete(r.orders, uid)
+    delete(r.history, uid)
+    delete(r.version, uid)
+    return nil
+}
+
+func (r *MemoryRepository) Stream(ctx context.Context, filter ListFilter, fn func(models.Order) error) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    // Снимок берётся под блокировкой, а fn вызывается без неё,
+    // чтобы обработчик мог обращаться к хранилищу.
+    r.mu.RLock()
+    matched := make([]models.Order, 0, len(r.orders))
+    for _, order := range r.orders {
+        if filter.matches(order) {
+            matched = append(matched, cloneOrder(order))
+        }
+    }
+    r.mu.RUnlock()
+
+    sort.Slice(matched, func(i, j int) bool {
+        return matched[i].OrderUID < matched[j].OrderUID
+    })
+
+    if filter.Offset > 0 {
+        if filter.Offset >= len(matched) {
+            return nil
+        }
+        matched = matched[filter.Offset:]
+    }
+    if filter.Limit > 0 && filter.Limit < len(matched) {
+        matched = matched[:filter.Limit]
+    }
+
+    for _, order := range matched {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if err := fn(order); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (f ListFilter) matches(order models.Order) bool {
+    if f.CustomerID != "" && order.CustomerID != f.CustomerID {
+        return false
+    }
+    if f.DeliveryService != "" && order.DeliveryService != f.DeliveryService {
+        return false
+    }
+    if f.Locale != "" && order.Locale != f.Locale {
+        return false
+    }
+    if !f.CreatedFrom.IsZero() || !f.CreatedTo.IsZero() {
+        created := order.DateCreated
+        if created.IsZero() {
+            return false
+        }
+        if !f.CreatedFrom.IsZero() && created.Before(f.CreatedFrom) {
+            return false
+        }
+        if !f.CreatedTo.IsZero() && !created.Before(f.CreatedTo) {
+            return false
+        }
+    }
+    return true
+}
+
+func (r *MemoryRepository) OrderTotals(ctx context.Context, filter AnalyticsFilter, by Dimension) ([]OrderTotals, error) {
+    if by != "" {
+        if err := CheckDimension(by, OrderDimensions); err != nil {
+            return nil, err
+        }
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    groups := make(map[OrderTotals]*OrderTotals)
+    for _, order := range r.orders {
+        if !filter.matches(order) {
+            continue
+        }
+        if filter.Brand != "" && !slices.ContainsFunc(order.Items, func(item models.Item) bool { return item.Brand == filter.Brand }) {
+            continue
+        }
+        group := OrderTotals{Day: Day(order.DateCreated), Currency: order.Payment.Currency, Key: orderKey(order, by)}
+        totals, ok := groups[group]
+        if !ok {
+            totals = &group
+            groups[group] = totals
+        }
+        totals.Orders++
+        totals.Revenue += order.Payment.Amount
+        for _, item := range order.Items {
+            totals.Items++
+            totals.Sale += int64(item.Sale)
+        }
+    }
+
+    result := make([]OrderTotals, 0, len(groups))
+    for _, totals := range groups {
+        result = append(result, *totals)
+    }
+    slices.SortFunc(result, func(a, b OrderTotals) int {
+        return compareTotals(a.Day, b.Day, a.Currency, b.Currency, a.Key, b.Key)
+    })
+    return result, nil
+}
+
+func (r *MemoryRepository) ItemTotals(ctx context.Context, filter AnalyticsFilter, by Dimension) ([]ItemTotals, error) {
+    if err := CheckDimension(by, ItemDimensions); err != nil {
+        return nil, err
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    groups := make(map[ItemTotals]*ItemTotals)
+    for _, order := range r.orders {
+        if !filter.matches(order) {
+            continue
+        }
+        for _, item := range order.Items {
+            if filter.Brand != "" && item.Brand != filter.Brand {
+                continue
+            }
+            group := ItemTotals{Day: Day(order.DateCreated), Currency: order.Payment.Currency, Key: itemKey(item, by)}
+            totals, ok := groups[group]
+            if !ok {
+                totals = &group
+                groups[group] = totals
+            }
+            totals.Units++
+            totals.Revenue += item.TotalPrice
+            totals.Sale += int64(item.Sale)
+        }
+    }
+
+    result := make([]ItemTotals, 0, len(groups))
+    for _, totals := range groups {
+        result = append(result, *totals)
+    }
+    slices.SortFunc(result, func(a, b ItemTotals) int {
+        return compareTotals(a.Day, b.Day, a.Currency, b.Currency, a.Key, b.Key)
+    })
+    return result, nil
+}
+
+func cloneOrder(order models.Order) models.Order {
+    items := make([]models.Item, len(order.Items))
+    copy(items, order.Items)
+    for i := range items {
+        items[i].Extra = maps.Clone(items[i].Extra)
+    }
+    order.Items = items
+    order.Extra = maps.Clone(order.Extra)
+    order.Delivery.Extra = maps.Clone(order.Delivery.Extra)
+    order.Payment.Extra = maps.Clone(order.Payment.Extra)
+    return order
+}
@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+    "testing"
+
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+    repotest.Run(t, func(t *testing.T) repository.OrderRepository {
+        return repository.NewMemory()
+    })
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "wb-order-hub/internal/models"
+)
+
+// ErrNotFound возвращается, если заказа с указанным order_uid нет в хранилище.
+var ErrNotFound = errors.New("заказ не найден")
+
+// OrderRepository - хранилище заказов.
+//
+// Save работает как upsert: повторное сохранение заказа с тем же order_uid
+// полностью заменяет его данные, включая доставку, оплату и список товаров.
+type OrderRepository interface {
+    Save(ctx context.Context, order models.Order) error
+    GetByUID(ctx context.Context, uid string) (models.Order, error)
+    List(ctx context.Context, filter ListFilter) ([]models.Order, error)
+    Delete(ctx context.Context, uid string) error
+    // Stream последовательно передаёт в fn все заказы, подходящие под фильтр,
+    // не загружая их в память целиком. Ошибка из fn прерывает обход и возвращается как есть.
+    Stream(ctx context.Context, filter ListFilter, fn func(models.Order) error) error
+}
+
+// ErrVersionConflict возвращается, если версия заказа изменилась с момента её чтения.
+var ErrVersionConflict = errors.New("версия заказа изменилась")
+
+// ErrSuperseded возвращает Save версионируемого хранилища, если к сохранённому заказу уже
+// применены события: полный заказ старше них и не записывается, чтобы не откатить их.
+var ErrSuperseded = errors.New("заказ уже изменён событиями")
+
+// Update - изменение сохранённого заказа.
+type Update struct {
+    // Apply меняет заказ. Ошибка отменяет изменение и возвращается из Update как есть.
+    Apply func(order *models.Order) error
+    // Source и Comment записываются в историю вместе с переходами статусов, сделанными Apply.
+    Source  string
+    Comment string
+}
+
+// VersionedRepository реализуют хранилища, ведущие версию заказа для оптимистичной блокировки.
+// Новый заказ получает версию 1, каждый Update увеличивает её на единицу. Save существующего
+// заказа версии 1 перезаписывает его, не меняя версию, поэтому повторная доставка полного заказа
+// безопасна; заказ версии выше 1 Save не трогает и возвращает ErrSuperseded. Версию не меняет
+// и StatusRepository.ChangeStatus: версия считает события производителя, а не правки через API.
+type VersionedRepository interface {
+    // Version возвращает текущую версию заказа или ErrNotFound.
+    Version(ctx context.Context, uid string) (int64, error)
+    // Update применяет изменение к заказу, если он всё ещё в версии version, и сохраняет
+    // его с версией version+1. Иначе возвращает ErrVersionConflict или ErrNotFound.
+    Update(ctx context.Context, uid string, version int64, update Update) (models.Order, error)
+}
+
+// StatusUpdate - запрос на смену статуса товаров заказа.
+type StatusUpdate struct {
+    // RID - товар; пустой RID переводит все товары заказа (см. models.Order.ChangeStatus).
+    RID     string
+    Status  models.ItemStatus
+    Source  string
+    Comment string
+}
+
+// StatusRepository реализуют хранилища, ведущие историю статусов товаров.
+// Их Save записывает в историю переходы, принесённые полным заказом, с источником
+// models.StatusSourceMessage.
+type StatusRepository interface {
+    // ChangeStatus меняет статусы товаров и записывает переходы в историю в одной транзакции.
+    // Возвращает ErrNotFound, models.ErrItemNotFound или *models.TransitionError.
+    ChangeStatus(ctx context.Context, uid string, update StatusUpdate) ([]models.StatusChange, error)
+    // StatusHistory возвращает историю статусов заказа от первых переходов к последним.
+    StatusHistory(ctx context.Context, uid string) ([]models.StatusChange, error)
+}
+
+// ChildRows - строки одной из таблиц частей заказа: delivery, payment или items.
+type ChildRows struct {
+    Table    string `json:"table"`
+    OrderUID string `json:"order_uid"`
+    Rows     int    `json:"rows"`
+}
+
+// IntegrityChecker реализуют хранилища, в которых доставка, оплата и товары лежат
+// отдельно от заказа и могут с ним разойтись.
+type IntegrityChecker interface {
+    // OrphanRows возвращает строки частей заказа, для которых нет заказа.
+    OrphanRows(ctx context.Context) ([]ChildRows, error)
+    // MissingRows возвращает заказы под фильтром без доставки, оплаты или товаров, Rows = 0.
+    MissingRows(ctx context.Context, filter ListFilter) ([]ChildRows, error)
+    // DeleteOrphanRows удаляет строки частей заказа без заказа и возвращает их число.
+    DeleteOrphanRows(ctx context.Context) (int64, error)
+}
+
+// ListFilter - условия выборки заказов. Пустые поля не участвуют в отборе.
+// Заказы возвращаются в порядке возрастания order_uid.
+type ListFilter struct {
+    CustomerID      string
+    DeliveryService string
+    Locale          string
+    // CreatedFrom и CreatedTo ограничивают date_created полуинтервалом [CreatedFrom, CreatedTo).
+    // Заказы без даты создания под такой фильтр не попадают.
+    CreatedFrom time.Time
+    CreatedTo   time.Time
+    Limit       int
+    Offset      int
+}
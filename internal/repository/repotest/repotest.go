@@ -0,0 +1,591 @@
+// Package repotest содержит общий набор тестов, которому должна соответствовать
+// любая реализация repository.OrderRepository.
+package repotest
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "reflect"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// SampleOrder возвращает заказ из model.json с указанным order_uid.
+func SampleOrder(uid string) models.Order {
+    return models.Order{
+        OrderUID:    uid,
+        TrackNumber: "WBILMTESTTRACK",
+        Entry:       "WBIL",
+        Delivery: models.Delivery{
+            Name:    "Test Testov",
+            Phone:   "+9720000000",
+            Zip:     "2639809",
+            City:    "Kiryat Mozkin",
+            Address: "Ploshad Mira 15",
+            Region:  "Kraiot",
+            Email:   "test@gmail.com",
+        },
+        Payment: models.Payment{
+            Transaction:  uid,
+            Currency:     "USD",
+            Provider:     "wbpay",
+            Amount:       1817,
+            PaymentDt:    time.Date(2021, 11, 26, 6, 22, 7, 0, time.UTC),
+            Bank:         "alpha",
+            DeliveryCost: 1500,
+            GoodsTotal:   317,
+        },
+        Items: []models.Item{
+            {
+                ChrtID:      9934930,
+                TrackNumber: "WBILMTESTTRACK",
+                Price:       453,
+                RID:         "ab4219087a764ae0btest",
+                Name:        "Mascaras",
+                Sale:        30,
+                Size:        "0",
+                TotalPrice:  317,
+                NmID:        2389212,
+                Brand:       "Vivienne Sabo",
+                Status:      202,
+            },
+        },
+        Locale:          "en",
+        CustomerID:      "test",
+        DeliveryService: "meest",
+        Shardkey:        "9",
+        SmID:            99,
+        DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+        OofShard:        "1",
+    }
+}
+
+// Run прогоняет набор тестов на хранилище. newRepo должен возвращать пустое хранилище.
+func Run(t *testing.T, newRepo func(t *testing.T) repository.OrderRepository) {
+    tests := []struct {
+        name string
+        fn   func(t *testing.T, repo repository.OrderRepository)
+    }{
+        {"SaveAndGet", testSaveAndGet},
+        {"GetNotFound", testGetNotFound},
+        {"Upsert", testUpsert},
+        {"Time", testTime},
+        {"Delete", testDelete},
+        {"ListFilters", testListFilters},
+        {"ListPagination", testListPagination},
+        {"Stream", testStream},
+        {"CanceledContext", testCanceledContext},
+        {"StatusHistory", testStatusHistory},
+        {"Versions", testVersions},
+        {"Extra", testExtra},
+        {"Analytics", testAnalytics},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.fn(t, newRepo(t))
+        })
+    }
+}
+
+func testSaveAndGet(t *testing.T, repo repository.OrderRepository) {
+    ctx := context.Background()
+    order := SampleOrder("order-1")
+
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+
+    got, err := repo.GetByUID(ctx, "order-1")
+    if err != nil {
+        t.Fatalf("GetByUID: %v", err)
+    }
+    if !reflect.DeepEqual(got, order) {
+        t.Errorf("Заказ изменился при сохранении:\nполучили %+v\nожидали  %+v", got, order)
+    }
+
+    got.Items[0].Name = "changed"
+    again, _ := repo.GetByUID(ctx, "order-1")
+    if again.Items[0].Name != "Mascaras" {
+        t.Error("Изменение возвращённого заказа не должно влиять на хранилище")
+    }
+}
+
+func testGetNotFound(t *testing.T, repo repository.OrderRepository) {
+    _, err := repo.GetByUID(context.Background(), "missing")
+    if !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Ожидалась ошибка ErrNotFound, получили %v", err)
+    }
+}
+
+func testUpsert(t *testing.T, repo repository.OrderRepository) {
+    ctx := context.Background()
+    order := SampleOrder("order-1")
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+
+    order.TrackNumber = "NEWTRACK"
+    order.Delivery.City = "Moscow"
+    order.Payment.Amount = 2000
+    second := order.Items[0]
+    second.ChrtID = 1
+    second.Name = "Lipstick"
+    order.Items = append(order.Items, second)
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Повторный Save: %v", err)
+    }
+
+    got, err := repo.GetByUID(ctx, "order-1")
+    if err != nil {
+        t.Fatalf("GetByUID: %v", err)
+    }
+    if !reflect.DeepEqual(got, order) {
+        t.Errorf("Повторное сохранение должно заменить заказ:\nполучили %+v\nожидали  %+v", got, order)
+    }
+
+    order.Items = order.Items[:1]
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Третий Save: %v", err)
+    }
+    got, _ = repo.GetByUID(ctx, "order-1")
+    if len(got.Items) != 1 {
+        t.Errorf("Ожидался 1 товар после замены, получили %d", len(got.Items))
+    }
+}
+
+// testTime проверяет, что время читается в UTC и без даты создания заказ сохраняется.
+func testTime(t *testing.T, repo repository.OrderRepository) {
+    ctx := context.Background()
+    order := SampleOrder("order-1")
+    moscow := time.FixedZone("MSK", 3*60*60)
+    order.DateCreated = time.Date(2021, 11, 26, 9, 22, 19, 0, moscow)
+    order.Payment.PaymentDt = time.Date(2021, 11, 26, 9, 22, 7, 0, moscow)
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    got, _ := repo.GetByUID(ctx, "order-1")
+    if want := SampleOrder("order-1"); got.DateCreated != want.DateCreated || got.Payment.PaymentDt != want.Payment.PaymentDt {
+        t.Errorf("Время должно читаться в UTC: %v, %v", got.DateCreated, got.Payment.PaymentDt)
+    }
+
+    order.DateCreated = time.Time{}
+    order.Payment.PaymentDt = time.Time{}
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Заказ без даты создания должен сохраняться: %v", err)
+    }
+    got, _ = repo.GetByUID(ctx, "order-1")
+    if !got.DateCreated.IsZero() || !got.Payment.PaymentDt.IsZero() {
+        t.Errorf("Ожидалось нулевое время, получили %v, %v", got.DateCreated, got.Payment.PaymentDt)
+    }
+}
+
+func testDelete(t *testing.T, repo repository.OrderRepository) {
+    ctx := context.Background()
+    if err := repo.Save(ctx, SampleOrder("order-1")); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+
+    if err := repo.Delete(ctx, "order-1"); err != nil {
+        t.Fatalf("Delete: %v", err)
+    }
+    if _, err := repo.GetByUID(ctx, "order-1"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Удалённый заказ не должен находиться, получили %v", err)
+    }
+    if err := repo.Delete(ctx, "order-1"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Повторное удаление должно вернуть ErrNotFound, получили %v", err)
+    }
+}
+
+func seed(t *testing.T, repo repository.OrderRepository) {
+    t.Helper()
+    variants := []struct {
+        uid, customer, service, locale, created string
+    }{
+        {"a", "alice", "meest", "en", "2021-11-01T10:00:00Z"},
+        {"b", "bob", "meest", "ru", "2021-11-15T10:00:00Z"},
+        {"c", "alice", "cdek", "ru", "2021-12-01T00:00:00Z"},
+        {"d", "carol", "cdek", "en", "2021-12-20T10:00:00Z"},
+        {"e", "alice", "meest", "en", ""},
+    }
+    for _, v := range variants {
+        order := SampleOrder(v.uid)
+        order.CustomerID = v.customer
+        order.DeliveryService = v.service
+        order.Locale = v.locale
+        order.DateCreated, _ = models.ParseTime(v.created)
+        if err := repo.Save(context.Background(), order); err != nil {
+            t.Fatalf("Save %s: %v", v.uid, err)
+        }
+    }
+}
+
+func uids(orders []models.Order) []string {
+    result := make([]string, len(orders))
+    for i, order := range orders {
+        result[i] = order.OrderUID
+    }
+    return result
+}
+
+func testListFilters(t *testing.T, repo repository.OrderRepository) {
+    seed(t, repo)
+
+    tests := []struct {
+        name   string
+        filter repository.ListFilter
+        want   []string
+    }{
+        {"Все", repository.ListFilter{}, []string{"a", "b", "c", "d", "e"}},
+        {"Клиент", repository.ListFilter{CustomerID: "alice"}, []string{"a", "c", "e"}},
+        {"Служба доставки", repository.ListFilter{DeliveryService: "cdek"}, []string{"c", "d"}},
+        {"Локаль", repository.ListFilter{Locale: "en", CustomerID: "alice"}, []string{"a", "e"}},
+        {"Дата с", repository.ListFilter{CreatedFrom: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)}, []string{"c", "d"}},
+        {"Дата по", repository.ListFilter{CreatedTo: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)}, []string{"a", "b"}},
+        {"Нет совпадений", repository.ListFilter{CustomerID: "nobody"}, []string{}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            orders, err := repo.List(context.Background(), tt.filter)
+            if err != nil {
+                t.Fatalf("List: %v", err)
+            }
+            if got := uids(orders); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("Получили %v, ожидали %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func testListPagination(t *testing.T, repo repository.OrderRepository) {
+    seed(t, repo)
+
+    orders, err := repo.List(context.Background(), repository.ListFilter{Limit: 2, Offset: 1})
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    if got, want := uids(orders), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("Получили %v, ожидали %v", got, want)
+    }
+
+    orders, err = repo.List(context.Background(), repository.ListFilter{Offset: 10})
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    if len(orders) != 0 {
+        t.Errorf("Ожидался пустой список, получили %v", uids(orders))
+    }
+}
+
+func testStream(t *testing.T, repo repository.OrderRepository) {
+    seed(t, repo)
+    ctx := context.Background()
+
+    var seen []string
+    err := repo.Stream(ctx, repository.ListFilter{CustomerID: "alice"}, func(order models.Order) error {
+        if len(order.Items) != 1 {
+            t.Errorf("Заказ %s передан без товаров", order.OrderUID)
+        }
+        seen = append(seen, order.OrderUID)
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("Stream: %v", err)
+    }
+    if want := []string{"a", "c", "e"}; !reflect.DeepEqual(seen, want) {
+        t.Errorf("Получили %v, ожидали %v", seen, want)
+    }
+
+    stop := errors.New("stop")
+    calls := 0
+    err = repo.Stream(ctx, repository.ListFilter{}, func(models.Order) error {
+        calls++
+        return stop
+    })
+    if !errors.Is(err, stop) {
+        t.Errorf("Ожидалась ошибка из обработчика, получили %v", err)
+    }
+    if calls != 1 {
+        t.Errorf("После ошибки обход должен прекратиться, вызовов: %d", calls)
+    }
+}
+
+func testCanceledContext(t *testing.T, repo repository.OrderRepository) {
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    if err := repo.Save(ctx, SampleOrder("order-1")); err == nil {
+        t.Error("Save с отменённым контекстом должен вернуть ошибку")
+    }
+    if _, err := repo.GetByUID(ctx, "order-1"); err == nil || errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("GetByUID с отменённым контекстом должен вернуть ошибку контекста, получили %v", err)
+    }
+}
+
+func testStatusHistory(t *testing.T, repo repository.OrderRepository) {
+    statuses, ok := repo.(repository.StatusRepository)
+    if !ok {
+        t.Skip("Хранилище не ведёт историю статусов")
+    }
+    ctx := context.Background()
+    order := SampleOrder("order-1")
+    order.Items[0].Status = models.ItemAccepted
+    second := order.Items[0]
+    second.RID = "second"
+    order.Items = append(order.Items, second)
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+
+    changes, err := statuses.ChangeStatus(ctx, "order-1", repository.StatusUpdate{RID: "second", Status: models.ItemAssembled, Source: models.StatusSourceAPI, Comment: "склад"})
+    if err != nil || len(changes) != 1 || changes[0].OrderTo != models.OrderAssembling || changes[0].ChangedAt.IsZero() {
+        t.Fatalf("ChangeStatus: %+v, %v", changes, err)
+    }
+    if got, _ := repo.GetByUID(ctx, "order-1"); got.Items[1].Status != models.ItemAssembled {
+        t.Errorf("Смена статуса не видна при чтении заказа: %+v", got.Items)
+    }
+    var transition *models.TransitionError
+    if _, err := statuses.ChangeStatus(ctx, "order-1", repository.StatusUpdate{Status: models.ItemDelivered}); !errors.As(err, &transition) {
+        t.Errorf("Ожидалась ошибка перехода, получили %v", err)
+    }
+    if _, err := statuses.ChangeStatus(ctx, "order-1", repository.StatusUpdate{RID: "missing", Status: models.ItemCancelled}); !errors.Is(err, models.ErrItemNotFound) {
+        t.Errorf("Ожидалась ErrItemNotFound, получили %v", err)
+    }
+    if _, err := statuses.ChangeStatus(ctx, "missing", repository.StatusUpdate{Status: models.ItemCancelled}); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Ожидалась ErrNotFound, получили %v", err)
+    }
+
+    // Полный заказ из канала приносит свои статусы, они тоже попадают в историю.
+    order.Items[0].Status = models.ItemCancelled
+    order.Items[1].Status = models.ItemAssembled
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    got, _ := repo.GetByUID(ctx, "order-1")
+    if got.Status() != models.OrderAssembling {
+        t.Errorf("Статус заказа %s, ожидался %s", got.Status(), models.OrderAssembling)
+    }
+
+    history, err := statuses.StatusHistory(ctx, "order-1")
+    if err != nil {
+        t.Fatalf("StatusHistory: %v", err)
+    }
+    type step struct {
+        rid      string
+        from, to models.ItemStatus
+        source   string
+    }
+    want := []step{
+        {order.Items[0].RID, 0, models.ItemAccepted, models.StatusSourceMessage},
+        {"second", 0, models.ItemAccepted, models.StatusSourceMessage},
+        {"second", models.ItemAccepted, models.ItemAssembled, models.StatusSourceAPI},
+        {order.Items[0].RID, models.ItemAccepted, models.ItemCancelled, models.StatusSourceMessage},
+    }
+    if len(history) != len(want) {
+        t.Fatalf("Ожидалось %d переходов, получили %+v", len(want), history)
+    }
+    for i, w := range want {
+        h := history[i]
+        if h.RID != w.rid || h.From != w.from || h.To != w.to || h.Source != w.source {
+            t.Errorf("Переход %d: %+v, ожидался %+v", i, h, w)
+        }
+    }
+    if history[2].Comment != "склад" {
+        t.Errorf("Комментарий не сохранён: %+v", history[2])
+    }
+
+    if _, err := statuses.StatusHistory(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Ожидалась ErrNotFound, получили %v", err)
+    }
+}
+
+func testVersions(t *testing.T, repo repository.OrderRepository) {
+    versioned, ok := repo.(repository.VersionedRepository)
+    if !ok {
+        t.Skip("Хранилище не ведёт версии заказов")
+    }
+    ctx := context.Background()
+    if _, err := versioned.Version(ctx, "order-1"); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Ожидалась ErrNotFound, получили %v", err)
+    }
+    order := SampleOrder("order-1")
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    if version, err := versioned.Version(ctx, "order-1"); err != nil || version != 1 {
+        t.Fatalf("Новый заказ должен получить версию 1, получили %d, %v", version, err)
+    }
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Повторное сохранение заказа без событий: %v", err)
+    }
+    if version, _ := versioned.Version(ctx, "order-1"); version != 1 {
+        t.Errorf("Повторное сохранение не должно менять версию, получили %d", version)
+    }
+
+    setAmount := func(amount models.Amount) repository.Update {
+        return repository.Update{Apply: func(o *models.Order) error {
+            o.Payment.Amount = amount
+            o.Items[0].Status = models.ItemDelivered
+            return nil
+        }, Source: "event", Comment: "вручен"}
+    }
+    updated, err := versioned.Update(ctx, "order-1", 1, setAmount(2000))
+    if err != nil || updated.Payment.Amount != 2000 {
+        t.Fatalf("Update: %+v, %v", updated.Payment, err)
+    }
+    if _, err := versioned.Update(ctx, "order-1", 1, setAmount(3000)); !errors.Is(err, repository.ErrVersionConflict) {
+        t.Errorf("Ожидалась ErrVersionConflict, получили %v", err)
+    }
+    if _, err := versioned.Update(ctx, "missing", 1, setAmount(3000)); !errors.Is(err, repository.ErrNotFound) {
+        t.Errorf("Ожидалась ErrNotFound, получили %v", err)
+    }
+    failed := errors.New("событие не применимо")
+    if _, err := versioned.Update(ctx, "order-1", 2, repository.Update{Apply: func(o *models.Order) error {
+        o.Payment.Amount = 0
+        return failed
+    }}); !errors.Is(err, failed) {
+        t.Errorf("Ожидалась ошибка Apply, получили %v", err)
+    }
+
+    got, err := repo.GetByUID(ctx, "order-1")
+    if err != nil || got.Payment.Amount != 2000 || got.Items[0].Status != models.ItemDelivered {
+        t.Errorf("Неожиданный заказ после Update: %+v, %v", got, err)
+    }
+    if statuses, ok := repo.(repository.StatusRepository); ok {
+        history, _ := statuses.StatusHistory(ctx, "order-1")
+        if len(history) < 2 || history[1].Source != "event" || history[1].Comment != "вручен" {
+            t.Errorf("Переход из Update не записан в историю: %+v", history)
+        }
+    }
+
+    // Save после Update: повторно доставленный полный заказ старше события и не откатывает его.
+    if err := repo.Save(ctx, order); !errors.Is(err, repository.ErrSuperseded) {
+        t.Fatalf("Ожидалась ErrSuperseded, получили %v", err)
+    }
+    got, err = repo.GetByUID(ctx, "order-1")
+    if err != nil || got.Payment.Amount != 2000 || got.Items[0].Status != models.ItemDelivered {
+        t.Errorf("Save после Update откатил событие: %+v, %v", got, err)
+    }
+    if version, _ := versioned.Version(ctx, "order-1"); version != 2 {
+        t.Errorf("Save после Update не должен менять версию, получили %d", version)
+    }
+    if statuses, ok := repo.(repository.StatusRepository); ok {
+        history, _ := statuses.StatusHistory(ctx, "order-1")
+        for _, change := range history {
+            if change.Source == models.StatusSourceMessage && change.From == models.ItemDelivered {
+                t.Errorf("Save после Update записал в историю откат статуса: %+v", history)
+            }
+        }
+    }
+}
+
+// testExtra: неизвестные поля сообщения сохраняются вместе с заказом.
+func testExtra(t *testing.T, repo repository.OrderRepository) {
+    ctx := context.Background()
+    order := SampleOrder("extra")
+    order.Extra = models.Extra{"loyalty": json.RawMessage(`{"points":10,"tier":"gold"}`)}
+    order.Delivery.Extra = models.Extra{"floor": json.RawMessage(`5`)}
+    order.Payment.Extra = models.Extra{"installments": json.RawMessage(`[1,2]`)}
+    order.Items[0].Extra = models.Extra{"color": json.RawMessage(`"red"`)}
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    got, err := repo.GetByUID(ctx, "extra")
+    if err != nil {
+        t.Fatalf("GetByUID: %v", err)
+    }
+    if !reflect.DeepEqual(got, order) {
+        t.Errorf("Неизвестные поля изменились:\nполучили %+v\nожидали  %+v", got, order)
+    }
+
+    order.Extra = nil
+    order.Items[0].Extra = nil
+    if err := repo.Save(ctx, order); err != nil {
+        t.Fatalf("Повторный Save: %v", err)
+    }
+    if got, _ := repo.GetByUID(ctx, "extra"); got.Extra != nil || got.Items[0].Extra != nil || got.Delivery.Extra == nil {
+        t.Errorf("Повторное сохранение должно заменить неизвестные поля: %+v", got)
+    }
+}
+
+// analyticsOrders - заказы для проверки итогов: два дня, две валюты, два бренда.
+func analyticsOrders() []models.Order {
+    moscow := time.FixedZone("MSK", 3*60*60)
+    first := SampleOrder("a-1")
+    first.DateCreated = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+    first.Items = append(first.Items, first.Items[0])
+    first.Items[1].RID, first.Items[1].Brand, first.Items[1].NmID, first.Items[1].TotalPrice, first.Items[1].Sale = "a-1-2", "Nivea", 1, 100, 10
+
+    // 02:00 по Москве 2 марта - ещё 1 марта в UTC.
+    second := SampleOrder("a-2")
+    second.DateCreated = time.Date(2024, 3, 2, 2, 0, 0, 0, moscow)
+    second.Payment.Bank, second.Payment.Amount = "sber", 1000
+
+    third := SampleOrder("a-3")
+    third.DateCreated = time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+    third.Payment.Currency, third.Payment.Amount = "RUB", 50000
+    third.Delivery.City = "Moscow"
+
+    // Заказ без даты создания в итоги не попадает.
+    undated := SampleOrder("a-4")
+    undated.DateCreated = time.Time{}
+    return []models.Order{first, second, third, undated}
+}
+
+// testAnalytics: итоги заказов и товаров по дням UTC, валютам и разрезам.
+func testAnalytics(t *testing.T, repo repository.OrderRepository) {
+    totals, ok := repo.(repository.AnalyticsRepository)
+    if !ok {
+        t.Skip("Хранилище не считает итоги")
+    }
+    ctx := context.Background()
+    for _, order := range analyticsOrders() {
+        if err := repo.Save(ctx, order); err != nil {
+            t.Fatalf("Save: %v", err)
+        }
+    }
+    march1, march2 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+    orders, err := totals.OrderTotals(ctx, repository.AnalyticsFilter{}, "")
+    want := []repository.OrderTotals{
+        {Day: march1, Currency: "USD", Orders: 2, Revenue: 2817, Items: 3, Sale: 70},
+        {Day: march2, Currency: "RUB", Orders: 1, Revenue: 50000, Items: 1, Sale: 30},
+    }
+    if err != nil || !reflect.DeepEqual(orders, want) {
+        t.Errorf("OrderTotals:\nполучили %+v, %v\nожидали  %+v", orders, err, want)
+    }
+
+    orders, err = totals.OrderTotals(ctx, repository.AnalyticsFilter{Currency: "USD", Brand: "Nivea"}, repository.ByBank)
+    want = []repository.OrderTotals{{Day: march1, Currency: "USD", Key: "alpha", Orders: 1, Revenue: 1817, Items: 2, Sale: 40}}
+    if err != nil || !reflect.DeepEqual(orders, want) {
+        t.Errorf("OrderTotals по банкам с брендом:\nполучили %+v, %v\nожидали  %+v", orders, err, want)
+    }
+
+    orders, err = totals.OrderTotals(ctx, repository.AnalyticsFilter{From: march2, City: "Moscow"}, repository.ByCity)
+    if err != nil || len(orders) != 1 || orders[0].Key != "Moscow" || orders[0].Orders != 1 {
+        t.Errorf("OrderTotals по городам с фильтром: %+v, %v", orders, err)
+    }
+
+    items, err := totals.ItemTotals(ctx, repository.AnalyticsFilter{To: march2}, repository.ByBrand)
+    wantItems := []repository.ItemTotals{
+        {Day: march1, Currency: "USD", Key: "Nivea", Units: 1, Revenue: 100, Sale: 10},
+        {Day: march1, Currency: "USD", Key: "Vivienne Sabo", Units: 2, Revenue: 634, Sale: 60},
+    }
+    if err != nil || !reflect.DeepEqual(items, wantItems) {
+        t.Errorf("ItemTotals по брендам:\nполучили %+v, %v\nожидали  %+v", items, err, wantItems)
+    }
+
+    items, err = totals.ItemTotals(ctx, repository.AnalyticsFilter{Brand: "Nivea"}, repository.ByNmID)
+    if err != nil || len(items) != 1 || items[0].Key != "1" || items[0].Units != 1 {
+        t.Errorf("ItemTotals по артикулам: %+v, %v", items, err)
+    }
+
+    if _, err := totals.ItemTotals(ctx, repository.AnalyticsFilter{}, repository.ByBank); err == nil {
+        t.Error("Разрез заказов для товаров должен давать ошибку")
+    }
+}
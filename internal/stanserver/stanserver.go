@@ -0,0 +1,70 @@
+// Package stanserver запускает встроенный сервер NATS Streaming внутри процесса.
+package stanserver
+
+import (
+    "fmt"
+
+    stand "github.com/nats-io/nats-streaming-server/server"
+    "github.com/nats-io/nats-streaming-server/stores"
+)
+
+// Options - параметры встроенного сервера.
+type Options struct {
+    ClusterID string
+    Host      string
+    // Port - порт клиентских подключений. Значение -1 выбирает свободный порт.
+    Port int
+    // StoreDir - каталог файлового хранилища каналов. Пустое значение хранит сообщения в памяти.
+    StoreDir string
+    Debug    bool
+}
+
+// Server - запущенный встроенный сервер NATS Streaming.
+type Server struct {
+    stan      *stand.StanServer
+    clusterID string
+}
+
+// Start запускает сервер и дожидается готовности к подключениям.
+func Start(opts Options) (*Server, error) {
+    stanOpts := stand.GetDefaultOptions()
+    if opts.ClusterID != "" {
+        stanOpts.ID = opts.ClusterID
+    }
+    if opts.StoreDir != "" {
+        stanOpts.StoreType = stores.TypeFile
+        stanOpts.FilestoreDir = opts.StoreDir
+    }
+    if opts.Debug {
+        stanOpts.EnableLogging = true
+        stanOpts.Debug = true
+    }
+
+    natsOpts := stand.NewNATSOptions()
+    natsOpts.Host = opts.Host
+    if natsOpts.Host == "" {
+        natsOpts.Host = "127.0.0.1"
+    }
+    natsOpts.Port = opts.Port
+    natsOpts.NoSigs = true
+    natsOpts.NoLog = !opts.Debug
+
+    srv, err := stand.RunServerWithOpts(stanOpts, natsOpts)
+    if err != nil {
+        return nil, fmt.Errorf("не удалось запустить встроенный NATS Streaming: %w", err)
+    }
+    return &Server{stan: srv, clusterID: stanOpts.ID}, nil
+}
+
+// URL возвращает адрес для stan.NatsURL.
+func (s *Server) URL() string {
+    return s.stan.ClientURL()
+}
+
+func (s *Server) ClusterID() string {
+    return s.clusterID
+}
+
+func (s *Server) Shutdown() {
+    s.stan.Shutdown()
+}
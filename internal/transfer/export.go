@@ -0,0 +1,101 @@
+package transfer
+
+import (
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// Форматы выгрузки.
+const (
+    FormatNDJSON = "ndjson"
+    FormatCSV    = "csv"
+)
+
+// Export выгружает заказы, подходящие под filter, в w и возвращает их количество.
+// В NDJSON каждый заказ занимает строку в том же виде, в каком приходит из NATS.
+// В CSV каждая строка - товар вместе с полями заказа, доставки и оплаты; заказ без товаров
+// занимает одну строку с пустыми полями товара.
+func Export(ctx context.Context, repo repository.OrderRepository, w io.Writer, filter repository.ListFilter, format string) (int, error) {
+    var write func(models.Order) error
+    var flush func() error
+
+    switch format {
+    case FormatNDJSON:
+        enc := json.NewEncoder(w)
+        write = func(order models.Order) error { return enc.Encode(order) }
+        flush = func() error { return nil }
+    case FormatCSV:
+        cw := csv.NewWriter(w)
+        if err := cw.Write(csvHeader); err != nil {
+            return 0, err
+        }
+        write = func(order models.Order) error { return writeCSV(cw, order) }
+        flush = func() error {
+            cw.Flush()
+            return cw.Error()
+        }
+    default:
+        return 0, fmt.Errorf("неизвестный формат выгрузки %q, ожидается ndjson или csv", format)
+    }
+
+    count := 0
+    err := repo.Stream(ctx, filter, func(order models.Order) error {
+        count++
+        return write(order)
+    })
+    if err != nil {
+        return count, err
+    }
+    return count, flush()
+}
+
+var csvHeader = []string{
+    "order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+    "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+    "delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+    "delivery_region", "delivery_email",
+    "payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+    "payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+    "payment_goods_total", "payment_custom_fee",
+    "item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale",
+    "item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+// writeCSV пишет заказ строкой на каждый товар. Суммы - в минимальных единицах валюты,
+// дата создания - в RFC3339, payment_dt - unix-временем, как в JSON.
+func writeCSV(w *csv.Writer, o models.Order) error {
+    d, p := o.Delivery, o.Payment
+    base := []string{
+        o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+        o.DeliveryService, o.Shardkey, strconv.Itoa(o.SmID), models.FormatTime(o.DateCreated), o.OofShard,
+        d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+        p.Transaction, p.RequestID, p.Currency, p.Provider, formatAmount(p.Amount),
+        strconv.FormatInt(models.UnixSeconds(p.PaymentDt), 10), p.Bank, formatAmount(p.DeliveryCost),
+        formatAmount(p.GoodsTotal), formatAmount(p.CustomFee),
+    }
+    if len(o.Items) == 0 {
+        return w.Write(append(base, make([]string, len(csvHeader)-len(base))...))
+    }
+    for _, it := range o.Items {
+        row := append(base[:len(base):len(base)],
+            strconv.Itoa(it.ChrtID), it.TrackNumber, formatAmount(it.Price), it.RID, it.Name,
+            strconv.Itoa(it.Sale), it.Size, formatAmount(it.TotalPrice), strconv.Itoa(it.NmID),
+            it.Brand, strconv.Itoa(int(it.Status)),
+        )
+        if err := w.Write(row); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func formatAmount(a models.Amount) string {
+    return strconv.FormatInt(int64(a), 10)
+}
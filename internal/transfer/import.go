@@ -0,0 +1,65 @@
+// Package transfer загружает заказы из файлов в хранилище и выгружает их обратно.
+package transfer
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+
+    "wb-order-hub/internal/envelope"
+    "wb-order-hub/internal/input"
+    "wb-order-hub/internal/repository"
+)
+
+// ImportOptions - параметры загрузки.
+type ImportOptions struct {
+    Format input.Format
+    // Stdin читается для входа "-".
+    Stdin io.Reader
+    // DryRun только проверяет заказы, ничего не сохраняя.
+    DryRun bool
+}
+
+// ImportStats - итог загрузки.
+type ImportStats struct {
+    Imported int
+    // Invalid - сообщения, не прошедшие ту же проверку, что и сообщения из NATS.
+    Invalid int
+    // Superseded - заказы, уже изменённые событиями: полный заказ их не перезаписывает.
+    Superseded int
+}
+
+// Import сохраняет заказы из inputs в repo. Некорректные заказы пропускаются и пишутся в журнал;
+// ошибка хранилища прерывает загрузку.
+func Import(ctx context.Context, repo repository.OrderRepository, inputs []string, opts ImportOptions) (ImportStats, error) {
+    var stats ImportStats
+    for _, name := range inputs {
+        err := input.ReadInput(name, opts.Stdin, opts.Format, func(msg input.Message) error {
+            order, _, err := envelope.ParseOrder(msg.Data)
+            if err != nil {
+                log.Printf("Пропущен некорректный заказ %s: %v", msg.Source, err)
+                stats.Invalid++
+                return nil
+            }
+            if !opts.DryRun {
+                err := repo.Save(ctx, order)
+                if errors.Is(err, repository.ErrSuperseded) {
+                    log.Printf("Пропущен заказ %s: %v", order.OrderUID, err)
+                    stats.Superseded++
+                    return nil
+                }
+                if err != nil {
+                    return fmt.Errorf("%s: не удалось сохранить заказ %s: %w", msg.Source, order.OrderUID, err)
+                }
+            }
+            stats.Imported++
+            return nil
+        })
+        if err != nil {
+            return stats, err
+        }
+    }
+    return stats, nil
+}
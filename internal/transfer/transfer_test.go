@@ -0,0 +1,140 @@
+package transfer
+
+import (
+    "bytes"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/input"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func orderJSON(t *testing.T, uid string) string {
+    t.Helper()
+    data, err := json.Marshal(repotest.SampleOrder(uid))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
+
+func TestImport(t *testing.T) {
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "one.json"), []byte(orderJSON(t, "a")), 0o600)
+    ndjson := orderJSON(t, "b") + "\n" + `{"order_uid":""}` + "\nnot json\n" + orderJSON(t, "c") + "\n"
+    os.WriteFile(filepath.Join(dir, "many.ndjson"), []byte(ndjson), 0o600)
+
+    inputs, err := input.Expand([]string{dir})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    ctx := context.Background()
+    repo := repository.NewMemory()
+    stats, err := Import(ctx, repo, inputs, ImportOptions{Format: input.FormatAuto, DryRun: true})
+    if err != nil {
+        t.Fatalf("Import: %v", err)
+    }
+    if stats.Imported != 3 || stats.Invalid != 2 {
+        t.Errorf("Ожидалось 3 корректных и 2 некорректных заказа, получили %+v", stats)
+    }
+    if orders, _ := repo.List(ctx, repository.ListFilter{}); len(orders) != 0 {
+        t.Errorf("В режиме проверки ничего не должно сохраняться, сохранено %d", len(orders))
+    }
+
+    if _, err := Import(ctx, repo, inputs, ImportOptions{Format: input.FormatAuto}); err != nil {
+        t.Fatalf("Import: %v", err)
+    }
+    orders, _ := repo.List(ctx, repository.ListFilter{})
+    if got := len(orders); got != 3 {
+        t.Errorf("Ожидалось 3 сохранённых заказа, получили %d", got)
+    }
+}
+
+func seed(t *testing.T) repository.OrderRepository {
+    t.Helper()
+    repo := repository.NewMemory()
+    for i, uid := range []string{"a", "b", "c"} {
+        order := repotest.SampleOrder(uid)
+        order.DateCreated = time.Date(2021, 11, 1+i*10, 0, 0, 0, 0, time.UTC)
+        if uid == "c" {
+            order.Items = append(order.Items, order.Items[0])
+            order.Items[1].Name = "Lipstick"
+        }
+        if err := repo.Save(context.Background(), order); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return repo
+}
+
+func TestExport_NDJSON(t *testing.T) {
+    repo := seed(t)
+    var buf bytes.Buffer
+    filter := repository.ListFilter{CreatedFrom: time.Date(2021, 11, 5, 0, 0, 0, 0, time.UTC)}
+    count, err := Export(context.Background(), repo, &buf, filter, FormatNDJSON)
+    if err != nil {
+        t.Fatalf("Export: %v", err)
+    }
+    if count != 2 {
+        t.Errorf("Ожидалось 2 заказа в диапазоне дат, получили %d", count)
+    }
+
+    // Выгрузка снова загружается без потерь.
+    var uids []string
+    err = input.Read(&buf, "export", input.FormatNDJSON, func(msg input.Message) error {
+        order, err := models.ParseOrder(msg.Data)
+        if err != nil {
+            return err
+        }
+        want, _ := repo.GetByUID(context.Background(), order.OrderUID)
+        if !reflect.DeepEqual(order, want) {
+            t.Errorf("Заказ %s изменился при выгрузке", order.OrderUID)
+        }
+        uids = append(uids, order.OrderUID)
+        return nil
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(uids, []string{"b", "c"}) {
+        t.Errorf("Получили %v", uids)
+    }
+}
+
+func TestExport_CSV(t *testing.T) {
+    repo := seed(t)
+    var buf bytes.Buffer
+    if _, err := Export(context.Background(), repo, &buf, repository.ListFilter{}, FormatCSV); err != nil {
+        t.Fatalf("Export: %v", err)
+    }
+
+    rows, err := csv.NewReader(&buf).ReadAll()
+    if err != nil {
+        t.Fatalf("Некорректный CSV: %v", err)
+    }
+    if !reflect.DeepEqual(rows[0], csvHeader) {
+        t.Errorf("Неожиданный заголовок: %v", rows[0])
+    }
+    // По строке на товар: a и b по одному, c - два.
+    if len(rows) != 5 {
+        t.Fatalf("Ожидалось 4 строки товаров, получили %d", len(rows)-1)
+    }
+    last := rows[4]
+    if last[0] != "c" || last[len(last)-7] != "Lipstick" {
+        t.Errorf("Неожиданная строка: %v", last)
+    }
+
+    if _, err := Export(context.Background(), repo, &buf, repository.ListFilter{}, "xml"); err == nil || !strings.Contains(err.Error(), "xml") {
+        t.Errorf("Ожидалась ошибка неизвестного формата, получили %v", err)
+    }
+}
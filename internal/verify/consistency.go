@@ -0,0 +1,389 @@
+package verify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/rand/v2"
+    "reflect"
+    "sort"
+    "strconv"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// Source возвращает заказ в том виде, в каком его прислал производитель, например
+// из архива исходных сообщений. Если исходного сообщения нет, возвращает repository.ErrNotFound.
+type Source interface {
+    Original(ctx context.Context, uid string) (models.Order, error)
+}
+
+// Cache - кэш заказов сервиса: order_uid -> JSON заказа.
+type Cache interface {
+    Get(key string) (string, bool)
+    Set(key, value string)
+    Delete(key string)
+    Keys() []string
+}
+
+// ConsistencyOptions - что сравнивать и нужно ли исправлять расхождения.
+type ConsistencyOptions struct {
+    Filter repository.ListFilter
+    // Sample - доля проверяемых заказов от 0 до 1; 0 и 1 - проверить все.
+    Sample float64
+    // Checks прогоняются по каждому проверяемому заказу из базы.
+    Checks []Check
+    // Cache сравнивается с базой целиком, без учёта Filter; nil - не сравнивать.
+    Cache Cache
+    // Source - источник истины для базы; nil - сравнивать не с чем. Заказы, к которым уже
+    // применены события, с Source не сравниваются: исходное сообщение старше событий.
+    Source Source
+    // Repair переписывает базу из Source, удаляет строки без заказа и обновляет кэш из базы.
+    Repair bool
+}
+
+// Проверки согласованности в Problem.Check.
+const (
+    CheckSource  = "source"
+    CheckCache   = "cache"
+    CheckOrphan  = "orphan"
+    CheckMissing = "missing"
+)
+
+// Consistency сверяет заказы базы с исходными сообщениями и кэшем, ищет строки доставки,
+// оплаты и товаров без заказа и заказы без них (если repo реализует repository.IntegrityChecker)
+// и прогоняет opts.Checks. С opts.Repair исправимые расхождения исправляются
+// и помечаются в отчёте как Repaired.
+func Consistency(ctx context.Context, repo repository.OrderRepository, opts ConsistencyOptions) (Report, error) {
+    c := &consistency{repo: repo, opts: opts, report: Report{Problems: []Problem{}}, restored: make(map[string]bool)}
+    if checker, ok := repo.(repository.IntegrityChecker); ok {
+        if err := c.checkIntegrity(ctx, checker); err != nil {
+            return c.report, err
+        }
+    }
+    if err := repo.Stream(ctx, opts.Filter, func(order models.Order) error {
+        if !c.sampled() {
+            return nil
+        }
+        return c.checkStored(ctx, order)
+    }); err != nil {
+        return c.report, err
+    }
+    if opts.Cache != nil {
+        for _, uid := range opts.Cache.Keys() {
+            if !c.sampled() {
+                continue
+            }
+            if err := c.checkCached(ctx, uid); err != nil {
+                return c.report, err
+            }
+        }
+    }
+    return c.report, nil
+}
+
+// consistency - состояние одного прогона Consistency.
+type consistency struct {
+    repo   repository.OrderRepository
+    opts   ConsistencyOptions
+    report Report
+    // restored - заказы, уже переписанные из Source: true, если исходное сообщение нашлось.
+    restored map[string]bool
+}
+
+func (c *consistency) sampled() bool {
+    return c.opts.Sample <= 0 || c.opts.Sample >= 1 || rand.Float64() < c.opts.Sample
+}
+
+// add добавляет нарушение в отчёт и возвращает его индекс.
+func (c *consistency) add(uid, check, message string) int {
+    c.report.Problems = append(c.report.Problems, Problem{OrderUID: uid, Check: check, Message: message})
+    return len(c.report.Problems) - 1
+}
+
+func (c *consistency) repaired(indexes ...int) {
+    for _, i := range indexes {
+        c.report.Problems[i].Repaired = true
+        c.report.Repaired++
+    }
+}
+
+// checkIntegrity ищет строки частей заказа без заказа и заказы без частей.
+// Строки без заказа удаляются, заказы без частей восстанавливаются из Source.
+func (c *consistency) checkIntegrity(ctx context.Context, checker repository.IntegrityChecker) error {
+    orphans, err := checker.OrphanRows(ctx)
+    if err != nil {
+        return err
+    }
+    var found []int
+    for _, rows := range orphans {
+        found = append(found, c.add(rows.OrderUID, CheckOrphan, fmt.Sprintf("в таблице %s строк без заказа: %d", rows.Table, rows.Rows)))
+    }
+    if len(found) > 0 && c.opts.Repair {
+        if _, err := checker.DeleteOrphanRows(ctx); err != nil {
+            return err
+        }
+        c.repaired(found...)
+    }
+
+    missing, err := checker.MissingRows(ctx, c.opts.Filter)
+    if err != nil {
+        return err
+    }
+    for _, rows := range missing {
+        i := c.add(rows.OrderUID, CheckMissing, fmt.Sprintf("нет строк в таблице %s", rows.Table))
+        if !c.opts.Repair || c.opts.Source == nil {
+            continue
+        }
+        ok, err := c.restore(ctx, rows.OrderUID)
+        if err != nil {
+            return err
+        }
+        if ok {
+            c.repaired(i)
+        }
+    }
+    return nil
+}
+
+// restore один раз переписывает заказ из Source. Возвращает false, если исходного сообщения нет.
+func (c *consistency) restore(ctx context.Context, uid string) (bool, error) {
+    if ok, seen := c.restored[uid]; seen {
+        return ok, nil
+    }
+    original, err := c.opts.Source.Original(ctx, uid)
+    if errors.Is(err, repository.ErrNotFound) {
+        c.restored[uid] = false
+        return false, nil
+    }
+    if err != nil {
+        return false, fmt.Errorf("исходное сообщение заказа %s: %w", uid, err)
+    }
+    err = c.repo.Save(ctx, original)
+    if errors.Is(err, repository.ErrSuperseded) {
+        c.restored[uid] = false
+        return false, nil
+    }
+    if err != nil {
+        return false, fmt.Errorf("не удалось восстановить заказ %s: %w", uid, err)
+    }
+    c.restored[uid] = true
+    return true, nil
+}
+
+// checkStored прогоняет проверки по заказу из базы и сравнивает его с исходным сообщением.
+func (c *consistency) checkStored(ctx context.Context, order models.Order) error {
+    c.report.Checked++
+    for _, check := range c.opts.Checks {
+        for _, msg := range check.Fn(order) {
+            c.add(order.OrderUID, check.Name, msg)
+        }
+    }
+    if c.opts.Source == nil {
+        return nil
+    }
+    if changed, err := c.changedByEvents(ctx, order.OrderUID); err != nil || changed {
+        return err
+    }
+    original, err := c.opts.Source.Original(ctx, order.OrderUID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("исходное сообщение заказа %s: %w", order.OrderUID, err)
+    }
+    original = keepStatuses(original, order)
+    var found []int
+    for _, d := range Diff(order, original) {
+        found = append(found, c.add(order.OrderUID, CheckSource, d.describe("в исходном сообщении")))
+    }
+    if len(found) > 0 && c.opts.Repair {
+        err := c.repo.Save(ctx, original)
+        if errors.Is(err, repository.ErrSuperseded) {
+            // Событие применено уже после сверки: расхождение остаётся неисправленным.
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("не удалось восстановить заказ %s: %w", order.OrderUID, err)
+        }
+        c.repaired(found...)
+    }
+    return nil
+}
+
+// changedByEvents сообщает, применены ли к заказу события (версия выше 1).
+func (c *consistency) changedByEvents(ctx context.Context, uid string) (bool, error) {
+    versioned, ok := c.repo.(repository.VersionedRepository)
+    if !ok {
+        return false, nil
+    }
+    version, err := versioned.Version(ctx, uid)
+    if errors.Is(err, repository.ErrNotFound) {
+        return false, nil
+    }
+    if err != nil {
+        return false, fmt.Errorf("версия заказа %s: %w", uid, err)
+    }
+    return version > 1, nil
+}
+
+// keepStatuses переносит в исходный заказ статусы товаров из базы: после получения
+// сообщения они меняются через API, и это расхождением не считается.
+func keepStatuses(original, stored models.Order) models.Order {
+    statuses := make(map[string]models.ItemStatus, len(stored.Items))
+    for _, item := range stored.Items {
+        statuses[item.RID] = item.Status
+    }
+    original.Items = append([]models.Item(nil), original.Items...)
+    for i, item := range original.Items {
+        if status, ok := statuses[item.RID]; ok {
+            original.Items[i].Status = status
+        }
+    }
+    return original
+}
+
+// checkCached сравнивает запись кэша с базой. База считается источником истины для кэша.
+func (c *consistency) checkCached(ctx context.Context, uid string) error {
+    value, ok := c.opts.Cache.Get(uid)
+    if !ok {
+        // Запись вытеснена, пока шла проверка.
+        return nil
+    }
+    c.report.CacheChecked++
+
+    stored, err := c.repo.GetByUID(ctx, uid)
+    if errors.Is(err, repository.ErrNotFound) {
+        i := c.add(uid, CheckCache, "заказ есть в кэше, но не в базе")
+        if c.opts.Repair {
+            c.opts.Cache.Delete(uid)
+            c.repaired(i)
+        }
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    var found []int
+    var cached models.Order
+    if err := json.Unmarshal([]byte(value), &cached); err != nil {
+        found = append(found, c.add(uid, CheckCache, fmt.Sprintf("запись кэша не разбирается: %v", err)))
+    } else {
+        for _, d := range Diff(stored, cached) {
+            found = append(found, c.add(uid, CheckCache, d.describe("в кэше")))
+        }
+    }
+    if len(found) > 0 && c.opts.Repair {
+        data, err := json.Marshal(stored)
+        if err != nil {
+            return err
+        }
+        c.opts.Cache.Set(uid, string(data))
+        c.repaired(found...)
+    }
+    return nil
+}
+
+// FieldDiff - расхождение одного поля заказа. Path - путь по JSON-именам полей,
+// например payment.amount или items[1].price; отсутствующее значение - nil.
+type FieldDiff struct {
+    Path  string
+    Want  any
+    Other any
+}
+
+func (d FieldDiff) describe(where string) string {
+    return fmt.Sprintf("%s: в базе %s, %s %s", d.Path, formatValue(d.Want), where, formatValue(d.Other))
+}
+
+func formatValue(v any) string {
+    if v == nil {
+        return "отсутствует"
+    }
+    data, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Sprint(v)
+    }
+    return string(data)
+}
+
+// Diff сравнивает заказы по полям их JSON-представления. Даты в RFC3339 сравниваются
+// как моменты времени, поэтому разная запись часового пояса расхождением не считается.
+func Diff(want, other models.Order) []FieldDiff {
+    var diffs []FieldDiff
+    diffValues("", toJSONValue(want), toJSONValue(other), &diffs)
+    return diffs
+}
+
+func toJSONValue(order models.Order) any {
+    data, _ := json.Marshal(order)
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    var v any
+    dec.Decode(&v)
+    return v
+}
+
+func diffValues(path string, a, b any, diffs *[]FieldDiff) {
+    switch av := a.(type) {
+    case map[string]any:
+        if bv, ok := b.(map[string]any); ok {
+            keys := make([]string, 0, len(av)+len(bv))
+            for k := range av {
+                keys = append(keys, k)
+            }
+            for k := range bv {
+                if _, ok := av[k]; !ok {
+                    keys = append(keys, k)
+                }
+            }
+            sort.Strings(keys)
+            for _, k := range keys {
+                diffValues(joinPath(path, k), av[k], bv[k], diffs)
+            }
+            return
+        }
+    case []any:
+        if bv, ok := b.([]any); ok {
+            for i := 0; i < max(len(av), len(bv)); i++ {
+                var x, y any
+                if i < len(av) {
+                    x = av[i]
+                }
+                if i < len(bv) {
+                    y = bv[i]
+                }
+                diffValues(path+"["+strconv.Itoa(i)+"]", x, y, diffs)
+            }
+            return
+        }
+    case string:
+        if bv, ok := b.(string); ok && sameTime(av, bv) {
+            return
+        }
+    }
+    if !reflect.DeepEqual(a, b) {
+        *diffs = append(*diffs, FieldDiff{Path: path, Want: a, Other: b})
+    }
+}
+
+func joinPath(path, key string) string {
+    if path == "" {
+        return key
+    }
+    return path + "." + key
+}
+
+func sameTime(a, b string) bool {
+    ta, err := time.Parse(time.RFC3339Nano, a)
+    if err != nil {
+        return false
+    }
+    tb, err := time.Parse(time.RFC3339Nano, b)
+    return err == nil && ta.Equal(tb)
+}
@@ -0,0 +1,144 @@
+package verify
+
+import (
+    "context"
+    "encoding/json"
+    "strings"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/cache"
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+// mapSource - исходные сообщения в памяти.
+type mapSource map[string]models.Order
+
+func (s mapSource) Original(_ context.Context, uid string) (models.Order, error) {
+    order, ok := s[uid]
+    if !ok {
+        return models.Order{}, repository.ErrNotFound
+    }
+    return order, nil
+}
+
+// integrityRepository - хранилище в памяти с заранее заданными строками без заказа и заказами без частей.
+type integrityRepository struct {
+    *repository.MemoryRepository
+    orphans []repository.ChildRows
+    missing []repository.ChildRows
+}
+
+func (r *integrityRepository) OrphanRows(context.Context) ([]repository.ChildRows, error) {
+    return r.orphans, nil
+}
+
+func (r *integrityRepository) MissingRows(context.Context, repository.ListFilter) ([]repository.ChildRows, error) {
+    return r.missing, nil
+}
+
+func (r *integrityRepository) DeleteOrphanRows(context.Context) (int64, error) {
+    n := int64(len(r.orphans))
+    r.orphans = nil
+    return n, nil
+}
+
+func cacheJSON(t *testing.T, order models.Order) string {
+    data, err := json.Marshal(order)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
+
+func messages(report Report, check string) []string {
+    var result []string
+    for _, p := range report.Problems {
+        if p.Check == check {
+            result = append(result, p.OrderUID+" "+p.Message)
+        }
+    }
+    return result
+}
+
+func TestConsistency(t *testing.T) {
+    ctx := context.Background()
+    repo := &integrityRepository{
+        MemoryRepository: repository.NewMemory(),
+        orphans:          []repository.ChildRows{{Table: "items", OrderUID: "ghost", Rows: 2}},
+        missing:          []repository.ChildRows{{Table: "payment", OrderUID: "nopay"}},
+    }
+    for _, uid := range []string{"same", "changed", "nopay", "noarchive"} {
+        repo.Save(ctx, repotest.SampleOrder(uid))
+    }
+    // Статус, сменённый через API, расходится с исходным сообщением законно.
+    if _, err := repo.ChangeStatus(ctx, "changed", repository.StatusUpdate{Status: models.ItemDelivered}); err != nil {
+        t.Fatal(err)
+    }
+
+    same := repotest.SampleOrder("same")
+    // Другая запись часового пояса - не расхождение.
+    same.DateCreated = time.Date(2021, 11, 26, 9, 22, 19, 0, time.FixedZone("MSK", 3*60*60))
+    source := mapSource{"same": same, "nopay": repotest.SampleOrder("nopay")}
+    changed := repotest.SampleOrder("changed")
+    changed.Payment.Amount = 999
+    changed.Items = append(changed.Items, changed.Items[0])
+    source["changed"] = changed
+
+    c := cache.New(10)
+    c.Set("same", cacheJSON(t, repotest.SampleOrder("same")))
+    stale := repotest.SampleOrder("noarchive")
+    stale.Delivery.City = "Old"
+    c.Set("noarchive", cacheJSON(t, stale))
+    c.Set("deleted", cacheJSON(t, repotest.SampleOrder("deleted")))
+
+    opts := ConsistencyOptions{Cache: c, Source: source}
+    report, err := Consistency(ctx, repo, opts)
+    if err != nil {
+        t.Fatalf("Consistency: %v", err)
+    }
+    if report.Checked != 4 || report.CacheChecked != 3 || report.Repaired != 0 {
+        t.Errorf("Неожиданный итог: checked %d, cache %d, repaired %d", report.Checked, report.CacheChecked, report.Repaired)
+    }
+    if got := messages(report, CheckOrphan); len(got) != 1 || !strings.Contains(got[0], "items") {
+        t.Errorf("Строки без заказа: %v", got)
+    }
+    if got := messages(report, CheckMissing); len(got) != 1 || !strings.HasPrefix(got[0], "nopay") {
+        t.Errorf("Заказы без частей: %v", got)
+    }
+    got := messages(report, CheckSource)
+    if len(got) != 2 || !strings.Contains(got[0], "items[1]: в базе отсутствует") ||
+        !strings.Contains(got[1], "payment.amount: в базе") {
+        t.Errorf("Расхождения с исходным сообщением: %v", got)
+    }
+    got = messages(report, CheckCache)
+    if len(got) != 2 || got[0] != `noarchive delivery.city: в базе "Kiryat Mozkin", в кэше "Old"` ||
+        got[1] != "deleted заказ есть в кэше, но не в базе" {
+        t.Errorf("Расхождения с кэшем: %v", got)
+    }
+
+    opts.Repair = true
+    report, err = Consistency(ctx, repo, opts)
+    if err != nil {
+        t.Fatalf("Consistency: %v", err)
+    }
+    if report.Unrepaired() != 0 {
+        t.Errorf("Остались неисправленные нарушения: %v", report.Problems)
+    }
+    if order, _ := repo.GetByUID(ctx, "changed"); order.Payment.Amount != 999 || len(order.Items) != 2 {
+        t.Errorf("Заказ не переписан из исходного сообщения: %+v", order.Payment)
+    } else if order.Items[0].Status != models.ItemDelivered {
+        t.Errorf("Восстановление из исходного сообщения откатило статус товара: %d", order.Items[0].Status)
+    }
+    if _, ok := c.Get("deleted"); ok {
+        t.Error("Удалённый из базы заказ должен быть убран из кэша")
+    }
+
+    repo.missing = nil
+    report, err = Consistency(ctx, repo, opts)
+    if err != nil || len(report.Problems) != 0 {
+        t.Errorf("После исправления ожидалось отсутствие нарушений: %v, %v", report.Problems, err)
+    }
+}
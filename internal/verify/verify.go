@@ -0,0 +1,108 @@
+// Package verify проверяет согласованность сохранённых заказов.
+package verify
+
+import (
+    "context"
+    "fmt"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// Problem - нарушение, найденное в заказе.
+type Problem struct {
+    OrderUID string `json:"order_uid"`
+    Check    string `json:"check"`
+    Message  string `json:"message"`
+    // Repaired - нарушение исправлено в режиме восстановления.
+    Repaired bool `json:"repaired,omitempty"`
+}
+
+func (p Problem) String() string {
+    s := fmt.Sprintf("%s [%s]: %s", p.OrderUID, p.Check, p.Message)
+    if p.Repaired {
+        s += " (исправлено)"
+    }
+    return s
+}
+
+// Check - проверка одного заказа. Возвращает описания нарушений.
+type Check struct {
+    Name string
+    Fn   func(order models.Order) []string
+}
+
+// OrderChecks - проверки, которым должен соответствовать любой сохранённый заказ.
+var OrderChecks = []Check{
+    {"valid", checkValid},
+    {"items", checkItems},
+    {"totals", checkTotals},
+}
+
+// Report - итог проверки.
+type Report struct {
+    Checked  int       `json:"checked"`
+    Problems []Problem `json:"problems"`
+    // CacheChecked и Repaired заполняет только Consistency.
+    CacheChecked int `json:"cache_checked,omitempty"`
+    Repaired     int `json:"repaired,omitempty"`
+}
+
+// Unrepaired - число нарушений, оставшихся после проверки.
+func (r Report) Unrepaired() int {
+    return len(r.Problems) - r.Repaired
+}
+
+// Orders прогоняет checks по заказам repo, подходящим под filter.
+func Orders(ctx context.Context, repo repository.OrderRepository, filter repository.ListFilter, checks []Check) (Report, error) {
+    report := Report{Problems: []Problem{}}
+    err := repo.Stream(ctx, filter, func(order models.Order) error {
+        report.Checked++
+        for _, check := range checks {
+            for _, msg := range check.Fn(order) {
+                report.Problems = append(report.Problems, Problem{OrderUID: order.OrderUID, Check: check.Name, Message: msg})
+            }
+        }
+        return nil
+    })
+    return report, err
+}
+
+func checkValid(order models.Order) []string {
+    if err := order.Validate(); err != nil {
+        return []string{err.Error()}
+    }
+    return nil
+}
+
+func checkItems(order models.Order) []string {
+    if len(order.Items) == 0 {
+        return []string{"в заказе нет товаров"}
+    }
+    var problems []string
+    for _, item := range order.Items {
+        if item.TrackNumber != order.TrackNumber {
+            problems = append(problems, fmt.Sprintf("товар %s: track_number %q не совпадает с заказом %q", item.RID, item.TrackNumber, order.TrackNumber))
+        }
+        if want := item.Price * models.Amount(100-item.Sale) / 100; item.TotalPrice != want {
+            problems = append(problems, fmt.Sprintf("товар %s: total_price %d, ожидалось %d (цена %d, скидка %d%%)", item.RID, item.TotalPrice, want, item.Price, item.Sale))
+        }
+    }
+    return problems
+}
+
+func checkTotals(order models.Order) []string {
+    p := order.Payment
+    var problems []string
+    var goods models.Amount
+    for _, item := range order.Items {
+        goods += item.TotalPrice
+    }
+    if p.GoodsTotal != goods {
+        problems = append(problems, fmt.Sprintf("goods_total %d, сумма total_price товаров %d", p.GoodsTotal, goods))
+    }
+    if want := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != want {
+        problems = append(problems, fmt.Sprintf("amount %d, ожидалось goods_total + delivery_cost + custom_fee = %d", p.Amount, want))
+    }
+    return problems
+}
@@ -0,0 +1,49 @@
+package verify
+
+import (
+    "context"
+    "testing"
+
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/repository/repotest"
+)
+
+func TestOrders(t *testing.T) {
+    ctx := context.Background()
+    repo := repository.NewMemory()
+    repo.Save(ctx, repotest.SampleOrder("good"))
+
+    broken := repotest.SampleOrder("broken")
+    broken.Payment.GoodsTotal = 300
+    broken.Items[0].TrackNumber = "OTHER"
+    repo.Save(ctx, broken)
+
+    empty := repotest.SampleOrder("empty")
+    empty.Items = nil
+    empty.Payment.GoodsTotal = 0
+    empty.Payment.Amount = empty.Payment.DeliveryCost
+    repo.Save(ctx, empty)
+
+    report, err := Orders(ctx, repo, repository.ListFilter{}, OrderChecks)
+    if err != nil {
+        t.Fatalf("Orders: %v", err)
+    }
+    if report.Checked != 3 {
+        t.Errorf("Ожидалась проверка 3 заказов, получили %d", report.Checked)
+    }
+
+    got := make(map[string][]string)
+    for _, p := range report.Problems {
+        got[p.OrderUID] = append(got[p.OrderUID], p.Check)
+    }
+    if len(got["good"]) != 0 {
+        t.Errorf("У корректного заказа не должно быть нарушений: %v", got["good"])
+    }
+    // goods_total расходится с товарами и с amount, track_number товара - с заказом.
+    if want := []string{"items", "totals", "totals"}; len(got["broken"]) != len(want) {
+        t.Errorf("Ожидались нарушения %v, получили %v", want, got["broken"])
+    }
+    if want := []string{"items"}; len(got["empty"]) != 1 || got["empty"][0] != want[0] {
+        t.Errorf("Ожидались нарушения %v, получили %v", want, got["empty"])
+    }
+}
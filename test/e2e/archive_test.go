@@ -0,0 +1,50 @@
+package e2e
+
+import (
+    "bytes"
+    "io"
+    "net/http"
+    "testing"
+
+    "wb-order-hub/internal/archive"
+)
+
+func TestRawMessagesAreArchived(t *testing.T) {
+    h := newHarness(t)
+    h.options.Archive = archive.NewMemory()
+    // Ключи не настроены, поэтому /order/{id}/raw открывается так же, как в режиме -dev.
+    h.options.OpenWithoutAPIKeys = true
+    h.startService(h.repo)
+
+    invalid := orderWithUID(t, "archived")
+    invalid["date_created"] = "yesterday"
+    h.publishJSON(invalid)
+    // Исходное сообщение хранится байт в байт, с исходным форматированием.
+    original := append([]byte("  "), readModel(t)...)
+    h.publish(original)
+    h.waitForOrder("b563feb7b2b84b6test")
+
+    resp, err := httpClient.Get(h.baseURL + "/order/b563feb7b2b84b6test/raw")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    body, _ := io.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK || !bytes.Equal(body, original) {
+        t.Fatalf("Ожидалось исходное сообщение, статус %d: %q", resp.StatusCode, body)
+    }
+    if resp.Header.Get("X-Nats-Sequence") != "2" || resp.Header.Get("X-Nats-Subject") != "orders" ||
+        resp.Header.Get("X-Nats-Redelivered") != "false" || resp.Header.Get("X-Received-At") == "" {
+        t.Errorf("Неожиданные метаданные: %v", resp.Header)
+    }
+
+    // Некорректный заказ в базу не попал, но его сообщение сохранено.
+    resp, err = httpClient.Get(h.baseURL + "/order/archived/raw")
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Nats-Sequence") != "1" {
+        t.Errorf("Некорректное сообщение должно быть в архиве, статус %d", resp.StatusCode)
+    }
+}
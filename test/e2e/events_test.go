@@ -0,0 +1,62 @@
+package e2e
+
+import (
+    "encoding/json"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/events"
+)
+
+func TestEventsAppliedInVersionOrder(t *testing.T) {
+    h := newHarness(t)
+    h.options.EventsPrefix = "orders.events"
+    h.startService(h.repo)
+
+    publishEvent := func(typ events.Type, version int64, payload string) {
+        t.Helper()
+        data, _ := json.Marshal(events.Event{OrderUID: "evented", Version: version, Payload: json.RawMessage(payload)})
+        h.publishTo(events.Subject("orders.events", typ), data)
+    }
+
+    // Отмена (версия 3) приходит раньше смены адреса (версия 2), а оба события - раньше заказа.
+    publishEvent(events.OrderCancelled, 3, `{"reason": "передумал"}`)
+    publishEvent(events.DeliveryAddressChanged, 2, `{"zip": "101000", "city": "Москва", "address": "Тверская 1"}`)
+    time.Sleep(100 * time.Millisecond)
+    if status, _ := h.getOrder("evented"); status != 404 {
+        t.Fatalf("События не создают заказ, получили статус %d", status)
+    }
+
+    order := orderWithUID(t, "evented")
+    order["items"].([]any)[0].(map[string]any)["status"] = 200
+    h.publishJSON(order)
+
+    deadline := time.Now().Add(waitTimeout)
+    for {
+        got := h.waitForOrder("evented")
+        if got.Delivery.City == "Москва" && got.Status == "cancelled" {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("События не применились: город %s, статус %s", got.Delivery.City, got.Status)
+        }
+        time.Sleep(20 * time.Millisecond)
+    }
+
+    // Повторная доставка применённого события ничего не меняет.
+    publishEvent(events.DeliveryAddressChanged, 2, `{"city": "Казань", "address": "Баумана 1"}`)
+    publishEvent(events.PaymentUpdated, 4, `{"transaction": "evented", "currency": "RUB", "amount": 100}`)
+    for {
+        got := h.waitForOrder("evented")
+        if got.Payment.Amount == 100 {
+            if got.Delivery.City != "Москва" {
+                t.Errorf("Повторное событие применилось: город %s", got.Delivery.City)
+            }
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("Событие оплаты не применилось: %+v", got.Payment)
+        }
+        time.Sleep(20 * time.Millisecond)
+    }
+}
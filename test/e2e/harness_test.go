@@ -0,0 +1,225 @@
+// Package e2e содержит сквозные тесты сервиса со встроенным сервером NATS Streaming.
+//
+// По умолчанию используется хранилище в памяти. Если задан DB_HOST,
+// тесты работают с PostgreSQL, параметры которого берутся из переменных окружения DB_*.
+package e2e
+
+import (
+    "context"
+    "encoding/json"
+    "net"
+    "net/http"
+    "os"
+    "testing"
+    "time"
+
+    "github.com/nats-io/stan.go"
+    "wb-order-hub/internal/app"
+    "wb-order-hub/internal/cache"
+    "wb-order-hub/internal/config"
+    "wb-order-hub/internal/database"
+    "wb-order-hub/internal/dto"
+    "wb-order-hub/internal/repository"
+    "wb-order-hub/internal/stanserver"
+)
+
+const (
+    serviceClientID = "order-service-e2e"
+    waitTimeout     = 10 * time.Second
+)
+
+// httpClient не держит соединения открытыми, чтобы не задерживать остановку HTTP-сервера.
+var httpClient = &http.Client{
+    Timeout:   waitTimeout,
+    Transport: &http.Transport{DisableKeepAlives: true},
+}
+
+type harness struct {
+    t         *testing.T
+    server    *stanserver.Server
+    repo      repository.OrderRepository
+    publisher stan.Conn
+    baseURL   string
+    stop      func() error
+    // options передаются сервису при каждом запуске.
+    options app.Options
+}
+
+func newHarness(t *testing.T) *harness {
+    t.Helper()
+
+    server, err := stanserver.Start(stanserver.Options{ClusterID: "e2e-cluster", Port: -1})
+    if err != nil {
+        t.Fatalf("Не удалось запустить NATS Streaming: %v", err)
+    }
+    t.Cleanup(server.Shutdown)
+
+    publisher, err := stan.Connect(server.ClusterID(), "e2e-publisher", stan.NatsURL(server.URL()))
+    if err != nil {
+        t.Fatalf("Не удалось подключить издателя: %v", err)
+    }
+    t.Cleanup(func() { publisher.Close() })
+
+    return &harness{
+        t:         t,
+        server:    server,
+        repo:      newRepository(t),
+        publisher: publisher,
+        options: app.Options{
+            ShutdownTimeout: time.Second,
+            AckWait:         time.Second,
+        },
+    }
+}
+
+func newRepository(t *testing.T) repository.OrderRepository {
+    t.Helper()
+    if _, ok := os.LookupEnv("DB_HOST"); !ok {
+        return repository.NewMemory()
+    }
+
+    ctx := context.Background()
+    cfg, err := config.Load(nil)
+    if err != nil {
+        t.Fatalf("Некорректная конфигурация: %v", err)
+    }
+    pool, err := database.NewPool(ctx, database.ConfigFrom(cfg.Database))
+    if err != nil {
+        t.Fatalf("NewPool: %v", err)
+    }
+    t.Cleanup(pool.Close)
+
+    if _, err := database.Migrate(ctx, pool); err != nil {
+        t.Fatalf("Не удалось применить миграции: %v", err)
+    }
+    if _, err := pool.Exec(ctx, "TRUNCATE orders, parked_events CASCADE"); err != nil {
+        t.Fatalf("Не удалось очистить таблицы: %v", err)
+    }
+    return database.NewRepository(pool)
+}
+
+// startService запускает сервис поверх repo. Повторный запуск после stopService
+// подключается к той же durable-подписке.
+func (h *harness) startService(repo repository.OrderRepository) {
+    h.t.Helper()
+
+    sc, err := stan.Connect(h.server.ClusterID(), serviceClientID, stan.NatsURL(h.server.URL()))
+    if err != nil {
+        h.t.Fatalf("Не удалось подключить сервис к NATS Streaming: %v", err)
+    }
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        h.t.Fatalf("Не удалось открыть порт: %v", err)
+    }
+    h.baseURL = "http://" + ln.Addr().String()
+
+    service := app.New(h.options, repo, cache.New(100), sc)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error, 1)
+    go func() {
+        done <- service.Serve(ctx, ln)
+    }()
+
+    h.stop = func() error {
+        cancel()
+        err := <-done
+        sc.Close()
+        h.stop = nil
+        return err
+    }
+    h.t.Cleanup(func() {
+        if h.stop != nil {
+            if err := h.stop(); err != nil {
+                h.t.Errorf("Сервис остановился с ошибкой: %v", err)
+            }
+        }
+    })
+
+    // HTTP-сервер запускается после подписки, поэтому первый ответ означает,
+    // что сервис уже получает сообщения.
+    h.getOrder("readiness-probe")
+}
+
+// stopService останавливает сервис и возвращает ошибку корректной остановки.
+func (h *harness) stopService() error {
+    h.t.Helper()
+    return h.stop()
+}
+
+func (h *harness) publish(data []byte) {
+    h.t.Helper()
+    h.publishTo("orders", data)
+}
+
+func (h *harness) publishTo(subject string, data []byte) {
+    h.t.Helper()
+    if err := h.publisher.Publish(subject, data); err != nil {
+        h.t.Fatalf("Не удалось опубликовать сообщение в %s: %v", subject, err)
+    }
+}
+
+func (h *harness) publishJSON(v any) {
+    h.t.Helper()
+    data, err := json.Marshal(v)
+    if err != nil {
+        h.t.Fatalf("Не удалось сериализовать сообщение: %v", err)
+    }
+    h.publish(data)
+}
+
+// getOrder выполняет GET /order/{id} и возвращает код ответа и заказ, если он найден.
+func (h *harness) getOrder(uid string) (int, dto.OrderResponse) {
+    h.t.Helper()
+    var order dto.OrderResponse
+
+    resp, err := httpClient.Get(h.baseURL + "/order/" + uid)
+    if err != nil {
+        h.t.Fatalf("Запрос заказа %s не удался: %v", uid, err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode == http.StatusOK {
+        if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+            h.t.Fatalf("Некорректный ответ для заказа %s: %v", uid, err)
+        }
+    }
+    return resp.StatusCode, order
+}
+
+// waitForOrder ждёт, пока заказ станет доступен через HTTP.
+func (h *harness) waitForOrder(uid string) dto.OrderResponse {
+    h.t.Helper()
+    var lastStatus int
+    deadline := time.Now().Add(waitTimeout)
+    for time.Now().Before(deadline) {
+        status, order := h.getOrder(uid)
+        if status == http.StatusOK {
+            return order
+        }
+        lastStatus = status
+        time.Sleep(20 * time.Millisecond)
+    }
+    h.t.Fatalf("Заказ %s не появился за %s (последний статус %d)", uid, waitTimeout, lastStatus)
+    return dto.OrderResponse{}
+}
+
+func readModel(t *testing.T) []byte {
+    t.Helper()
+    data, err := os.ReadFile("../../model.json")
+    if err != nil {
+        t.Fatalf("Не удалось прочитать model.json: %v", err)
+    }
+    return data
+}
+
+func orderWithUID(t *testing.T, uid string) map[string]any {
+    t.Helper()
+    var order map[string]any
+    if err := json.Unmarshal(readModel(t), &order); err != nil {
+        t.Fatalf("model.json некорректен: %v", err)
+    }
+    order["order_uid"] = uid
+    return order
+}
@@ -0,0 +1,118 @@
+package e2e
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+func TestPublishedOrderIsServed(t *testing.T) {
+    h := newHarness(t)
+    h.startService(h.repo)
+
+    h.publish(readModel(t))
+
+    order := h.waitForOrder("b563feb7b2b84b6test")
+    if order.TrackNumber != "WBILMTESTTRACK" || len(order.Items) != 1 || order.Payment.Amount != 1817 {
+        t.Errorf("Заказ отдан с искажениями: %+v", order)
+    }
+}
+
+func TestMalformedMessagesAreSkipped(t *testing.T) {
+    h := newHarness(t)
+    h.startService(h.repo)
+
+    h.publish([]byte("not json at all"))
+    h.publish([]byte(`{"order_uid": ""}`))
+    invalidDate := orderWithUID(t, "bad-date")
+    invalidDate["date_created"] = "yesterday"
+    h.publishJSON(invalidDate)
+    h.publishJSON(orderWithUID(t, "after-garbage"))
+
+    h.waitForOrder("after-garbage")
+    if status, _ := h.getOrder("bad-date"); status != http.StatusNotFound {
+        t.Errorf("Заказ с некорректной датой не должен сохраняться, статус %d", status)
+    }
+}
+
+func TestDuplicateMessagesAreIdempotent(t *testing.T) {
+    h := newHarness(t)
+    h.startService(h.repo)
+
+    h.publish(readModel(t))
+    h.publish(readModel(t))
+    marker := orderWithUID(t, "marker")
+    h.publishJSON(marker)
+
+    // Сообщения одного канала обрабатываются по порядку, поэтому после marker оба дубликата уже сохранены.
+    h.waitForOrder("marker")
+
+    orders, err := h.repo.List(context.Background(), repository.ListFilter{})
+    if err != nil {
+        t.Fatalf("List: %v", err)
+    }
+    if len(orders) != 2 {
+        t.Fatalf("Ожидалось 2 заказа, получили %d", len(orders))
+    }
+    order := h.waitForOrder("b563feb7b2b84b6test")
+    if len(order.Items) != 1 {
+        t.Errorf("Дубликат не должен размножать товары, товаров: %d", len(order.Items))
+    }
+}
+
+// flakyRepository отклоняет первые failures сохранений.
+type flakyRepository struct {
+    repository.OrderRepository
+    failures int32
+    saves    atomic.Int32
+}
+
+func (r *flakyRepository) Save(ctx context.Context, order models.Order) error {
+    if r.saves.Add(1) <= r.failures {
+        return errors.New("база данных временно недоступна")
+    }
+    return r.OrderRepository.Save(ctx, order)
+}
+
+func TestFailedSaveIsRedelivered(t *testing.T) {
+    h := newHarness(t)
+    repo := &flakyRepository{OrderRepository: h.repo, failures: 1}
+    h.startService(repo)
+
+    start := time.Now()
+    h.publish(readModel(t))
+    h.waitForOrder("b563feb7b2b84b6test")
+
+    if saves := repo.saves.Load(); saves < 2 {
+        t.Errorf("Ожидалась повторная доставка после ошибки, попыток сохранения: %d", saves)
+    }
+    if elapsed := time.Since(start); elapsed < time.Second {
+        t.Errorf("Повторная доставка должна случиться не раньше AckWait, прошло %s", elapsed)
+    }
+}
+
+func TestRestartResumesDurableSubscription(t *testing.T) {
+    h := newHarness(t)
+    h.startService(h.repo)
+
+    h.publishJSON(orderWithUID(t, "before-restart"))
+    h.waitForOrder("before-restart")
+    if err := h.stopService(); err != nil {
+        t.Fatalf("Сервис остановился с ошибкой: %v", err)
+    }
+
+    // Пока сервис остановлен, сообщения копятся в канале.
+    h.publishJSON(orderWithUID(t, "while-down-1"))
+    h.publishJSON(orderWithUID(t, "while-down-2"))
+
+    h.startService(h.repo)
+    h.waitForOrder("while-down-1")
+    h.waitForOrder("while-down-2")
+    h.waitForOrder("before-restart")
+}
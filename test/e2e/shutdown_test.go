@@ -0,0 +1,126 @@
+package e2e
+
+import (
+    "context"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "wb-order-hub/internal/models"
+    "wb-order-hub/internal/repository"
+)
+
+// blockingRepository задерживает сохранение до закрытия release или отмены контекста.
+type blockingRepository struct {
+    repository.OrderRepository
+    started chan struct{}
+    release chan struct{}
+}
+
+func newBlockingRepository(repo repository.OrderRepository) *blockingRepository {
+    return &blockingRepository{
+        OrderRepository: repo,
+        started:         make(chan struct{}, 1),
+        release:         make(chan struct{}),
+    }
+}
+
+func (r *blockingRepository) Save(ctx context.Context, order models.Order) error {
+    select {
+    case r.started <- struct{}{}:
+    default:
+    }
+    select {
+    case <-r.release:
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+    return r.OrderRepository.Save(ctx, order)
+}
+
+// countingRepository считает вызовы Save.
+type countingRepository struct {
+    repository.OrderRepository
+    saves atomic.Int32
+}
+
+func (r *countingRepository) Save(ctx context.Context, order models.Order) error {
+    r.saves.Add(1)
+    return r.OrderRepository.Save(ctx, order)
+}
+
+func waitStarted(t *testing.T, repo *blockingRepository) {
+    t.Helper()
+    select {
+    case <-repo.started:
+    case <-time.After(waitTimeout):
+        t.Fatal("Обработка сообщения не началась")
+    }
+}
+
+func TestShutdownWaitsForInFlightMessage(t *testing.T) {
+    h := newHarness(t)
+    h.options.ShutdownTimeout = 5 * time.Second
+    blocking := newBlockingRepository(h.repo)
+    h.startService(blocking)
+
+    h.publish(readModel(t))
+    waitStarted(t, blocking)
+
+    stopped := make(chan error, 1)
+    go func() {
+        stopped <- h.stopService()
+    }()
+
+    select {
+    case err := <-stopped:
+        t.Fatalf("Сервис остановился, не дождавшись обработчика: %v", err)
+    case <-time.After(200 * time.Millisecond):
+    }
+
+    close(blocking.release)
+    select {
+    case err := <-stopped:
+        if err != nil {
+            t.Fatalf("Сервис остановился с ошибкой: %v", err)
+        }
+    case <-time.After(waitTimeout):
+        t.Fatal("Сервис не остановился после завершения обработчика")
+    }
+
+    if _, err := h.repo.GetByUID(context.Background(), "b563feb7b2b84b6test"); err != nil {
+        t.Fatalf("Заказ, обрабатывавшийся при остановке, должен быть сохранён: %v", err)
+    }
+
+    // Сообщение было подтверждено, поэтому после перезапуска повторной доставки нет.
+    counting := &countingRepository{OrderRepository: h.repo}
+    h.startService(counting)
+    time.Sleep(h.options.AckWait + 500*time.Millisecond)
+    if saves := counting.saves.Load(); saves != 0 {
+        t.Errorf("Подтверждённое сообщение доставлено повторно %d раз", saves)
+    }
+}
+
+func TestShutdownDeadlineLeavesMessageForRedelivery(t *testing.T) {
+    h := newHarness(t)
+    h.options.ShutdownTimeout = 300 * time.Millisecond
+    blocking := newBlockingRepository(h.repo)
+    h.startService(blocking)
+
+    h.publish(readModel(t))
+    waitStarted(t, blocking)
+
+    start := time.Now()
+    if err := h.stopService(); err == nil {
+        t.Error("Ожидалась ошибка о прерванной обработке")
+    }
+    if elapsed := time.Since(start); elapsed > 3*time.Second {
+        t.Errorf("Остановка должна уложиться в срок, заняла %s", elapsed)
+    }
+    if _, err := h.repo.GetByUID(context.Background(), "b563feb7b2b84b6test"); err == nil {
+        t.Fatal("Прерванное сохранение не должно попасть в хранилище")
+    }
+
+    h.startService(h.repo)
+    h.waitForOrder("b563feb7b2b84b6test")
+}
@@ -0,0 +1,7 @@
+// Package web содержит статику веб-интерфейса, встроенную в исполняемый файл.
+package web
+
+import "embed"
+
+//go:embed *.html
+var Files embed.FS